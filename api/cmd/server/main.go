@@ -1,18 +1,24 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	_ "encoding/xml"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	_ "strconv"
 	"strings"
-	_ "time"
+	"syscall"
+	"time"
 
 	_ "github.com/BennyEisner/test-results/docs"
+	"github.com/BennyEisner/test-results/internal/attachment/domain/ports"
+	"github.com/BennyEisner/test-results/internal/attachment/infrastructure/blob"
 	"github.com/BennyEisner/test-results/internal/shared/container"
 	"github.com/gorilla/sessions"
 	_ "github.com/lib/pq"
@@ -24,16 +30,26 @@ import (
 
 // Config holds the application configuration
 type Config struct {
-	DBHost         string
-	DBPort         int
-	DBUser         string
-	DBPassword     string
-	DBName         string
-	ServerAddr     string
-	FrontendURL    string
-	GithubClientID string
-	GithubSecret   string
-	SessionSecret  string
+	DBHost           string
+	DBPort           int
+	DBUser           string
+	DBPassword       string
+	DBName           string
+	ServerAddr       string
+	FrontendURL      string
+	GithubClientID   string
+	GithubSecret     string
+	SessionSecret    string
+	ImportConfig     container.ImportConfig
+	AttachmentConfig container.AttachmentConfig
+	BlobStore        BlobStoreConfig
+}
+
+// BlobStoreConfig selects where the content of attachments is kept
+type BlobStoreConfig struct {
+	Kind string // "local" (default) or "s3"
+	Dir  string // directory of the local store
+	S3   blob.S3Config
 }
 
 // loadConfig loads configuration from environment variables
@@ -47,12 +63,40 @@ func loadConfig() *Config {
 	githubClientID := os.Getenv("GITHUB_CLIENT_ID")
 	githubSecret := os.Getenv("GITHUB_CLIENT_SECRET")
 	sessionSecret := os.Getenv("SESSION_SECRET")
+	importMemoryLimitMB := os.Getenv("IMPORT_MEMORY_LIMIT_MB")
+	importWorkers := os.Getenv("IMPORT_WORKERS")
+	importQueueSize := os.Getenv("IMPORT_QUEUE_SIZE")
+	importJobTimeoutMinutes := os.Getenv("IMPORT_JOB_TIMEOUT_MINUTES")
+	logMaxSizeKB := os.Getenv("LOG_MAX_SIZE_KB")
+	attachmentQuotaMB := os.Getenv("ATTACHMENT_QUOTA_MB")
+	attachmentMaxSizeMB := os.Getenv("ATTACHMENT_MAX_SIZE_MB")
+	attachmentDir := os.Getenv("ATTACHMENT_DIR")
 
 	portInt, err := strconv.Atoi(dbPort)
 	if err != nil {
 		portInt = 5432 // Default if parsing fails
 	}
 
+	importMemoryLimit, err := strconv.ParseInt(importMemoryLimitMB, 10, 64)
+	if err != nil {
+		importMemoryLimit = 0 // Use the default import memory limit
+	}
+
+	// Unset or invalid sizes select the default worker pool
+	workers, _ := strconv.Atoi(importWorkers)
+	queueSize, _ := strconv.Atoi(importQueueSize)
+	jobTimeout, _ := strconv.Atoi(importJobTimeoutMinutes)
+
+	// An unset or invalid size selects the default log size of an execution
+	logMaxSize, _ := strconv.ParseInt(logMaxSizeKB, 10, 64)
+
+	// Unset or invalid sizes select the default attachment limits
+	attachmentQuota, _ := strconv.ParseInt(attachmentQuotaMB, 10, 64)
+	attachmentMaxSize, _ := strconv.ParseInt(attachmentMaxSizeMB, 10, 64)
+	if attachmentDir == "" {
+		attachmentDir = "attachments"
+	}
+
 	return &Config{
 		DBHost:         dbHost,
 		DBPort:         portInt,
@@ -64,6 +108,40 @@ func loadConfig() *Config {
 		GithubClientID: githubClientID,
 		GithubSecret:   githubSecret,
 		SessionSecret:  sessionSecret,
+		ImportConfig: container.ImportConfig{
+			MemoryLimit: importMemoryLimit << 20,
+			Workers:     workers,
+			QueueSize:   queueSize,
+			MaxLogSize:  logMaxSize << 10,
+			JobTimeout:  time.Duration(jobTimeout) * time.Minute,
+		},
+		AttachmentConfig: container.AttachmentConfig{
+			Quota:   attachmentQuota << 20,
+			MaxSize: attachmentMaxSize << 20,
+		},
+		BlobStore: BlobStoreConfig{
+			Kind: os.Getenv("ATTACHMENT_STORE"),
+			Dir:  attachmentDir,
+			S3: blob.S3Config{
+				Endpoint:        os.Getenv("S3_ENDPOINT"),
+				Bucket:          os.Getenv("S3_BUCKET"),
+				Region:          os.Getenv("S3_REGION"),
+				AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			},
+		},
+	}
+}
+
+// createBlobStore creates the blob store attachments are kept in
+func createBlobStore(config BlobStoreConfig) (ports.BlobStore, error) {
+	switch config.Kind {
+	case "", "local":
+		return blob.NewLocalStore(config.Dir)
+	case "s3":
+		return blob.NewS3Store(config.S3, nil)
+	default:
+		return nil, fmt.Errorf("unknown ATTACHMENT_STORE %q, expected local or s3", config.Kind)
 	}
 }
 
@@ -88,17 +166,52 @@ func connectDB(config *Config) (*sql.DB, error) {
 	return db, nil
 }
 
-// createServer creates and configures the HTTP server
-func createServer(db *sql.DB, frontendURL string) http.Handler {
+// shutdownTimeout bounds the time the server takes to finish the requests and imports
+// in progress once it is asked to stop
+const shutdownTimeout = 30 * time.Second
+
+// createServer creates and configures the HTTP server, and the func stopping its
+// background work
+func createServer(db *sql.DB, config *Config) (http.Handler, func(ctx context.Context) error) {
 	// Use the new hexagonal architecture router
-	return container.NewRouter(db, frontendURL)
+	return container.NewRouter(db, config.FrontendURL, config.ImportConfig, config.AttachmentConfig)
 }
 
-// runServer starts the HTTP server
-func runServer(addr string, handler http.Handler) error {
-	log.Printf("Starting server on %s", addr)
-	err := http.ListenAndServe(addr, handler)
-	log.Printf("ListenAndServe returned: %v", err)
+// runServer starts the HTTP server and serves until an interrupt or termination signal,
+// then stops accepting requests and waits up to shutdownTimeout for the requests and
+// background work in progress before returning
+func runServer(addr string, handler http.Handler, shutdown func(ctx context.Context) error) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on %s", addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+		log.Printf("ListenAndServe returned: %v", err)
+	case <-ctx.Done():
+		log.Printf("Shutting down server")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil {
+		log.Printf("Server shutdown failed: %v", shutdownErr)
+	}
+	if shutdownErr := shutdown(shutdownCtx); shutdownErr != nil {
+		log.Printf("Stopping background work failed: %v", shutdownErr)
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
 	return err
 }
 
@@ -136,14 +249,20 @@ func run() error {
 
 	initGoth(config)
 
+	store, err := createBlobStore(config.BlobStore)
+	if err != nil {
+		return fmt.Errorf("attachment store setup failed: %w", err)
+	}
+	config.AttachmentConfig.Store = store
+
 	db, err := connectDB(config)
 	if err != nil {
 		return fmt.Errorf("database connection failed: %w", err)
 	}
 	defer db.Close()
 
-	server := createServer(db, config.FrontendURL)
-	return runServer(config.ServerAddr, server)
+	server, shutdown := createServer(db, config)
+	return runServer(config.ServerAddr, server, shutdown)
 }
 
 func main() {
@@ -0,0 +1,292 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/BennyEisner/test-results/internal/attachment/domain/errors"
+	"github.com/BennyEisner/test-results/internal/attachment/domain/models"
+	"github.com/BennyEisner/test-results/internal/attachment/domain/ports"
+)
+
+// maxNameLength bounds the file name of an attachment
+const maxNameLength = 255
+
+// AttachmentService implements the AttachmentService interface
+type AttachmentService struct {
+	repo   ports.AttachmentRepository
+	store  ports.BlobStore
+	limits models.Limits
+}
+
+func NewAttachmentService(repo ports.AttachmentRepository, store ports.BlobStore, limits models.Limits) ports.AttachmentService {
+	return &AttachmentService{repo: repo, store: store, limits: limits}
+}
+
+// UploadAttachment stores the content read from r in the blob store and records it as an
+// attachment of the execution. The quota of the project is checked before the content is
+// stored, when its size is known, and again when the attachment is recorded.
+func (s *AttachmentService) UploadAttachment(ctx context.Context, executionID int64, name, contentType string, r io.Reader, size int64) (*models.Attachment, error) {
+	if executionID <= 0 {
+		return nil, fmt.Errorf("invalid execution ID")
+	}
+
+	projectID, err := s.repo.GetExecutionProjectID(ctx, executionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up execution %d: %w", executionID, err)
+	}
+	if projectID == 0 {
+		return nil, errors.ErrExecutionNotFound
+	}
+	return s.save(ctx, executionID, projectID, name, contentType, r, size)
+}
+
+// AttachToTestCase stores a file referenced by an imported report with the execution
+// of the test case it was reported for
+func (s *AttachmentService) AttachToTestCase(ctx context.Context, buildID int64, classname, testName, fileName string, r io.Reader, size int64) error {
+	executionID, err := s.repo.FindExecutionID(ctx, buildID, classname, testName)
+	if err != nil {
+		return fmt.Errorf("failed to look up execution of %s.%s: %w", classname, testName, err)
+	}
+	if executionID == 0 {
+		return errors.ErrExecutionNotFound
+	}
+	_, err = s.UploadAttachment(ctx, executionID, fileName, "", r, size)
+	return err
+}
+
+// save checks the size of an attachment against the limits of its project, stores its
+// content and records it; content whose attachment cannot be recorded is deleted again
+func (s *AttachmentService) save(ctx context.Context, executionID, projectID int64, name, contentType string, r io.Reader, size int64) (*models.Attachment, error) {
+	name, err := s.checkAttachment(name, size)
+	if err != nil {
+		return nil, err
+	}
+	quota, err := s.quotaOf(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		if err := s.checkQuota(ctx, projectID, quota, size); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := storageKey(projectID, executionID)
+	if err != nil {
+		return nil, err
+	}
+	written, err := s.putContent(ctx, key, name, r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &models.Attachment{
+		ExecutionID: executionID,
+		ProjectID:   projectID,
+		Name:        name,
+		ContentType: attachmentContentType(name, contentType),
+		Size:        written,
+		StorageKey:  key,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.repo.Create(ctx, attachment, quota); err != nil {
+		_ = s.store.Delete(ctx, key)
+		return nil, fmt.Errorf("failed to create attachment: %w", err)
+	}
+	return attachment, nil
+}
+
+// checkAttachment returns the base name of an attachment, rejecting missing names and
+// attachments known to be too large
+func (s *AttachmentService) checkAttachment(name string, size int64) (string, error) {
+	name = path.Base(strings.ReplaceAll(strings.TrimSpace(name), "\\", "/"))
+	if name == "." || name == "/" || len(name) > maxNameLength {
+		return "", fmt.Errorf("%w: file name is required and at most %d characters", errors.ErrInvalidAttachment, maxNameLength)
+	}
+	if size > s.limits.MaxSize {
+		return "", fmt.Errorf("%w: %s is larger than %d bytes", errors.ErrAttachmentTooLarge, name, s.limits.MaxSize)
+	}
+	return name, nil
+}
+
+// putContent stores the content of an attachment, returning its size. Content larger
+// than the size limit is not kept.
+func (s *AttachmentService) putContent(ctx context.Context, key, name string, r io.Reader, size int64) (int64, error) {
+	counter := &sizeLimitReader{r: r, remaining: s.limits.MaxSize}
+	if err := s.store.Put(ctx, key, counter, size); err != nil {
+		_ = s.store.Delete(ctx, key)
+		if counter.exceeded {
+			return 0, fmt.Errorf("%w: %s is larger than %d bytes", errors.ErrAttachmentTooLarge, name, s.limits.MaxSize)
+		}
+		return 0, fmt.Errorf("failed to store attachment: %w", err)
+	}
+	return counter.read, nil
+}
+
+// checkQuota fails with ErrQuotaExceeded when size more bytes would exceed the quota of a project
+func (s *AttachmentService) checkQuota(ctx context.Context, projectID, quota, size int64) error {
+	used, err := s.repo.GetUsage(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to get attachment usage of project %d: %w", projectID, err)
+	}
+	if used+size > quota {
+		return fmt.Errorf("%w: %d of %d bytes used", errors.ErrQuotaExceeded, used, quota)
+	}
+	return nil
+}
+
+// quotaOf returns the quota configured for a project, or the default quota
+func (s *AttachmentService) quotaOf(ctx context.Context, projectID int64) (int64, error) {
+	quota, err := s.repo.GetQuota(ctx, projectID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get attachment quota of project %d: %w", projectID, err)
+	}
+	if quota == nil {
+		return s.limits.Quota, nil
+	}
+	return *quota, nil
+}
+
+func (s *AttachmentService) GetAttachment(ctx context.Context, id int64) (*models.Attachment, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid attachment ID")
+	}
+
+	attachment, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment by ID %d: %w", id, err)
+	}
+	if attachment == nil {
+		return nil, errors.ErrAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+func (s *AttachmentService) GetAttachmentsByExecution(ctx context.Context, executionID int64) ([]*models.Attachment, error) {
+	if executionID <= 0 {
+		return nil, fmt.Errorf("invalid execution ID")
+	}
+
+	attachments, err := s.repo.GetByExecutionID(ctx, executionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments by execution ID %d: %w", executionID, err)
+	}
+	return attachments, nil
+}
+
+func (s *AttachmentService) OpenAttachment(ctx context.Context, id int64) (*models.Attachment, io.ReadCloser, error) {
+	attachment, err := s.GetAttachment(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := s.store.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open attachment %d: %w", id, err)
+	}
+	return attachment, content, nil
+}
+
+// DeleteAttachment removes an attachment and then its content from the blob store
+func (s *AttachmentService) DeleteAttachment(ctx context.Context, id int64) error {
+	attachment, err := s.GetAttachment(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	if err := s.store.Delete(ctx, attachment.StorageKey); err != nil {
+		return fmt.Errorf("failed to delete attachment content: %w", err)
+	}
+	return nil
+}
+
+func (s *AttachmentService) GetQuota(ctx context.Context, projectID int64) (*models.Quota, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	configured, err := s.repo.GetQuota(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment quota of project %d: %w", projectID, err)
+	}
+	used, err := s.repo.GetUsage(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment usage of project %d: %w", projectID, err)
+	}
+
+	quota := &models.Quota{ProjectID: projectID, QuotaBytes: s.limits.Quota, UsedBytes: used, Default: configured == nil}
+	if configured != nil {
+		quota.QuotaBytes = *configured
+	}
+	return quota, nil
+}
+
+// SetQuota configures the attachment quota of a project. Lowering it below the usage
+// of the project only rejects further attachments.
+func (s *AttachmentService) SetQuota(ctx context.Context, projectID, quotaBytes int64) (*models.Quota, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+	if quotaBytes < 0 {
+		return nil, fmt.Errorf("%w: quota_bytes must not be negative", errors.ErrInvalidAttachment)
+	}
+
+	if err := s.repo.SetQuota(ctx, projectID, quotaBytes); err != nil {
+		return nil, fmt.Errorf("failed to set attachment quota of project %d: %w", projectID, err)
+	}
+	return s.GetQuota(ctx, projectID)
+}
+
+// storageKey returns a new blob store key for an attachment of an execution
+func storageKey(projectID, executionID int64) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate storage key: %w", err)
+	}
+	return fmt.Sprintf("projects/%d/executions/%d/%s", projectID, executionID, hex.EncodeToString(random)), nil
+}
+
+// attachmentContentType returns the content type sent with an attachment, or else the
+// one of its file extension
+func attachmentContentType(name, contentType string) string {
+	if contentType != "" && contentType != "application/octet-stream" {
+		return contentType
+	}
+	if byExtension := mime.TypeByExtension(path.Ext(name)); byExtension != "" {
+		return byExtension
+	}
+	return "application/octet-stream"
+}
+
+// sizeLimitReader counts the bytes read and fails once more than remaining bytes were read
+type sizeLimitReader struct {
+	r         io.Reader
+	remaining int64
+	read      int64
+	exceeded  bool
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		l.exceeded = true
+		return n, errors.ErrAttachmentTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,12 @@
+package errors
+
+import "errors"
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrExecutionNotFound  = errors.New("execution not found")
+	ErrInvalidAttachment  = errors.New("invalid attachment")
+	ErrAttachmentTooLarge = errors.New("attachment is too large")
+	ErrQuotaExceeded      = errors.New("attachment quota of the project exceeded")
+	ErrBlobNotFound       = errors.New("blob not found")
+)
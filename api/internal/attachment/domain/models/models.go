@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Attachment is a file, such as a screenshot or a log, stored with an execution
+type Attachment struct {
+	ID          int64     `json:"id"`
+	ExecutionID int64     `json:"execution_id"`
+	ProjectID   int64     `json:"project_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Quota is the total size of attachments a project may store and the size it stores
+type Quota struct {
+	ProjectID  int64 `json:"project_id"`
+	QuotaBytes int64 `json:"quota_bytes"`
+	UsedBytes  int64 `json:"used_bytes"`
+	// Default is set when the project uses the configured default quota
+	Default bool `json:"default"`
+}
+
+// DefaultQuota is the attachment quota of a project when none is configured
+const DefaultQuota = 1 << 30
+
+// DefaultMaxSize is the size limit of a single attachment when none is configured
+const DefaultMaxSize = 50 << 20
+
+// Limits bounds the attachments of a project
+type Limits struct {
+	Quota   int64 // default total size of the attachments of a project
+	MaxSize int64 // size of a single attachment
+}
+
+// NewLimits returns the limits for a quota and a size limit in bytes; zero or less
+// selects DefaultQuota and DefaultMaxSize
+func NewLimits(quota, maxSize int64) Limits {
+	if quota <= 0 {
+		quota = DefaultQuota
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	return Limits{Quota: quota, MaxSize: maxSize}
+}
@@ -0,0 +1,52 @@
+package ports
+
+import (
+	"context"
+	"io"
+
+	"github.com/BennyEisner/test-results/internal/attachment/domain/models"
+)
+
+// BlobStore stores the content of attachments under keys of slash-separated segments
+type BlobStore interface {
+	// Put stores size bytes read from r under key; a size below zero means unknown
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens the content stored under key, failing with ErrBlobNotFound when there is none
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the content stored under key; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// AttachmentRepository defines the interface for attachment data access
+type AttachmentRepository interface {
+	// GetExecutionProjectID returns the project of an execution, or 0 when it does not exist
+	GetExecutionProjectID(ctx context.Context, executionID int64) (int64, error)
+	// FindExecutionID returns the execution of the test case of a build with the classname
+	// and name, or 0 when there is none
+	FindExecutionID(ctx context.Context, buildID int64, classname, name string) (int64, error)
+	GetByID(ctx context.Context, id int64) (*models.Attachment, error)
+	GetByExecutionID(ctx context.Context, executionID int64) ([]*models.Attachment, error)
+	// Create stores an attachment unless the attachments of its project would then exceed
+	// quota bytes, in which case it fails with ErrQuotaExceeded
+	Create(ctx context.Context, attachment *models.Attachment, quota int64) error
+	Delete(ctx context.Context, id int64) error
+	// GetUsage returns the total size of the attachments of a project
+	GetUsage(ctx context.Context, projectID int64) (int64, error)
+	// GetQuota returns the quota configured for a project, or nil when it uses the default
+	GetQuota(ctx context.Context, projectID int64) (*int64, error)
+	SetQuota(ctx context.Context, projectID, quotaBytes int64) error
+}
+
+// AttachmentService defines the interface for attachment business logic
+type AttachmentService interface {
+	UploadAttachment(ctx context.Context, executionID int64, name, contentType string, r io.Reader, size int64) (*models.Attachment, error)
+	// AttachToTestCase stores a file as an attachment of the execution of a test case of a build
+	AttachToTestCase(ctx context.Context, buildID int64, classname, testName, fileName string, r io.Reader, size int64) error
+	GetAttachment(ctx context.Context, id int64) (*models.Attachment, error)
+	GetAttachmentsByExecution(ctx context.Context, executionID int64) ([]*models.Attachment, error)
+	// OpenAttachment returns an attachment with its content, which the caller closes
+	OpenAttachment(ctx context.Context, id int64) (*models.Attachment, io.ReadCloser, error)
+	DeleteAttachment(ctx context.Context, id int64) error
+	GetQuota(ctx context.Context, projectID int64) (*models.Quota, error)
+	SetQuota(ctx context.Context, projectID, quotaBytes int64) (*models.Quota, error)
+}
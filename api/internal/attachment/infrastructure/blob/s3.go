@@ -0,0 +1,232 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BennyEisner/test-results/internal/attachment/domain/errors"
+	"github.com/BennyEisner/test-results/internal/attachment/domain/ports"
+)
+
+// unsignedPayload is the payload hash of a request whose body is not signed
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Config holds the settings of an S3-compatible object store
+type S3Config struct {
+	Endpoint        string // base URL of the store, e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000
+	Bucket          string
+	Region          string // defaults to us-east-1
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3Store keeps blobs as objects of a bucket of an S3-compatible object store, such as
+// AWS S3 or MinIO. Requests address the bucket by path and are signed with AWS
+// Signature Version 4.
+type S3Store struct {
+	config S3Config
+	base   *url.URL
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3Store creates a blob store for the bucket of an S3-compatible object store.
+// A nil client selects http.DefaultClient.
+func NewS3Store(config S3Config, client *http.Client) (ports.BlobStore, error) {
+	base, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3Store{config: config, base: base, client: client, now: time.Now}, nil
+}
+
+// Put uploads the content as the object of a key. The store needs the length of the
+// content up front, so content of unknown size is spooled to a temporary file first.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if size < 0 {
+		spool, spooledSize, err := spoolContent(r)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		r, size = spool, spooledSize
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, key, io.LimitReader(r, size), size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError("put", key, resp)
+	}
+	return nil
+}
+
+// Get downloads the object of a key
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", errors.ErrBlobNotFound, key)
+	default:
+		defer resp.Body.Close()
+		return nil, responseError("get", key, resp)
+	}
+}
+
+// Delete removes the object of a key
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return responseError("delete", key, resp)
+	}
+	return nil
+}
+
+// do sends a signed request for the object of a key
+func (s *S3Store) do(ctx context.Context, method, key string, body io.Reader, size int64) (*http.Response, error) {
+	if !validKey(key) {
+		return nil, fmt.Errorf("invalid blob key %q", key)
+	}
+	if body != nil && size == 0 {
+		body = http.NoBody
+	}
+	target := *s.base
+	target.Path = s.base.Path + "/" + s.config.Bucket + "/" + key
+	target.RawPath = s.base.Path + "/" + escapePath(s.config.Bucket+"/"+key)
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request: %w", err)
+	}
+	if body != nil && body != http.NoBody {
+		req.ContentLength = size
+	}
+	s.sign(req, s.now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 %s of %s failed: %w", strings.ToLower(method), key, err)
+	}
+	return resp, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to a request. The payload is left
+// unsigned, which keeps uploads streaming.
+func (s *S3Store) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + unsignedPayload + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// escapePath escapes the segments of an object path the way Signature Version 4 expects:
+// everything but unreserved characters is percent-encoded
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		var escaped strings.Builder
+		for _, b := range []byte(segment) {
+			if isUnreserved(b) {
+				escaped.WriteByte(b)
+			} else {
+				fmt.Fprintf(&escaped, "%%%02X", b)
+			}
+		}
+		segments[i] = escaped.String()
+	}
+	return strings.Join(segments, "/")
+}
+
+func isUnreserved(b byte) bool {
+	return b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' ||
+		b == '-' || b == '_' || b == '.' || b == '~'
+}
+
+func hashHex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// spoolContent copies content of unknown size to a temporary file, rewound for reading
+func spoolContent(r io.Reader) (*os.File, int64, error) {
+	spool, err := os.CreateTemp("", "test-results-blob-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to spool blob: %w", err)
+	}
+	size, err := io.Copy(spool, r)
+	if err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, 0, fmt.Errorf("failed to spool blob: %w", err)
+	}
+	return spool, size, nil
+}
+
+// responseError describes an unexpected response of the store, including the start of
+// the error document it sent
+func responseError(operation, key string, resp *http.Response) error {
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("S3 %s of %s failed with status %d: %s", operation, key, resp.StatusCode, strings.TrimSpace(string(detail)))
+}
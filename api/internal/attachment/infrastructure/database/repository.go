@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/BennyEisner/test-results/internal/attachment/domain/errors"
+	"github.com/BennyEisner/test-results/internal/attachment/domain/models"
+	"github.com/BennyEisner/test-results/internal/attachment/domain/ports"
+)
+
+const attachmentColumns = `id, execution_id, project_id, name, content_type, size, storage_key, created_at`
+
+// SQLAttachmentRepository implements the AttachmentRepository interface
+type SQLAttachmentRepository struct {
+	db *sql.DB
+}
+
+// NewSQLAttachmentRepository creates a new SQL attachment repository
+func NewSQLAttachmentRepository(db *sql.DB) ports.AttachmentRepository {
+	return &SQLAttachmentRepository{db: db}
+}
+
+// GetExecutionProjectID returns the project of an execution through its build and suite
+func (r *SQLAttachmentRepository) GetExecutionProjectID(ctx context.Context, executionID int64) (int64, error) {
+	query := `
+		SELECT ts.project_id
+		FROM build_test_case_executions btce
+		JOIN builds b ON b.id = btce.build_id
+		JOIN test_suites ts ON ts.id = b.test_suite_id
+		WHERE btce.id = $1`
+
+	var projectID int64
+	err := r.db.QueryRowContext(ctx, query, executionID).Scan(&projectID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get project of execution: %w", err)
+	}
+	return projectID, nil
+}
+
+// FindExecutionID returns the last attempt of the execution of a build whose test case has
+// the classname and name
+func (r *SQLAttachmentRepository) FindExecutionID(ctx context.Context, buildID int64, classname, name string) (int64, error) {
+	query := `
+		SELECT btce.id
+		FROM build_test_case_executions btce
+		JOIN test_cases tc ON tc.id = btce.test_case_id
+		WHERE btce.build_id = $1 AND NOT btce.retried AND tc.classname = $2 AND tc.name = $3
+		ORDER BY btce.id
+		LIMIT 1`
+
+	var executionID int64
+	err := r.db.QueryRowContext(ctx, query, buildID, classname, name).Scan(&executionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to find execution: %w", err)
+	}
+	return executionID, nil
+}
+
+// GetByID retrieves an attachment by its ID
+func (r *SQLAttachmentRepository) GetByID(ctx context.Context, id int64) (*models.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id = $1`
+
+	var attachment models.Attachment
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&attachment.ID, &attachment.ExecutionID, &attachment.ProjectID, &attachment.Name,
+		&attachment.ContentType, &attachment.Size, &attachment.StorageKey, &attachment.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get attachment by ID: %w", err)
+	}
+
+	return &attachment, nil
+}
+
+// GetByExecutionID retrieves the attachments of an execution in the order they were added
+func (r *SQLAttachmentRepository) GetByExecutionID(ctx context.Context, executionID int64) ([]*models.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE execution_id = $1 ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, executionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments by execution ID: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []*models.Attachment{}
+	for rows.Next() {
+		var attachment models.Attachment
+		if err := rows.Scan(
+			&attachment.ID, &attachment.ExecutionID, &attachment.ProjectID, &attachment.Name,
+			&attachment.ContentType, &attachment.Size, &attachment.StorageKey, &attachment.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, &attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read attachments: %w", err)
+	}
+
+	return attachments, nil
+}
+
+// Create stores an attachment. The project row is locked while its usage is summed, so
+// that concurrent uploads cannot together exceed the quota.
+func (r *SQLAttachmentRepository) Create(ctx context.Context, attachment *models.Attachment, quota int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM projects WHERE id = $1 FOR UPDATE`, attachment.ProjectID); err != nil {
+		return fmt.Errorf("failed to lock project: %w", err)
+	}
+
+	var used int64
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(size), 0) FROM attachments WHERE project_id = $1`, attachment.ProjectID).Scan(&used)
+	if err != nil {
+		return fmt.Errorf("failed to get attachment usage: %w", err)
+	}
+	if used+attachment.Size > quota {
+		return fmt.Errorf("%w: %d of %d bytes used", errors.ErrQuotaExceeded, used, quota)
+	}
+
+	query := `
+		INSERT INTO attachments (execution_id, project_id, name, content_type, size, storage_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err = tx.QueryRowContext(ctx, query,
+		attachment.ExecutionID, attachment.ProjectID, attachment.Name, attachment.ContentType,
+		attachment.Size, attachment.StorageKey, attachment.CreatedAt,
+	).Scan(&attachment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit attachment: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes an attachment by its ID
+func (r *SQLAttachmentRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM attachments WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.ErrAttachmentNotFound
+	}
+
+	return nil
+}
+
+// GetUsage returns the total size of the attachments of a project
+func (r *SQLAttachmentRepository) GetUsage(ctx context.Context, projectID int64) (int64, error) {
+	var used int64
+	err := r.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(size), 0) FROM attachments WHERE project_id = $1`, projectID).Scan(&used)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get attachment usage: %w", err)
+	}
+	return used, nil
+}
+
+// GetQuota returns the quota configured for a project, or nil when there is none
+func (r *SQLAttachmentRepository) GetQuota(ctx context.Context, projectID int64) (*int64, error) {
+	var quota int64
+	err := r.db.QueryRowContext(ctx, `SELECT quota_bytes FROM project_attachment_quotas WHERE project_id = $1`, projectID).Scan(&quota)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get attachment quota: %w", err)
+	}
+	return &quota, nil
+}
+
+// SetQuota configures the quota of a project, replacing the one configured before
+func (r *SQLAttachmentRepository) SetQuota(ctx context.Context, projectID, quotaBytes int64) error {
+	query := `
+		INSERT INTO project_attachment_quotas (project_id, quota_bytes) VALUES ($1, $2)
+		ON CONFLICT (project_id) DO UPDATE SET quota_bytes = EXCLUDED.quota_bytes`
+
+	if _, err := r.db.ExecContext(ctx, query, projectID, quotaBytes); err != nil {
+		return fmt.Errorf("failed to set attachment quota: %w", err)
+	}
+	return nil
+}
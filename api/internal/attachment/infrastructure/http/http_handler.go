@@ -0,0 +1,251 @@
+package http
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/BennyEisner/test-results/internal/attachment/domain/errors"
+	"github.com/BennyEisner/test-results/internal/attachment/domain/ports"
+)
+
+// uploadMemory is the part of a multipart upload kept in memory; the rest spills to disk
+const uploadMemory = 8 << 20
+
+// AttachmentHandler handles HTTP requests for attachments
+type AttachmentHandler struct {
+	Service ports.AttachmentService
+}
+
+// NewAttachmentHandler creates a new AttachmentHandler
+func NewAttachmentHandler(service ports.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{Service: service}
+}
+
+// UploadAttachment handles POST /executions/{id}/attachments
+// @Summary Upload an attachment
+// @Description Store a file, such as a screenshot, HAR file or log, with a test execution. The upload is rejected when it is larger than the size limit of a single attachment or would exceed the attachment quota of the project.
+// @Tags attachments
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Execution ID"
+// @Param file formData file true "Attachment"
+// @Success 201 {object} models.Attachment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /executions/{id}/attachments [post]
+func (h *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	executionID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid execution ID")
+		return
+	}
+
+	if err := r.ParseMultipartForm(uploadMemory); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid multipart form")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "missing file")
+		return
+	}
+	defer file.Close()
+
+	ctx := r.Context()
+	attachment, err := h.Service.UploadAttachment(ctx, executionID, header.Filename, header.Header.Get("Content-Type"), file, header.Size)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, attachment)
+}
+
+// GetAttachmentsByExecution handles GET /executions/{id}/attachments
+// @Summary List the attachments of an execution
+// @Description List the attachments stored with a test execution
+// @Tags attachments
+// @Produce json
+// @Param id path int true "Execution ID"
+// @Success 200 {array} models.Attachment
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /executions/{id}/attachments [get]
+func (h *AttachmentHandler) GetAttachmentsByExecution(w http.ResponseWriter, r *http.Request) {
+	executionID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid execution ID")
+		return
+	}
+
+	ctx := r.Context()
+	attachments, err := h.Service.GetAttachmentsByExecution(ctx, executionID)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, attachments)
+}
+
+// DownloadAttachment handles GET /attachments/{id}
+// @Summary Download an attachment
+// @Description Download the content of an attachment
+// @Tags attachments
+// @Produce octet-stream
+// @Param id path int true "Attachment ID"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /attachments/{id} [get]
+func (h *AttachmentHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid attachment ID")
+		return
+	}
+
+	ctx := r.Context()
+	attachment, content, err := h.Service.OpenAttachment(ctx, id)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, content); err != nil {
+		slog.Error("failed to write attachment", "id", id, "error", err)
+	}
+}
+
+// DeleteAttachment handles DELETE /attachments/{id}
+// @Summary Delete an attachment
+// @Description Delete an attachment and its content
+// @Tags attachments
+// @Param id path int true "Attachment ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /attachments/{id} [delete]
+func (h *AttachmentHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid attachment ID")
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.Service.DeleteAttachment(ctx, id); err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetQuota handles GET /projects/{projectID}/attachment_quota
+// @Summary Get the attachment quota of a project
+// @Description Get the total size of attachments a project may store and the size it stores
+// @Tags attachments
+// @Produce json
+// @Param projectID path int true "Project ID"
+// @Success 200 {object} models.Quota
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{projectID}/attachment_quota [get]
+func (h *AttachmentHandler) GetQuota(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(r.PathValue("projectID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid project ID")
+		return
+	}
+
+	ctx := r.Context()
+	quota, err := h.Service.GetQuota(ctx, projectID)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, quota)
+}
+
+// SetQuota handles PUT /projects/{projectID}/attachment_quota
+// @Summary Set the attachment quota of a project
+// @Description Set the total size of attachments a project may store, replacing the configured default
+// @Tags attachments
+// @Accept json
+// @Produce json
+// @Param projectID path int true "Project ID"
+// @Param quota body object true "Quota" schema="{quota_bytes:integer}"
+// @Success 200 {object} models.Quota
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{projectID}/attachment_quota [put]
+func (h *AttachmentHandler) SetQuota(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(r.PathValue("projectID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid project ID")
+		return
+	}
+
+	var input struct {
+		QuotaBytes *int64 `json:"quota_bytes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.QuotaBytes == nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	ctx := r.Context()
+	quota, err := h.Service.SetQuota(ctx, projectID, *input.QuotaBytes)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, quota)
+}
+
+func statusForError(err error) int {
+	switch {
+	case stderrors.Is(err, errors.ErrAttachmentNotFound),
+		stderrors.Is(err, errors.ErrExecutionNotFound),
+		stderrors.Is(err, errors.ErrBlobNotFound):
+		return http.StatusNotFound
+	case stderrors.Is(err, errors.ErrAttachmentTooLarge),
+		stderrors.Is(err, errors.ErrQuotaExceeded):
+		return http.StatusRequestEntityTooLarge
+	case stderrors.Is(err, errors.ErrInvalidAttachment):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Helper functions for HTTP responses
+func respondWithError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondWithJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,176 @@
+package application
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/BennyEisner/test-results/internal/attachment/domain/errors"
+	"github.com/BennyEisner/test-results/internal/attachment/domain/ports"
+	"github.com/BennyEisner/test-results/internal/attachment/infrastructure/blob"
+	"github.com/stretchr/testify/assert"
+)
+
+// s3StandIn is a minimal S3-compatible object store serving one bucket from memory
+type s3StandIn struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string]string
+	lengths []int64
+}
+
+func newS3StandIn(t *testing.T, bucket string) (*s3StandIn, *httptest.Server) {
+	standIn := &s3StandIn{bucket: bucket, objects: make(map[string]string)}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+	return standIn, server
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		!strings.Contains(auth, "/eu-central-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") ||
+		r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+s.bucket+"/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.objects[key] = string(data)
+		s.lengths = append(s.lengths, r.ContentLength)
+	case http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, data)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// testBlobStore checks the behavior every BlobStore shares
+func testBlobStore(t *testing.T, store ports.BlobStore) {
+	ctx := context.Background()
+
+	t.Run("put, get and delete", func(t *testing.T) {
+		err := store.Put(ctx, "projects/1/executions/2/abc", strings.NewReader("screenshot"), 10)
+		assert.NoError(t, err)
+
+		content, err := store.Get(ctx, "projects/1/executions/2/abc")
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(content)
+		content.Close()
+		assert.Equal(t, "screenshot", string(data))
+
+		assert.NoError(t, store.Delete(ctx, "projects/1/executions/2/abc"))
+		_, err = store.Get(ctx, "projects/1/executions/2/abc")
+		assert.True(t, stderrors.Is(err, errors.ErrBlobNotFound))
+	})
+
+	t.Run("unknown size", func(t *testing.T) {
+		err := store.Put(ctx, "projects/1/executions/2/log", strings.NewReader("line 1\nline 2\n"), -1)
+		assert.NoError(t, err)
+
+		content, err := store.Get(ctx, "projects/1/executions/2/log")
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(content)
+		content.Close()
+		assert.Equal(t, "line 1\nline 2\n", string(data))
+	})
+
+	t.Run("deleting a missing key", func(t *testing.T) {
+		assert.NoError(t, store.Delete(ctx, "projects/1/missing"))
+	})
+
+	t.Run("keys outside of the store", func(t *testing.T) {
+		for _, key := range []string{"", "/etc/passwd", "../escape", "a/../../b", `a\b`} {
+			assert.Error(t, store.Put(ctx, key, strings.NewReader("x"), 1), key)
+		}
+	})
+}
+
+func TestLocalStore(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "attachments")
+	store, err := blob.NewLocalStore(root)
+	assert.NoError(t, err)
+
+	testBlobStore(t, store)
+
+	t.Run("failed write leaves no blob", func(t *testing.T) {
+		err := store.Put(context.Background(), "projects/1/partial", io.MultiReader(strings.NewReader("abc"), failingReader{}), 6)
+
+		assert.Error(t, err)
+		_, statErr := os.Stat(filepath.Join(root, "projects", "1", "partial"))
+		assert.True(t, os.IsNotExist(statErr))
+		entries, _ := os.ReadDir(filepath.Join(root, "projects", "1"))
+		for _, entry := range entries {
+			assert.False(t, strings.HasPrefix(entry.Name(), ".upload-"), entry.Name())
+		}
+	})
+}
+
+func TestS3Store(t *testing.T) {
+	standIn, server := newS3StandIn(t, "results")
+	config := blob.S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "results",
+		Region:          "eu-central-1",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+	}
+	store, err := blob.NewS3Store(config, server.Client())
+	assert.NoError(t, err)
+
+	testBlobStore(t, store)
+
+	t.Run("sends the content length", func(t *testing.T) {
+		assert.Contains(t, standIn.lengths, int64(10))
+		assert.Contains(t, standIn.lengths, int64(14))
+	})
+
+	t.Run("rejected credentials", func(t *testing.T) {
+		config := config
+		config.AccessKeyID = "OTHER"
+		other, err := blob.NewS3Store(config, server.Client())
+		assert.NoError(t, err)
+
+		err = other.Put(context.Background(), "projects/1/a", strings.NewReader("a"), 1)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "status 403")
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		_, err := blob.NewS3Store(blob.S3Config{Endpoint: "localhost:9000", Bucket: "results"}, nil)
+		assert.Error(t, err)
+
+		_, err = blob.NewS3Store(blob.S3Config{Endpoint: server.URL}, nil)
+		assert.Error(t, err)
+	})
+}
+
+// failingReader fails every read
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, stderrors.New("connection reset")
+}
@@ -0,0 +1,366 @@
+package application
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/BennyEisner/test-results/internal/attachment/application"
+	"github.com/BennyEisner/test-results/internal/attachment/domain/errors"
+	"github.com/BennyEisner/test-results/internal/attachment/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAttachmentRepository is a mock implementation of AttachmentRepository
+type MockAttachmentRepository struct {
+	mock.Mock
+}
+
+func (m *MockAttachmentRepository) GetExecutionProjectID(ctx context.Context, executionID int64) (int64, error) {
+	args := m.Called(ctx, executionID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAttachmentRepository) FindExecutionID(ctx context.Context, buildID int64, classname, name string) (int64, error) {
+	args := m.Called(ctx, buildID, classname, name)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAttachmentRepository) GetByID(ctx context.Context, id int64) (*models.Attachment, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Attachment), args.Error(1)
+}
+
+func (m *MockAttachmentRepository) GetByExecutionID(ctx context.Context, executionID int64) ([]*models.Attachment, error) {
+	args := m.Called(ctx, executionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Attachment), args.Error(1)
+}
+
+func (m *MockAttachmentRepository) Create(ctx context.Context, attachment *models.Attachment, quota int64) error {
+	args := m.Called(ctx, attachment, quota)
+	return args.Error(0)
+}
+
+func (m *MockAttachmentRepository) Delete(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockAttachmentRepository) GetUsage(ctx context.Context, projectID int64) (int64, error) {
+	args := m.Called(ctx, projectID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAttachmentRepository) GetQuota(ctx context.Context, projectID int64) (*int64, error) {
+	args := m.Called(ctx, projectID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*int64), args.Error(1)
+}
+
+func (m *MockAttachmentRepository) SetQuota(ctx context.Context, projectID, quotaBytes int64) error {
+	args := m.Called(ctx, projectID, quotaBytes)
+	return args.Error(0)
+}
+
+// memoryStore is a BlobStore keeping blobs in memory
+type memoryStore struct {
+	blobs   map[string]string
+	deleted []string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{blobs: make(map[string]string)}
+}
+
+func (s *memoryStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.blobs[key] = string(data)
+	return nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, errors.ErrBlobNotFound
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	delete(s.blobs, key)
+	s.deleted = append(s.deleted, key)
+	return nil
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func TestAttachmentService_UploadAttachment(t *testing.T) {
+	ctx := context.Background()
+	limits := models.NewLimits(100, 10)
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		store := newMemoryStore()
+		service := application.NewAttachmentService(mockRepo, store, limits)
+
+		mockRepo.On("GetExecutionProjectID", ctx, int64(7)).Return(int64(3), nil).Once()
+		mockRepo.On("GetQuota", ctx, int64(3)).Return(nil, nil).Once()
+		mockRepo.On("GetUsage", ctx, int64(3)).Return(int64(90), nil).Once()
+		mockRepo.On("Create", ctx, mock.MatchedBy(func(a *models.Attachment) bool {
+			return a.ExecutionID == 7 && a.ProjectID == 3 && a.Size == 5 &&
+				strings.HasPrefix(a.StorageKey, "projects/3/executions/7/")
+		}), int64(100)).Return(nil).Once()
+
+		attachment, err := service.UploadAttachment(ctx, 7, `C:\shots\login.png`, "", strings.NewReader("image"), 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "login.png", attachment.Name)
+		assert.Equal(t, "image/png", attachment.ContentType)
+		assert.Equal(t, "image", store.blobs[attachment.StorageKey])
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("execution not found", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		service := application.NewAttachmentService(mockRepo, newMemoryStore(), limits)
+
+		mockRepo.On("GetExecutionProjectID", ctx, int64(7)).Return(int64(0), nil).Once()
+
+		_, err := service.UploadAttachment(ctx, 7, "a.txt", "", strings.NewReader("a"), 1)
+
+		assert.True(t, stderrors.Is(err, errors.ErrExecutionNotFound))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("larger than the size limit", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		service := application.NewAttachmentService(mockRepo, newMemoryStore(), limits)
+
+		mockRepo.On("GetExecutionProjectID", ctx, int64(7)).Return(int64(3), nil).Once()
+
+		_, err := service.UploadAttachment(ctx, 7, "trace.har", "", strings.NewReader("01234567890"), 11)
+
+		assert.True(t, stderrors.Is(err, errors.ErrAttachmentTooLarge))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("unknown size larger than the size limit", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		store := newMemoryStore()
+		service := application.NewAttachmentService(mockRepo, store, limits)
+
+		mockRepo.On("GetExecutionProjectID", ctx, int64(7)).Return(int64(3), nil).Once()
+		mockRepo.On("GetQuota", ctx, int64(3)).Return(nil, nil).Once()
+
+		_, err := service.UploadAttachment(ctx, 7, "trace.har", "", strings.NewReader("01234567890"), -1)
+
+		assert.True(t, stderrors.Is(err, errors.ErrAttachmentTooLarge))
+		assert.Empty(t, store.blobs)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("quota exceeded before storing", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		store := newMemoryStore()
+		service := application.NewAttachmentService(mockRepo, store, limits)
+
+		mockRepo.On("GetExecutionProjectID", ctx, int64(7)).Return(int64(3), nil).Once()
+		mockRepo.On("GetQuota", ctx, int64(3)).Return(int64Ptr(50), nil).Once()
+		mockRepo.On("GetUsage", ctx, int64(3)).Return(int64(48), nil).Once()
+
+		_, err := service.UploadAttachment(ctx, 7, "a.log", "text/plain", strings.NewReader("abc"), 3)
+
+		assert.True(t, stderrors.Is(err, errors.ErrQuotaExceeded))
+		assert.Empty(t, store.blobs)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("quota exceeded when recorded", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		store := newMemoryStore()
+		service := application.NewAttachmentService(mockRepo, store, limits)
+
+		mockRepo.On("GetExecutionProjectID", ctx, int64(7)).Return(int64(3), nil).Once()
+		mockRepo.On("GetQuota", ctx, int64(3)).Return(nil, nil).Once()
+		mockRepo.On("GetUsage", ctx, int64(3)).Return(int64(0), nil).Once()
+		mockRepo.On("Create", ctx, mock.Anything, int64(100)).Return(errors.ErrQuotaExceeded).Once()
+
+		_, err := service.UploadAttachment(ctx, 7, "a.log", "text/plain", strings.NewReader("abc"), 3)
+
+		assert.True(t, stderrors.Is(err, errors.ErrQuotaExceeded))
+		assert.Empty(t, store.blobs)
+		assert.Len(t, store.deleted, 1)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("missing file name", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		service := application.NewAttachmentService(mockRepo, newMemoryStore(), limits)
+
+		mockRepo.On("GetExecutionProjectID", ctx, int64(7)).Return(int64(3), nil).Once()
+
+		_, err := service.UploadAttachment(ctx, 7, " ", "", strings.NewReader("a"), 1)
+
+		assert.True(t, stderrors.Is(err, errors.ErrInvalidAttachment))
+	})
+}
+
+func TestAttachmentService_AttachToTestCase(t *testing.T) {
+	ctx := context.Background()
+	limits := models.NewLimits(0, 0)
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		store := newMemoryStore()
+		service := application.NewAttachmentService(mockRepo, store, limits)
+
+		mockRepo.On("FindExecutionID", ctx, int64(12), "ui.Checkout", "testPay").Return(int64(7), nil).Once()
+		mockRepo.On("GetExecutionProjectID", ctx, int64(7)).Return(int64(3), nil).Once()
+		mockRepo.On("GetQuota", ctx, int64(3)).Return(nil, nil).Once()
+		mockRepo.On("GetUsage", ctx, int64(3)).Return(int64(0), nil).Once()
+		mockRepo.On("Create", ctx, mock.MatchedBy(func(a *models.Attachment) bool {
+			return a.ExecutionID == 7 && a.Name == "pay.png" && a.ContentType == "image/png"
+		}), int64(models.DefaultQuota)).Return(nil).Once()
+
+		err := service.AttachToTestCase(ctx, 12, "ui.Checkout", "testPay", "pay.png", strings.NewReader("png"), 3)
+
+		assert.NoError(t, err)
+		assert.Len(t, store.blobs, 1)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("test case not in the build", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		service := application.NewAttachmentService(mockRepo, newMemoryStore(), limits)
+
+		mockRepo.On("FindExecutionID", ctx, int64(12), "ui.Checkout", "testPay").Return(int64(0), nil).Once()
+
+		err := service.AttachToTestCase(ctx, 12, "ui.Checkout", "testPay", "pay.png", strings.NewReader("png"), 3)
+
+		assert.True(t, stderrors.Is(err, errors.ErrExecutionNotFound))
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestAttachmentService_OpenAndDelete(t *testing.T) {
+	ctx := context.Background()
+	attachment := &models.Attachment{ID: 4, Name: "a.txt", StorageKey: "projects/1/executions/2/abc"}
+
+	t.Run("open", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		store := newMemoryStore()
+		store.blobs[attachment.StorageKey] = "hello"
+		service := application.NewAttachmentService(mockRepo, store, models.NewLimits(0, 0))
+
+		mockRepo.On("GetByID", ctx, int64(4)).Return(attachment, nil).Once()
+
+		result, content, err := service.OpenAttachment(ctx, 4)
+
+		assert.NoError(t, err)
+		assert.Equal(t, attachment, result)
+		data, _ := io.ReadAll(content)
+		assert.Equal(t, "hello", string(data))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		service := application.NewAttachmentService(mockRepo, newMemoryStore(), models.NewLimits(0, 0))
+
+		mockRepo.On("GetByID", ctx, int64(4)).Return(nil, nil).Once()
+
+		_, _, err := service.OpenAttachment(ctx, 4)
+
+		assert.True(t, stderrors.Is(err, errors.ErrAttachmentNotFound))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("delete removes the blob", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		store := newMemoryStore()
+		store.blobs[attachment.StorageKey] = "hello"
+		service := application.NewAttachmentService(mockRepo, store, models.NewLimits(0, 0))
+
+		mockRepo.On("GetByID", ctx, int64(4)).Return(attachment, nil).Once()
+		mockRepo.On("Delete", ctx, int64(4)).Return(nil).Once()
+
+		err := service.DeleteAttachment(ctx, 4)
+
+		assert.NoError(t, err)
+		assert.Empty(t, store.blobs)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("delete keeps the blob when the row remains", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		store := newMemoryStore()
+		store.blobs[attachment.StorageKey] = "hello"
+		service := application.NewAttachmentService(mockRepo, store, models.NewLimits(0, 0))
+
+		mockRepo.On("GetByID", ctx, int64(4)).Return(attachment, nil).Once()
+		mockRepo.On("Delete", ctx, int64(4)).Return(stderrors.New("db down")).Once()
+
+		err := service.DeleteAttachment(ctx, 4)
+
+		assert.Error(t, err)
+		assert.Len(t, store.blobs, 1)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestAttachmentService_Quota(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("default quota", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		service := application.NewAttachmentService(mockRepo, newMemoryStore(), models.NewLimits(500, 0))
+
+		mockRepo.On("GetQuota", ctx, int64(3)).Return(nil, nil).Once()
+		mockRepo.On("GetUsage", ctx, int64(3)).Return(int64(20), nil).Once()
+
+		quota, err := service.GetQuota(ctx, 3)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.Quota{ProjectID: 3, QuotaBytes: 500, UsedBytes: 20, Default: true}, quota)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("set quota", func(t *testing.T) {
+		mockRepo := new(MockAttachmentRepository)
+		service := application.NewAttachmentService(mockRepo, newMemoryStore(), models.NewLimits(500, 0))
+
+		mockRepo.On("SetQuota", ctx, int64(3), int64(2000)).Return(nil).Once()
+		mockRepo.On("GetQuota", ctx, int64(3)).Return(int64Ptr(2000), nil).Once()
+		mockRepo.On("GetUsage", ctx, int64(3)).Return(int64(20), nil).Once()
+
+		quota, err := service.SetQuota(ctx, 3, 2000)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.Quota{ProjectID: 3, QuotaBytes: 2000, UsedBytes: 20}, quota)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("negative quota", func(t *testing.T) {
+		service := application.NewAttachmentService(new(MockAttachmentRepository), newMemoryStore(), models.NewLimits(0, 0))
+
+		_, err := service.SetQuota(ctx, 3, -1)
+
+		assert.True(t, stderrors.Is(err, errors.ErrInvalidAttachment))
+	})
+}
@@ -0,0 +1,90 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/BennyEisner/test-results/internal/build/domain"
+	"github.com/BennyEisner/test-results/internal/build/domain/models"
+)
+
+// normalizeStatus lower-cases a build status and checks it is known; an empty status
+// becomes fallback
+func normalizeStatus(status, fallback string) (string, error) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if status == "" {
+		return fallback, nil
+	}
+	for _, known := range models.BuildStatuses {
+		if status == known {
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("%w: status must be one of %s", domain.ErrInvalidBuildData, strings.Join(models.BuildStatuses, ", "))
+}
+
+// getBuild returns a build by ID, failing with ErrBuildNotFound only when there is no
+// such build; other errors of the repository are returned as they are
+func (s *BuildServiceImpl) getBuild(ctx context.Context, id int64) (*models.Build, error) {
+	build, err := s.repo.GetBuildByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", domain.ErrBuildNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get build %d: %w", id, err)
+	}
+	return build, nil
+}
+
+// StartBuild moves a pending build to running
+func (s *BuildServiceImpl) StartBuild(ctx context.Context, id int64) (*models.Build, error) {
+	build, err := s.getBuild(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if build.Status != models.BuildPending {
+		return nil, fmt.Errorf("%w: build %d is %s, only pending builds can start", domain.ErrInvalidTransition, id, build.Status)
+	}
+
+	started, err := s.repo.StartBuild(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start build %d: %w", id, err)
+	}
+	if !started {
+		return nil, fmt.Errorf("%w: build %d changed status meanwhile", domain.ErrInvalidTransition, id)
+	}
+	return s.repo.GetBuildByID(ctx, id)
+}
+
+// FinishBuild moves a pending or running build to a finished status. Without a status,
+// the build failed when any of its executions failed, errored when any errored and
+// passed otherwise. A sharded build is finalized with it.
+func (s *BuildServiceImpl) FinishBuild(ctx context.Context, id int64, status string) (*models.Build, error) {
+	status, err := normalizeStatus(status, "")
+	if err != nil {
+		return nil, err
+	}
+	if status != "" && !models.IsFinished(status) {
+		return nil, fmt.Errorf("%w: a build cannot finish as %s", domain.ErrInvalidBuildData, status)
+	}
+
+	build, err := s.getBuild(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if models.IsFinished(build.Status) {
+		return nil, fmt.Errorf("%w: build %d already finished as %s", domain.ErrInvalidTransition, id, build.Status)
+	}
+
+	finished, err := s.repo.FinishBuild(ctx, id, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finish build %d: %w", id, err)
+	}
+	if !finished {
+		return nil, fmt.Errorf("%w: build %d changed status meanwhile", domain.ErrInvalidTransition, id)
+	}
+	return s.repo.GetBuildByID(ctx, id)
+}
@@ -0,0 +1,93 @@
+package application
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/BennyEisner/test-results/internal/build/domain"
+	"github.com/BennyEisner/test-results/internal/build/domain/models"
+)
+
+// defaultCIProvider is recorded for builds that do not name their CI provider
+const defaultCIProvider = "unknown"
+
+// maxTagLength bounds the name of a tag
+const maxTagLength = 100
+
+// commitSHAPattern matches a full or abbreviated SHA-1 or SHA-256 commit hash
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{4,64}$`)
+
+// normalizeMetadata trims the metadata of a build and checks it
+func normalizeMetadata(metadata *models.BuildMetadata) error {
+	for _, field := range []*string{
+		&metadata.CIProvider, &metadata.CIURL, &metadata.CommitSHA, &metadata.Branch,
+		&metadata.BaseBranch, &metadata.Author, &metadata.Trigger,
+	} {
+		*field = strings.TrimSpace(*field)
+	}
+	if metadata.CIProvider == "" {
+		metadata.CIProvider = defaultCIProvider
+	}
+	metadata.CommitSHA = strings.ToLower(metadata.CommitSHA)
+	metadata.Trigger = strings.ToLower(metadata.Trigger)
+
+	if metadata.CommitSHA != "" && !commitSHAPattern.MatchString(metadata.CommitSHA) {
+		return fmt.Errorf("%w: commit_sha must be a hexadecimal commit hash", domain.ErrInvalidBuildData)
+	}
+	if metadata.PRNumber != nil && *metadata.PRNumber <= 0 {
+		return fmt.Errorf("%w: pr_number must be positive", domain.ErrInvalidBuildData)
+	}
+	for name := range metadata.Properties {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%w: property names must not be empty", domain.ErrInvalidBuildData)
+		}
+	}
+	tags, err := normalizeTags(metadata.Tags)
+	metadata.Tags = tags
+	return err
+}
+
+// normalizeTags trims tags and the @ of Cucumber-style tags, drops repeated ones and
+// checks the rest
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "@"))
+		if tag == "" || len(tag) > maxTagLength || strings.Contains(tag, ",") {
+			return nil, fmt.Errorf("%w: tags must be 1 to %d characters without commas", domain.ErrInvalidBuildData, maxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeFilter trims the fields of a build filter and checks them
+func normalizeFilter(filter *models.BuildFilter) error {
+	for _, field := range []*string{&filter.CommitSHA, &filter.Branch, &filter.BaseBranch, &filter.Author, &filter.Trigger} {
+		*field = strings.TrimSpace(*field)
+	}
+	filter.CommitSHA = strings.ToLower(filter.CommitSHA)
+	filter.Trigger = strings.ToLower(filter.Trigger)
+
+	if filter.ProjectID <= 0 {
+		return fmt.Errorf("%w: project_id must be positive", domain.ErrInvalidBuildData)
+	}
+	if filter.CommitSHA != "" && !commitSHAPattern.MatchString(filter.CommitSHA) {
+		return fmt.Errorf("%w: commit must be a hexadecimal commit hash of at least 4 characters", domain.ErrInvalidBuildData)
+	}
+	for i, status := range filter.Statuses {
+		normalized, err := normalizeStatus(status, "")
+		if err != nil {
+			return err
+		}
+		filter.Statuses[i] = normalized
+	}
+	tags, err := normalizeTags(filter.Tags)
+	filter.Tags = tags
+	return err
+}
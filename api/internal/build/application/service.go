@@ -2,18 +2,27 @@ package application
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/BennyEisner/test-results/internal/build/domain"
 	"github.com/BennyEisner/test-results/internal/build/domain/models"
 	"github.com/BennyEisner/test-results/internal/build/domain/ports"
 )
 
 type BuildService interface {
-	GetBuilds(ctx context.Context, projectID int64, suiteID *int64) ([]*models.Build, error)
+	GetBuilds(ctx context.Context, filter *models.BuildFilter) ([]*models.Build, error)
 	GetBuildByID(ctx context.Context, id int64) (*models.Build, error)
 	CreateBuild(ctx context.Context, build *models.Build) (int64, error)
 	UpdateBuild(ctx context.Context, build *models.Build) error
 	DeleteBuild(ctx context.Context, id int64) error
 	GetBuildDurationTrends(ctx context.Context, projectID int64, suiteID int64) ([]*models.BuildDurationTrend, error)
+	// FinalizeBuild finalizes a sharded build with the shards received so far and returns it
+	FinalizeBuild(ctx context.Context, id int64) (*models.Build, error)
+	FinalizeExpiredBuilds(ctx context.Context) (int64, error)
+	StartBuild(ctx context.Context, id int64) (*models.Build, error)
+	// FinishBuild finishes a build with a status, or with the status derived from its executions when empty
+	FinishBuild(ctx context.Context, id int64, status string) (*models.Build, error)
 }
 
 type BuildServiceImpl struct {
@@ -24,8 +33,12 @@ func NewBuildService(repo ports.BuildRepository) BuildService {
 	return &BuildServiceImpl{repo: repo}
 }
 
-func (s *BuildServiceImpl) GetBuilds(ctx context.Context, projectID int64, suiteID *int64) ([]*models.Build, error) {
-	return s.repo.GetBuilds(ctx, projectID, suiteID)
+// GetBuilds returns the builds matching a filter
+func (s *BuildServiceImpl) GetBuilds(ctx context.Context, filter *models.BuildFilter) ([]*models.Build, error) {
+	if err := normalizeFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.repo.GetBuilds(ctx, filter)
 }
 
 func (s *BuildServiceImpl) GetBuildByID(ctx context.Context, id int64) (*models.Build, error) {
@@ -36,14 +49,56 @@ func (s *BuildServiceImpl) GetBuildDurationTrends(ctx context.Context, projectID
 	return s.repo.GetBuildDurationTrends(ctx, projectID, suiteID)
 }
 
+// CreateBuild creates a build, pending unless it is given another status. A build
+// expecting shards is opened for its shards to upload their results to, and finalized
+// once they all did or its timeout passed.
 func (s *BuildServiceImpl) CreateBuild(ctx context.Context, build *models.Build) (int64, error) {
+	if err := normalizeMetadata(&build.BuildMetadata); err != nil {
+		return 0, err
+	}
+	status, err := normalizeStatus(build.Status, models.BuildPending)
+	if err != nil {
+		return 0, err
+	}
+	build.Status = status
+	if err := normalizeSharding(&build.Sharding, time.Now().UTC()); err != nil {
+		return 0, err
+	}
 	return s.repo.CreateBuild(ctx, build)
 }
 
 func (s *BuildServiceImpl) UpdateBuild(ctx context.Context, build *models.Build) error {
+	if err := normalizeMetadata(&build.BuildMetadata); err != nil {
+		return err
+	}
 	return s.repo.UpdateBuild(ctx, build)
 }
 
 func (s *BuildServiceImpl) DeleteBuild(ctx context.Context, id int64) error {
 	return s.repo.DeleteBuild(ctx, id)
 }
+
+// FinalizeBuild finalizes a sharded build before all of its shards arrived; finalizing
+// it again has no effect. Shards uploaded after it are rejected.
+func (s *BuildServiceImpl) FinalizeBuild(ctx context.Context, id int64) (*models.Build, error) {
+	build, err := s.getBuild(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if build.ExpectedShards == nil {
+		return nil, domain.ErrBuildNotSharded
+	}
+	if build.FinalizedAt != nil {
+		return build, nil
+	}
+
+	if err := s.repo.FinalizeBuild(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to finalize build %d: %w", id, err)
+	}
+	return s.repo.GetBuildByID(ctx, id)
+}
+
+// FinalizeExpiredBuilds finalizes the sharded builds whose deadline passed
+func (s *BuildServiceImpl) FinalizeExpiredBuilds(ctx context.Context) (int64, error) {
+	return s.repo.FinalizeExpiredBuilds(ctx)
+}
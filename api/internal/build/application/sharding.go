@@ -0,0 +1,65 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/BennyEisner/test-results/internal/build/domain"
+	"github.com/BennyEisner/test-results/internal/build/domain/models"
+)
+
+const (
+	// maxShards bounds the number of shards a build may expect
+	maxShards = 1000
+	// defaultShardTimeout is the number of seconds after which a sharded build is finalized
+	// when it does not give a timeout
+	defaultShardTimeout = 60 * 60
+	// maxShardTimeout bounds the timeout of a sharded build to a day
+	maxShardTimeout = 24 * 60 * 60
+)
+
+// normalizeSharding checks the shards a new build expects and sets its deadline. Builds
+// that do not expect shards are left unsharded.
+func normalizeSharding(sharding *models.Sharding, now time.Time) error {
+	timeout := sharding.ShardTimeout
+	*sharding = models.Sharding{ExpectedShards: sharding.ExpectedShards}
+	if sharding.ExpectedShards == nil {
+		return nil
+	}
+
+	if *sharding.ExpectedShards < 1 || *sharding.ExpectedShards > maxShards {
+		return fmt.Errorf("%w: expected_shards must be between 1 and %d", domain.ErrInvalidBuildData, maxShards)
+	}
+	if timeout < 0 || timeout > maxShardTimeout {
+		return fmt.Errorf("%w: shard_timeout must be between 1 and %d seconds", domain.ErrInvalidBuildData, maxShardTimeout)
+	}
+	if timeout == 0 {
+		timeout = defaultShardTimeout
+	}
+	sharding.ShardTimeout = timeout
+	deadline := now.Add(time.Duration(timeout) * time.Second)
+	sharding.ShardDeadline = &deadline
+	return nil
+}
+
+// RunShardFinalizer finalizes the sharded builds whose deadline passed every interval
+// until ctx is done
+func RunShardFinalizer(ctx context.Context, service BuildService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			finalized, err := service.FinalizeExpiredBuilds(ctx)
+			if err != nil {
+				slog.Error("failed to finalize expired sharded builds", "error", err)
+			} else if finalized > 0 {
+				slog.Info("finalized sharded builds past their deadline", "builds", finalized)
+			}
+		}
+	}
+}
@@ -5,6 +5,8 @@ import "errors"
 var (
 	ErrInvalidBuildData     = errors.New("invalid build data")
 	ErrBuildNotFound        = errors.New("build not found")
+	ErrBuildNotSharded      = errors.New("build is not sharded")
+	ErrInvalidTransition    = errors.New("build cannot change to this status")
 	ErrInvalidProjectName   = errors.New("invalid project name")
 	ErrInvalidTestSuiteName = errors.New("invalid test suite name")
 )
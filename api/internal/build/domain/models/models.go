@@ -3,15 +3,89 @@ package models
 import "time"
 
 type Build struct {
-	ID          int64     `json:"id"`
-	ProjectID   int64     `json:"project_id"`
-	SuiteID     int64     `json:"test_suite_id"`
-	BuildNumber string    `json:"build_number"`
-	Status      string    `json:"status"`
-	Duration    float64   `json:"duration"`
-	Timestamp   time.Time `json:"timestamp"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64      `json:"id"`
+	ProjectID   int64      `json:"project_id"`
+	SuiteID     int64      `json:"test_suite_id"`
+	BuildNumber string     `json:"build_number"`
+	Status      string     `json:"status"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Duration    float64    `json:"duration"`
+	Timestamp   time.Time  `json:"timestamp"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	BuildMetadata
+	Sharding
+}
+
+// Build statuses. A build is pending until it starts running and ends in one of the
+// finished statuses; passed, failed and errored are derived from its executions when
+// it finishes without being given a status.
+const (
+	BuildPending = "pending"
+	BuildRunning = "running"
+	BuildPassed  = "passed"
+	BuildFailed  = "failed"
+	BuildErrored = "errored"
+	BuildAborted = "aborted"
+)
+
+// BuildStatuses lists the statuses of a build in lifecycle order
+var BuildStatuses = []string{BuildPending, BuildRunning, BuildPassed, BuildFailed, BuildErrored, BuildAborted}
+
+// IsFinished reports whether a build status is one of the finished statuses
+func IsFinished(status string) bool {
+	return status != BuildPending && status != BuildRunning
+}
+
+// Sharding describes a build whose results are uploaded by parallel CI shards. The build
+// is opened with the number of shards it expects, each shard uploads its results with its
+// index, and the build is finalized once all shards arrived or its deadline passed.
+type Sharding struct {
+	// ExpectedShards is the number of shards the build waits for; nil for builds imported in one upload
+	ExpectedShards *int `json:"expected_shards,omitempty"`
+	// ShardTimeout is the number of seconds after opening at which the build is finalized
+	// with the shards received so far; it is only read when the build is created
+	ShardTimeout  int        `json:"shard_timeout,omitempty"`
+	ShardDeadline *time.Time `json:"shard_deadline,omitempty"`
+	// ReceivedShards and MissingShards count and list the shard indexes uploaded and still missing
+	ReceivedShards int        `json:"received_shards,omitempty"`
+	MissingShards  []int      `json:"missing_shards,omitempty"`
+	FinalizedAt    *time.Time `json:"finalized_at,omitempty"`
+}
+
+// BuildMetadata describes where a build comes from: the CI run, the commit and
+// branch it built and what triggered it
+type BuildMetadata struct {
+	CIProvider string `json:"ci_provider,omitempty"`
+	CIURL      string `json:"ci_url,omitempty"`
+	CommitSHA  string `json:"commit_sha,omitempty"`
+	Branch     string `json:"branch,omitempty"`
+	BaseBranch string `json:"base_branch,omitempty"` // branch a pull request merges into
+	PRNumber   *int64 `json:"pr_number,omitempty"`
+	Author     string `json:"author,omitempty"`
+	Trigger    string `json:"trigger,omitempty"` // e.g. push, pull_request, schedule or manual
+	// Properties are arbitrary build-wide key/value pairs, e.g. the environment tested
+	Properties map[string]string `json:"properties,omitempty"`
+	// Tags label the build, e.g. smoke or nightly
+	Tags []string `json:"tags,omitempty"`
+}
+
+// BuildFilter selects the builds of a project; empty fields match every build.
+// CommitSHA matches commits starting with it, so a short SHA can be given, builds
+// match Tags when they carry all of them and Statuses when they have any of them.
+type BuildFilter struct {
+	ProjectID  int64
+	SuiteID    *int64
+	Statuses   []string
+	CommitSHA  string
+	Branch     string
+	BaseBranch string
+	PRNumber   *int64
+	Author     string
+	Trigger    string
+	Properties map[string]string
+	Tags       []string
 }
 
 type BuildDurationTrend struct {
@@ -7,7 +7,7 @@ import (
 )
 
 type BuildRepository interface {
-	GetBuilds(ctx context.Context, projectID int64, suiteID *int64) ([]*models.Build, error)
+	GetBuilds(ctx context.Context, filter *models.BuildFilter) ([]*models.Build, error)
 	GetBuildByID(ctx context.Context, id int64) (*models.Build, error)
 	CreateBuild(ctx context.Context, build *models.Build) (int64, error)
 	UpdateBuild(ctx context.Context, build *models.Build) error
@@ -15,4 +15,13 @@ type BuildRepository interface {
 	GetBuildDurationTrends(ctx context.Context, projectID int64, suiteID int64) ([]*models.BuildDurationTrend, error)
 	GetLatestBuildStatus(ctx context.Context, projectID int64) (string, error)
 	GetLatestBuilds(ctx context.Context, projectID int64, limit int) ([]*models.Build, error)
+	// FinalizeBuild finalizes a sharded build with the shards received so far
+	FinalizeBuild(ctx context.Context, id int64) error
+	// FinalizeExpiredBuilds finalizes the sharded builds whose deadline passed and returns their number
+	FinalizeExpiredBuilds(ctx context.Context) (int64, error)
+	// StartBuild moves a pending build to running and reports whether it was pending
+	StartBuild(ctx context.Context, id int64) (bool, error)
+	// FinishBuild moves a pending or running build to status, or to the status derived
+	// from its executions when status is empty, and reports whether it was unfinished
+	FinishBuild(ctx context.Context, id int64, status string) (bool, error)
 }
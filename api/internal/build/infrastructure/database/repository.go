@@ -3,9 +3,13 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/BennyEisner/test-results/internal/build/domain/models"
 	"github.com/BennyEisner/test-results/internal/build/domain/ports"
+	"github.com/lib/pq"
 )
 
 type SQLBuildRepository struct {
@@ -16,19 +20,28 @@ func NewSQLBuildRepository(db *sql.DB) ports.BuildRepository {
 	return &SQLBuildRepository{db: db}
 }
 
-func (r *SQLBuildRepository) GetBuilds(ctx context.Context, projectID int64, suiteID *int64) ([]*models.Build, error) {
+// buildColumns are the columns read by scanBuild; the properties are the build-wide ones
+const buildColumns = `b.id, ts.project_id, b.test_suite_id, b.build_number, b.status, b.started_at, b.finished_at,
+		b.duration, b.created_at,
+		b.ci_provider, COALESCE(b.ci_url, ''), COALESCE(b.commit_sha, ''), COALESCE(b.branch, ''),
+		COALESCE(b.base_branch, ''), b.pr_number, COALESCE(b.author, ''), COALESCE(b.trigger, ''),
+		COALESCE((SELECT jsonb_object_agg(p.name, COALESCE(p.value, '')) FROM build_properties p
+		          WHERE p.build_id = b.id AND p.build_suite_run_id IS NULL), '{}'),
+		ARRAY(SELECT t.name FROM build_tags t WHERE t.build_id = b.id ORDER BY t.name),
+		b.expected_shards, b.shard_deadline, b.finalized_at,
+		(SELECT COUNT(*) FROM build_shards s WHERE s.build_id = b.id),
+		ARRAY(SELECT i FROM generate_series(0, COALESCE(b.expected_shards, 0) - 1) i
+		      WHERE NOT EXISTS (SELECT 1 FROM build_shards s WHERE s.build_id = b.id AND s.shard_index = i))`
+
+func (r *SQLBuildRepository) GetBuilds(ctx context.Context, filter *models.BuildFilter) ([]*models.Build, error) {
 	query := `
-		SELECT b.id, ts.project_id, b.test_suite_id, b.build_number, b.duration, b.created_at
+		SELECT ` + buildColumns + `
 		FROM builds b
 		JOIN test_suites ts ON b.test_suite_id = ts.id
 		WHERE ts.project_id = $1
 	`
-	args := []interface{}{projectID}
-
-	if suiteID != nil {
-		query += " AND b.test_suite_id = $2"
-		args = append(args, *suiteID)
-	}
+	conditions, args := buildFilterConditions(filter)
+	query += conditions
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -38,55 +51,194 @@ func (r *SQLBuildRepository) GetBuilds(ctx context.Context, projectID int64, sui
 
 	var builds []*models.Build
 	for rows.Next() {
-		var build models.Build
-		var sqlSuiteID sql.NullInt64
-		if err := rows.Scan(&build.ID, &build.ProjectID, &sqlSuiteID, &build.BuildNumber, &build.Duration, &build.Timestamp); err != nil {
+		build, err := scanBuild(rows)
+		if err != nil {
 			return nil, err
 		}
-		if sqlSuiteID.Valid {
-			build.SuiteID = sqlSuiteID.Int64
-		}
-		builds = append(builds, &build)
+		builds = append(builds, build)
 	}
 
-	return builds, nil
+	return builds, rows.Err()
 }
 
-func (r *SQLBuildRepository) GetBuildByID(ctx context.Context, id int64) (*models.Build, error) {
-	query := `
-		SELECT b.id, ts.project_id, b.test_suite_id, b.build_number, b.duration, b.created_at
-		FROM builds b
-		JOIN test_suites ts ON b.test_suite_id = ts.id
-		WHERE b.id = $1
-	`
-	row := r.db.QueryRowContext(ctx, query, id)
+// buildFilterConditions returns the conditions of a filter, to follow the project
+// condition, and the arguments of the query
+func buildFilterConditions(filter *models.BuildFilter) (string, []interface{}) {
+	args := []interface{}{filter.ProjectID}
+	var conditions strings.Builder
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		fmt.Fprintf(&conditions, " AND "+condition, len(args))
+	}
 
+	if filter.SuiteID != nil {
+		add("b.test_suite_id = $%d", *filter.SuiteID)
+	}
+	if len(filter.Statuses) > 0 {
+		add("b.status = ANY($%d)", pq.Array(filter.Statuses))
+	}
+	if filter.CommitSHA != "" {
+		add("b.commit_sha LIKE $%d || '%%'", filter.CommitSHA)
+	}
+	for _, match := range []struct{ column, value string }{
+		{"branch", filter.Branch}, {"base_branch", filter.BaseBranch}, {"author", filter.Author}, {"trigger", filter.Trigger},
+	} {
+		if match.value != "" {
+			add("b."+match.column+" = $%d", match.value)
+		}
+	}
+	if filter.PRNumber != nil {
+		add("b.pr_number = $%d", *filter.PRNumber)
+	}
+	for name, value := range filter.Properties {
+		args = append(args, name, value)
+		fmt.Fprintf(&conditions, ` AND EXISTS (SELECT 1 FROM build_properties p WHERE p.build_id = b.id
+			AND p.build_suite_run_id IS NULL AND p.name = $%d AND p.value = $%d)`, len(args)-1, len(args))
+	}
+	if len(filter.Tags) > 0 {
+		add("ARRAY(SELECT t.name FROM build_tags t WHERE t.build_id = b.id) @> $%d", pq.Array(filter.Tags))
+	}
+	return conditions.String(), args
+}
+
+// scanBuild reads a row of buildColumns
+func scanBuild(row interface{ Scan(...interface{}) error }) (*models.Build, error) {
 	var build models.Build
-	var sqlSuiteID sql.NullInt64
-	if err := row.Scan(&build.ID, &build.ProjectID, &sqlSuiteID, &build.BuildNumber, &build.Duration, &build.Timestamp); err != nil {
+	var sqlSuiteID, prNumber, expectedShards sql.NullInt64
+	var properties []byte
+	var tags pq.StringArray
+	var missingShards pq.Int64Array
+	err := row.Scan(&build.ID, &build.ProjectID, &sqlSuiteID, &build.BuildNumber, &build.Status, &build.StartedAt, &build.FinishedAt,
+		&build.Duration, &build.Timestamp,
+		&build.CIProvider, &build.CIURL, &build.CommitSHA, &build.Branch,
+		&build.BaseBranch, &prNumber, &build.Author, &build.Trigger, &properties, &tags,
+		&expectedShards, &build.ShardDeadline, &build.FinalizedAt, &build.ReceivedShards, &missingShards)
+	if err != nil {
 		return nil, err
 	}
+	if expectedShards.Valid {
+		expected := int(expectedShards.Int64)
+		build.ExpectedShards = &expected
+	}
+	for _, index := range missingShards {
+		build.MissingShards = append(build.MissingShards, int(index))
+	}
+	if len(tags) > 0 {
+		build.Tags = tags
+	}
 	if sqlSuiteID.Valid {
 		build.SuiteID = sqlSuiteID.Int64
 	}
-
+	if prNumber.Valid {
+		build.PRNumber = &prNumber.Int64
+	}
+	if err := json.Unmarshal(properties, &build.Properties); err != nil {
+		return nil, fmt.Errorf("failed to read build properties: %w", err)
+	}
+	if len(build.Properties) == 0 {
+		build.Properties = nil
+	}
 	return &build, nil
 }
 
+func (r *SQLBuildRepository) GetBuildByID(ctx context.Context, id int64) (*models.Build, error) {
+	query := `
+		SELECT ` + buildColumns + `
+		FROM builds b
+		JOIN test_suites ts ON b.test_suite_id = ts.id
+		WHERE b.id = $1
+	`
+	return scanBuild(r.db.QueryRowContext(ctx, query, id))
+}
+
 func (r *SQLBuildRepository) CreateBuild(ctx context.Context, build *models.Build) (int64, error) {
-	query := "INSERT INTO builds (test_suite_id, build_number, duration, created_at) VALUES ($1, $2, $3, $4) RETURNING id"
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `INSERT INTO builds (test_suite_id, build_number, duration, created_at, ci_provider, ci_url,
+			  commit_sha, branch, base_branch, pr_number, author, trigger, expected_shards, shard_deadline, status,
+			  started_at, finished_at)
+			  VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10, NULLIF($11, ''), NULLIF($12, ''), $13, $14, $15,
+			  CASE WHEN $15 <> 'pending' THEN $4::timestamptz END, CASE WHEN $15 NOT IN ('pending', 'running') THEN $4::timestamptz END)
+			  RETURNING id`
 	var id int64
-	err := r.db.QueryRowContext(ctx, query, build.SuiteID, build.BuildNumber, build.Duration, build.Timestamp).Scan(&id)
+	err = tx.QueryRowContext(ctx, query, build.SuiteID, build.BuildNumber, build.Duration, build.Timestamp,
+		build.CIProvider, build.CIURL, build.CommitSHA, build.Branch, build.BaseBranch, build.PRNumber, build.Author, build.Trigger,
+		build.ExpectedShards, build.ShardDeadline, build.Status,
+	).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
-	return id, nil
+	if err := insertBuildProperties(ctx, tx, id, build.Properties); err != nil {
+		return 0, err
+	}
+	if err := insertBuildTags(ctx, tx, id, build.Tags); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
 }
 
+// UpdateBuild updates a build and replaces its build-wide properties and tags
 func (r *SQLBuildRepository) UpdateBuild(ctx context.Context, build *models.Build) error {
-	query := "UPDATE builds SET test_suite_id = $1, build_number = $2, duration = $3, created_at = $4 WHERE id = $5"
-	_, err := r.db.ExecContext(ctx, query, build.SuiteID, build.BuildNumber, build.Duration, build.Timestamp, build.ID)
-	return err
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `UPDATE builds SET test_suite_id = $1, build_number = $2, duration = $3, created_at = $4,
+			  ci_provider = $5, ci_url = NULLIF($6, ''), commit_sha = NULLIF($7, ''), branch = NULLIF($8, ''),
+			  base_branch = NULLIF($9, ''), pr_number = $10, author = NULLIF($11, ''), trigger = NULLIF($12, '')
+			  WHERE id = $13`
+	_, err = tx.ExecContext(ctx, query, build.SuiteID, build.BuildNumber, build.Duration, build.Timestamp,
+		build.CIProvider, build.CIURL, build.CommitSHA, build.Branch, build.BaseBranch, build.PRNumber, build.Author, build.Trigger,
+		build.ID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM build_properties WHERE build_id = $1 AND build_suite_run_id IS NULL`, build.ID); err != nil {
+		return err
+	}
+	if err := insertBuildProperties(ctx, tx, build.ID, build.Properties); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM build_tags WHERE build_id = $1`, build.ID); err != nil {
+		return err
+	}
+	if err := insertBuildTags(ctx, tx, build.ID, build.Tags); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertBuildProperties records the build-wide properties of a build
+func insertBuildProperties(ctx context.Context, tx *sql.Tx, buildID int64, properties map[string]string) error {
+	for name, value := range properties {
+		query := `INSERT INTO build_properties (build_id, name, value) VALUES ($1, $2, $3)`
+		if _, err := tx.ExecContext(ctx, query, buildID, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertBuildTags tags a build
+func insertBuildTags(ctx context.Context, tx *sql.Tx, buildID int64, tags []string) error {
+	for _, name := range tags {
+		query := `INSERT INTO build_tags (build_id, name) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+		if _, err := tx.ExecContext(ctx, query, buildID, name); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *SQLBuildRepository) DeleteBuild(ctx context.Context, id int64) error {
@@ -95,6 +247,66 @@ func (r *SQLBuildRepository) DeleteBuild(ctx context.Context, id int64) error {
 	return err
 }
 
+// notQuarantined is the condition that the test case of an execution e is not in an active quarantine
+const notQuarantined = `NOT EXISTS (SELECT 1 FROM test_case_quarantines q WHERE q.test_case_id = e.test_case_id
+		AND (q.expires_at IS NULL OR q.expires_at > CURRENT_TIMESTAMP))`
+
+// derivedStatus is the status a build finishes with from the last attempts of its executions;
+// quarantined test cases do not fail a build
+const derivedStatus = `CASE
+		WHEN EXISTS (SELECT 1 FROM build_test_case_executions e WHERE e.build_id = b.id AND NOT e.retried AND e.status = 'failed'
+		             AND ` + notQuarantined + `) THEN 'failed'
+		WHEN EXISTS (SELECT 1 FROM build_test_case_executions e WHERE e.build_id = b.id AND NOT e.retried AND e.status = 'error'
+		             AND ` + notQuarantined + `) THEN 'errored'
+		ELSE 'passed' END`
+
+// finalizeColumns close a sharded build to further shards and finish it, unless it finished before
+const finalizeColumns = `finalized_at = CURRENT_TIMESTAMP,
+		status = CASE WHEN b.status IN ('pending', 'running') THEN ` + derivedStatus + ` ELSE b.status END,
+		finished_at = COALESCE(b.finished_at, CURRENT_TIMESTAMP)`
+
+// FinalizeBuild closes a sharded build to further shards
+func (r *SQLBuildRepository) FinalizeBuild(ctx context.Context, id int64) error {
+	query := `UPDATE builds b SET ` + finalizeColumns + ` WHERE b.id = $1 AND b.finalized_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// FinalizeExpiredBuilds closes the sharded builds whose deadline passed and returns how many it closed
+func (r *SQLBuildRepository) FinalizeExpiredBuilds(ctx context.Context) (int64, error) {
+	query := `UPDATE builds b SET ` + finalizeColumns + `
+			  WHERE b.expected_shards IS NOT NULL AND b.finalized_at IS NULL AND b.shard_deadline <= CURRENT_TIMESTAMP`
+	result, err := r.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// StartBuild moves a pending build to running
+func (r *SQLBuildRepository) StartBuild(ctx context.Context, id int64) (bool, error) {
+	query := `UPDATE builds SET status = 'running', started_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'pending'`
+	return updatedRow(r.db.ExecContext(ctx, query, id))
+}
+
+// FinishBuild moves an unfinished build to a finished status, finalizing it when it is sharded
+func (r *SQLBuildRepository) FinishBuild(ctx context.Context, id int64, status string) (bool, error) {
+	query := `UPDATE builds b SET status = COALESCE(NULLIF($2, ''), ` + derivedStatus + `),
+			  started_at = COALESCE(b.started_at, CURRENT_TIMESTAMP), finished_at = CURRENT_TIMESTAMP,
+			  finalized_at = CASE WHEN b.expected_shards IS NOT NULL THEN COALESCE(b.finalized_at, CURRENT_TIMESTAMP) END
+			  WHERE b.id = $1 AND b.status IN ('pending', 'running')`
+	return updatedRow(r.db.ExecContext(ctx, query, id, status))
+}
+
+// updatedRow reports whether an UPDATE of one row changed it
+func updatedRow(result sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
 func (r *SQLBuildRepository) GetBuildDurationTrends(ctx context.Context, projectID int64, suiteID int64) ([]*models.BuildDurationTrend, error) {
 	query := `
 		SELECT b.build_number, b.duration, b.created_at
@@ -123,20 +335,11 @@ func (r *SQLBuildRepository) GetBuildDurationTrends(ctx context.Context, project
 
 func (r *SQLBuildRepository) GetLatestBuildStatus(ctx context.Context, projectID int64) (string, error) {
 	query := `
-		SELECT status
-		FROM build_test_case_executions
-		WHERE build_id IN (
-			SELECT id
-			FROM builds
-			WHERE test_suite_id IN (
-				SELECT id
-				FROM test_suites
-				WHERE project_id = $1
-			)
-			ORDER BY created_at DESC
-			LIMIT 1
-		)
-		ORDER BY created_at DESC
+		SELECT b.status
+		FROM builds b
+		JOIN test_suites ts ON b.test_suite_id = ts.id
+		WHERE ts.project_id = $1
+		ORDER BY b.created_at DESC, b.id DESC
 		LIMIT 1
 	`
 	row := r.db.QueryRowContext(ctx, query, projectID)
@@ -154,7 +357,7 @@ func (r *SQLBuildRepository) GetLatestBuildStatus(ctx context.Context, projectID
 
 func (r *SQLBuildRepository) GetLatestBuilds(ctx context.Context, projectID int64, limit int) ([]*models.Build, error) {
 	query := `
-		SELECT b.id, ts.project_id, b.test_suite_id, b.build_number, b.duration, b.created_at
+		SELECT ` + buildColumns + `
 		FROM builds b
 		JOIN test_suites ts ON b.test_suite_id = ts.id
 		WHERE ts.project_id = $1
@@ -169,15 +372,11 @@ func (r *SQLBuildRepository) GetLatestBuilds(ctx context.Context, projectID int6
 
 	var builds []*models.Build
 	for rows.Next() {
-		var build models.Build
-		var sqlSuiteID sql.NullInt64
-		if err := rows.Scan(&build.ID, &build.ProjectID, &sqlSuiteID, &build.BuildNumber, &build.Duration, &build.Timestamp); err != nil {
+		build, err := scanBuild(rows)
+		if err != nil {
 			return nil, err
 		}
-		if sqlSuiteID.Valid {
-			build.SuiteID = sqlSuiteID.Int64
-		}
-		builds = append(builds, &build)
+		builds = append(builds, build)
 	}
 
 	return builds, nil
@@ -2,10 +2,16 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/BennyEisner/test-results/internal/build/application"
+	"github.com/BennyEisner/test-results/internal/build/domain"
 	"github.com/BennyEisner/test-results/internal/build/domain/models"
 )
 
@@ -48,52 +54,101 @@ func (h *BuildHandler) GetBuildByID(w http.ResponseWriter, r *http.Request) {
 
 // GetBuilds handles GET /builds
 // @Summary Get builds by project or test suite
-// @Description Retrieve builds by either project_id or suite_id
+// @Description Retrieve the builds of a project, optionally narrowed by test suite, build metadata and tags
 // @Tags builds
 // @Accept json
 // @Produce json
 // @Param project_id query int true "Project ID"
 // @Param suite_id query int false "Test Suite ID"
+// @Param commit_sha query string false "Commit SHA, or its first characters (at least 4)"
+// @Param branch query string false "Branch"
+// @Param base_branch query string false "Branch a pull request merges into"
+// @Param pr_number query int false "Pull request number"
+// @Param author query string false "Author"
+// @Param trigger query string false "Trigger, e.g. push, pull_request, schedule or manual"
+// @Param property query []string false "Build property as name=value; repeat to require several" collectionFormat(multi)
+// @Param tag query []string false "Build tag; repeat to require several" collectionFormat(multi)
+// @Param status query []string false "Build status: pending, running, passed, failed, errored or aborted; repeat to match any of several" collectionFormat(multi)
 // @Success 200 {array} models.Build
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /builds [get]
 func (h *BuildHandler) GetBuilds(w http.ResponseWriter, r *http.Request) {
-	projectIDStr := r.URL.Query().Get("project_id")
-	if projectIDStr == "" {
-		respondWithError(w, http.StatusBadRequest, "missing project_id")
+	filter, err := parseBuildFilter(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	projectID, err := strconv.ParseInt(projectIDStr, 10, 64)
+	ctx := r.Context()
+	builds, err := h.Service.GetBuilds(ctx, filter)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid project_id")
+		respondWithError(w, statusForError(err), err.Error())
 		return
 	}
+	respondWithJSON(w, http.StatusOK, builds)
+}
+
+// parseBuildFilter reads the filter of GET /builds from its query parameters
+func parseBuildFilter(query url.Values) (*models.BuildFilter, error) {
+	if query.Get("project_id") == "" {
+		return nil, fmt.Errorf("missing project_id")
+	}
+	projectID, err := strconv.ParseInt(query.Get("project_id"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid project_id")
+	}
 
-	var suiteID *int64
-	suiteIDStr := r.URL.Query().Get("suite_id")
-	if suiteIDStr != "" {
-		id, err := strconv.ParseInt(suiteIDStr, 10, 64)
-		if err != nil {
-			respondWithError(w, http.StatusBadRequest, "invalid suite_id")
-			return
+	filter := &models.BuildFilter{
+		ProjectID:  projectID,
+		CommitSHA:  query.Get("commit_sha"),
+		Branch:     query.Get("branch"),
+		BaseBranch: query.Get("base_branch"),
+		Author:     query.Get("author"),
+		Trigger:    query.Get("trigger"),
+		Tags:       query["tag"],
+		Statuses:   query["status"],
+	}
+	if filter.SuiteID, err = parseOptionalID(query, "suite_id"); err != nil {
+		return nil, err
+	}
+	if filter.PRNumber, err = parseOptionalID(query, "pr_number"); err != nil {
+		return nil, err
+	}
+	for _, property := range query["property"] {
+		name, value, ok := strings.Cut(property, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid property %q: must be name=value", property)
+		}
+		if filter.Properties == nil {
+			filter.Properties = make(map[string]string)
 		}
-		suiteID = &id
+		filter.Properties[name] = value
 	}
+	return filter, nil
+}
 
-	ctx := r.Context()
-	builds, err := h.Service.GetBuilds(ctx, projectID, suiteID)
+// parseOptionalID parses an optional integer query parameter
+func parseOptionalID(query url.Values, name string) (*int64, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
-		return
+		return nil, fmt.Errorf("invalid %s", name)
 	}
-	respondWithJSON(w, http.StatusOK, builds)
+	return &id, nil
 }
 
 // CreateBuild handles POST /builds
 // @Summary Create a new build
-// @Description Create a new build for a project and test suite
+// @Description Create a new build for a project and test suite, with the commit, branch, pull request,
+// @Description author and trigger it was built for, arbitrary key/value properties and tags.
+// @Description A build is pending unless created with another status.
+// @Description A build with expected_shards is opened for that many CI shards to upload their results to
+// @Description with its ID and their shard index; it is finalized once all shards arrived or, at the latest,
+// @Description shard_timeout seconds (default 3600, at most a day) after it was created.
 // @Tags builds
 // @Accept json
 // @Produce json
@@ -111,7 +166,7 @@ func (h *BuildHandler) CreateBuild(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id, err := h.Service.CreateBuild(ctx, &build)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, statusForError(err), err.Error())
 		return
 	}
 	build.ID = id
@@ -146,7 +201,7 @@ func (h *BuildHandler) UpdateBuild(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	err = h.Service.UpdateBuild(ctx, &build)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, statusForError(err), err.Error())
 		return
 	}
 	respondWithJSON(w, http.StatusOK, build)
@@ -177,6 +232,109 @@ func (h *BuildHandler) DeleteBuild(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// FinalizeBuild handles POST /builds/{id}/finalize
+// @Summary Finalize a sharded build
+// @Description Finalize a sharded build with the shards received so far, before all expected shards arrived or its deadline passed. Shards uploaded afterwards are rejected. Finalizing a finalized build has no effect.
+// @Tags builds
+// @Produce json
+// @Param id path int true "Build ID"
+// @Success 200 {object} models.Build
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /builds/{id}/finalize [post]
+func (h *BuildHandler) FinalizeBuild(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid build ID")
+		return
+	}
+	build, err := h.Service.FinalizeBuild(r.Context(), id)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, build)
+}
+
+// FinishBuildRequest optionally gives the status a build finishes with
+type FinishBuildRequest struct {
+	Status string `json:"status"`
+}
+
+// StartBuild handles POST /builds/{id}/start
+// @Summary Start a build
+// @Description Move a pending build to running
+// @Tags builds
+// @Produce json
+// @Param id path int true "Build ID"
+// @Success 200 {object} models.Build
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /builds/{id}/start [post]
+func (h *BuildHandler) StartBuild(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid build ID")
+		return
+	}
+	build, err := h.Service.StartBuild(r.Context(), id)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, build)
+}
+
+// FinishBuild handles POST /builds/{id}/finish
+// @Summary Finish a build
+// @Description Move a pending or running build to passed, failed, errored or aborted. Without a status in the body, the build failed when any of its executions failed, errored when any errored and passed otherwise. A sharded build accepts no more shards once finished.
+// @Tags builds
+// @Accept json
+// @Produce json
+// @Param id path int true "Build ID"
+// @Param finish body FinishBuildRequest false "Status to finish with"
+// @Success 200 {object} models.Build
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /builds/{id}/finish [post]
+func (h *BuildHandler) FinishBuild(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid build ID")
+		return
+	}
+	var input FinishBuildRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	build, err := h.Service.FinishBuild(r.Context(), id, input.Status)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, build)
+}
+
+// statusForError maps a service error to an HTTP status
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrInvalidBuildData), errors.Is(err, domain.ErrBuildNotSharded):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrInvalidTransition):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrBuildNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // Helper functions for HTTP responses
 func respondWithError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
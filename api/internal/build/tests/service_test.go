@@ -0,0 +1,450 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/BennyEisner/test-results/internal/build/application"
+	"github.com/BennyEisner/test-results/internal/build/domain"
+	"github.com/BennyEisner/test-results/internal/build/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockBuildRepository is a mock implementation of BuildRepository
+type MockBuildRepository struct {
+	mock.Mock
+}
+
+func (m *MockBuildRepository) GetBuilds(ctx context.Context, filter *models.BuildFilter) ([]*models.Build, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Build), args.Error(1)
+}
+
+func (m *MockBuildRepository) GetBuildByID(ctx context.Context, id int64) (*models.Build, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Build), args.Error(1)
+}
+
+func (m *MockBuildRepository) CreateBuild(ctx context.Context, build *models.Build) (int64, error) {
+	args := m.Called(ctx, build)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockBuildRepository) UpdateBuild(ctx context.Context, build *models.Build) error {
+	args := m.Called(ctx, build)
+	return args.Error(0)
+}
+
+func (m *MockBuildRepository) DeleteBuild(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockBuildRepository) GetBuildDurationTrends(ctx context.Context, projectID int64, suiteID int64) ([]*models.BuildDurationTrend, error) {
+	args := m.Called(ctx, projectID, suiteID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.BuildDurationTrend), args.Error(1)
+}
+
+func (m *MockBuildRepository) GetLatestBuildStatus(ctx context.Context, projectID int64) (string, error) {
+	args := m.Called(ctx, projectID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockBuildRepository) GetLatestBuilds(ctx context.Context, projectID int64, limit int) ([]*models.Build, error) {
+	args := m.Called(ctx, projectID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Build), args.Error(1)
+}
+
+func (m *MockBuildRepository) FinalizeBuild(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockBuildRepository) FinalizeExpiredBuilds(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockBuildRepository) StartBuild(ctx context.Context, id int64) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBuildRepository) FinishBuild(ctx context.Context, id int64, status string) (bool, error) {
+	args := m.Called(ctx, id, status)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestBuildService_CreateBuild(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("metadata is normalized", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+		pr := int64(42)
+		build := &models.Build{SuiteID: 2, BuildNumber: "17", BuildMetadata: models.BuildMetadata{
+			CommitSHA:  " 4F2A9C1E ",
+			Branch:     " feature/login ",
+			BaseBranch: "main",
+			PRNumber:   &pr,
+			Trigger:    "Pull_Request",
+			Properties: map[string]string{"environment": "staging"},
+			Tags:       []string{" smoke", "@smoke", "nightly"},
+		}}
+
+		mockRepo.On("CreateBuild", ctx, build).Return(int64(9), nil).Once()
+
+		id, err := service.CreateBuild(ctx, build)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(9), id)
+		assert.Equal(t, "4f2a9c1e", build.CommitSHA)
+		assert.Equal(t, "feature/login", build.Branch)
+		assert.Equal(t, "pull_request", build.Trigger)
+		assert.Equal(t, "unknown", build.CIProvider)
+		assert.Equal(t, []string{"smoke", "nightly"}, build.Tags)
+		assert.Equal(t, models.BuildPending, build.Status)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("given status is kept", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+		build := &models.Build{SuiteID: 2, Status: " Running "}
+
+		mockRepo.On("CreateBuild", ctx, build).Return(int64(9), nil).Once()
+
+		_, err := service.CreateBuild(ctx, build)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.BuildRunning, build.Status)
+	})
+
+	t.Run("unknown status", func(t *testing.T) {
+		service := application.NewBuildService(new(MockBuildRepository))
+
+		_, err := service.CreateBuild(ctx, &models.Build{SuiteID: 2, Status: "green"})
+
+		assert.True(t, stderrors.Is(err, domain.ErrInvalidBuildData))
+	})
+
+	t.Run("invalid metadata", func(t *testing.T) {
+		zero := int64(0)
+		for name, metadata := range map[string]models.BuildMetadata{
+			"commit":   {CommitSHA: "not-a-sha"},
+			"pr":       {PRNumber: &zero},
+			"property": {Properties: map[string]string{" ": "x"}},
+			"tag":      {Tags: []string{"smoke,api"}},
+		} {
+			service := application.NewBuildService(new(MockBuildRepository))
+
+			_, err := service.CreateBuild(ctx, &models.Build{SuiteID: 2, BuildMetadata: metadata})
+
+			assert.True(t, stderrors.Is(err, domain.ErrInvalidBuildData), name)
+		}
+	})
+
+	t.Run("sharded build gets a deadline", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+		shards := 20
+		build := &models.Build{SuiteID: 2, Sharding: models.Sharding{ExpectedShards: &shards}}
+
+		mockRepo.On("CreateBuild", ctx, build).Return(int64(9), nil).Once()
+
+		before := time.Now()
+		_, err := service.CreateBuild(ctx, build)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3600, build.ShardTimeout)
+		if assert.NotNil(t, build.ShardDeadline) {
+			assert.WithinDuration(t, before.Add(time.Hour), *build.ShardDeadline, time.Minute)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid sharding", func(t *testing.T) {
+		zero, many, one := 0, 1001, 1
+		for name, sharding := range map[string]models.Sharding{
+			"no shards":        {ExpectedShards: &zero},
+			"too many shards":  {ExpectedShards: &many},
+			"negative timeout": {ExpectedShards: &one, ShardTimeout: -1},
+			"long timeout":     {ExpectedShards: &one, ShardTimeout: 7 * 24 * 60 * 60},
+		} {
+			service := application.NewBuildService(new(MockBuildRepository))
+
+			_, err := service.CreateBuild(ctx, &models.Build{SuiteID: 2, Sharding: sharding})
+
+			assert.True(t, stderrors.Is(err, domain.ErrInvalidBuildData), name)
+		}
+	})
+}
+
+func TestBuildService_FinalizeBuild(t *testing.T) {
+	ctx := context.Background()
+	shards := 3
+
+	t.Run("open build is finalized", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+		now := time.Now()
+		open := &models.Build{ID: 5, Sharding: models.Sharding{ExpectedShards: &shards, ReceivedShards: 2, MissingShards: []int{1}}}
+		finalized := &models.Build{ID: 5, Sharding: models.Sharding{ExpectedShards: &shards, ReceivedShards: 2, MissingShards: []int{1}, FinalizedAt: &now}}
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(open, nil).Once()
+		mockRepo.On("FinalizeBuild", ctx, int64(5)).Return(nil).Once()
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(finalized, nil).Once()
+
+		result, err := service.FinalizeBuild(ctx, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, finalized, result)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("finalized build is left as is", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+		now := time.Now()
+		build := &models.Build{ID: 5, Sharding: models.Sharding{ExpectedShards: &shards, FinalizedAt: &now}}
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(build, nil).Once()
+
+		result, err := service.FinalizeBuild(ctx, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, build, result)
+		mockRepo.AssertNotCalled(t, "FinalizeBuild", ctx, int64(5))
+	})
+
+	t.Run("unsharded build", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(&models.Build{ID: 5}, nil).Once()
+
+		_, err := service.FinalizeBuild(ctx, 5)
+
+		assert.True(t, stderrors.Is(err, domain.ErrBuildNotSharded))
+	})
+
+	t.Run("build not found", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(nil, sql.ErrNoRows).Once()
+
+		_, err := service.FinalizeBuild(ctx, 5)
+
+		assert.True(t, stderrors.Is(err, domain.ErrBuildNotFound))
+	})
+
+	t.Run("repository error is not a missing build", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(nil, stderrors.New("db down")).Once()
+
+		_, err := service.FinalizeBuild(ctx, 5)
+
+		assert.Error(t, err)
+		assert.False(t, stderrors.Is(err, domain.ErrBuildNotFound))
+	})
+}
+
+func TestBuildService_GetBuilds(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("filter is normalized", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+		filter := &models.BuildFilter{ProjectID: 1, CommitSHA: "ABCD", Branch: " main ", Trigger: "PUSH", Tags: []string{"@smoke "}, Statuses: []string{"FAILED"}}
+		builds := []*models.Build{{ID: 3, BuildMetadata: models.BuildMetadata{CommitSHA: "abcdef0", Branch: "main"}}}
+
+		mockRepo.On("GetBuilds", ctx, &models.BuildFilter{ProjectID: 1, CommitSHA: "abcd", Branch: "main", Trigger: "push", Tags: []string{"smoke"}, Statuses: []string{"failed"}}).Return(builds, nil).Once()
+
+		result, err := service.GetBuilds(ctx, filter)
+
+		assert.NoError(t, err)
+		assert.Equal(t, builds, result)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("unknown status", func(t *testing.T) {
+		service := application.NewBuildService(new(MockBuildRepository))
+
+		_, err := service.GetBuilds(ctx, &models.BuildFilter{ProjectID: 1, Statuses: []string{"passed", "green"}})
+
+		assert.True(t, stderrors.Is(err, domain.ErrInvalidBuildData))
+	})
+
+	t.Run("commit prefix too short", func(t *testing.T) {
+		service := application.NewBuildService(new(MockBuildRepository))
+
+		_, err := service.GetBuilds(ctx, &models.BuildFilter{ProjectID: 1, CommitSHA: "ab"})
+
+		assert.True(t, stderrors.Is(err, domain.ErrInvalidBuildData))
+	})
+}
+
+func TestBuildService_StartBuild(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("pending build starts", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+		running := &models.Build{ID: 5, Status: models.BuildRunning}
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(&models.Build{ID: 5, Status: models.BuildPending}, nil).Once()
+		mockRepo.On("StartBuild", ctx, int64(5)).Return(true, nil).Once()
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(running, nil).Once()
+
+		result, err := service.StartBuild(ctx, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, running, result)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("running build cannot start again", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(&models.Build{ID: 5, Status: models.BuildRunning}, nil).Once()
+
+		_, err := service.StartBuild(ctx, 5)
+
+		assert.True(t, stderrors.Is(err, domain.ErrInvalidTransition))
+		mockRepo.AssertNotCalled(t, "StartBuild", ctx, int64(5))
+	})
+
+	t.Run("build started meanwhile", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(&models.Build{ID: 5, Status: models.BuildPending}, nil).Once()
+		mockRepo.On("StartBuild", ctx, int64(5)).Return(false, nil).Once()
+
+		_, err := service.StartBuild(ctx, 5)
+
+		assert.True(t, stderrors.Is(err, domain.ErrInvalidTransition))
+	})
+
+	t.Run("build not found", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(nil, sql.ErrNoRows).Once()
+
+		_, err := service.StartBuild(ctx, 5)
+
+		assert.True(t, stderrors.Is(err, domain.ErrBuildNotFound))
+	})
+
+	t.Run("repository error is not a missing build", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(nil, stderrors.New("db down")).Once()
+
+		_, err := service.StartBuild(ctx, 5)
+
+		assert.Error(t, err)
+		assert.False(t, stderrors.Is(err, domain.ErrBuildNotFound))
+		mockRepo.AssertNotCalled(t, "StartBuild", ctx, int64(5))
+	})
+}
+
+func TestBuildService_FinishBuild(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("status is derived when not given", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+		failed := &models.Build{ID: 5, Status: models.BuildFailed}
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(&models.Build{ID: 5, Status: models.BuildRunning}, nil).Once()
+		mockRepo.On("FinishBuild", ctx, int64(5), "").Return(true, nil).Once()
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(failed, nil).Once()
+
+		result, err := service.FinishBuild(ctx, 5, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, failed, result)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("given status", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(&models.Build{ID: 5, Status: models.BuildPending}, nil).Once()
+		mockRepo.On("FinishBuild", ctx, int64(5), models.BuildAborted).Return(true, nil).Once()
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(&models.Build{ID: 5, Status: models.BuildAborted}, nil).Once()
+
+		_, err := service.FinishBuild(ctx, 5, "Aborted")
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("unfinished status", func(t *testing.T) {
+		service := application.NewBuildService(new(MockBuildRepository))
+
+		_, err := service.FinishBuild(ctx, 5, models.BuildRunning)
+
+		assert.True(t, stderrors.Is(err, domain.ErrInvalidBuildData))
+	})
+
+	t.Run("finished build cannot finish again", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(&models.Build{ID: 5, Status: models.BuildPassed}, nil).Once()
+
+		_, err := service.FinishBuild(ctx, 5, "")
+
+		assert.True(t, stderrors.Is(err, domain.ErrInvalidTransition))
+		mockRepo.AssertNotCalled(t, "FinishBuild", ctx, int64(5), "")
+	})
+
+	t.Run("build not found", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(nil, sql.ErrNoRows).Once()
+
+		_, err := service.FinishBuild(ctx, 5, "")
+
+		assert.True(t, stderrors.Is(err, domain.ErrBuildNotFound))
+	})
+
+	t.Run("repository error is not a missing build", func(t *testing.T) {
+		mockRepo := new(MockBuildRepository)
+		service := application.NewBuildService(mockRepo)
+
+		mockRepo.On("GetBuildByID", ctx, int64(5)).Return(nil, stderrors.New("db down")).Once()
+
+		_, err := service.FinishBuild(ctx, 5, "")
+
+		assert.Error(t, err)
+		assert.False(t, stderrors.Is(err, domain.ErrBuildNotFound))
+		mockRepo.AssertNotCalled(t, "FinishBuild", ctx, int64(5), "")
+	})
+}
@@ -0,0 +1,101 @@
+package application
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BennyEisner/test-results/internal/build_test_case_execution/domain/models"
+)
+
+const (
+	ctrfReportFormat = "CTRF"
+	ctrfSpecVersion  = "0.0.0"
+	ctrfToolName     = "test-results"
+)
+
+// newCTRFReport builds the CTRF report of a build started at startedAt
+func newCTRFReport(startedAt time.Time, executions []*models.BuildExecutionDetail) *models.CTRFReport {
+	report := &models.CTRFReport{
+		ReportFormat: ctrfReportFormat,
+		SpecVersion:  ctrfSpecVersion,
+		Results: models.CTRFResults{
+			Tool:  models.CTRFTool{Name: ctrfToolName},
+			Tests: make([]models.CTRFTest, 0, len(executions)),
+		},
+	}
+
+	var duration int64
+	for _, execution := range executions {
+		test := toCTRFTest(execution)
+		report.Results.Tests = append(report.Results.Tests, test)
+		duration += test.Duration
+		countCTRFStatus(&report.Results.Summary, test.Status)
+	}
+
+	if len(executions) > 0 {
+		report.Results.Extra = map[string]string{"buildId": strconv.FormatInt(executions[0].BuildID, 10)}
+	}
+	report.Results.Summary.Tests = len(executions)
+	report.Results.Summary.Start = startedAt.UnixMilli()
+	report.Results.Summary.Stop = report.Results.Summary.Start + duration
+	return report
+}
+
+func toCTRFTest(execution *models.BuildExecutionDetail) models.CTRFTest {
+	test := models.CTRFTest{
+		Name:      execution.TestCaseName,
+		Status:    ctrfStatus(execution.Status),
+		Duration:  int64(execution.ExecutionTime * 1000),
+		Suite:     execution.ClassName,
+		RawStatus: execution.Status,
+		Message:   execution.SkipMessage,
+		Stdout:    splitLines(execution.SystemOut),
+		Stderr:    splitLines(execution.SystemErr),
+		Flaky:     execution.Status == models.StatusPassedOnRetry,
+	}
+	if execution.Attempt > 1 {
+		test.Retries = execution.Attempt - 1
+	}
+	if execution.Failure != nil {
+		test.Message = execution.Failure.Message
+		test.Trace = execution.Failure.Details
+	}
+	return test
+}
+
+// ctrfStatus maps an execution status onto the CTRF statuses
+func ctrfStatus(status string) string {
+	switch status {
+	case "passed", "failed", "skipped", "pending":
+		return status
+	case models.StatusPassedOnRetry:
+		return "passed"
+	case "error":
+		return "failed"
+	default:
+		return "other"
+	}
+}
+
+func countCTRFStatus(summary *models.CTRFSummary, status string) {
+	switch status {
+	case "passed":
+		summary.Passed++
+	case "failed":
+		summary.Failed++
+	case "skipped":
+		summary.Skipped++
+	case "pending":
+		summary.Pending++
+	default:
+		summary.Other++
+	}
+}
+
+func splitLines(output string) []string {
+	if output == "" {
+		return nil
+	}
+	return strings.Split(output, "\n")
+}
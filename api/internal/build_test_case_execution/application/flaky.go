@@ -0,0 +1,99 @@
+package application
+
+import (
+	"sort"
+
+	"github.com/BennyEisner/test-results/internal/build_test_case_execution/domain/models"
+)
+
+// Defaults of flaky test detection: the number of recent builds of a project looked at
+// and the number of flaky tests returned
+const (
+	DefaultFlakyWindow = 50
+	DefaultFlakyLimit  = 20
+)
+
+// sameCommitWeight is how much heavier a flip on the same commit weighs than a flip
+// between commits, which a code change may explain
+const sameCommitWeight = 3.0
+
+// failing reports whether a status counts as a failure when looking for flips
+func failing(status string) bool {
+	return status == "failed" || status == "error"
+}
+
+// detectFlakyTests scores the runs of test cases, ordered by test case and then by build,
+// and returns the test cases that flipped, the flakiest first
+func detectFlakyTests(runs []*models.TestRun) []*models.FlakyTest {
+	flaky := []*models.FlakyTest{}
+	for start := 0; start < len(runs); {
+		end := start + 1
+		for end < len(runs) && runs[end].TestCaseID == runs[start].TestCaseID {
+			end++
+		}
+		if test := scoreRuns(runs[start:end]); test != nil {
+			flaky = append(flaky, test)
+		}
+		start = end
+	}
+
+	sort.SliceStable(flaky, func(i, j int) bool {
+		if flaky[i].Score != flaky[j].Score {
+			return flaky[i].Score > flaky[j].Score
+		}
+		return flaky[i].Flips > flaky[j].Flips
+	})
+	return flaky
+}
+
+// scoreRuns counts the flips of a test case over its runs and scores them, or returns nil
+// where it never flipped. Every run after the first is a transition that flips where its
+// outcome differs from the run before; a pass on retry is a further transition within its
+// build that flipped on the same commit.
+func scoreRuns(runs []*models.TestRun) *models.FlakyTest {
+	test := &models.FlakyTest{
+		TestCaseID:   runs[0].TestCaseID,
+		TestCaseName: runs[0].TestCaseName,
+		ClassName:    runs[0].ClassName,
+		Runs:         len(runs),
+		Evidence:     []*models.FlakyEvidence{},
+	}
+
+	transitions := len(runs) - 1
+	for i, run := range runs {
+		flips, sameCommit := 0, 0
+		if i > 0 && failing(run.Status) != failing(runs[i-1].Status) {
+			flips++
+			if run.CommitSHA != "" && run.CommitSHA == runs[i-1].CommitSHA {
+				sameCommit++
+			}
+		}
+		if run.Status == models.StatusPassedOnRetry {
+			transitions++
+			flips++
+			sameCommit++
+		}
+		if flips == 0 {
+			continue
+		}
+
+		test.Flips += flips
+		test.SameCommitFlips += sameCommit
+		test.Evidence = append(test.Evidence, &models.FlakyEvidence{
+			BuildID:     run.BuildID,
+			BuildNumber: run.BuildNumber,
+			CommitSHA:   run.CommitSHA,
+			Status:      run.Status,
+			SameCommit:  sameCommit > 0,
+			CreatedAt:   run.CreatedAt,
+		})
+	}
+	if test.Flips == 0 {
+		return nil
+	}
+
+	weighted := float64(test.Flips-test.SameCommitFlips) + sameCommitWeight*float64(test.SameCommitFlips)
+	test.FlipRate = float64(test.Flips) / float64(transitions)
+	test.Score = weighted / (sameCommitWeight * float64(transitions))
+	return test
+}
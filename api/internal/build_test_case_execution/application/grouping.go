@@ -0,0 +1,60 @@
+package application
+
+import (
+	"strings"
+
+	"github.com/BennyEisner/test-results/internal/build_test_case_execution/domain/models"
+)
+
+// statusSeverity orders the statuses of executions from best to worst; the status of a
+// group is the worst status among its parameter sets
+var statusSeverity = map[string]int{
+	"skipped":                  1,
+	"passed":                   2,
+	models.StatusPassedOnRetry: 3,
+	"failed":                   4,
+	"error":                    5,
+}
+
+// testName is the name of the test an execution belongs to, without its parameters
+func testName(execution *models.BuildExecutionDetail) string {
+	return strings.TrimSuffix(execution.TestCaseName, execution.Params)
+}
+
+// groupExecutions aggregates executions by test, keeping the order in which the tests
+// first ran
+func groupExecutions(executions []*models.BuildExecutionDetail) []*models.ExecutionGroup {
+	type groupKey struct{ className, testName string }
+
+	groups := []*models.ExecutionGroup{}
+	byKey := make(map[groupKey]*models.ExecutionGroup)
+	for _, execution := range executions {
+		key := groupKey{execution.ClassName, testName(execution)}
+		group, ok := byKey[key]
+		if !ok {
+			group = &models.ExecutionGroup{TestName: key.testName, ClassName: key.className, Statuses: make(map[string]int)}
+			byKey[key] = group
+			groups = append(groups, group)
+		}
+
+		group.ParamSets++
+		group.ExecutionTime += execution.ExecutionTime
+		group.Statuses[execution.Status]++
+		if statusSeverity[execution.Status] > statusSeverity[group.Status] {
+			group.Status = execution.Status
+		}
+	}
+	return groups
+}
+
+// filterExecutions returns the executions of a test, all parameter sets included. An
+// empty class name matches the test in any class.
+func filterExecutions(executions []*models.BuildExecutionDetail, className, name string) []*models.BuildExecutionDetail {
+	filtered := []*models.BuildExecutionDetail{}
+	for _, execution := range executions {
+		if testName(execution) == name && (className == "" || execution.ClassName == className) {
+			filtered = append(filtered, execution)
+		}
+	}
+	return filtered
+}
@@ -44,6 +44,120 @@ func (s *BuildTestCaseExecutionService) GetExecutionsByBuildID(ctx context.Conte
 	return executions, nil
 }
 
+// GetExecutionGroups returns the executions of a build aggregated by test, folding the
+// parameter sets of parameterized tests into one group each
+func (s *BuildTestCaseExecutionService) GetExecutionGroups(ctx context.Context, buildID int64) ([]*models.ExecutionGroup, error) {
+	executions, err := s.GetExecutionsByBuildID(ctx, buildID)
+	if err != nil {
+		return nil, err
+	}
+	return groupExecutions(executions), nil
+}
+
+// GetTestExecutions returns the executions of a test in a build, one per parameter set,
+// drilling down into a group of GetExecutionGroups
+func (s *BuildTestCaseExecutionService) GetTestExecutions(ctx context.Context, buildID int64, className, testName string) ([]*models.BuildExecutionDetail, error) {
+	if testName == "" {
+		return nil, domain.ErrInvalidExecutionData
+	}
+	executions, err := s.GetExecutionsByBuildID(ctx, buildID)
+	if err != nil {
+		return nil, err
+	}
+	return filterExecutions(executions, className, testName), nil
+}
+
+// GetCTRFReport renders the executions of a build, with their failures, as a CTRF report
+func (s *BuildTestCaseExecutionService) GetCTRFReport(ctx context.Context, buildID int64) (*models.CTRFReport, error) {
+	if buildID <= 0 {
+		return nil, domain.ErrInvalidBuildData
+	}
+
+	createdAt, err := s.repo.GetBuildCreatedAt(ctx, buildID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get build %d: %w", buildID, err)
+	}
+	if createdAt == nil {
+		return nil, domain.ErrBuildNotFound
+	}
+
+	executions, err := s.repo.GetAllByBuildID(ctx, buildID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get executions by build ID %d: %w", buildID, err)
+	}
+	logs, err := s.repo.GetLogsByBuildID(ctx, buildID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get execution logs of build %d: %w", buildID, err)
+	}
+	addLogs(executions, logs)
+
+	return newCTRFReport(*createdAt, executions), nil
+}
+
+// GetExecutionLog returns the standard output or error of an execution; an execution
+// that reported none has an empty log
+func (s *BuildTestCaseExecutionService) GetExecutionLog(ctx context.Context, id int64, stream string) (*models.ExecutionLog, error) {
+	if id <= 0 {
+		return nil, domain.ErrInvalidExecutionData
+	}
+	if stream != models.LogStdout && stream != models.LogStderr {
+		return nil, domain.ErrInvalidLogStream
+	}
+
+	if _, err := s.GetExecutionByID(ctx, id); err != nil {
+		return nil, err
+	}
+	log, err := s.repo.GetLog(ctx, id, stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s of execution %d: %w", stream, id, err)
+	}
+	if log == nil {
+		return &models.ExecutionLog{ExecutionID: id, Stream: stream}, nil
+	}
+	return log, nil
+}
+
+// GetFlakyTests ranks the tests of a project that flipped between passed and failed over
+// its last window builds, returning the limit flakiest, or all of them for a limit of 0
+func (s *BuildTestCaseExecutionService) GetFlakyTests(ctx context.Context, projectID int64, window, limit int) ([]*models.FlakyTest, error) {
+	if projectID <= 0 {
+		return nil, domain.ErrInvalidExecutionData
+	}
+	if window <= 0 || limit < 0 {
+		return nil, domain.ErrInvalidFlakyQuery
+	}
+
+	runs, err := s.repo.GetRecentRuns(ctx, projectID, window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent runs of project %d: %w", projectID, err)
+	}
+	flaky := detectFlakyTests(runs)
+	if limit > 0 && len(flaky) > limit {
+		flaky = flaky[:limit]
+	}
+	return flaky, nil
+}
+
+// addLogs sets the standard output and error of executions from their logs
+func addLogs(executions []*models.BuildExecutionDetail, logs []*models.ExecutionLog) {
+	byID := make(map[int64]*models.BuildExecutionDetail, len(executions))
+	for _, execution := range executions {
+		byID[execution.ExecutionID] = execution
+	}
+	for _, log := range logs {
+		execution, ok := byID[log.ExecutionID]
+		if !ok {
+			continue
+		}
+		switch log.Stream {
+		case models.LogStdout:
+			execution.SystemOut = string(log.Content)
+		case models.LogStderr:
+			execution.SystemErr = string(log.Content)
+		}
+	}
+}
+
 func (s *BuildTestCaseExecutionService) CreateExecution(ctx context.Context, buildID int64, input *models.BuildExecutionInput) (*models.BuildTestCaseExecution, error) {
 	if buildID <= 0 || input == nil {
 		return nil, domain.ErrInvalidExecutionData
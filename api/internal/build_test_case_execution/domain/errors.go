@@ -6,4 +6,7 @@ var (
 	ErrInvalidExecutionData   = errors.New("invalid execution data")
 	ErrBuildExecutionNotFound = errors.New("build execution not found")
 	ErrInvalidBuildData       = errors.New("invalid build data")
+	ErrBuildNotFound          = errors.New("build not found")
+	ErrInvalidLogStream       = errors.New("invalid log stream: must be stdout or stderr")
+	ErrInvalidFlakyQuery      = errors.New("invalid flaky test query: window must be positive and limit not negative")
 )
@@ -10,8 +10,17 @@ type BuildTestCaseExecution struct {
 	Status        string    `json:"status"`
 	ExecutionTime float64   `json:"execution_time"`
 	CreatedAt     time.Time `json:"created_at"`
+	// Attempt counts the runs of the test case in the build; Retried is set on the
+	// attempts the test case was retried after
+	Attempt int  `json:"attempt"`
+	Retried bool `json:"retried"`
 }
 
+// StatusPassedOnRetry is the status of an execution whose last attempt passed after the
+// test case was retried. Its last attempt is stored as passed; the status is derived
+// so that metrics count it apart from clean passes.
+const StatusPassedOnRetry = "passed_on_retry"
+
 // BuildExecution represents a test case execution within a build
 type BuildExecution struct {
 	ID            int64     `json:"id"`
@@ -32,7 +41,98 @@ type BuildExecutionDetail struct {
 	Status        string    `json:"status"`
 	ExecutionTime float64   `json:"execution_time"`
 	CreatedAt     time.Time `json:"created_at"`
-	Failure       *Failure  `json:"failure,omitempty"`
+	SkipMessage   string    `json:"skip_message,omitempty"`
+	// Params are the parameters at the end of the name of a parameterized test case
+	Params string `json:"params,omitempty"`
+	// Quarantined is set where the test case is quarantined; it then counts in neither
+	// pass rates nor the status of the build
+	Quarantined bool `json:"quarantined"`
+	// Attempt is the number of the last attempt; Retries are the attempts before it
+	Attempt int                 `json:"attempt"`
+	Retries []*ExecutionAttempt `json:"retries,omitempty"`
+	// StdoutSize and StderrSize are the sizes of the logs served by GET /executions/{id}/logs
+	StdoutSize int64    `json:"stdout_size,omitempty"`
+	StderrSize int64    `json:"stderr_size,omitempty"`
+	SystemOut  string   `json:"-"`
+	SystemErr  string   `json:"-"`
+	Failure    *Failure `json:"failure,omitempty"`
+}
+
+// ExecutionGroup aggregates the executions of a test in a build over its parameter sets.
+// Its status is the worst status among them; a test without parameters is a group of one.
+type ExecutionGroup struct {
+	TestName      string         `json:"test_name"`
+	ClassName     string         `json:"class_name"`
+	Status        string         `json:"status"`
+	ExecutionTime float64        `json:"execution_time"`
+	ParamSets     int            `json:"param_sets"`
+	Statuses      map[string]int `json:"statuses"`
+}
+
+// TestRun is the final attempt of a test case in a build, the input of flaky test detection
+type TestRun struct {
+	TestCaseID   int64
+	TestCaseName string
+	ClassName    string
+	BuildID      int64
+	BuildNumber  string
+	CommitSHA    string
+	Status       string
+	CreatedAt    time.Time
+}
+
+// FlakyTest is a test case whose outcome flipped between passed and failed over the
+// builds of a window. FlipRate is the share of its transitions that flipped, counting a
+// pass on retry as a flip within its build; Score weighs flips on the same commit
+// heavier, as no code change explains them, and ranges from 0 to 1.
+type FlakyTest struct {
+	TestCaseID      int64            `json:"test_case_id"`
+	TestCaseName    string           `json:"test_case_name"`
+	ClassName       string           `json:"class_name"`
+	Runs            int              `json:"runs"`
+	Flips           int              `json:"flips"`
+	SameCommitFlips int              `json:"same_commit_flips"`
+	FlipRate        float64          `json:"flip_rate"`
+	Score           float64          `json:"score"`
+	Evidence        []*FlakyEvidence `json:"evidence"`
+}
+
+// FlakyEvidence is a build in which a flaky test flipped: its status differs from the
+// run before, or it passed on retry. SameCommit is set where the run before was of the
+// same commit, or for a pass on retry.
+type FlakyEvidence struct {
+	BuildID     int64     `json:"build_id"`
+	BuildNumber string    `json:"build_number"`
+	CommitSHA   string    `json:"commit_sha,omitempty"`
+	Status      string    `json:"status"`
+	SameCommit  bool      `json:"same_commit"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ExecutionAttempt is an attempt of an execution the test case was retried after
+type ExecutionAttempt struct {
+	ExecutionID   int64    `json:"execution_id"`
+	Attempt       int      `json:"attempt"`
+	Status        string   `json:"status"`
+	ExecutionTime float64  `json:"execution_time"`
+	Failure       *Failure `json:"failure,omitempty"`
+}
+
+// Log streams of an execution
+const (
+	LogStdout = "stdout"
+	LogStderr = "stderr"
+)
+
+// ExecutionLog is the standard output or error of an execution. Logs larger than the
+// configured size were cut when imported, keeping their beginning and end.
+type ExecutionLog struct {
+	ExecutionID  int64  `json:"execution_id"`
+	Stream       string `json:"stream"`
+	Content      []byte `json:"-"`
+	Size         int64  `json:"size"`
+	OriginalSize int64  `json:"original_size"`
+	Truncated    bool   `json:"truncated"`
 }
 
 // BuildExecutionInput represents input for creating a build execution
@@ -51,3 +151,50 @@ type Failure struct {
 	Type    string `json:"type,omitempty"`
 	Details string `json:"details,omitempty"`
 }
+
+// CTRFReport is a build rendered in the Common Test Report Format (https://ctrf.io)
+type CTRFReport struct {
+	ReportFormat string      `json:"reportFormat"`
+	SpecVersion  string      `json:"specVersion"`
+	Results      CTRFResults `json:"results"`
+}
+
+// CTRFResults holds the tool, summary and tests of a CTRF report
+type CTRFResults struct {
+	Tool    CTRFTool          `json:"tool"`
+	Summary CTRFSummary       `json:"summary"`
+	Tests   []CTRFTest        `json:"tests"`
+	Extra   map[string]string `json:"extra,omitempty"`
+}
+
+// CTRFTool names the tool that produced a CTRF report
+type CTRFTool struct {
+	Name string `json:"name"`
+}
+
+// CTRFSummary counts the tests of a CTRF report per status; start and stop are in epoch milliseconds
+type CTRFSummary struct {
+	Tests   int   `json:"tests"`
+	Passed  int   `json:"passed"`
+	Failed  int   `json:"failed"`
+	Pending int   `json:"pending"`
+	Skipped int   `json:"skipped"`
+	Other   int   `json:"other"`
+	Start   int64 `json:"start"`
+	Stop    int64 `json:"stop"`
+}
+
+// CTRFTest is a single test of a CTRF report; duration is in milliseconds
+type CTRFTest struct {
+	Name      string   `json:"name"`
+	Status    string   `json:"status"`
+	Duration  int64    `json:"duration"`
+	Suite     string   `json:"suite,omitempty"`
+	Message   string   `json:"message,omitempty"`
+	Trace     string   `json:"trace,omitempty"`
+	RawStatus string   `json:"rawStatus,omitempty"`
+	Stdout    []string `json:"stdout,omitempty"`
+	Stderr    []string `json:"stderr,omitempty"`
+	Retries   int      `json:"retries,omitempty"`
+	Flaky     bool     `json:"flaky,omitempty"`
+}
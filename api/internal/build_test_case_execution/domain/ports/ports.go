@@ -2,6 +2,7 @@ package ports
 
 import (
 	"context"
+	"time"
 
 	"github.com/BennyEisner/test-results/internal/build_test_case_execution/domain/models"
 	dashboardModels "github.com/BennyEisner/test-results/internal/dashboard/domain/models"
@@ -11,22 +12,36 @@ import (
 type BuildTestCaseExecutionRepository interface {
 	GetByID(ctx context.Context, id int64) (*models.BuildTestCaseExecution, error)
 	GetAllByBuildID(ctx context.Context, buildID int64) ([]*models.BuildExecutionDetail, error)
+	GetBuildCreatedAt(ctx context.Context, buildID int64) (*time.Time, error)
+	GetLog(ctx context.Context, executionID int64, stream string) (*models.ExecutionLog, error)
+	GetLogsByBuildID(ctx context.Context, buildID int64) ([]*models.ExecutionLog, error)
 	Create(ctx context.Context, execution *models.BuildTestCaseExecution) error
 	Update(ctx context.Context, id int64, execution *models.BuildTestCaseExecution) (*models.BuildTestCaseExecution, error)
 	Delete(ctx context.Context, id int64) error
-	GetMetric(ctx context.Context, projectID int64, metricType string) (*dashboardModels.MetricCardDTO, error)
-	GetChartData(ctx context.Context, projectID int64, chartType string, suiteID *int64, buildID *int64, limit *int) (*dashboardModels.DataChartDTO, error)
+	GetRecentRuns(ctx context.Context, projectID int64, window int) ([]*models.TestRun, error)
+	GetMetric(ctx context.Context, projectID int64, metricType string, includeQuarantined bool) (*dashboardModels.MetricCardDTO, error)
+	GetChartData(ctx context.Context, projectID int64, chartType string, suiteID *int64, buildID *int64, limit *int, tag, test string, includeQuarantined bool) (*dashboardModels.DataChartDTO, error)
 }
 
 // BuildTestCaseExecutionService defines the interface for build test case execution business logic
 type BuildTestCaseExecutionService interface {
 	GetExecutionByID(ctx context.Context, id int64) (*models.BuildTestCaseExecution, error)
 	GetExecutionsByBuildID(ctx context.Context, buildID int64) ([]*models.BuildExecutionDetail, error)
+	GetExecutionGroups(ctx context.Context, buildID int64) ([]*models.ExecutionGroup, error)
+	GetTestExecutions(ctx context.Context, buildID int64, className, testName string) ([]*models.BuildExecutionDetail, error)
+	GetCTRFReport(ctx context.Context, buildID int64) (*models.CTRFReport, error)
+	GetExecutionLog(ctx context.Context, id int64, stream string) (*models.ExecutionLog, error)
+	GetFlakyTests(ctx context.Context, projectID int64, window, limit int) ([]*models.FlakyTest, error)
 	CreateExecution(ctx context.Context, buildID int64, input *models.BuildExecutionInput) (*models.BuildTestCaseExecution, error)
 	UpdateExecution(ctx context.Context, id int64, execution *models.BuildTestCaseExecution) (*models.BuildTestCaseExecution, error)
 	DeleteExecution(ctx context.Context, id int64) error
 }
 
+// FlakyTestService defines the interface for detecting flaky tests from recent builds
+type FlakyTestService interface {
+	GetFlakyTests(ctx context.Context, projectID int64, window, limit int) ([]*models.FlakyTest, error)
+}
+
 // BuildExecutionService defines the interface for build execution business logic (adapter pattern)
 type BuildExecutionService interface {
 	CreateBuildExecutions(ctx context.Context, buildID int64, executions []*models.BuildExecution) error
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/BennyEisner/test-results/internal/build_test_case_execution/domain/models"
+)
+
+// GetLog retrieves a log of an execution, uncompressed, or nil if the execution has none
+func (r *SQLBuildTestCaseExecutionRepository) GetLog(ctx context.Context, executionID int64, stream string) (*models.ExecutionLog, error) {
+	query := `SELECT execution_id, stream, content, encoding, size, original_size, truncated
+			  FROM execution_logs WHERE execution_id = $1 AND stream = $2`
+
+	log, err := scanLog(r.db.QueryRowContext(ctx, query, executionID, stream))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get execution log: %w", err)
+	}
+
+	return log, nil
+}
+
+// GetLogsByBuildID retrieves the logs of all executions of a build, uncompressed
+func (r *SQLBuildTestCaseExecutionRepository) GetLogsByBuildID(ctx context.Context, buildID int64) ([]*models.ExecutionLog, error) {
+	query := `SELECT l.execution_id, l.stream, l.content, l.encoding, l.size, l.original_size, l.truncated
+			  FROM execution_logs l
+			  JOIN build_test_case_executions e ON l.execution_id = e.id
+			  WHERE e.build_id = $1
+			  ORDER BY l.execution_id, l.stream`
+
+	rows, err := r.db.QueryContext(ctx, query, buildID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get execution logs by build ID: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*models.ExecutionLog
+	for rows.Next() {
+		log, err := scanLog(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan execution log: %w", err)
+		}
+		logs = append(logs, log)
+	}
+
+	return logs, rows.Err()
+}
+
+func scanLog(row interface{ Scan(...interface{}) error }) (*models.ExecutionLog, error) {
+	var log models.ExecutionLog
+	var content []byte
+	var encoding string
+	if err := row.Scan(&log.ExecutionID, &log.Stream, &content, &encoding, &log.Size, &log.OriginalSize, &log.Truncated); err != nil {
+		return nil, err
+	}
+
+	text, err := decodeLog(content, encoding)
+	if err != nil {
+		return nil, err
+	}
+	log.Content = text
+	return &log, nil
+}
+
+// decodeLog returns the text of a log stored with an encoding: gzip, or identity for
+// logs imported before logs were compressed
+func decodeLog(content []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "identity":
+		return content, nil
+	case "gzip":
+		reader, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress log: %w", err)
+		}
+		defer reader.Close()
+		text, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress log: %w", err)
+		}
+		return text, nil
+	default:
+		return nil, fmt.Errorf("unknown log encoding %q", encoding)
+	}
+}
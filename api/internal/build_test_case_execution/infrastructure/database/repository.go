@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/BennyEisner/test-results/internal/build_test_case_execution/domain/models"
 	"github.com/BennyEisner/test-results/internal/build_test_case_execution/domain/ports"
@@ -23,7 +24,7 @@ func NewSQLBuildTestCaseExecutionRepository(db *sql.DB) ports.BuildTestCaseExecu
 
 // GetByID retrieves a build test case execution by ID
 func (r *SQLBuildTestCaseExecutionRepository) GetByID(ctx context.Context, id int64) (*models.BuildTestCaseExecution, error) {
-	query := `SELECT id, build_id, test_case_id, status, execution_time, created_at 
+	query := `SELECT id, build_id, test_case_id, status, execution_time, created_at, attempt, retried
 			  FROM build_test_case_executions WHERE id = $1`
 
 	var execution models.BuildTestCaseExecution
@@ -34,6 +35,8 @@ func (r *SQLBuildTestCaseExecutionRepository) GetByID(ctx context.Context, id in
 		&execution.Status,
 		&execution.ExecutionTime,
 		&execution.CreatedAt,
+		&execution.Attempt,
+		&execution.Retried,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -45,13 +48,42 @@ func (r *SQLBuildTestCaseExecutionRepository) GetByID(ctx context.Context, id in
 	return &execution, nil
 }
 
-// GetAllByBuildID retrieves all build test case executions for a build
+// executionStatus is the status of the last attempt of an execution with the given alias,
+// passed_on_retry where it passed after the test case was retried
+func executionStatus(alias string) string {
+	return `CASE WHEN ` + alias + `.status = 'passed' AND ` + alias + `.attempt > 1 THEN '` + models.StatusPassedOnRetry + `' ELSE ` + alias + `.status END`
+}
+
+// quarantined is the condition that the test case of the execution with the given alias
+// is in an active quarantine
+func quarantined(alias string) string {
+	return `EXISTS (SELECT 1 FROM test_case_quarantines q WHERE q.test_case_id = ` + alias + `.test_case_id
+			AND (q.expires_at IS NULL OR q.expires_at > CURRENT_TIMESTAMP))`
+}
+
+// quarantineFilter leaves the executions of quarantined test cases out of a query unless
+// they are included
+func quarantineFilter(includeQuarantined bool, alias string) string {
+	if includeQuarantined {
+		return ""
+	}
+	return ` AND NOT ` + quarantined(alias)
+}
+
+// GetAllByBuildID retrieves all build test case executions for a build, with their failures,
+// the sizes of their logs, the attempts of retried test cases and whether they are quarantined
 func (r *SQLBuildTestCaseExecutionRepository) GetAllByBuildID(ctx context.Context, buildID int64) ([]*models.BuildExecutionDetail, error) {
-	query := `SELECT e.id, e.build_id, e.test_case_id, tc.name, tc.classname, 
-			  e.status, e.execution_time, e.created_at
+	query := `SELECT e.id, e.build_id, e.test_case_id, tc.name, tc.classname, COALESCE(tc.params, ''),
+			  ` + executionStatus("e") + `, e.execution_time, e.created_at, e.attempt, ` + quarantined("e") + `,
+			  COALESCE(e.skip_message, ''), COALESCE(lo.size, 0), COALESCE(le.size, 0),
+			  f.id, COALESCE(f.message, ''), COALESCE(f.type, ''), COALESCE(f.details, '')
 			  FROM build_test_case_executions e
 			  JOIN test_cases tc ON e.test_case_id = tc.id
-			  WHERE e.build_id = $1`
+			  LEFT JOIN failures f ON f.build_test_case_execution_id = e.id
+			  LEFT JOIN execution_logs lo ON lo.execution_id = e.id AND lo.stream = 'stdout'
+			  LEFT JOIN execution_logs le ON le.execution_id = e.id AND le.stream = 'stderr'
+			  WHERE e.build_id = $1 AND NOT e.retried
+			  ORDER BY e.id`
 
 	rows, err := r.db.QueryContext(ctx, query, buildID)
 	if err != nil {
@@ -62,36 +94,112 @@ func (r *SQLBuildTestCaseExecutionRepository) GetAllByBuildID(ctx context.Contex
 	var executions []*models.BuildExecutionDetail
 	for rows.Next() {
 		var execution models.BuildExecutionDetail
+		var failureID sql.NullInt64
+		var failure models.Failure
 		err := rows.Scan(
 			&execution.ExecutionID,
 			&execution.BuildID,
 			&execution.TestCaseID,
 			&execution.TestCaseName,
 			&execution.ClassName,
+			&execution.Params,
 			&execution.Status,
 			&execution.ExecutionTime,
 			&execution.CreatedAt,
+			&execution.Attempt,
+			&execution.Quarantined,
+			&execution.SkipMessage,
+			&execution.StdoutSize,
+			&execution.StderrSize,
+			&failureID,
+			&failure.Message,
+			&failure.Type,
+			&failure.Details,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan execution: %w", err)
 		}
+		if failureID.Valid {
+			execution.Failure = &failure
+		}
 		executions = append(executions, &execution)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get executions by build ID: %w", err)
+	}
 
+	if err := r.addRetries(ctx, buildID, executions); err != nil {
+		return nil, err
+	}
 	return executions, nil
 }
 
+// addRetries attaches the attempts the test cases of a build were retried after to their executions
+func (r *SQLBuildTestCaseExecutionRepository) addRetries(ctx context.Context, buildID int64, executions []*models.BuildExecutionDetail) error {
+	query := `SELECT e.id, e.test_case_id, e.attempt, e.status, e.execution_time,
+			  f.id, COALESCE(f.message, ''), COALESCE(f.type, ''), COALESCE(f.details, '')
+			  FROM build_test_case_executions e
+			  LEFT JOIN failures f ON f.build_test_case_execution_id = e.id
+			  WHERE e.build_id = $1 AND e.retried
+			  ORDER BY e.test_case_id, e.attempt`
+
+	rows, err := r.db.QueryContext(ctx, query, buildID)
+	if err != nil {
+		return fmt.Errorf("failed to get retried executions: %w", err)
+	}
+	defer rows.Close()
+
+	byTestCase := make(map[int64]*models.BuildExecutionDetail, len(executions))
+	for _, execution := range executions {
+		byTestCase[execution.TestCaseID] = execution
+	}
+	for rows.Next() {
+		var attempt models.ExecutionAttempt
+		var testCaseID int64
+		var failureID sql.NullInt64
+		var failure models.Failure
+		err := rows.Scan(&attempt.ExecutionID, &testCaseID, &attempt.Attempt, &attempt.Status, &attempt.ExecutionTime,
+			&failureID, &failure.Message, &failure.Type, &failure.Details)
+		if err != nil {
+			return fmt.Errorf("failed to scan retried execution: %w", err)
+		}
+		if failureID.Valid {
+			attempt.Failure = &failure
+		}
+		if execution, ok := byTestCase[testCaseID]; ok {
+			execution.Retries = append(execution.Retries, &attempt)
+		}
+	}
+	return rows.Err()
+}
+
+// GetBuildCreatedAt returns when a build was created, or nil if the build does not exist
+func (r *SQLBuildTestCaseExecutionRepository) GetBuildCreatedAt(ctx context.Context, buildID int64) (*time.Time, error) {
+	query := `SELECT created_at FROM builds WHERE id = $1`
+
+	var createdAt time.Time
+	err := r.db.QueryRowContext(ctx, query, buildID).Scan(&createdAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get build: %w", err)
+	}
+
+	return &createdAt, nil
+}
+
 // Create creates a new build test case execution
 func (r *SQLBuildTestCaseExecutionRepository) Create(ctx context.Context, execution *models.BuildTestCaseExecution) error {
 	query := `INSERT INTO build_test_case_executions (build_id, test_case_id, status, execution_time)
-			  VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+			  VALUES ($1, $2, $3, $4) RETURNING id, created_at, attempt`
 
 	err := r.db.QueryRowContext(ctx, query,
 		execution.BuildID,
 		execution.TestCaseID,
 		execution.Status,
 		execution.ExecutionTime,
-	).Scan(&execution.ID, &execution.CreatedAt)
+	).Scan(&execution.ID, &execution.CreatedAt, &execution.Attempt)
 	if err != nil {
 		return fmt.Errorf("failed to create execution: %w", err)
 	}
@@ -103,7 +211,7 @@ func (r *SQLBuildTestCaseExecutionRepository) Create(ctx context.Context, execut
 func (r *SQLBuildTestCaseExecutionRepository) Update(ctx context.Context, id int64, execution *models.BuildTestCaseExecution) (*models.BuildTestCaseExecution, error) {
 	query := `UPDATE build_test_case_executions 
 			  SET build_id = $1, test_case_id = $2, status = $3, execution_time = $4
-			  WHERE id = $5 RETURNING id, build_id, test_case_id, status, execution_time, created_at`
+			  WHERE id = $5 RETURNING id, build_id, test_case_id, status, execution_time, created_at, attempt, retried`
 
 	var updatedExecution models.BuildTestCaseExecution
 	err := r.db.QueryRowContext(ctx, query,
@@ -119,6 +227,8 @@ func (r *SQLBuildTestCaseExecutionRepository) Update(ctx context.Context, id int
 		&updatedExecution.Status,
 		&updatedExecution.ExecutionTime,
 		&updatedExecution.CreatedAt,
+		&updatedExecution.Attempt,
+		&updatedExecution.Retried,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update execution: %w", err)
@@ -148,60 +258,106 @@ func (r *SQLBuildTestCaseExecutionRepository) Delete(ctx context.Context, id int
 	return nil
 }
 
-// GetMetric returns a metric for a project
-func (r *SQLBuildTestCaseExecutionRepository) GetMetric(ctx context.Context, projectID int64, metricType string) (*dashboardModels.MetricCardDTO, error) {
-	var query string
-	var args []interface{}
+// GetRecentRuns returns the final attempts of the test cases in the last window builds of
+// a project, ordered by test case and then by build. Skipped runs are left out, as are
+// test cases that neither both passed and failed nor passed on retry in the window.
+func (r *SQLBuildTestCaseExecutionRepository) GetRecentRuns(ctx context.Context, projectID int64, window int) ([]*models.TestRun, error) {
+	query := `WITH recent AS (
+				  SELECT b.id, b.build_number, COALESCE(b.commit_sha, '') AS commit_sha, b.created_at
+				  FROM builds b
+				  JOIN test_suites ts ON b.test_suite_id = ts.id
+				  WHERE ts.project_id = $1
+				  ORDER BY b.created_at DESC, b.id DESC
+				  LIMIT $2
+			  ), runs AS (
+				  SELECT e.test_case_id, tc.name, tc.classname, rb.id AS build_id, rb.build_number, rb.commit_sha,
+				         ` + executionStatus("e") + ` AS status, rb.created_at
+				  FROM build_test_case_executions e
+				  JOIN recent rb ON rb.id = e.build_id
+				  JOIN test_cases tc ON tc.id = e.test_case_id
+				  WHERE NOT e.retried AND e.status <> 'skipped'
+			  )
+			  SELECT test_case_id, name, classname, build_id, build_number, commit_sha, status, created_at
+			  FROM runs
+			  WHERE test_case_id IN (
+				  SELECT test_case_id FROM runs
+				  GROUP BY test_case_id
+				  HAVING (BOOL_OR(status IN ('failed', 'error')) AND BOOL_OR(status NOT IN ('failed', 'error')))
+				      OR BOOL_OR(status = '` + models.StatusPassedOnRetry + `')
+			  )
+			  ORDER BY test_case_id, created_at, build_id`
+
+	rows, err := r.db.QueryContext(ctx, query, projectID, window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent runs: %w", err)
+	}
+	defer rows.Close()
 
+	runs := []*models.TestRun{}
+	for rows.Next() {
+		var run models.TestRun
+		err := rows.Scan(&run.TestCaseID, &run.TestCaseName, &run.ClassName, &run.BuildID, &run.BuildNumber, &run.CommitSHA,
+			&run.Status, &run.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan run: %w", err)
+		}
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
+
+// GetMetric returns a metric for a project over the last attempts of its executions:
+// pass_rate is the share of clean passes and passed_on_retry the share of executions
+// that passed after being retried. Quarantined test cases count only if included.
+func (r *SQLBuildTestCaseExecutionRepository) GetMetric(ctx context.Context, projectID int64, metricType string, includeQuarantined bool) (*dashboardModels.MetricCardDTO, error) {
+	var status, title string
 	switch metricType {
 	case "pass_rate":
-		query = `
-			SELECT 
-				COALESCE(SUM(CASE WHEN btce.status = 'passed' THEN 1 ELSE 0 END) * 100.0 / COUNT(btce.id), 0)
-			FROM build_test_case_executions btce
-			JOIN builds b ON btce.build_id = b.id
-			JOIN test_suites ts ON b.test_suite_id = ts.id
-			WHERE ts.project_id = $1
-		`
-		args = append(args, projectID)
+		status, title = "passed", "Pass Rate"
+	case "passed_on_retry":
+		status, title = models.StatusPassedOnRetry, "Passed on Retry"
 	default:
 		return nil, fmt.Errorf("unknown metric type: %s", metricType)
 	}
 
+	query := `
+		SELECT 
+			COALESCE(SUM(CASE WHEN ` + executionStatus("btce") + ` = $2 THEN 1 ELSE 0 END) * 100.0 / COUNT(btce.id), 0)
+		FROM build_test_case_executions btce
+		JOIN builds b ON btce.build_id = b.id
+		JOIN test_suites ts ON b.test_suite_id = ts.id
+		WHERE ts.project_id = $1 AND NOT btce.retried` + quarantineFilter(includeQuarantined, "btce") + `
+	`
+
 	var value float64
-	err := r.db.QueryRowContext(ctx, query, args...).Scan(&value)
+	err := r.db.QueryRowContext(ctx, query, projectID, status).Scan(&value)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return &dashboardModels.MetricCardDTO{Title: "Pass Rate", Value: "0%"}, nil
+			return &dashboardModels.MetricCardDTO{Title: title, Value: "0%"}, nil
 		}
 		return nil, fmt.Errorf("failed to get metric: %w", err)
 	}
 
 	return &dashboardModels.MetricCardDTO{
-		Title: "Pass Rate",
+		Title: title,
 		Value: fmt.Sprintf("%.2f%%", value),
 	}, nil
 }
 
-// getChartQuery constructs the SQL query for a given chart type and context.
-func (r *SQLBuildTestCaseExecutionRepository) getChartQuery(chartType string, projectID int64, suiteID, buildID *int64) (string, string, string, []interface{}, int) {
+// getChartQuery constructs the SQL query for a given chart type and context. A tag is
+// passed in $2 and restricts the query with chartTagFilter; a test drills the bar chart
+// down into its parameter sets. The pass rates and the pass/fail trend leave quarantined
+// test cases out unless they are included.
+func (r *SQLBuildTestCaseExecutionRepository) getChartQuery(chartType string, projectID int64, suiteID, buildID *int64, tag, test string, includeQuarantined bool) (string, string, string, []interface{}, int) {
 	var baseQuery, groupBy, orderBy string
+	var testArgs []interface{}
 	args := []interface{}{projectID}
 	paramIndex := 2
 
 	switch chartType {
 	case "bar":
-		baseQuery = `
-            SELECT
-                tc.name as label,
-                COUNT(btce.id) as value
-            FROM build_test_case_executions btce
-            JOIN test_cases tc ON btce.test_case_id = tc.id
-            JOIN builds b ON btce.build_id = b.id
-            JOIN test_suites ts ON b.test_suite_id = ts.id
-            WHERE ts.project_id = $1
-        `
-		groupBy = "GROUP BY tc.name"
+		baseQuery, testArgs = barChartQuery(tag, test)
+		groupBy = "GROUP BY 1"
 		orderBy = "ORDER BY value DESC"
 	case "build-duration":
 
@@ -213,7 +369,7 @@ func (r *SQLBuildTestCaseExecutionRepository) getChartQuery(chartType string, pr
                 FROM builds b
                 JOIN test_suites ts ON b.test_suite_id = ts.id
                 WHERE ts.project_id = $1
-            `
+            ` + chartTagFilter(tag, "b.id", "")
 			groupBy = "GROUP BY ts.name"
 			orderBy = "ORDER BY value DESC"
 		} else {
@@ -226,7 +382,7 @@ func (r *SQLBuildTestCaseExecutionRepository) getChartQuery(chartType string, pr
                         ROW_NUMBER() OVER(PARTITION BY ts.id ORDER BY b.created_at DESC) as rn
                     FROM builds b
                     JOIN test_suites ts ON b.test_suite_id = ts.id
-                    WHERE ts.project_id = $1
+                    WHERE ts.project_id = $1` + chartTagFilter(tag, "b.id", "") + `
                 )
                 SELECT label, value FROM ranked_builds
             `
@@ -237,26 +393,27 @@ func (r *SQLBuildTestCaseExecutionRepository) getChartQuery(chartType string, pr
 		baseQuery = `
             SELECT
                 DATE(b.created_at)::text as date,
-                SUM(CASE WHEN btce.status = 'passed' THEN 1 ELSE 0 END) as passed,
+                SUM(CASE WHEN ` + executionStatus("btce") + ` = 'passed' THEN 1 ELSE 0 END) as passed,
                 SUM(CASE WHEN btce.status = 'failed' THEN 1 ELSE 0 END) as failed,
-                SUM(CASE WHEN btce.status = 'skipped' THEN 1 ELSE 0 END) as skipped
+                SUM(CASE WHEN btce.status = 'skipped' THEN 1 ELSE 0 END) as skipped,
+                SUM(CASE WHEN ` + executionStatus("btce") + ` = '` + models.StatusPassedOnRetry + `' THEN 1 ELSE 0 END) as passed_on_retry
 
             FROM build_test_case_executions btce
             JOIN builds b ON btce.build_id = b.id
             JOIN test_suites ts ON b.test_suite_id = ts.id
-            WHERE ts.project_id = $1
-        `
+            WHERE ts.project_id = $1 AND NOT btce.retried
+        ` + chartTagFilter(tag, "b.id", "btce") + quarantineFilter(includeQuarantined, "btce")
 		groupBy = "GROUP BY DATE(b.created_at)"
 		orderBy = "ORDER BY DATE(b.created_at)"
 	case "test-case-pass-rate":
 		if buildID != nil {
 			baseQuery = `
                 SELECT
-                    e.status as label,
+                    ` + executionStatus("e") + ` as label,
                     COUNT(e.id) as value
                 FROM build_test_case_executions e
-                WHERE e.build_id = $1
-                GROUP BY e.status
+                WHERE e.build_id = $1 AND NOT e.retried` + chartTagFilter(tag, "e.build_id", "e") + quarantineFilter(includeQuarantined, "e") + `
+                GROUP BY 1
             `
 			args = []interface{}{*buildID}
 			paramIndex = 2
@@ -266,40 +423,98 @@ func (r *SQLBuildTestCaseExecutionRepository) getChartQuery(chartType string, pr
 			baseQuery = `
                 SELECT
                     ts.name as label,
-                    (SUM(CASE WHEN e.status = 'passed' THEN 1 ELSE 0 END) * 100.0 / COUNT(e.id)) as value
+                    (SUM(CASE WHEN ` + executionStatus("e") + ` = 'passed' THEN 1 ELSE 0 END) * 100.0 / COUNT(e.id)) as value
                 FROM build_test_case_executions e
                 JOIN builds b ON e.build_id = b.id
                 JOIN test_suites ts ON b.test_suite_id = ts.id
-                WHERE ts.project_id = $1
-            `
+                WHERE ts.project_id = $1 AND NOT e.retried
+            ` + chartTagFilter(tag, "b.id", "e") + quarantineFilter(includeQuarantined, "e")
 			groupBy = "GROUP BY ts.name"
 			orderBy = "ORDER BY value DESC"
 		} else {
 			baseQuery = `
                 SELECT
                     b.id::text as label,
-                    (SUM(CASE WHEN e.status = 'passed' THEN 1 ELSE 0 END) * 100.0 / COUNT(e.id)) as value
+                    (SUM(CASE WHEN ` + executionStatus("e") + ` = 'passed' THEN 1 ELSE 0 END) * 100.0 / COUNT(e.id)) as value
                 FROM build_test_case_executions e
                 JOIN builds b ON e.build_id = b.id
-                WHERE b.test_suite_id = $1
-            `
+                WHERE b.test_suite_id = $1 AND NOT e.retried
+            ` + chartTagFilter(tag, "b.id", "e") + quarantineFilter(includeQuarantined, "e")
 			args = []interface{}{*suiteID}
 			paramIndex = 2
 			groupBy = "GROUP BY b.id"
 			orderBy = "ORDER BY b.id DESC"
 		}
 	}
-	return baseQuery, groupBy, orderBy, args, paramIndex
+	if tag != "" {
+		args = append(args, tag)
+		paramIndex++
+	}
+	args = append(args, testArgs...)
+	return baseQuery, groupBy, orderBy, args, paramIndex + len(testArgs)
 }
 
-// GetChartData returns data for a chart
-func (r *SQLBuildTestCaseExecutionRepository) GetChartData(ctx context.Context, projectID int64, chartType string, suiteID, buildID *int64, limit *int) (*dashboardModels.DataChartDTO, error) {
+// parentName is the name of the test case with the given alias without its parameters,
+// the name shared by the parameter sets of a parameterized test
+func parentName(alias string) string {
+	return `LEFT(` + alias + `.name, LENGTH(` + alias + `.name) - COALESCE(LENGTH(` + alias + `.params), 0))`
+}
+
+// barChartQuery returns the query of the bar chart, which counts the executions of each
+// test with the parameter sets of parameterized tests folded into it. Given a test, it
+// counts the executions of each parameter set of that test instead; the test is passed
+// after the tag and returned in the arguments to append.
+func barChartQuery(tag, test string) (string, []interface{}) {
+	label := parentName("tc")
+	var filter string
+	var args []interface{}
+	if test != "" {
+		index := 2
+		if tag != "" {
+			index = 3
+		}
+		filter = fmt.Sprintf(" AND %s = $%d", parentName("tc"), index)
+		label = "COALESCE(tc.params, tc.name)"
+		args = []interface{}{test}
+	}
+
+	query := `
+            SELECT
+                ` + label + ` as label,
+                COUNT(btce.id) as value
+            FROM build_test_case_executions btce
+            JOIN test_cases tc ON btce.test_case_id = tc.id
+            JOIN builds b ON btce.build_id = b.id
+            JOIN test_suites ts ON b.test_suite_id = ts.id
+            WHERE ts.project_id = $1 AND NOT btce.retried
+        ` + chartTagFilter(tag, "b.id", "btce") + filter
+	return query, args
+}
+
+// chartTagFilter returns the condition restricting a chart query to the tag in $2: builds
+// must carry it, and executions must belong to such a build or be of a test case carrying
+// it. buildID is the build column of the query and execution the alias of its executions,
+// empty for queries over builds.
+func chartTagFilter(tag, buildID, execution string) string {
+	if tag == "" {
+		return ""
+	}
+	filter := ` AND (EXISTS (SELECT 1 FROM build_tags bt WHERE bt.build_id = ` + buildID + ` AND bt.name = $2)`
+	if execution != "" {
+		filter += ` OR EXISTS (SELECT 1 FROM test_case_tags tt WHERE tt.test_case_id = ` + execution + `.test_case_id AND tt.name = $2)`
+	}
+	return filter + `)`
+}
+
+// GetChartData returns data for a chart, restricted to a tag unless it is empty. The bar
+// chart counts parameterized tests as one unless a test is given to drill down into.
+func (r *SQLBuildTestCaseExecutionRepository) GetChartData(ctx context.Context, projectID int64, chartType string, suiteID, buildID *int64, limit *int, tag, test string, includeQuarantined bool) (*dashboardModels.DataChartDTO, error) {
 	limitVal := 15 // A more reasonable default limit
 	if limit != nil {
 		limitVal = *limit
 	}
 
-	baseQuery, groupBy, orderBy, args, paramIndex := r.getChartQuery(chartType, projectID, suiteID, buildID)
+	baseQuery, groupBy, orderBy, args, paramIndex := r.getChartQuery(chartType, projectID, suiteID, buildID, tag, test, includeQuarantined)
 	if baseQuery == "" {
 
 		return nil, fmt.Errorf("unknown chart type: %s", chartType)
@@ -343,6 +558,7 @@ func (r *SQLBuildTestCaseExecutionRepository) GetChartData(ctx context.Context,
 	var passedData []int
 	var failedData []int
 	var skippedData []int
+	var retriedData []int
 	var values []float64
 
 	datasets := []dashboardModels.DatasetDTO{}
@@ -360,8 +576,8 @@ func (r *SQLBuildTestCaseExecutionRepository) GetChartData(ctx context.Context,
 			values = append(values, value)
 		case "line", "pass-fail-trend":
 			var date string
-			var passed, failed, skipped int
-			if err := rows.Scan(&date, &passed, &failed, &skipped); err != nil {
+			var passed, failed, skipped, passedOnRetry int
+			if err := rows.Scan(&date, &passed, &failed, &skipped, &passedOnRetry); err != nil {
 
 
 				return nil, fmt.Errorf("failed to scan chart data: %w", err)
@@ -370,13 +586,14 @@ func (r *SQLBuildTestCaseExecutionRepository) GetChartData(ctx context.Context,
 			passedData = append(passedData, passed)
 			failedData = append(failedData, failed)
 			skippedData = append(skippedData, skipped)
+			retriedData = append(retriedData, passedOnRetry)
 
 		}
 	}
 
 	log.Printf("GetChartData query returned %d labels", len(labels))
 
-	datasets, xAxisLabel, yAxisLabel := r.getChartStyling(chartType, passedData, failedData, skippedData, values, labels)
+	datasets, xAxisLabel, yAxisLabel := r.getChartStyling(chartType, passedData, failedData, skippedData, retriedData, values, labels)
 
 	return &dashboardModels.DataChartDTO{
 		Labels:     labels,
@@ -386,7 +603,7 @@ func (r *SQLBuildTestCaseExecutionRepository) GetChartData(ctx context.Context,
 	}, nil
 }
 
-func (r *SQLBuildTestCaseExecutionRepository) getChartStyling(chartType string, passedData, failedData, skippedData []int, values []float64, labels []string) ([]dashboardModels.DatasetDTO, string, string) {
+func (r *SQLBuildTestCaseExecutionRepository) getChartStyling(chartType string, passedData, failedData, skippedData, retriedData []int, values []float64, labels []string) ([]dashboardModels.DatasetDTO, string, string) {
 	var xAxisLabel, yAxisLabel string
 	datasets := []dashboardModels.DatasetDTO{}
 
@@ -441,6 +658,11 @@ func (r *SQLBuildTestCaseExecutionRepository) getChartStyling(chartType string,
 			Data:            skippedData,
 			BackgroundColor: []string{"#808080"},
 			BorderColor:     []string{"#808080"},
+		}, dashboardModels.DatasetDTO{
+			Label:           "Passed on Retry",
+			Data:            retriedData,
+			BackgroundColor: []string{"#E9EE5C"},
+			BorderColor:     []string{"#E9EE5C"},
 		})
 	}
 	return datasets, xAxisLabel, yAxisLabel
@@ -459,7 +681,7 @@ func (r *SQLBuildTestCaseExecutionRepository) getDynamicColors(chartType string,
 		// Check if this is a build-level view by looking at the labels
 		isBuildLevel := false
 		for _, label := range labels {
-			if label == "passed" || label == "failed" || label == "skipped" {
+			if label == "passed" || label == "failed" || label == "skipped" || label == models.StatusPassedOnRetry {
 				isBuildLevel = true
 				break
 			}
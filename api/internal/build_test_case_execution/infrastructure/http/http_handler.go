@@ -1,10 +1,15 @@
 package http
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/BennyEisner/test-results/internal/build_test_case_execution/application"
+	"github.com/BennyEisner/test-results/internal/build_test_case_execution/domain"
 	"github.com/BennyEisner/test-results/internal/build_test_case_execution/domain/models"
 	"github.com/BennyEisner/test-results/internal/build_test_case_execution/domain/ports"
 )
@@ -50,11 +55,13 @@ func (h *BuildTestCaseExecutionHandler) GetExecutionByID(w http.ResponseWriter,
 
 // GetExecutionsByBuildID handles GET /builds/{buildID}/executions
 // @Summary Get executions by build ID
-// @Description Retrieve all test case executions for a specific build
+// @Description Retrieve all test case executions for a specific build. Each execution is the last attempt of its test case; the attempts of a retried test case come first in its retries, and an execution that passed after retries has the status passed_on_retry. A test restricts the executions to the parameter sets of that test, e.g. test=test_login for test_login[chrome-admin].
 // @Tags executions
 // @Accept json
 // @Produce json
 // @Param buildID path int true "Build ID"
+// @Param test query string false "Name of a test without its parameters"
+// @Param classname query string false "Class name of the test"
 // @Success 200 {array} object
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -68,7 +75,12 @@ func (h *BuildTestCaseExecutionHandler) GetExecutionsByBuildID(w http.ResponseWr
 	}
 
 	ctx := r.Context()
-	executions, err := h.Service.GetExecutionsByBuildID(ctx, buildID)
+	var executions []*models.BuildExecutionDetail
+	if test := r.URL.Query().Get("test"); test != "" {
+		executions, err = h.Service.GetTestExecutions(ctx, buildID, r.URL.Query().Get("classname"), test)
+	} else {
+		executions, err = h.Service.GetExecutionsByBuildID(ctx, buildID)
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -77,6 +89,120 @@ func (h *BuildTestCaseExecutionHandler) GetExecutionsByBuildID(w http.ResponseWr
 	respondWithJSON(w, http.StatusOK, executions)
 }
 
+// GetExecutionGroups handles GET /builds/{buildID}/executions/grouped
+// @Summary Get the executions of a build grouped by test
+// @Description Retrieve the executions of a build aggregated by test: the parameter sets of a parameterized test such as test_login[chrome-admin] form one group, with the worst status among them, their total execution time and their count per status. The executions of a group are listed by GET /builds/{buildID}/executions with its test and classname.
+// @Tags executions
+// @Produce json
+// @Param buildID path int true "Build ID"
+// @Success 200 {array} models.ExecutionGroup
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /builds/{buildID}/executions/grouped [get]
+func (h *BuildTestCaseExecutionHandler) GetExecutionGroups(w http.ResponseWriter, r *http.Request) {
+	buildID, err := strconv.ParseInt(r.PathValue("buildID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid build ID")
+		return
+	}
+
+	ctx := r.Context()
+	groups, err := h.Service.GetExecutionGroups(ctx, buildID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, groups)
+}
+
+// GetCTRFReport handles GET /builds/{id}/report.ctrf.json
+// @Summary Export a build as CTRF
+// @Description Render the executions and failures of a build as a Common Test Report Format (CTRF) JSON report
+// @Tags executions
+// @Produce json
+// @Param id path int true "Build ID"
+// @Success 200 {object} models.CTRFReport
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /builds/{id}/report.ctrf.json [get]
+func (h *BuildTestCaseExecutionHandler) GetCTRFReport(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	buildID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid build ID")
+		return
+	}
+
+	ctx := r.Context()
+	report, err := h.Service.GetCTRFReport(ctx, buildID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrBuildNotFound):
+			respondWithError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, domain.ErrInvalidBuildData):
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// GetExecutionLog handles GET /executions/{id}/logs
+// @Summary Get the log of an execution
+// @Description Download the standard output (default) or error of an execution as plain text.
+// @Description Range requests are supported, e.g. "Range: bytes=-65536" for the end of a log.
+// @Description Logs larger than the configured size were cut when imported; X-Log-Truncated
+// @Description and X-Log-Original-Size then describe the log as reported.
+// @Tags executions
+// @Produce plain
+// @Param id path int true "Execution ID"
+// @Param stream query string false "Log stream: stdout or stderr"
+// @Param Range header string false "Byte range of the log"
+// @Success 200 {string} string
+// @Success 206 {string} string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 416 {string} string
+// @Failure 500 {object} map[string]string
+// @Router /executions/{id}/logs [get]
+func (h *BuildTestCaseExecutionHandler) GetExecutionLog(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid execution ID")
+		return
+	}
+	stream := r.URL.Query().Get("stream")
+	if stream == "" {
+		stream = models.LogStdout
+	}
+
+	ctx := r.Context()
+	log, err := h.Service.GetExecutionLog(ctx, id, stream)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrBuildExecutionNotFound):
+			respondWithError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, domain.ErrInvalidExecutionData), errors.Is(err, domain.ErrInvalidLogStream):
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		default:
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Log-Truncated", strconv.FormatBool(log.Truncated))
+	w.Header().Set("X-Log-Original-Size", strconv.FormatInt(log.OriginalSize, 10))
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(log.Content))
+}
+
 // CreateExecution handles POST /builds/{buildID}/executions
 // @Summary Create a new execution
 // @Description Create a new test case execution for a specific build
@@ -149,6 +275,58 @@ func (h *BuildTestCaseExecutionHandler) UpdateExecution(w http.ResponseWriter, r
 	respondWithJSON(w, http.StatusOK, updatedExecution)
 }
 
+// GetFlakyTests handles GET /projects/{projectID}/flaky-tests
+// @Summary Get the flaky tests of a project
+// @Description Rank the tests of a project that flipped between passed and failed over its last builds. The flip rate is the share of transitions between consecutive runs that flipped, a pass on retry counting as a flip within its build. The score, from 0 to 1, weighs flips on the same commit three times as heavy as flips between commits. The evidence lists the builds in which a test flipped.
+// @Tags executions
+// @Produce json
+// @Param projectID path int true "Project ID"
+// @Param window query int false "Number of recent builds to look at (default 50)"
+// @Param limit query int false "Maximum number of flaky tests (default 20)"
+// @Success 200 {array} models.FlakyTest
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{projectID}/flaky-tests [get]
+func (h *BuildTestCaseExecutionHandler) GetFlakyTests(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(r.PathValue("projectID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid project ID")
+		return
+	}
+	window, err := queryInt(r, "window", application.DefaultFlakyWindow)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid window")
+		return
+	}
+	limit, err := queryInt(r, "limit", application.DefaultFlakyLimit)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+
+	ctx := r.Context()
+	flaky, err := h.Service.GetFlakyTests(ctx, projectID, window, limit)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidExecutionData) || errors.Is(err, domain.ErrInvalidFlakyQuery) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		} else {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, flaky)
+}
+
+// queryInt returns an integer query parameter, or def where it is absent
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
 // DeleteExecution handles DELETE /executions/{id}
 // @Summary Delete an execution
 // @Description Delete a test case execution by its ID
@@ -0,0 +1,99 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BennyEisner/test-results/internal/build_test_case_execution/infrastructure/database"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// schemaFile is the schema the database tests create their tables from
+const schemaFile = "../../../../db/schema.sql"
+
+// openTestDB connects to the PostgreSQL database of TEST_DATABASE_URL and creates the
+// tables of schemaFile in a schema of their own, dropped when the test ends. Tests of
+// the SQL repository are skipped when the variable is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	schema, err := os.ReadFile(schemaFile)
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+
+	admin, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	name := fmt.Sprintf("execution_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + name); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec("DROP SCHEMA " + name + " CASCADE")
+		_ = admin.Close()
+	})
+
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+	db, err := sql.Open("postgres", url+separator+"search_path="+name)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+	return db
+}
+
+// exec runs a statement returning an ID and returns it
+func exec(t *testing.T, db *sql.DB, query string, args ...interface{}) int64 {
+	var id int64
+	if err := db.QueryRow(query, args...).Scan(&id); err != nil {
+		t.Fatalf("failed to run %q: %v", query, err)
+	}
+	return id
+}
+
+func TestSQLBuildTestCaseExecutionRepository_PassFailTrendQuarantine(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	repo := database.NewSQLBuildTestCaseExecutionRepository(db)
+
+	projectID := exec(t, db, `INSERT INTO projects (name) VALUES ('checkout') RETURNING id`)
+	suiteID := exec(t, db, `INSERT INTO test_suites (project_id, name, time) VALUES ($1, 'checkout', 0) RETURNING id`, projectID)
+	buildID := exec(t, db, `INSERT INTO builds (test_suite_id, build_number, ci_provider, status) VALUES ($1, '1', 'unknown', 'failed') RETURNING id`, suiteID)
+	for name, status := range map[string]string{"testPay": "passed", "testRefund": "failed", "testCancel": "failed"} {
+		testCaseID := exec(t, db, `INSERT INTO test_cases (suite_id, name, classname) VALUES ($1, $2, 'Checkout') RETURNING id`, suiteID, name)
+		exec(t, db, `INSERT INTO build_test_case_executions (build_id, test_case_id, status) VALUES ($1, $2, $3) RETURNING id`, buildID, testCaseID, status)
+		if name == "testCancel" {
+			exec(t, db, `INSERT INTO test_case_quarantines (test_case_id, quarantined_by, reason) VALUES ($1, 'ci', 'flaky') RETURNING id`, testCaseID)
+		}
+	}
+
+	for _, chartType := range []string{"line", "pass-fail-trend"} {
+		t.Run(chartType, func(t *testing.T) {
+			for includeQuarantined, wantFailed := range map[bool]int{false: 1, true: 2} {
+				chart, err := repo.GetChartData(ctx, projectID, chartType, nil, nil, nil, "", "", includeQuarantined)
+
+				if !assert.NoError(t, err) || !assert.Len(t, chart.Datasets, 4) {
+					continue
+				}
+				assert.Equal(t, []int{1}, chart.Datasets[0].Data)
+				assert.Equal(t, []int{wantFailed}, chart.Datasets[1].Data, "includeQuarantined = %v", includeQuarantined)
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/BennyEisner/test-results/internal/build_test_case_execution/application"
+	"github.com/BennyEisner/test-results/internal/build_test_case_execution/domain"
+	"github.com/BennyEisner/test-results/internal/build_test_case_execution/domain/models"
+	dashboardModels "github.com/BennyEisner/test-results/internal/dashboard/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockBuildTestCaseExecutionRepository is a mock implementation of BuildTestCaseExecutionRepository
+type MockBuildTestCaseExecutionRepository struct {
+	mock.Mock
+}
+
+func (m *MockBuildTestCaseExecutionRepository) GetByID(ctx context.Context, id int64) (*models.BuildTestCaseExecution, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.BuildTestCaseExecution), args.Error(1)
+}
+
+func (m *MockBuildTestCaseExecutionRepository) GetAllByBuildID(ctx context.Context, buildID int64) ([]*models.BuildExecutionDetail, error) {
+	args := m.Called(ctx, buildID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.BuildExecutionDetail), args.Error(1)
+}
+
+func (m *MockBuildTestCaseExecutionRepository) GetBuildCreatedAt(ctx context.Context, buildID int64) (*time.Time, error) {
+	args := m.Called(ctx, buildID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*time.Time), args.Error(1)
+}
+
+func (m *MockBuildTestCaseExecutionRepository) GetLog(ctx context.Context, executionID int64, stream string) (*models.ExecutionLog, error) {
+	args := m.Called(ctx, executionID, stream)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ExecutionLog), args.Error(1)
+}
+
+func (m *MockBuildTestCaseExecutionRepository) GetLogsByBuildID(ctx context.Context, buildID int64) ([]*models.ExecutionLog, error) {
+	args := m.Called(ctx, buildID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.ExecutionLog), args.Error(1)
+}
+
+func (m *MockBuildTestCaseExecutionRepository) Create(ctx context.Context, execution *models.BuildTestCaseExecution) error {
+	args := m.Called(ctx, execution)
+	return args.Error(0)
+}
+
+func (m *MockBuildTestCaseExecutionRepository) Update(ctx context.Context, id int64, execution *models.BuildTestCaseExecution) (*models.BuildTestCaseExecution, error) {
+	args := m.Called(ctx, id, execution)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.BuildTestCaseExecution), args.Error(1)
+}
+
+func (m *MockBuildTestCaseExecutionRepository) Delete(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockBuildTestCaseExecutionRepository) GetRecentRuns(ctx context.Context, projectID int64, window int) ([]*models.TestRun, error) {
+	args := m.Called(ctx, projectID, window)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.TestRun), args.Error(1)
+}
+
+func (m *MockBuildTestCaseExecutionRepository) GetMetric(ctx context.Context, projectID int64, metricType string, includeQuarantined bool) (*dashboardModels.MetricCardDTO, error) {
+	args := m.Called(ctx, projectID, metricType, includeQuarantined)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dashboardModels.MetricCardDTO), args.Error(1)
+}
+
+func (m *MockBuildTestCaseExecutionRepository) GetChartData(ctx context.Context, projectID int64, chartType string, suiteID *int64, buildID *int64, limit *int, tag, test string, includeQuarantined bool) (*dashboardModels.DataChartDTO, error) {
+	args := m.Called(ctx, projectID, chartType, suiteID, buildID, limit, tag, test, includeQuarantined)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dashboardModels.DataChartDTO), args.Error(1)
+}
+
+func TestBuildTestCaseExecutionService_GetCTRFReport(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockBuildTestCaseExecutionRepository)
+		service := application.NewBuildTestCaseExecutionService(mockRepo)
+
+		mockRepo.On("GetBuildCreatedAt", ctx, int64(5)).Return(&createdAt, nil).Once()
+		mockRepo.On("GetAllByBuildID", ctx, int64(5)).Return([]*models.BuildExecutionDetail{
+			{ExecutionID: 1, BuildID: 5, TestCaseName: "TestAdd", ClassName: "calc", Status: "passed", ExecutionTime: 1.5, StdoutSize: 3},
+			{ExecutionID: 2, BuildID: 5, TestCaseName: "TestDiv", ClassName: "calc", Status: "error", ExecutionTime: 0.25,
+				Failure: &models.Failure{Message: "panic", Details: "goroutine 1"}},
+			{ExecutionID: 3, BuildID: 5, TestCaseName: "TestSub", ClassName: "calc", Status: "skipped", SkipMessage: "flaky"},
+		}, nil).Once()
+		mockRepo.On("GetLogsByBuildID", ctx, int64(5)).Return([]*models.ExecutionLog{
+			{ExecutionID: 1, Stream: models.LogStdout, Content: []byte("a\nb"), Size: 3, OriginalSize: 3},
+		}, nil).Once()
+
+		report, err := service.GetCTRFReport(ctx, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "CTRF", report.ReportFormat)
+		assert.Equal(t, models.CTRFSummary{
+			Tests:   3,
+			Passed:  1,
+			Failed:  1,
+			Skipped: 1,
+			Start:   createdAt.UnixMilli(),
+			Stop:    createdAt.UnixMilli() + 1750,
+		}, report.Results.Summary)
+		assert.Equal(t, models.CTRFTest{
+			Name: "TestAdd", Status: "passed", Duration: 1500, Suite: "calc", RawStatus: "passed", Stdout: []string{"a", "b"},
+		}, report.Results.Tests[0])
+		assert.Equal(t, models.CTRFTest{
+			Name: "TestDiv", Status: "failed", Duration: 250, Suite: "calc", RawStatus: "error", Message: "panic", Trace: "goroutine 1",
+		}, report.Results.Tests[1])
+		assert.Equal(t, "flaky", report.Results.Tests[2].Message)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("passed on retry", func(t *testing.T) {
+		mockRepo := new(MockBuildTestCaseExecutionRepository)
+		service := application.NewBuildTestCaseExecutionService(mockRepo)
+
+		mockRepo.On("GetBuildCreatedAt", ctx, int64(5)).Return(&createdAt, nil).Once()
+		mockRepo.On("GetAllByBuildID", ctx, int64(5)).Return([]*models.BuildExecutionDetail{
+			{ExecutionID: 4, BuildID: 5, TestCaseName: "TestMul", ClassName: "calc", Status: models.StatusPassedOnRetry, ExecutionTime: 0.5, Attempt: 3,
+				Retries: []*models.ExecutionAttempt{{ExecutionID: 2, Attempt: 1, Status: "failed"}, {ExecutionID: 3, Attempt: 2, Status: "failed"}}},
+		}, nil).Once()
+		mockRepo.On("GetLogsByBuildID", ctx, int64(5)).Return([]*models.ExecutionLog{}, nil).Once()
+
+		report, err := service.GetCTRFReport(ctx, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Results.Summary.Passed)
+		assert.Equal(t, models.CTRFTest{
+			Name: "TestMul", Status: "passed", Duration: 500, Suite: "calc", RawStatus: models.StatusPassedOnRetry, Retries: 2, Flaky: true,
+		}, report.Results.Tests[0])
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("build not found", func(t *testing.T) {
+		mockRepo := new(MockBuildTestCaseExecutionRepository)
+		service := application.NewBuildTestCaseExecutionService(mockRepo)
+
+		mockRepo.On("GetBuildCreatedAt", ctx, int64(5)).Return(nil, nil).Once()
+
+		report, err := service.GetCTRFReport(ctx, 5)
+
+		assert.Equal(t, domain.ErrBuildNotFound, err)
+		assert.Nil(t, report)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid build ID", func(t *testing.T) {
+		service := application.NewBuildTestCaseExecutionService(new(MockBuildTestCaseExecutionRepository))
+
+		report, err := service.GetCTRFReport(ctx, 0)
+
+		assert.Equal(t, domain.ErrInvalidBuildData, err)
+		assert.Nil(t, report)
+	})
+}
+
+func TestBuildTestCaseExecutionService_GetExecutionLog(t *testing.T) {
+	ctx := context.Background()
+	execution := &models.BuildTestCaseExecution{ID: 7, BuildID: 5, Status: "failed"}
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockBuildTestCaseExecutionRepository)
+		service := application.NewBuildTestCaseExecutionService(mockRepo)
+		stored := &models.ExecutionLog{ExecutionID: 7, Stream: models.LogStderr, Content: []byte("boom"), Size: 4, OriginalSize: 4}
+
+		mockRepo.On("GetByID", ctx, int64(7)).Return(execution, nil).Once()
+		mockRepo.On("GetLog", ctx, int64(7), models.LogStderr).Return(stored, nil).Once()
+
+		log, err := service.GetExecutionLog(ctx, 7, models.LogStderr)
+
+		assert.NoError(t, err)
+		assert.Equal(t, stored, log)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("no output", func(t *testing.T) {
+		mockRepo := new(MockBuildTestCaseExecutionRepository)
+		service := application.NewBuildTestCaseExecutionService(mockRepo)
+
+		mockRepo.On("GetByID", ctx, int64(7)).Return(execution, nil).Once()
+		mockRepo.On("GetLog", ctx, int64(7), models.LogStdout).Return(nil, nil).Once()
+
+		log, err := service.GetExecutionLog(ctx, 7, models.LogStdout)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.ExecutionLog{ExecutionID: 7, Stream: models.LogStdout}, log)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("execution not found", func(t *testing.T) {
+		mockRepo := new(MockBuildTestCaseExecutionRepository)
+		service := application.NewBuildTestCaseExecutionService(mockRepo)
+
+		mockRepo.On("GetByID", ctx, int64(7)).Return(nil, nil).Once()
+
+		log, err := service.GetExecutionLog(ctx, 7, models.LogStdout)
+
+		assert.Equal(t, domain.ErrBuildExecutionNotFound, err)
+		assert.Nil(t, log)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid stream", func(t *testing.T) {
+		service := application.NewBuildTestCaseExecutionService(new(MockBuildTestCaseExecutionRepository))
+
+		log, err := service.GetExecutionLog(ctx, 7, "console")
+
+		assert.Equal(t, domain.ErrInvalidLogStream, err)
+		assert.Nil(t, log)
+	})
+}
+
+func TestBuildTestCaseExecutionService_GetExecutionGroups(t *testing.T) {
+	ctx := context.Background()
+	executions := []*models.BuildExecutionDetail{
+		{ExecutionID: 1, TestCaseName: "test_login[chrome-admin]", ClassName: "auth", Params: "[chrome-admin]", Status: "passed", ExecutionTime: 1},
+		{ExecutionID: 2, TestCaseName: "TestAdd", ClassName: "calc", Status: "passed", ExecutionTime: 0.5},
+		{ExecutionID: 3, TestCaseName: "test_login[firefox-user]", ClassName: "auth", Params: "[firefox-user]", Status: "failed", ExecutionTime: 2},
+		{ExecutionID: 4, TestCaseName: "test_login[chrome-user]", ClassName: "auth", Params: "[chrome-user]", Status: models.StatusPassedOnRetry, ExecutionTime: 1.5},
+	}
+
+	t.Run("parameter sets fold into their test", func(t *testing.T) {
+		mockRepo := new(MockBuildTestCaseExecutionRepository)
+		service := application.NewBuildTestCaseExecutionService(mockRepo)
+
+		mockRepo.On("GetAllByBuildID", ctx, int64(5)).Return(executions, nil).Once()
+
+		groups, err := service.GetExecutionGroups(ctx, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.ExecutionGroup{
+			{TestName: "test_login", ClassName: "auth", Status: "failed", ExecutionTime: 4.5, ParamSets: 3,
+				Statuses: map[string]int{"passed": 1, "failed": 1, models.StatusPassedOnRetry: 1}},
+			{TestName: "TestAdd", ClassName: "calc", Status: "passed", ExecutionTime: 0.5, ParamSets: 1,
+				Statuses: map[string]int{"passed": 1}},
+		}, groups)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("drill down into a test", func(t *testing.T) {
+		mockRepo := new(MockBuildTestCaseExecutionRepository)
+		service := application.NewBuildTestCaseExecutionService(mockRepo)
+
+		mockRepo.On("GetAllByBuildID", ctx, int64(5)).Return(executions, nil).Twice()
+
+		filtered, err := service.GetTestExecutions(ctx, 5, "auth", "test_login")
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.BuildExecutionDetail{executions[0], executions[2], executions[3]}, filtered)
+
+		filtered, err = service.GetTestExecutions(ctx, 5, "calc", "test_login")
+		assert.NoError(t, err)
+		assert.Empty(t, filtered)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("test required", func(t *testing.T) {
+		service := application.NewBuildTestCaseExecutionService(new(MockBuildTestCaseExecutionRepository))
+
+		_, err := service.GetTestExecutions(ctx, 5, "auth", "")
+
+		assert.Equal(t, domain.ErrInvalidExecutionData, err)
+	})
+}
+
+func TestBuildTestCaseExecutionService_GetFlakyTests(t *testing.T) {
+	ctx := context.Background()
+	run := func(testCaseID, buildID int64, commit, status string) *models.TestRun {
+		return &models.TestRun{TestCaseID: testCaseID, TestCaseName: fmt.Sprintf("test_%d", testCaseID), ClassName: "calc",
+			BuildID: buildID, BuildNumber: fmt.Sprint(buildID), CommitSHA: commit, Status: status}
+	}
+	runs := []*models.TestRun{
+		// flips between commits only
+		run(1, 1, "a", "passed"), run(1, 2, "b", "failed"), run(1, 3, "c", "passed"), run(1, 4, "d", "passed"),
+		// fails then passes on the same commit
+		run(2, 1, "a", "passed"), run(2, 2, "b", "failed"), run(2, 3, "b", "passed"), run(2, 4, "d", "passed"),
+		// passes on retry
+		run(3, 1, "a", "passed"), run(3, 2, "b", models.StatusPassedOnRetry),
+		// errors but never passes
+		run(4, 1, "a", "failed"), run(4, 2, "b", "error"),
+	}
+
+	t.Run("ranked by score", func(t *testing.T) {
+		mockRepo := new(MockBuildTestCaseExecutionRepository)
+		service := application.NewBuildTestCaseExecutionService(mockRepo)
+
+		mockRepo.On("GetRecentRuns", ctx, int64(1), 50).Return(runs, nil).Once()
+
+		flaky, err := service.GetFlakyTests(ctx, 1, 50, 0)
+
+		assert.NoError(t, err)
+		assert.Len(t, flaky, 3)
+
+		assert.Equal(t, int64(3), flaky[0].TestCaseID)
+		assert.Equal(t, 1, flaky[0].Flips)
+		assert.Equal(t, 1, flaky[0].SameCommitFlips)
+		assert.InDelta(t, 0.5, flaky[0].FlipRate, 1e-9)
+		assert.InDelta(t, 0.5, flaky[0].Score, 1e-9)
+		assert.Equal(t, []*models.FlakyEvidence{{BuildID: 2, BuildNumber: "2", CommitSHA: "b", Status: models.StatusPassedOnRetry, SameCommit: true}},
+			flaky[0].Evidence)
+
+		assert.Equal(t, int64(2), flaky[1].TestCaseID)
+		assert.Equal(t, 2, flaky[1].Flips)
+		assert.Equal(t, 1, flaky[1].SameCommitFlips)
+		assert.InDelta(t, 2.0/3, flaky[1].FlipRate, 1e-9)
+		assert.InDelta(t, 4.0/9, flaky[1].Score, 1e-9)
+		assert.False(t, flaky[1].Evidence[0].SameCommit)
+		assert.True(t, flaky[1].Evidence[1].SameCommit)
+
+		assert.Equal(t, int64(1), flaky[2].TestCaseID)
+		assert.InDelta(t, 2.0/3, flaky[2].FlipRate, 1e-9)
+		assert.InDelta(t, 2.0/9, flaky[2].Score, 1e-9)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("limited", func(t *testing.T) {
+		mockRepo := new(MockBuildTestCaseExecutionRepository)
+		service := application.NewBuildTestCaseExecutionService(mockRepo)
+
+		mockRepo.On("GetRecentRuns", ctx, int64(1), 10).Return(runs, nil).Once()
+
+		flaky, err := service.GetFlakyTests(ctx, 1, 10, 1)
+
+		assert.NoError(t, err)
+		assert.Len(t, flaky, 1)
+		assert.Equal(t, int64(3), flaky[0].TestCaseID)
+	})
+
+	t.Run("invalid window", func(t *testing.T) {
+		service := application.NewBuildTestCaseExecutionService(new(MockBuildTestCaseExecutionRepository))
+
+		_, err := service.GetFlakyTests(ctx, 1, 0, 20)
+
+		assert.Equal(t, domain.ErrInvalidFlakyQuery, err)
+	})
+}
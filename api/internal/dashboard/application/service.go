@@ -2,21 +2,29 @@ package application
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	buildPorts "github.com/BennyEisner/test-results/internal/build/domain/ports"
+	buildExecApp "github.com/BennyEisner/test-results/internal/build_test_case_execution/application"
 	buildExecPorts "github.com/BennyEisner/test-results/internal/build_test_case_execution/domain/ports"
 	"github.com/BennyEisner/test-results/internal/dashboard/domain/models"
 )
 
+// flakyTestsWidget is the metric and chart type of the flaky tests widgets
+const flakyTestsWidget = "flaky-tests"
+
 type DashboardServiceImpl struct {
 	buildRepo     buildPorts.BuildRepository
 	buildExecRepo buildExecPorts.BuildTestCaseExecutionRepository
+	flakyService  buildExecPorts.FlakyTestService
 }
 
-func NewDashboardService(buildRepo buildPorts.BuildRepository, buildExecRepo buildExecPorts.BuildTestCaseExecutionRepository) *DashboardServiceImpl {
+func NewDashboardService(buildRepo buildPorts.BuildRepository, buildExecRepo buildExecPorts.BuildTestCaseExecutionRepository, flakyService buildExecPorts.FlakyTestService) *DashboardServiceImpl {
 	return &DashboardServiceImpl{
 		buildRepo:     buildRepo,
 		buildExecRepo: buildExecRepo,
+		flakyService:  flakyService,
 	}
 }
 
@@ -28,12 +36,64 @@ func (s *DashboardServiceImpl) GetStatus(ctx context.Context, projectID int64) (
 	return &models.StatusBadgeDTO{Status: status}, nil
 }
 
-func (s *DashboardServiceImpl) GetMetric(ctx context.Context, projectID int64, metricType string) (*models.MetricCardDTO, error) {
-	return s.buildExecRepo.GetMetric(ctx, projectID, metricType)
+// GetMetric returns a metric of a project; pass rates leave quarantined test cases out
+// unless they are included
+func (s *DashboardServiceImpl) GetMetric(ctx context.Context, projectID int64, metricType string, includeQuarantined bool) (*models.MetricCardDTO, error) {
+	if metricType == flakyTestsWidget {
+		return s.getFlakyTestsMetric(ctx, projectID)
+	}
+	return s.buildExecRepo.GetMetric(ctx, projectID, metricType, includeQuarantined)
 }
 
-func (s *DashboardServiceImpl) GetChartData(ctx context.Context, projectID int64, chartType string, suiteID *int64, buildID *int64, limit *int) (*models.DataChartDTO, error) {
-	return s.buildExecRepo.GetChartData(ctx, projectID, chartType, suiteID, buildID, limit)
+// getFlakyTestsMetric counts the flaky tests of a project over its recent builds
+func (s *DashboardServiceImpl) getFlakyTestsMetric(ctx context.Context, projectID int64) (*models.MetricCardDTO, error) {
+	flaky, err := s.flakyService.GetFlakyTests(ctx, projectID, buildExecApp.DefaultFlakyWindow, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &models.MetricCardDTO{Title: "Flaky Tests", Value: fmt.Sprintf("%d", len(flaky))}, nil
+}
+
+// GetChartData returns the data of a chart; a tag restricts it to the builds, and the
+// executions of test cases, carrying the tag. A test drills the bar chart down into the
+// parameter sets of that test. Pass rates leave quarantined test cases out unless they
+// are included.
+func (s *DashboardServiceImpl) GetChartData(ctx context.Context, projectID int64, chartType string, suiteID *int64, buildID *int64, limit *int, tag, test string, includeQuarantined bool) (*models.DataChartDTO, error) {
+	if chartType == flakyTestsWidget {
+		return s.getFlakyTestsChart(ctx, projectID, limit)
+	}
+	return s.buildExecRepo.GetChartData(ctx, projectID, chartType, suiteID, buildID, limit, strings.TrimPrefix(strings.TrimSpace(tag), "@"),
+		strings.TrimSpace(test), includeQuarantined)
+}
+
+// getFlakyTestsChart charts the flakiness scores of the flakiest tests of a project, in percent
+func (s *DashboardServiceImpl) getFlakyTestsChart(ctx context.Context, projectID int64, limit *int) (*models.DataChartDTO, error) {
+	limitVal := buildExecApp.DefaultFlakyLimit
+	if limit != nil {
+		limitVal = *limit
+	}
+	flaky, err := s.flakyService.GetFlakyTests(ctx, projectID, buildExecApp.DefaultFlakyWindow, limitVal)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, len(flaky))
+	scores := make([]int, len(flaky))
+	for i, test := range flaky {
+		labels[i] = test.TestCaseName
+		scores[i] = int(test.Score*100 + 0.5)
+	}
+	return &models.DataChartDTO{
+		Labels: labels,
+		Datasets: []models.DatasetDTO{{
+			Label:           "Flakiness Score (%)",
+			Data:            scores,
+			BackgroundColor: []string{"#E9EE5C"},
+			BorderColor:     []string{"#E9EE5C"},
+		}},
+		XAxisLabel: "Test Cases",
+		YAxisLabel: "Flakiness Score (%)",
+	}, nil
 }
 
 func (s *DashboardServiceImpl) GetAvailableWidgets(ctx context.Context) (*models.AvailableWidgetsDTO, error) {
@@ -41,12 +101,15 @@ func (s *DashboardServiceImpl) GetAvailableWidgets(ctx context.Context) (*models
 	return &models.AvailableWidgetsDTO{
 		Metrics: []models.WidgetOption{
 			{Value: "passing-rate", Label: "Passing Rate"},
+			{Value: "passed_on_retry", Label: "Passed on Retry"},
 			{Value: "execution-time", Label: "Execution Time"},
+			{Value: flakyTestsWidget, Label: "Flaky Tests"},
 		},
 		Charts: []models.WidgetOption{
 			{Value: "build-duration", Label: "Build Duration"},
 			{Value: "pass-fail-trend", Label: "Pass/Fail Trend"},
 			{Value: "test-case-pass-rate", Label: "Test Case Pass Rate"},
+			{Value: flakyTestsWidget, Label: "Flaky Tests"},
 		},
 	}, nil
 }
@@ -9,7 +9,7 @@ import (
 // DashboardService defines the interface for dashboard business logic.
 type DashboardService interface {
 	GetStatus(ctx context.Context, projectID int64) (*models.StatusBadgeDTO, error)
-	GetMetric(ctx context.Context, projectID int64, metricType string) (*models.MetricCardDTO, error)
-	GetChartData(ctx context.Context, projectID int64, chartType string, suiteID *int64, buildID *int64, limit *int) (*models.DataChartDTO, error)
+	GetMetric(ctx context.Context, projectID int64, metricType string, includeQuarantined bool) (*models.MetricCardDTO, error)
+	GetChartData(ctx context.Context, projectID int64, chartType string, suiteID *int64, buildID *int64, limit *int, tag, test string, includeQuarantined bool) (*models.DataChartDTO, error)
 	GetAvailableWidgets(ctx context.Context) (*models.AvailableWidgetsDTO, error)
 }
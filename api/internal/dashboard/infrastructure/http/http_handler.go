@@ -45,7 +45,8 @@ func (h *DashboardHandler) GetMetric(w http.ResponseWriter, r *http.Request) {
 	}
 
 	metricType := r.PathValue("metricType")
-	metric, err := h.service.GetMetric(r.Context(), projectID, metricType)
+	includeQuarantined, _ := strconv.ParseBool(r.URL.Query().Get("include_quarantined"))
+	metric, err := h.service.GetMetric(r.Context(), projectID, metricType, includeQuarantined)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -113,7 +114,9 @@ func (h *DashboardHandler) GetChartData(w http.ResponseWriter, r *http.Request)
 		limit = &l
 	}
 
-	chartData, err := h.service.GetChartData(r.Context(), projectID, chartType, suiteID, buildID, limit)
+	includeQuarantined, _ := strconv.ParseBool(r.URL.Query().Get("include_quarantined"))
+	chartData, err := h.service.GetChartData(r.Context(), projectID, chartType, suiteID, buildID, limit, r.URL.Query().Get("tag"), r.URL.Query().Get("test"),
+		includeQuarantined)
 	if err != nil {
 		if err.Error() == fmt.Sprintf("unknown chart type: %s", chartType) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -0,0 +1,57 @@
+package application
+
+import (
+	"regexp"
+	"strings"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+)
+
+// attachmentMarker is the convention of the Jenkins JUnit attachments plugin: a test
+// case references a file by printing [[ATTACHMENT|path]] to its output
+var attachmentMarker = regexp.MustCompile(`\[\[ATTACHMENT\|([^\]\r\n]+)\]\]`)
+
+// maxAttachmentRefs bounds the attachment references kept for one import
+const maxAttachmentRefs = 1000
+
+// attachmentRefs collects the files referenced by the test cases of a report
+type attachmentRefs struct {
+	refs    []models.AttachmentRef
+	seen    map[models.AttachmentRef]bool
+	omitted int
+}
+
+// collect adds the files referenced in the output of a test case
+func (a *attachmentRefs) collect(result *models.TestCaseResult) {
+	for _, output := range []string{result.SystemOut, result.SystemErr} {
+		if !strings.Contains(output, "[[ATTACHMENT|") {
+			continue
+		}
+		for _, match := range attachmentMarker.FindAllStringSubmatch(output, -1) {
+			a.add(models.AttachmentRef{Classname: result.Classname, Name: result.Name, Path: strings.TrimSpace(match[1])})
+		}
+	}
+}
+
+func (a *attachmentRefs) add(ref models.AttachmentRef) {
+	if ref.Path == "" || a.seen[ref] {
+		return
+	}
+	if len(a.refs) >= maxAttachmentRefs {
+		a.omitted++
+		return
+	}
+	if a.seen == nil {
+		a.seen = make(map[models.AttachmentRef]bool)
+	}
+	a.seen[ref] = true
+	a.refs = append(a.refs, ref)
+}
+
+// list returns the collected references, warning about the ones left out
+func (a *attachmentRefs) list(warnings *importWarnings) []models.AttachmentRef {
+	if a.omitted > 0 {
+		warnings.add("%d attachment references beyond the first %d were ignored", a.omitted, maxAttachmentRefs)
+	}
+	return a.refs
+}
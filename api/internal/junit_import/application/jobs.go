@@ -0,0 +1,291 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/ports"
+)
+
+// Worker pool settings used when the configuration leaves them unset
+const (
+	DefaultImportWorkers    = 2
+	DefaultImportQueueSize  = 100
+	DefaultImportJobTimeout = 30 * time.Minute
+)
+
+type queuedImport struct {
+	job  *models.ImportJob
+	task ports.ImportTask
+}
+
+// ImportJobService implements the ImportJobService interface. Submitted jobs are queued
+// and run by a fixed pool of workers; their state is kept in the repository so that it
+// can be polled while they run.
+type ImportJobService struct {
+	repo    ports.ImportJobRepository
+	queue   chan queuedImport
+	timeout time.Duration
+	// stop cancels the jobs still running or queued when a shutdown runs out of time
+	base   context.Context
+	stop   context.CancelFunc
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewImportJobService starts workers goroutines consuming a queue of queueSize jobs, each of
+// which is stopped once it has run for timeout. Values of zero or less select
+// DefaultImportWorkers, DefaultImportQueueSize and DefaultImportJobTimeout.
+func NewImportJobService(repo ports.ImportJobRepository, workers, queueSize int, timeout time.Duration) ports.ImportJobService {
+	if workers <= 0 {
+		workers = DefaultImportWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultImportQueueSize
+	}
+	if timeout <= 0 {
+		timeout = DefaultImportJobTimeout
+	}
+
+	base, stop := context.WithCancel(context.Background())
+	s := &ImportJobService{repo: repo, queue: make(chan queuedImport, queueSize), timeout: timeout, base: base, stop: stop}
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+// Submit records a queued job and hands its task to the worker pool. A job repeating the
+// idempotency key or the content of an earlier upload to its suite, or to the same shard of
+// a sharded build, is not queued; the earlier job is returned instead, marked as replayed.
+// A job is refused with ErrQueueFull, and recorded as failed, when the queue has no room left.
+func (s *ImportJobService) Submit(ctx context.Context, job *models.ImportJob, task ports.ImportTask) (*models.ImportJob, error) {
+	if job == nil || task == nil || job.ProjectID <= 0 || job.SuiteID <= 0 {
+		return nil, errors.ErrInvalidRequest
+	}
+
+	created, err := s.createJob(ctx, job)
+	if err != nil || created.Replayed {
+		return created, err
+	}
+
+	if s.enqueue(queuedImport{job: created, task: task}) {
+		return created, nil
+	}
+	return nil, s.reject(ctx, created)
+}
+
+// createJob stores the job as queued, or returns the earlier job it repeats
+func (s *ImportJobService) createJob(ctx context.Context, job *models.ImportJob) (*models.ImportJob, error) {
+	if previous, err := s.previousJob(ctx, job); previous != nil || err != nil {
+		return previous, err
+	}
+
+	job.State = models.JobQueued
+	created, err := s.repo.CreateJob(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import job: %w", err)
+	}
+	if created != nil {
+		return created, nil
+	}
+
+	// The same upload may have been stored by a concurrent request since the lookup
+	if previous, err := s.previousJob(ctx, job); previous != nil || err != nil {
+		return previous, err
+	}
+	return nil, errors.ErrSuiteNotFound
+}
+
+// previousJob returns the earlier job of the suite and shard that the job repeats, or nil if
+// there is none. Reusing an idempotency key for a different report is refused with ErrKeyConflict.
+func (s *ImportJobService) previousJob(ctx context.Context, job *models.ImportJob) (*models.ImportJob, error) {
+	if job.IdempotencyKey == "" && job.ContentHash == "" {
+		return nil, nil
+	}
+	previous, err := s.repo.FindJob(ctx, job.SuiteID, job.Shard, job.IdempotencyKey, job.ContentHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find previous import job: %w", err)
+	}
+	if previous == nil {
+		return nil, nil
+	}
+	if previous.ProjectID != job.ProjectID {
+		return nil, errors.ErrSuiteNotFound
+	}
+	if job.IdempotencyKey != "" && previous.IdempotencyKey == job.IdempotencyKey && previous.ContentHash != job.ContentHash {
+		return nil, errors.ErrKeyConflict
+	}
+
+	previous.Replayed = true
+	return previous, nil
+}
+
+// reject records a job that found no room in the queue as failed
+func (s *ImportJobService) reject(ctx context.Context, job *models.ImportJob) error {
+	job.State = models.JobFailed
+	job.Error = errors.ErrQueueFull.Error()
+	if err := s.repo.FinishJob(ctx, job); err != nil {
+		return fmt.Errorf("failed to fail import job %d: %w", job.ID, err)
+	}
+	return errors.ErrQueueFull
+}
+
+// GetJob returns the current state of a job
+func (s *ImportJobService) GetJob(ctx context.Context, id int64) (*models.ImportJob, error) {
+	if id <= 0 {
+		return nil, errors.ErrInvalidRequest
+	}
+	job, err := s.repo.GetJob(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import job %d: %w", id, err)
+	}
+	if job == nil {
+		return nil, errors.ErrJobNotFound
+	}
+	return job, nil
+}
+
+// FailInterruptedJobs records the jobs left queued or running by an earlier run of the
+// server as failed, as their tasks were lost with it. It is meant to be called once at
+// startup, before any job is submitted, and returns the number of jobs failed.
+func (s *ImportJobService) FailInterruptedJobs(ctx context.Context) (int64, error) {
+	failed, err := s.repo.FailUnfinishedJobs(ctx, errors.ErrJobInterrupted.Error())
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail interrupted import jobs: %w", err)
+	}
+	return failed, nil
+}
+
+// Close stops accepting jobs and waits for the queued ones to finish
+func (s *ImportJobService) Close() {
+	_ = s.Shutdown(context.Background())
+}
+
+// Shutdown stops accepting jobs and waits for the queued ones to finish. When ctx is done
+// first, the running and queued jobs are stopped and recorded as failed, and the error of
+// ctx is returned once they are.
+func (s *ImportJobService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.stop()
+		return nil
+	case <-ctx.Done():
+		s.stop()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *ImportJobService) enqueue(queued queuedImport) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return false
+	}
+	select {
+	case s.queue <- queued:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *ImportJobService) work() {
+	defer s.wg.Done()
+	for queued := range s.queue {
+		s.run(queued.job, queued.task)
+	}
+}
+
+// run runs the task of a job and records its outcome. Jobs run detached from the
+// request that submitted them, until their timeout or a shutdown stops them; their
+// state is still recorded once they are stopped.
+func (s *ImportJobService) run(job *models.ImportJob, task ports.ImportTask) {
+	ctx, cancel := context.WithTimeout(s.base, s.timeout)
+	defer cancel()
+	record := context.WithoutCancel(ctx)
+
+	var result *models.ImportResult
+	var err error
+	if s.base.Err() != nil {
+		err = errors.ErrJobInterrupted
+	} else {
+		s.report(record, job.ID, models.ImportProgress{State: models.JobParsing})
+		result, err = runTask(ctx, task, func(progress models.ImportProgress) {
+			s.report(record, job.ID, progress)
+		})
+		err = stoppedJobError(ctx, s.base, s.timeout, err)
+	}
+	finishJob(job, result, err)
+
+	if err := s.repo.FinishJob(record, job); err != nil {
+		slog.Error("failed to finish import job", "job_id", job.ID, "error", err)
+	}
+}
+
+// stoppedJobError replaces the error of a task that failed because its job was stopped
+// with the reason it was stopped
+func stoppedJobError(ctx, base context.Context, timeout time.Duration, err error) error {
+	switch {
+	case err == nil || ctx.Err() == nil:
+		return err
+	case base.Err() != nil:
+		return errors.ErrJobInterrupted
+	default:
+		return fmt.Errorf("%w after %s", errors.ErrJobTimedOut, timeout)
+	}
+}
+
+// runTask runs a task, turning a panic into an error so that the job is not left running
+func runTask(ctx context.Context, task ports.ImportTask, progress models.ProgressFunc) (result *models.ImportResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("import panicked: %v", r)
+		}
+	}()
+	return task(ctx, progress)
+}
+
+func (s *ImportJobService) report(ctx context.Context, id int64, progress models.ImportProgress) {
+	if err := s.repo.UpdateJobProgress(ctx, id, progress); err != nil {
+		slog.Error("failed to update import job progress", "job_id", id, "error", err)
+	}
+}
+
+// finishJob records the outcome of an import on its job
+func finishJob(job *models.ImportJob, result *models.ImportResult, err error) {
+	if err != nil {
+		job.State = models.JobFailed
+		job.Error = err.Error()
+		return
+	}
+
+	job.State = models.JobDone
+	job.BuildID = &result.BuildID
+	job.BuildNumber = result.BuildNumber
+	job.Total, job.Passed, job.Failed = result.Total, result.Passed, result.Failed
+	job.Errored, job.Skipped = result.Errored, result.Skipped
+	job.Warnings = result.Warnings
+	job.Files = result.Files
+}
@@ -0,0 +1,237 @@
+package application
+
+import (
+	"strings"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+)
+
+// timestampLayouts are the suite timestamp formats emitted by common JUnit producers
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999Z0700",
+}
+
+// rerunProperty is the test case property marking a test case as rerun, as pytest-rerunfailures
+// reports a rerun test case again
+const rerunProperty = "rerun"
+
+// junitToSuites converts a parsed JUnit report into normalized suites
+func junitToSuites(junitData *models.JUnitTestSuites) []*models.SuiteResult {
+	suites := make([]*models.SuiteResult, 0, len(junitData.TestSuites))
+	for _, suite := range junitData.TestSuites {
+		suites = append(suites, convertSuite(suite))
+	}
+	return suites
+}
+
+func convertSuite(suite models.JUnitTestSuite) *models.SuiteResult {
+	result := newSuiteResult(suite)
+	for _, child := range suite.TestSuites {
+		result.Suites = append(result.Suites, convertSuite(child))
+	}
+	for _, tc := range suite.TestCases {
+		result.TestCases = append(result.TestCases, toTestCaseResult(suite.Name, tc))
+	}
+	return result
+}
+
+// newSuiteResult converts what a <testsuite> reports about itself, without its
+// test cases and nested suites
+func newSuiteResult(suite models.JUnitTestSuite) *models.SuiteResult {
+	return &models.SuiteResult{
+		Name:       suite.Name,
+		Hostname:   suite.Hostname,
+		Timestamp:  parseTimestamp(suite.Timestamp),
+		Tests:      suite.Tests,
+		Failures:   suite.Failures,
+		Errors:     suite.Errors,
+		Skipped:    suite.Skipped + suite.Disabled,
+		Time:       suite.Time,
+		Properties: convertProperties(suite.Properties),
+		SystemOut:  strings.TrimSpace(suite.SystemOut),
+		SystemErr:  strings.TrimSpace(suite.SystemErr),
+	}
+}
+
+// toTestCaseResult converts a test case; test cases without a classname take the name of their suite
+func toTestCaseResult(suiteName string, tc models.JUnitTestCase) *models.TestCaseResult {
+	classname := tc.Classname
+	if classname == "" {
+		classname = suiteName
+	}
+
+	result := &models.TestCaseResult{
+		Name:       tc.Name,
+		Classname:  classname,
+		File:       tc.File,
+		Status:     models.StatusPassed,
+		Time:       tc.Time,
+		Properties: convertProperties(tc.Properties),
+		SystemOut:  strings.TrimSpace(tc.SystemOut),
+		SystemErr:  strings.TrimSpace(tc.SystemErr),
+	}
+	result.Tags = propertyTags(result.Properties)
+
+	switch {
+	case len(tc.Failures) > 0:
+		result.Status = models.StatusFailed
+		result.Failure = combineFailures(tc)
+	case len(tc.Errors) > 0:
+		result.Status = models.StatusError
+		result.Failure = combineFailures(tc)
+	case tc.Skipped != nil:
+		result.Status = models.StatusSkipped
+		result.SkipMessage = firstNonEmpty(tc.Skipped.Message, strings.TrimSpace(tc.Skipped.Value))
+	}
+	addSurefireRetries(result, tc)
+	addPytestReruns(result, tc)
+	result.Rerun = isRerun(tc)
+	return result
+}
+
+// addPytestReruns records the failed runs pytest-rerunfailures reports inside a test case
+// as its attempts, which ran before its result
+func addPytestReruns(result *models.TestCaseResult, tc models.JUnitTestCase) {
+	for _, run := range tc.Reruns {
+		result.Retries = append(result.Retries, rerunAttempt(models.StatusFailed, run))
+	}
+}
+
+// isRerun reports whether a test case is marked as rerun, by the rerun elements of Maven
+// Surefire or pytest-rerunfailures or by a rerun property
+func isRerun(tc models.JUnitTestCase) bool {
+	if len(tc.FlakyFailures)+len(tc.FlakyErrors)+len(tc.RerunFailures)+len(tc.RerunErrors)+len(tc.Reruns) > 0 {
+		return true
+	}
+	for _, p := range tc.Properties {
+		if p.Name == rerunProperty {
+			return true
+		}
+	}
+	return false
+}
+
+// addSurefireRetries records the reruns Maven Surefire reports inside a test case as its
+// attempts. A test that passed when rerun follows its failed runs. The last rerun of a
+// test that kept failing becomes its result, following its first run and the other reruns.
+func addSurefireRetries(result *models.TestCaseResult, tc models.JUnitTestCase) {
+	for _, run := range tc.FlakyFailures {
+		result.Retries = append(result.Retries, rerunAttempt(models.StatusFailed, run))
+	}
+	for _, run := range tc.FlakyErrors {
+		result.Retries = append(result.Retries, rerunAttempt(models.StatusError, run))
+	}
+
+	var reruns []*models.Attempt
+	for _, run := range tc.RerunFailures {
+		reruns = append(reruns, rerunAttempt(models.StatusFailed, run))
+	}
+	for _, run := range tc.RerunErrors {
+		reruns = append(reruns, rerunAttempt(models.StatusError, run))
+	}
+	if len(reruns) == 0 || result.Failure == nil {
+		return
+	}
+
+	last := reruns[len(reruns)-1]
+	result.Retries = append(result.Retries, &models.Attempt{Status: result.Status, Time: result.Time, Failure: result.Failure})
+	result.Retries = append(result.Retries, reruns[:len(reruns)-1]...)
+	result.Status, result.Failure = last.Status, last.Failure
+	if last.Time > 0 {
+		result.Time = last.Time
+	}
+}
+
+func rerunAttempt(status string, run models.JUnitRerun) *models.Attempt {
+	return &models.Attempt{
+		Status:  status,
+		Time:    run.Time,
+		Failure: &models.FailureDetail{Message: run.Message, Type: run.Type, Details: strings.TrimSpace(firstNonEmpty(run.StackTrace, run.Value))},
+	}
+}
+
+// combineFailures folds every <failure> and <error> of a test case into the single
+// failure row allowed per execution. The first entry provides message and type.
+func combineFailures(tc models.JUnitTestCase) *models.FailureDetail {
+	var entries []models.FailureDetail
+	for _, f := range tc.Failures {
+		entries = append(entries, models.FailureDetail{Message: f.Message, Type: f.Type, Details: strings.TrimSpace(f.Value)})
+	}
+	for _, e := range tc.Errors {
+		entries = append(entries, models.FailureDetail{Message: e.Message, Type: e.Type, Details: strings.TrimSpace(e.Value)})
+	}
+
+	detail := entries[0]
+	if len(entries) == 1 {
+		return &detail
+	}
+
+	sections := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		header := entry.Message
+		if entry.Type != "" {
+			header = strings.TrimSpace(entry.Type + ": " + entry.Message)
+		}
+		sections = append(sections, strings.TrimSpace(header+"\n"+entry.Details))
+	}
+	detail.Details = strings.Join(sections, "\n\n")
+	return &detail
+}
+
+func convertProperties(properties []models.JUnitProperty) []models.Property {
+	if len(properties) == 0 {
+		return nil
+	}
+	result := make([]models.Property, 0, len(properties))
+	for _, p := range properties {
+		value := p.Value
+		if value == "" {
+			value = strings.TrimSpace(p.Text)
+		}
+		result = append(result, models.Property{Name: p.Name, Value: value})
+	}
+	return result
+}
+
+// propertyTags reads the tags of a test case from its tag properties, holding one tag
+// each, and its tags properties, holding comma-separated tags
+func propertyTags(properties []models.Property) []string {
+	var tags []string
+	for _, p := range properties {
+		switch strings.ToLower(p.Name) {
+		case "tag":
+			tags = append(tags, p.Value)
+		case "tags":
+			tags = append(tags, strings.Split(p.Value, ",")...)
+		}
+	}
+	return models.NormalizeTags(tags)
+}
+
+// parseTimestamp parses a suite timestamp; timestamps without a zone are taken as UTC
+func parseTimestamp(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -1,19 +1,263 @@
-// Temporarily disabled due to cross-domain dependencies that need refactoring
 package application
 
 import (
 	"context"
 	"fmt"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/ports"
 )
 
+const defaultCIProvider = "unknown"
+
 // JUnitImportService implements the JUnitImportService interface
-type JUnitImportService struct{}
+type JUnitImportService struct {
+	repo ports.JUnitImportRepository
+}
+
+func NewJUnitImportService(repo ports.JUnitImportRepository) ports.JUnitImportService {
+	return &JUnitImportService{repo: repo}
+}
+
+// ProcessJUnitData normalizes a parsed JUnit report and stores it as a new build of the suite
+func (s *JUnitImportService) ProcessJUnitData(ctx context.Context, projectID int64, suiteID int64, opts *models.ImportOptions, junitData *models.JUnitTestSuites) (*models.ImportResult, error) {
+	if junitData == nil {
+		return nil, errors.ErrInvalidReport
+	}
+	return s.ProcessReport(ctx, projectID, suiteID, opts, junitToSuites(junitData))
+}
+
+// ProcessReport stores an already normalized report, whatever its source format, as a new build of the suite
+func (s *JUnitImportService) ProcessReport(ctx context.Context, projectID int64, suiteID int64, opts *models.ImportOptions, suites []*models.SuiteResult) (*models.ImportResult, error) {
+	if err := s.checkSuite(ctx, projectID, suiteID); err != nil {
+		return nil, err
+	}
+
+	summary := summarize(suites)
+	if summary.Total == 0 {
+		return nil, errors.ErrEmptyReport
+	}
+	summary.Duration = reportDuration(suites)
+
+	summary.Warnings, summary.Attachments = reportWarnings(suites)
+
+	build := newImportBuild(projectID, suiteID, opts, summary)
+	if startedAt := earliestTimestamp(suites); startedAt != nil {
+		build.CreatedAt = *startedAt
+	}
+
+	reportProgress(progressOf(opts), models.JobWriting, summary)
+	buildID, err := s.repo.SaveImport(ctx, build, suites)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save import: %w", err)
+	}
+	summary.Warnings = append(summary.Warnings, build.Warnings...)
+
+	return completeResult(summary, build, buildID), nil
+}
+
+// StreamJUnitData stores a JUnit report as a new build of the suite while stream parses
+// it. Test cases are handed to the repository as they are read, so that only the open
+// suites and the running totals of the report are held in memory.
+func (s *JUnitImportService) StreamJUnitData(ctx context.Context, projectID int64, suiteID int64, opts *models.ImportOptions, stream ports.JUnitStream) (*models.ImportResult, error) {
+	if stream == nil {
+		return nil, errors.ErrInvalidReport
+	}
+	return s.StreamReports(ctx, projectID, suiteID, opts, func(ctx context.Context, sink ports.ReportSink) error {
+		return stream(ctx, sink)
+	})
+}
+
+// StreamReports stores the reports of an archive as one new build of the suite while
+// stream parses them, streaming JUnit reports like StreamJUnitData does
+func (s *JUnitImportService) StreamReports(ctx context.Context, projectID int64, suiteID int64, opts *models.ImportOptions, stream ports.ReportStream) (*models.ImportResult, error) {
+	if stream == nil {
+		return nil, errors.ErrInvalidReport
+	}
+	if err := s.checkSuite(ctx, projectID, suiteID); err != nil {
+		return nil, err
+	}
+
+	build := newImportBuild(projectID, suiteID, opts, &models.ImportResult{})
+	session, err := s.repo.BeginImport(ctx, build)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin import: %w", err)
+	}
+	defer func() {
+		_ = session.Rollback()
+	}()
+
+	importer := &junitImporter{session: session, progress: progressOf(opts)}
+	if err := stream(ctx, importer); err != nil {
+		return nil, err
+	}
+	summary := importer.finish(build)
+	if summary.Total == 0 {
+		return nil, errors.ErrEmptyReport
+	}
+
+	importer.report(models.JobWriting)
+	buildID, err := session.Commit(ctx, build)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save import: %w", err)
+	}
+	summary.Warnings = append(summary.Warnings, build.Warnings...)
+
+	return completeResult(summary, build, buildID), nil
+}
+
+// checkSuite verifies that the suite an import targets belongs to the project
+func (s *JUnitImportService) checkSuite(ctx context.Context, projectID, suiteID int64) error {
+	if projectID <= 0 || suiteID <= 0 {
+		return errors.ErrInvalidRequest
+	}
+
+	suiteProjectID, err := s.repo.GetSuiteProjectID(ctx, suiteID)
+	if err != nil {
+		return fmt.Errorf("failed to look up test suite %d: %w", suiteID, err)
+	}
+	if suiteProjectID != projectID {
+		return errors.ErrSuiteNotFound
+	}
+	return nil
+}
+
+func progressOf(opts *models.ImportOptions) models.ProgressFunc {
+	if opts == nil {
+		return nil
+	}
+	return opts.Progress
+}
+
+func completeResult(summary *models.ImportResult, build *models.ImportBuild, buildID int64) *models.ImportResult {
+	summary.BuildID = buildID
+	summary.ProjectID = build.ProjectID
+	summary.SuiteID = build.SuiteID
+	summary.BuildNumber = build.BuildNumber
+	return summary
+}
+
+func newImportBuild(projectID, suiteID int64, opts *models.ImportOptions, summary *models.ImportResult) *models.ImportBuild {
+	now := time.Now().UTC()
+	build := &models.ImportBuild{
+		ProjectID:     projectID,
+		SuiteID:       suiteID,
+		BuildNumber:   now.Format("20060102-150405"),
+		CIProvider:    defaultCIProvider,
+		TestCaseCount: summary.Total,
+		Duration:      summary.Duration,
+		CreatedAt:     now,
+	}
+	if opts == nil {
+		return build
+	}
+	if opts.BuildNumber != "" {
+		build.BuildNumber = opts.BuildNumber
+	}
+	if opts.CIProvider != "" {
+		build.CIProvider = opts.CIProvider
+	}
+	build.CIURL = opts.CIURL
+	build.BuildMetadata = opts.BuildMetadata
+	build.Shard = opts.Shard
+	return build
+}
+
+// summarize counts the test cases of all suites per status
+func summarize(suites []*models.SuiteResult) *models.ImportResult {
+	summary := &models.ImportResult{}
+	walkTestCases(suites, func(result *models.TestCaseResult) {
+		countResult(summary, result)
+	})
+	return summary
+}
+
+// reportWarnings lists the problems found in a parsed report, along with the files its
+// test cases reference as attachments
+func reportWarnings(suites []*models.SuiteResult) ([]string, []models.AttachmentRef) {
+	var warnings importWarnings
+	var attachments attachmentRefs
+	walkTestCases(suites, func(result *models.TestCaseResult) {
+		if result.Name == "" {
+			warnings.add("test case of class %q without a name", result.Classname)
+		}
+		attachments.collect(result)
+	})
+	refs := attachments.list(&warnings)
+	return warnings.list(), refs
+}
+
+// countResult adds one test case to the totals of a summary
+func countResult(summary *models.ImportResult, result *models.TestCaseResult) {
+	summary.Total++
+	switch result.Status {
+	case models.StatusPassed:
+		summary.Passed++
+	case models.StatusFailed:
+		summary.Failed++
+	case models.StatusError:
+		summary.Errored++
+	case models.StatusSkipped:
+		summary.Skipped++
+	}
+}
+
+// walkTestCases calls fn for every test case of the suites and their nested suites
+func walkTestCases(suites []*models.SuiteResult, fn func(*models.TestCaseResult)) {
+	for _, suite := range suites {
+		walkResults(suite.TestCases, fn)
+		walkTestCases(suite.Suites, fn)
+	}
+}
+
+// walkResults calls fn for every test case and, depth first, its subtests
+func walkResults(results []*models.TestCaseResult, fn func(*models.TestCaseResult)) {
+	for _, tc := range results {
+		fn(tc)
+		walkResults(tc.Subtests, fn)
+	}
+}
+
+// reportDuration sums the reported time of the top-level suites, falling back
+// to the time of their test cases when a suite does not report one
+func reportDuration(suites []*models.SuiteResult) float64 {
+	var total float64
+	for _, suite := range suites {
+		if suite.Time > 0 {
+			total += suite.Time
+			continue
+		}
+		total += testCaseTime(suite)
+	}
+	return total
+}
 
-func NewJUnitImportService() interface{} {
-	return &JUnitImportService{}
+// testCaseTime sums the time of the test cases of a suite and its nested suites.
+// Subtests are not counted, their time is part of the time of their parent.
+func testCaseTime(suite *models.SuiteResult) float64 {
+	var total float64
+	for _, tc := range suite.TestCases {
+		total += tc.Time
+	}
+	for _, child := range suite.Suites {
+		total += testCaseTime(child)
+	}
+	return total
 }
 
-func (s *JUnitImportService) ProcessJUnitData(ctx context.Context, projectID int64, suiteID int64, junitData interface{}) (interface{}, error) {
-	// Implementation temporarily disabled
-	return nil, fmt.Errorf("JUnit import service temporarily disabled")
+// earliestTimestamp returns the earliest suite start time reported, if any
+func earliestTimestamp(suites []*models.SuiteResult) *time.Time {
+	var earliest *time.Time
+	for _, suite := range suites {
+		candidate := suite.Timestamp
+		if nested := earliestTimestamp(suite.Suites); nested != nil && (candidate == nil || nested.Before(*candidate)) {
+			candidate = nested
+		}
+		if candidate != nil && (earliest == nil || candidate.Before(*earliest)) {
+			earliest = candidate
+		}
+	}
+	return earliest
 }
@@ -0,0 +1,177 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/ports"
+)
+
+// progressInterval is the number of test cases between two progress reports
+const progressInterval = 1000
+
+// openSuite is a suite of a streamed report whose end was not read yet
+type openSuite struct {
+	name      string
+	testCases int
+	nested    bool
+}
+
+// junitImporter converts a streamed JUnit report into normalized results and writes
+// them to an import session. It only keeps the open suites and the running totals
+// of the report.
+type junitImporter struct {
+	session     ports.ImportSession
+	progress    models.ProgressFunc
+	suites      []*openSuite
+	summary     models.ImportResult
+	warnings    importWarnings
+	attachments attachmentRefs
+	caseTime    float64 // time of the test cases of the open top-level suite
+	startedAt   *time.Time
+	reportIndex int // index of the report being read, for archives of several reports
+}
+
+func (i *junitImporter) StartSuite(ctx context.Context, suite *models.JUnitTestSuite) error {
+	result := newSuiteResult(*suite)
+	if result.Timestamp != nil && (i.startedAt == nil || result.Timestamp.Before(*i.startedAt)) {
+		i.startedAt = result.Timestamp
+	}
+	if len(i.suites) == 0 {
+		i.caseTime = 0
+	} else {
+		i.suites[len(i.suites)-1].nested = true
+	}
+	i.suites = append(i.suites, &openSuite{name: suite.Name})
+	return i.session.StartSuite(ctx, result)
+}
+
+func (i *junitImporter) AddTestCase(ctx context.Context, testCase *models.JUnitTestCase) error {
+	if len(i.suites) == 0 {
+		return fmt.Errorf("%w: <testcase> outside of a <testsuite>", errors.ErrInvalidReport)
+	}
+	suite := i.suites[len(i.suites)-1]
+	suite.testCases++
+	if testCase.Name == "" {
+		i.warnings.add("test case without a name in suite %q", suite.name)
+	}
+
+	result := toTestCaseResult(suite.name, *testCase)
+	result.Report = i.reportIndex
+	countResult(&i.summary, result)
+	i.caseTime += result.Time
+	i.attachments.collect(result)
+	if i.summary.Total%progressInterval == 0 {
+		i.report(models.JobParsing)
+	}
+	return i.session.AddTestCase(ctx, result)
+}
+
+// EndSuite closes a suite; the duration of the report is summed over its top-level
+// suites like reportDuration does for a parsed report
+func (i *junitImporter) EndSuite(ctx context.Context, suite *models.JUnitTestSuite) error {
+	if len(i.suites) == 0 {
+		return fmt.Errorf("%w: unbalanced </testsuite>", errors.ErrInvalidReport)
+	}
+	open := i.suites[len(i.suites)-1]
+	if !open.nested && suite.Tests > 0 && suite.Tests != open.testCases {
+		i.warnings.add("suite %q declares %d tests but contains %d", suite.Name, suite.Tests, open.testCases)
+	}
+
+	result := newSuiteResult(*suite)
+	i.suites = i.suites[:len(i.suites)-1]
+	if len(i.suites) == 0 {
+		if result.Time > 0 {
+			i.summary.Duration += result.Time
+		} else {
+			i.summary.Duration += i.caseTime
+		}
+	}
+	return i.session.EndSuite(ctx, result)
+}
+
+// StartReport starts the next report of an archive
+func (i *junitImporter) StartReport(ctx context.Context) error {
+	if len(i.suites) > 0 {
+		return fmt.Errorf("%w: report started inside of a <testsuite>", errors.ErrInvalidReport)
+	}
+	i.reportIndex++
+	return nil
+}
+
+// AddSuite writes a suite parsed whole from a report of another format, with its test
+// cases and nested suites, as a top-level suite of the build. It is counted like
+// ProcessReport counts the suites of a parsed report.
+func (i *junitImporter) AddSuite(ctx context.Context, suite *models.SuiteResult) error {
+	if len(i.suites) > 0 {
+		return fmt.Errorf("%w: suite %q added inside of a <testsuite>", errors.ErrInvalidReport, suite.Name)
+	}
+	suites := []*models.SuiteResult{suite}
+	if startedAt := earliestTimestamp(suites); startedAt != nil && (i.startedAt == nil || startedAt.Before(*i.startedAt)) {
+		i.startedAt = startedAt
+	}
+	walkTestCases(suites, func(result *models.TestCaseResult) {
+		if result.Name == "" {
+			i.warnings.add("test case of class %q without a name", result.Classname)
+		}
+		result.Report = i.reportIndex
+		countResult(&i.summary, result)
+		i.attachments.collect(result)
+	})
+	i.summary.Duration += reportDuration(suites)
+	i.report(models.JobParsing)
+	return i.writeSuite(ctx, suite)
+}
+
+// writeSuite replays a parsed suite and its nested suites into the import session
+func (i *junitImporter) writeSuite(ctx context.Context, suite *models.SuiteResult) error {
+	if err := i.session.StartSuite(ctx, suite); err != nil {
+		return err
+	}
+	for _, result := range suite.TestCases {
+		if err := i.session.AddTestCase(ctx, result); err != nil {
+			return err
+		}
+	}
+	for _, child := range suite.Suites {
+		if err := i.writeSuite(ctx, child); err != nil {
+			return err
+		}
+	}
+	return i.session.EndSuite(ctx, suite)
+}
+
+// finish completes the build with the totals of the streamed report and returns them
+func (i *junitImporter) finish(build *models.ImportBuild) *models.ImportResult {
+	build.TestCaseCount = i.summary.Total
+	build.Duration = i.summary.Duration
+	if i.startedAt != nil {
+		build.CreatedAt = *i.startedAt
+	}
+	summary := i.summary
+	summary.Attachments = i.attachments.list(&i.warnings)
+	summary.Warnings = i.warnings.list()
+	return &summary
+}
+
+func (i *junitImporter) report(state string) {
+	reportProgress(i.progress, state, &i.summary)
+}
+
+// reportProgress passes the totals counted so far to progress, if set
+func reportProgress(progress models.ProgressFunc, state string, summary *models.ImportResult) {
+	if progress == nil {
+		return
+	}
+	progress(models.ImportProgress{
+		State:   state,
+		Total:   summary.Total,
+		Passed:  summary.Passed,
+		Failed:  summary.Failed,
+		Errored: summary.Errored,
+		Skipped: summary.Skipped,
+	})
+}
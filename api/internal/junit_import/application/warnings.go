@@ -0,0 +1,28 @@
+package application
+
+import "fmt"
+
+// maxImportWarnings bounds the warnings kept for one import
+const maxImportWarnings = 100
+
+// importWarnings collects problems found in a report that do not stop its import
+type importWarnings struct {
+	messages []string
+	omitted  int
+}
+
+func (w *importWarnings) add(format string, args ...interface{}) {
+	if len(w.messages) >= maxImportWarnings {
+		w.omitted++
+		return
+	}
+	w.messages = append(w.messages, fmt.Sprintf(format, args...))
+}
+
+// list returns the collected warnings, ending with how many were left out, if any
+func (w *importWarnings) list() []string {
+	if w.omitted == 0 {
+		return w.messages
+	}
+	return append(w.messages, fmt.Sprintf("%d more warnings omitted", w.omitted))
+}
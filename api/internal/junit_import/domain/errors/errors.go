@@ -0,0 +1,19 @@
+package errors
+
+import "errors"
+
+var (
+	ErrInvalidReport  = errors.New("invalid test report")
+	ErrEmptyReport    = errors.New("test report contains no test cases")
+	ErrSuiteNotFound  = errors.New("test suite not found in project")
+	ErrInvalidRequest = errors.New("invalid import request")
+	ErrReportTooLarge = errors.New("test report element exceeds the import memory limit")
+	ErrJobNotFound    = errors.New("import job not found")
+	ErrQueueFull      = errors.New("import queue is full, retry later")
+	ErrKeyConflict    = errors.New("idempotency key was already used for a different report")
+	ErrUnknownFormat  = errors.New("unrecognized report format")
+	ErrBuildNotFound  = errors.New("build not found in test suite")
+	ErrBuildFinalized = errors.New("build was finalized and accepts no more shards")
+	ErrJobInterrupted = errors.New("import interrupted by a server shutdown")
+	ErrJobTimedOut    = errors.New("import timed out")
+)
@@ -1,25 +1,76 @@
 package models
 
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
 // JUnitTestSuites represents JUnit XML data
 type JUnitTestSuites struct {
 	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       float64          `xml:"time,attr"`
+	Timestamp  string           `xml:"timestamp,attr"`
 	TestSuites []JUnitTestSuite `xml:"testsuite"`
 }
 
-// JUnitTestSuite represents a test suite in JUnit XML
+// JUnitTestSuite represents a test suite in JUnit XML. Suites may be nested.
 type JUnitTestSuite struct {
-	Name      string          `xml:"name,attr"`
-	TestCases []JUnitTestCase `xml:"testcase"`
+	Name       string           `xml:"name,attr"`
+	ID         string           `xml:"id,attr"`
+	Package    string           `xml:"package,attr"`
+	Hostname   string           `xml:"hostname,attr"`
+	Timestamp  string           `xml:"timestamp,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Disabled   int              `xml:"disabled,attr"`
+	Time       float64          `xml:"time,attr"`
+	Properties []JUnitProperty  `xml:"properties>property"`
+	TestSuites []JUnitTestSuite `xml:"testsuite"`
+	TestCases  []JUnitTestCase  `xml:"testcase"`
+	SystemOut  string           `xml:"system-out"`
+	SystemErr  string           `xml:"system-err"`
 }
 
 // JUnitTestCase represents a test case in JUnit XML
 type JUnitTestCase struct {
-	Name      string        `xml:"name,attr"`
-	Classname string        `xml:"classname,attr"`
-	Time      float64       `xml:"time,attr"`
-	Failure   *JUnitFailure `xml:"failure,omitempty"`
-	Error     *JUnitError   `xml:"error,omitempty"`
-	Skipped   *JUnitSkipped `xml:"skipped,omitempty"`
+	Name       string          `xml:"name,attr"`
+	Classname  string          `xml:"classname,attr"`
+	File       string          `xml:"file,attr"`
+	Line       int             `xml:"line,attr"`
+	Assertions int             `xml:"assertions,attr"`
+	Time       float64         `xml:"time,attr"`
+	Properties []JUnitProperty `xml:"properties>property"`
+	Failures   []JUnitFailure  `xml:"failure"`
+	Errors     []JUnitError    `xml:"error"`
+	Skipped    *JUnitSkipped   `xml:"skipped,omitempty"`
+	SystemOut  string          `xml:"system-out"`
+	SystemErr  string          `xml:"system-err"`
+	// Maven Surefire reports the runs of a rerun test inside its test case: flaky
+	// elements are failed runs of a test that passed when rerun, rerun elements are
+	// the reruns of a test that kept failing
+	FlakyFailures []JUnitRerun `xml:"flakyFailure"`
+	FlakyErrors   []JUnitRerun `xml:"flakyError"`
+	RerunFailures []JUnitRerun `xml:"rerunFailure"`
+	RerunErrors   []JUnitRerun `xml:"rerunError"`
+	// pytest-rerunfailures reports the failed runs before the result of a rerun test as
+	// rerun elements
+	Reruns []JUnitRerun `xml:"rerun"`
+}
+
+// JUnitProperty represents a <property> of a suite or test case.
+// The value may be given as an attribute or as element text.
+type JUnitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"`
 }
 
 // JUnitFailure represents a test failure in JUnit XML
@@ -36,7 +87,368 @@ type JUnitError struct {
 	Value   string `xml:",chardata"`
 }
 
+// JUnitRerun represents a failed run of a rerun test in a Maven Surefire or pytest-rerunfailures report
+type JUnitRerun struct {
+	Message    string  `xml:"message,attr"`
+	Type       string  `xml:"type,attr"`
+	Time       float64 `xml:"time,attr"`
+	StackTrace string  `xml:"stackTrace"`
+	Value      string  `xml:",chardata"`
+}
+
 // JUnitSkipped represents a skipped test in JUnit XML
 type JUnitSkipped struct {
 	Message string `xml:"message,attr"`
+	Value   string `xml:",chardata"`
+}
+
+// Execution statuses written to build_test_case_executions
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusError   = "error"
+	StatusSkipped = "skipped"
+)
+
+// ImportOptions carries the build metadata supplied alongside an uploaded report
+type ImportOptions struct {
+	BuildNumber string `json:"build_number"`
+	CIProvider  string `json:"ci_provider"`
+	CIURL       string `json:"ci_url,omitempty"`
+	BuildMetadata
+	// Shard, when set, adds the report to an open sharded build instead of creating one
+	Shard *Shard `json:"shard,omitempty"`
+	// Progress, when set, is called as the import advances
+	Progress ProgressFunc `json:"-"`
+}
+
+// Shard names the sharded build an upload belongs to and the index of the CI shard
+// that produced it. The build keeps its own number and metadata; the totals of the
+// shards are merged into it.
+type Shard struct {
+	BuildID int64 `json:"build_id"`
+	Index   int   `json:"index"`
+}
+
+// BuildMetadata describes the commit and branch an imported build ran against and
+// what triggered it
+type BuildMetadata struct {
+	CommitSHA  string `json:"commit_sha,omitempty"`
+	Branch     string `json:"branch,omitempty"`
+	BaseBranch string `json:"base_branch,omitempty"` // branch a pull request merges into
+	PRNumber   *int64 `json:"pr_number,omitempty"`
+	Author     string `json:"author,omitempty"`
+	Trigger    string `json:"trigger,omitempty"` // e.g. push, pull_request, schedule or manual
+	// Properties are arbitrary build-wide key/value pairs, e.g. the environment tested
+	Properties map[string]string `json:"properties,omitempty"`
+	// Tags label the build, e.g. smoke or nightly
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Import job states
+const (
+	JobQueued  = "queued"
+	JobParsing = "parsing"
+	JobWriting = "writing"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// ImportProgress is a snapshot of a running import: its state and the test cases counted so far
+type ImportProgress struct {
+	State   string `json:"state"`
+	Total   int    `json:"total"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	Errored int    `json:"errored"`
+	Skipped int    `json:"skipped"`
+}
+
+// ProgressFunc receives the progress of an import
+type ProgressFunc func(progress ImportProgress)
+
+// ImportJob is an upload queued for import in the background
+type ImportJob struct {
+	ID          int64  `json:"id"`
+	ProjectID   int64  `json:"project_id"`
+	SuiteID     int64  `json:"test_suite_id"`
+	Format      string `json:"format"`
+	BuildNumber string `json:"build_number,omitempty"`
+	// IdempotencyKey is the Idempotency-Key header of the upload, if any
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// ContentHash is the hex SHA-256 of the uploaded report
+	ContentHash string `json:"content_hash,omitempty"`
+	// Shard is set when the upload adds a shard to a sharded build. An upload only repeats
+	// an earlier one to the same shard, as shards may well upload identical reports.
+	Shard      *Shard       `json:"shard,omitempty"`
+	State      string       `json:"state"`
+	Total      int          `json:"total"`
+	Passed     int          `json:"passed"`
+	Failed     int          `json:"failed"`
+	Errored    int          `json:"errored"`
+	Skipped    int          `json:"skipped"`
+	Warnings   []string     `json:"warnings"`
+	Files      []FileResult `json:"files,omitempty"`
+	BuildID    *int64       `json:"build_id,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	StartedAt  *time.Time   `json:"started_at,omitempty"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	// Replayed is set when an upload repeated this job's upload and was not imported again
+	Replayed bool `json:"replayed,omitempty"`
+}
+
+// DefaultImportMemoryLimit is the memory ceiling of one import when none is configured
+const DefaultImportMemoryLimit = 64 << 20
+
+// DefaultMaxLogSize is the size of the logs stored per execution when none is configured
+const DefaultMaxLogSize = 1 << 20
+
+// ImportLimits splits the memory ceiling of an import between its stages, so that
+// the memory used stays the same however large the uploaded report is
+type ImportLimits struct {
+	UploadMemory   int64 // part of a multipart upload kept in memory; the rest spills to disk
+	MaxElementSize int64 // largest single report element, e.g. one <testcase>, held while parsing
+	BatchBytes     int64 // size of pending test case results after which they are written out
+	ArchiveFile    int64 // largest report of an archive upload that is parsed whole rather than streamed
+	MaxLogSize     int64 // size of the standard output and error stored per execution; the rest is cut
+}
+
+// NewImportLimits derives the limits of each stage from a memory ceiling in bytes.
+// A ceiling of zero or less selects DefaultImportMemoryLimit, and a log size of zero
+// or less DefaultMaxLogSize.
+func NewImportLimits(memoryLimit, maxLogSize int64) ImportLimits {
+	if memoryLimit <= 0 {
+		memoryLimit = DefaultImportMemoryLimit
+	}
+	if maxLogSize <= 0 {
+		maxLogSize = DefaultMaxLogSize
+	}
+	return ImportLimits{
+		UploadMemory:   memoryLimit / 4,
+		MaxElementSize: memoryLimit / 4,
+		BatchBytes:     memoryLimit / 4,
+		ArchiveFile:    memoryLimit / 4,
+		MaxLogSize:     maxLogSize,
+	}
+}
+
+// Log streams of an execution
+const (
+	LogStdout = "stdout"
+	LogStderr = "stderr"
+)
+
+// logTruncationMarker replaces the middle of a log cut to the size limit
+const logTruncationMarker = "\n\n[... %d bytes truncated ...]\n\n"
+
+// ExecutionLog is a log of an execution as it is stored
+type ExecutionLog struct {
+	Stream       string
+	Text         string
+	OriginalSize int64 // size of the log as reported, before it was cut
+	Truncated    bool
+}
+
+// ExecutionLogs returns the standard output and error of a result that are not empty,
+// cut to maxSize bytes together. A stream smaller than its half of maxSize leaves the
+// rest to the other one. A log that is cut keeps its beginning and its end, with a
+// marker naming the number of bytes left out in between.
+func ExecutionLogs(result *TestCaseResult, maxSize int64) []ExecutionLog {
+	outMax, errMax := splitLogSize(int64(len(result.SystemOut)), int64(len(result.SystemErr)), maxSize)
+
+	var logs []ExecutionLog
+	if result.SystemOut != "" {
+		logs = append(logs, truncateLog(LogStdout, result.SystemOut, outMax))
+	}
+	if result.SystemErr != "" {
+		logs = append(logs, truncateLog(LogStderr, result.SystemErr, errMax))
+	}
+	return logs
+}
+
+// splitLogSize shares maxSize bytes between the standard output and error
+func splitLogSize(outSize, errSize, maxSize int64) (int64, int64) {
+	half := maxSize / 2
+	switch {
+	case outSize+errSize <= maxSize:
+		return outSize, errSize
+	case outSize <= half:
+		return outSize, maxSize - outSize
+	case errSize <= maxSize-half:
+		return maxSize - errSize, errSize
+	default:
+		return half, maxSize - half
+	}
+}
+
+// truncateLog cuts text to maxSize bytes, not counting the marker, without splitting a character
+func truncateLog(stream, text string, maxSize int64) ExecutionLog {
+	log := ExecutionLog{Stream: stream, Text: text, OriginalSize: int64(len(text))}
+	if log.OriginalSize <= maxSize {
+		return log
+	}
+
+	head := int(maxSize / 2)
+	for head > 0 && !utf8.RuneStart(text[head]) {
+		head--
+	}
+	tail := len(text) - int(maxSize-maxSize/2)
+	for tail < len(text) && !utf8.RuneStart(text[tail]) {
+		tail++
+	}
+
+	log.Text = text[:head] + fmt.Sprintf(logTruncationMarker, tail-head) + text[tail:]
+	log.Truncated = true
+	return log
+}
+
+// ImportBuild is the build row created for an import
+type ImportBuild struct {
+	ProjectID   int64
+	SuiteID     int64
+	BuildNumber string
+	CIProvider  string
+	CIURL       string
+	BuildMetadata
+	// Shard is set when the import adds a shard to an existing build
+	Shard         *Shard
+	TestCaseCount int
+	Duration      float64
+	CreatedAt     time.Time
+	// Warnings are the problems found while the build was written, such as test cases
+	// reported more than once without being rerun
+	Warnings []string
+}
+
+// SuiteResult is a normalized suite of an import. Top-level suites are stored
+// against the suite targeted by the upload; nested suites become its children.
+type SuiteResult struct {
+	Name       string
+	Hostname   string
+	Timestamp  *time.Time
+	Tests      int
+	Failures   int
+	Errors     int
+	Skipped    int
+	Time       float64
+	Properties []Property
+	SystemOut  string
+	SystemErr  string
+	Suites     []*SuiteResult
+	TestCases  []*TestCaseResult
+}
+
+// TestCaseResult is a normalized test case outcome ready to be persisted
+type TestCaseResult struct {
+	Name      string
+	Classname string
+	// ExternalID is a stable identity reported by the test framework, e.g. an Allure
+	// historyId. When set it identifies the test case across builds instead of its name.
+	ExternalID string
+	File       string // source file reported for the test case
+	Status     string
+	Time       float64
+	Properties []Property
+	SystemOut  string
+	SystemErr  string
+	// SkipMessage is the reason reported for a skipped test case
+	SkipMessage string
+	Failure     *FailureDetail
+	// Tags are attached to the test case, e.g. the tags of a Cucumber scenario
+	Tags []string
+	// Subtests are stored as test cases whose parent is this test case
+	Subtests []*TestCaseResult
+	// Retries are the earlier attempts of a test case that was retried in the build,
+	// oldest first; the result itself is its last attempt
+	Retries []*Attempt
+	// Rerun marks a result reported as a rerun of its test case, whose earlier failed
+	// result in the build becomes one of its attempts even when reported elsewhere
+	Rerun bool
+	// Report tells the reports of an archive upload apart; a result reported again in the
+	// same report after it failed is a rerun as well
+	Report int
+	// Fingerprint identifies the test case by the fields its project identifies test
+	// cases by; it is computed when the result is written, along with Params
+	Fingerprint string
+	// Params are the parameters at the end of the name of a parameterized test case,
+	// recognized by the parameter patterns of its project
+	Params string
+}
+
+// Attempt is a run of a test case that was followed by a retry
+type Attempt struct {
+	Status  string
+	Time    float64
+	Failure *FailureDetail
+}
+
+// Property is a name/value pair reported by a suite or test case
+type Property struct {
+	Name  string
+	Value string
+}
+
+// MaxTagLength bounds the name of a tag; longer tags are dropped on import
+const MaxTagLength = 100
+
+// NormalizeTags trims tags and the @ of Cucumber-style tags, and drops empty, overlong
+// and repeated ones
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "@"))
+		if tag == "" || len(tag) > MaxTagLength || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// FailureDetail is the failure row written for a failed or errored test case
+type FailureDetail struct {
+	Message string
+	Type    string
+	Details string
+}
+
+// ImportResult summarizes a completed import
+type ImportResult struct {
+	BuildID     int64   `json:"build_id"`
+	ProjectID   int64   `json:"project_id"`
+	SuiteID     int64   `json:"test_suite_id"`
+	BuildNumber string  `json:"build_number"`
+	Total       int     `json:"total"`
+	Passed      int     `json:"passed"`
+	Failed      int     `json:"failed"`
+	Errored     int     `json:"errored"`
+	Skipped     int     `json:"skipped"`
+	Duration    float64 `json:"duration"`
+	// Warnings are problems found in the report that did not stop the import
+	Warnings []string `json:"warnings,omitempty"`
+	// Files are the parse results of the report files of an archive upload
+	Files []FileResult `json:"files,omitempty"`
+	// Attachments are the files the test cases of the report reference
+	Attachments []AttachmentRef `json:"-"`
+}
+
+// AttachmentRef is a file a test case references with the [[ATTACHMENT|path]] convention
+// in its output, stored with its execution when the file is part of the upload
+type AttachmentRef struct {
+	Classname string
+	Name      string
+	Path      string
+}
+
+// FileResult is the outcome of parsing one report file of an archive upload. A file
+// that could not be parsed is left out of the build.
+type FileResult struct {
+	Name string `json:"name"`
+	// Format is the format the file was parsed as, empty when it is of no known format
+	Format string `json:"format,omitempty"`
+	Total  int    `json:"total"`
+	Error  string `json:"error,omitempty"`
 }
@@ -0,0 +1,105 @@
+package ports
+
+import (
+	"context"
+	"io"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+)
+
+// JUnitImportRepository defines the data access needed to persist an import
+type JUnitImportRepository interface {
+	GetSuiteProjectID(ctx context.Context, suiteID int64) (int64, error)
+	SaveImport(ctx context.Context, build *models.ImportBuild, suites []*models.SuiteResult) (int64, error)
+	BeginImport(ctx context.Context, build *models.ImportBuild) (ImportSession, error)
+}
+
+// ImportSession writes an import incrementally, suite by suite, inside one transaction.
+// A suite is started before its test cases and nested suites and ended once all of
+// them were added; the suite passed to EndSuite carries what is only known at its end.
+// Commit finalizes the build with its totals; Rollback discards everything written
+// and is a no-op after Commit.
+type ImportSession interface {
+	StartSuite(ctx context.Context, suite *models.SuiteResult) error
+	AddTestCase(ctx context.Context, result *models.TestCaseResult) error
+	EndSuite(ctx context.Context, suite *models.SuiteResult) error
+	Commit(ctx context.Context, build *models.ImportBuild) (int64, error)
+	Rollback() error
+}
+
+// JUnitSink receives a JUnit report element by element while it is parsed. The suite
+// passed to StartSuite only holds the attributes of the <testsuite> element, the one
+// passed to EndSuite also holds its properties and output. Neither holds test cases
+// or nested suites, which are reported on their own in between.
+type JUnitSink interface {
+	StartSuite(ctx context.Context, suite *models.JUnitTestSuite) error
+	AddTestCase(ctx context.Context, testCase *models.JUnitTestCase) error
+	EndSuite(ctx context.Context, suite *models.JUnitTestSuite) error
+}
+
+// JUnitStream parses a JUnit report into sink
+type JUnitStream func(ctx context.Context, sink JUnitSink) error
+
+// ReportSink receives the reports of an archive: JUnit reports element by element while
+// they are parsed, and reports of other formats as whole parsed suites. StartReport is
+// called before each report.
+type ReportSink interface {
+	JUnitSink
+	StartReport(ctx context.Context) error
+	AddSuite(ctx context.Context, suite *models.SuiteResult) error
+}
+
+// ReportStream parses the reports of an archive into sink
+type ReportStream func(ctx context.Context, sink ReportSink) error
+
+// ImportJobRepository defines the data access needed to track import jobs
+type ImportJobRepository interface {
+	// CreateJob stores a queued job; it returns nil when the suite of the job is not part of its
+	// project, or when a job with the same idempotency key or content hash was stored meanwhile
+	CreateJob(ctx context.Context, job *models.ImportJob) (*models.ImportJob, error)
+	GetJob(ctx context.Context, id int64) (*models.ImportJob, error)
+	// FindJob returns the job of the suite and shard that was not failed and has the idempotency
+	// key, or else the content hash, of an upload; jobs matching the key take precedence. The
+	// shard is nil for uploads creating their own build.
+	FindJob(ctx context.Context, suiteID int64, shard *models.Shard, idempotencyKey, contentHash string) (*models.ImportJob, error)
+	UpdateJobProgress(ctx context.Context, id int64, progress models.ImportProgress) error
+	FinishJob(ctx context.Context, job *models.ImportJob) error
+	// FailUnfinishedJobs records every queued or running job as failed with the error
+	// message, returning the number of jobs failed
+	FailUnfinishedJobs(ctx context.Context, message string) (int64, error)
+}
+
+// ImportTask runs the import of one job, reporting its progress through progress
+type ImportTask func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error)
+
+// ImportJobService defines the interface for running imports in the background
+type ImportJobService interface {
+	Submit(ctx context.Context, job *models.ImportJob, task ImportTask) (*models.ImportJob, error)
+	GetJob(ctx context.Context, id int64) (*models.ImportJob, error)
+	// FailInterruptedJobs fails the jobs left unfinished by an earlier run of the server
+	FailInterruptedJobs(ctx context.Context) (int64, error)
+	// Close stops accepting jobs and waits for the queued ones to finish
+	Close()
+	// Shutdown is Close, stopping the running and queued jobs when ctx is done first
+	Shutdown(ctx context.Context) error
+}
+
+// JUnitImportService defines the interface for JUnit import business logic
+type JUnitImportService interface {
+	ProcessJUnitData(ctx context.Context, projectID int64, suiteID int64, opts *models.ImportOptions, junitData *models.JUnitTestSuites) (*models.ImportResult, error)
+	ProcessReport(ctx context.Context, projectID int64, suiteID int64, opts *models.ImportOptions, suites []*models.SuiteResult) (*models.ImportResult, error)
+	StreamJUnitData(ctx context.Context, projectID int64, suiteID int64, opts *models.ImportOptions, stream JUnitStream) (*models.ImportResult, error)
+	StreamReports(ctx context.Context, projectID int64, suiteID int64, opts *models.ImportOptions, stream ReportStream) (*models.ImportResult, error)
+}
+
+// AttachmentUploader stores the files referenced by an imported report with the
+// executions of the test cases that reference them
+type AttachmentUploader interface {
+	AttachToTestCase(ctx context.Context, buildID int64, classname, testName, fileName string, r io.Reader, size int64) error
+}
+
+// RenameDetector suggests renames for the test cases that disappeared from an imported
+// build while others appeared, and returns the number of new suggestions
+type RenameDetector interface {
+	DetectRenames(ctx context.Context, buildID int64) (int, error)
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/ports"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
+)
+
+// importArchive imports the report files of a zip or gzipped tar archive as one build,
+// leaving out the files that cannot be parsed. Unless a format was requested, the format
+// of every file is detected from its content, so that an archive may mix formats. The
+// parse result of every file is returned with the import, or in the error when no file
+// could be parsed.
+//
+// JUnit reports are first checked to parse and then streamed into the import along with
+// the suites of the other reports, which are parsed whole, report by report so that a
+// test case is only taken as retried within its own report. Reading the archive twice
+// keeps a report that fails half way through out of the build without holding any
+// JUnit report in memory.
+func (h *JUnitImportHandler) importArchive(ctx context.Context, projectID, suiteID int64, format, kind string, opts *models.ImportOptions, path string) (*models.ImportResult, error) {
+	if format == formatAuto {
+		format = ""
+	}
+
+	var files []models.FileResult
+	var reports [][]*models.SuiteResult
+	err := parser.WalkArchiveReports(path, kind, format, h.Limits.ArchiveFile, func(name, format string, r io.Reader, err error) error {
+		if err != nil {
+			files = append(files, fileResult(name, format, 0, err))
+			return nil
+		}
+		if format == formatJUnit {
+			counter := &testCaseCounter{}
+			err := parser.StreamJUnit(ctx, r, counter, h.Limits.MaxElementSize)
+			files = append(files, fileResult(name, format, counter.testCases, err))
+			return nil
+		}
+
+		parse, ok := parser.ForFormat(format)
+		if !ok {
+			err := fmt.Errorf("%w: unsupported report format %q", errors.ErrUnknownFormat, format)
+			files = append(files, fileResult(name, format, 0, err))
+			return nil
+		}
+		parsed, err := parse(r)
+		files = append(files, fileResult(name, format, countTestCases(parsed), err))
+		if err == nil {
+			reports = append(reports, parsed)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := archiveError(files); err != nil {
+		return nil, err
+	}
+
+	result, err := h.Service.StreamReports(ctx, projectID, suiteID, opts, h.archiveStream(path, kind, format, files, reports))
+	if err != nil {
+		return nil, err
+	}
+
+	result.Files = files
+	return result, nil
+}
+
+// archiveStream streams the JUnit reports of the archive that parsed, as listed in files,
+// followed by the suites parsed from each of its other reports
+func (h *JUnitImportHandler) archiveStream(path, kind, format string, files []models.FileResult, reports [][]*models.SuiteResult) ports.ReportStream {
+	return func(ctx context.Context, sink ports.ReportSink) error {
+		index := 0
+		err := parser.WalkArchiveReports(path, kind, format, 0, func(name, format string, r io.Reader, err error) error {
+			file := files[index]
+			index++
+			if file.Error != "" || file.Format != formatJUnit {
+				return nil
+			}
+			if err := sink.StartReport(ctx); err != nil {
+				return err
+			}
+			return parser.StreamJUnit(ctx, r, sink, h.Limits.MaxElementSize)
+		})
+		if err != nil {
+			return err
+		}
+		for _, suites := range reports {
+			if err := sink.StartReport(ctx); err != nil {
+				return err
+			}
+			for _, suite := range suites {
+				if err := sink.AddSuite(ctx, suite); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// archiveError fails an archive import that has no report file that could be parsed
+func archiveError(files []models.FileResult) error {
+	if len(files) == 0 {
+		return fmt.Errorf("%w: the archive holds no report files", errors.ErrEmptyReport)
+	}
+	for _, file := range files {
+		if file.Error == "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: none of the %d report files of the archive could be parsed, %s: %s",
+		errors.ErrInvalidReport, len(files), files[0].Name, files[0].Error)
+}
+
+func fileResult(name, format string, testCases int, err error) models.FileResult {
+	if err != nil {
+		return models.FileResult{Name: name, Format: format, Error: err.Error()}
+	}
+	return models.FileResult{Name: name, Format: format, Total: testCases}
+}
+
+// countTestCases counts the test cases, with their subtests, of parsed suites and their nested suites
+func countTestCases(suites []*models.SuiteResult) int {
+	total := 0
+	for _, suite := range suites {
+		total += countResults(suite.TestCases) + countTestCases(suite.Suites)
+	}
+	return total
+}
+
+func countResults(results []*models.TestCaseResult) int {
+	total := len(results)
+	for _, result := range results {
+		total += countResults(result.Subtests)
+	}
+	return total
+}
+
+// testCaseCounter counts the test cases of a streamed report without keeping them
+type testCaseCounter struct {
+	testCases int
+}
+
+func (c *testCaseCounter) StartSuite(ctx context.Context, suite *models.JUnitTestSuite) error {
+	return nil
+}
+
+func (c *testCaseCounter) AddTestCase(ctx context.Context, testCase *models.JUnitTestCase) error {
+	c.testCases++
+	return nil
+}
+
+func (c *testCaseCounter) EndSuite(ctx context.Context, suite *models.JUnitTestSuite) error {
+	return nil
+}
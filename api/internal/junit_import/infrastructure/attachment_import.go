@@ -0,0 +1,173 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
+)
+
+// maxMissingAttachmentsListed bounds the paths named by the warning about attachments
+// that are not part of an upload
+const maxMissingAttachmentsListed = 10
+
+// attachFiles stores the files the test cases of an imported report reference with the
+// executions of those test cases. The files are looked up in the archive the report was
+// uploaded in; references that cannot be resolved are reported as warnings of the import.
+func (h *JUnitImportHandler) attachFiles(ctx context.Context, result *models.ImportResult, kind, path string) {
+	if len(result.Attachments) == 0 || h.Attachments == nil {
+		return
+	}
+
+	matcher := newAttachmentMatcher(result.Attachments)
+	if kind != "" {
+		err := parser.WalkArchiveEntries(path, kind, func(name string, r io.Reader, size int64) error {
+			refs := matcher.match(name)
+			if len(refs) > 0 {
+				h.attachEntry(ctx, result, refs, name, r, size)
+			}
+			return nil
+		})
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("attachments could not be read: %v", err))
+			return
+		}
+	}
+
+	if missing := matcher.unmatched(); len(missing) > 0 {
+		result.Warnings = append(result.Warnings, missingAttachmentsWarning(missing))
+	}
+}
+
+// attachEntry stores an archive file with every test case that references it. A file
+// referenced more than once is copied to a temporary file first, to be read once per
+// reference.
+func (h *JUnitImportHandler) attachEntry(ctx context.Context, result *models.ImportResult, refs []models.AttachmentRef, name string, r io.Reader, size int64) {
+	if len(refs) == 1 {
+		h.attach(ctx, result, refs[0], name, r, size)
+		return
+	}
+
+	spool, err := os.CreateTemp("", "test-results-attachment-*")
+	if err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("attachment %s could not be read: %v", name, err))
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	if _, err := io.Copy(spool, r); err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("attachment %s could not be read: %v", name, err))
+		return
+	}
+
+	for _, ref := range refs {
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("attachment %s could not be read: %v", name, err))
+			return
+		}
+		h.attach(ctx, result, ref, name, spool, size)
+	}
+}
+
+func (h *JUnitImportHandler) attach(ctx context.Context, result *models.ImportResult, ref models.AttachmentRef, name string, r io.Reader, size int64) {
+	err := h.Attachments.AttachToTestCase(ctx, result.BuildID, ref.Classname, ref.Name, path.Base(name), r, size)
+	if err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("attachment %s of test case %q could not be stored: %v", ref.Path, ref.Name, err))
+	}
+}
+
+func missingAttachmentsWarning(missing []string) string {
+	listed := missing
+	if len(listed) > maxMissingAttachmentsListed {
+		listed = listed[:maxMissingAttachmentsListed]
+	}
+	warning := fmt.Sprintf("%d attachments referenced by the report are not part of the upload: %s",
+		len(missing), strings.Join(listed, ", "))
+	if len(missing) > len(listed) {
+		warning += ", ..."
+	}
+	return warning
+}
+
+// attachmentMatcher finds the references an archive file satisfies. The path of a
+// reference is usually the path the file had on the machine that ran the tests, while
+// the archive holds it relative to where it was packed, so a file matches a reference
+// when the path of one ends with the whole path of the other.
+type attachmentMatcher struct {
+	refs     []models.AttachmentRef
+	matched  []bool
+	suffixes map[string][]int // every trailing run of path segments of each reference
+	paths    map[string][]int // the whole path of each reference
+}
+
+func newAttachmentMatcher(refs []models.AttachmentRef) *attachmentMatcher {
+	m := &attachmentMatcher{
+		refs:     refs,
+		matched:  make([]bool, len(refs)),
+		suffixes: make(map[string][]int),
+		paths:    make(map[string][]int),
+	}
+	for i, ref := range refs {
+		normalized := normalizeAttachmentPath(ref.Path)
+		m.paths[normalized] = append(m.paths[normalized], i)
+		for _, suffix := range pathSuffixes(normalized) {
+			m.suffixes[suffix] = append(m.suffixes[suffix], i)
+		}
+	}
+	return m
+}
+
+// match returns the references an archive file satisfies that no earlier file did
+func (m *attachmentMatcher) match(name string) []models.AttachmentRef {
+	normalized := normalizeAttachmentPath(name)
+	candidates := append([]int(nil), m.suffixes[normalized]...)
+	for _, suffix := range pathSuffixes(normalized) {
+		candidates = append(candidates, m.paths[suffix]...)
+	}
+
+	var refs []models.AttachmentRef
+	for _, i := range candidates {
+		if !m.matched[i] {
+			m.matched[i] = true
+			refs = append(refs, m.refs[i])
+		}
+	}
+	return refs
+}
+
+// unmatched returns the paths of the references no archive file satisfied
+func (m *attachmentMatcher) unmatched() []string {
+	var paths []string
+	for i, ref := range m.refs {
+		if !m.matched[i] {
+			paths = append(paths, ref.Path)
+		}
+	}
+	return paths
+}
+
+// normalizeAttachmentPath turns a path into a slash-separated path without a leading
+// slash, drive letter or ./ segments
+func normalizeAttachmentPath(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	if len(p) >= 2 && p[1] == ':' {
+		p = p[2:]
+	}
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// pathSuffixes returns the trailing runs of segments of a path, longest first
+func pathSuffixes(p string) []string {
+	suffixes := []string{p}
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' {
+			suffixes = append(suffixes, p[i+1:])
+		}
+	}
+	return suffixes
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+// commitSHAPattern matches a full or abbreviated SHA-1 or SHA-256 commit hash
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{4,64}$`)
+
+// buildMetadata reads the commit, branch, pull request, author, trigger, properties and
+// tags an upload names for its build
+func buildMetadata(r *http.Request) (models.BuildMetadata, error) {
+	metadata := models.BuildMetadata{
+		CommitSHA:  strings.ToLower(strings.TrimSpace(r.FormValue("commit_sha"))),
+		Branch:     strings.TrimSpace(r.FormValue("branch")),
+		BaseBranch: strings.TrimSpace(r.FormValue("base_branch")),
+		Author:     strings.TrimSpace(r.FormValue("author")),
+		Trigger:    strings.ToLower(strings.TrimSpace(r.FormValue("trigger"))),
+	}
+	if metadata.CommitSHA != "" && !commitSHAPattern.MatchString(metadata.CommitSHA) {
+		return metadata, fmt.Errorf("%w: commit_sha must be a hexadecimal commit hash", errors.ErrInvalidRequest)
+	}
+
+	if value := strings.TrimSpace(r.FormValue("pr_number")); value != "" {
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || number <= 0 {
+			return metadata, fmt.Errorf("%w: pr_number must be a positive number", errors.ErrInvalidRequest)
+		}
+		metadata.PRNumber = &number
+	}
+
+	properties, err := buildProperties(r.Form["property"])
+	if err != nil {
+		return metadata, err
+	}
+	metadata.Properties = properties
+	metadata.Tags = buildTags(r.Form["tag"])
+	return metadata, nil
+}
+
+// buildShard reads the build_id and shard_index of an upload adding a shard to an open
+// sharded build, or returns nil when the upload creates its own build
+func buildShard(r *http.Request) (*models.Shard, error) {
+	buildID := strings.TrimSpace(r.FormValue("build_id"))
+	index := strings.TrimSpace(r.FormValue("shard_index"))
+	if buildID == "" && index == "" {
+		return nil, nil
+	}
+	if buildID == "" || index == "" {
+		return nil, fmt.Errorf("%w: build_id and shard_index must be given together", errors.ErrInvalidRequest)
+	}
+
+	shard := &models.Shard{}
+	var err error
+	if shard.BuildID, err = strconv.ParseInt(buildID, 10, 64); err != nil || shard.BuildID <= 0 {
+		return nil, fmt.Errorf("%w: build_id must be a positive number", errors.ErrInvalidRequest)
+	}
+	if shard.Index, err = strconv.Atoi(index); err != nil || shard.Index < 0 {
+		return nil, fmt.Errorf("%w: shard_index must be a number from 0", errors.ErrInvalidRequest)
+	}
+	return shard, nil
+}
+
+// buildProperties reads the name=value pairs of the property fields of an upload
+func buildProperties(values []string) (map[string]string, error) {
+	var properties map[string]string
+	for _, property := range values {
+		name, value, ok := strings.Cut(property, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%w: property %q must be name=value", errors.ErrInvalidRequest, property)
+		}
+		if properties == nil {
+			properties = make(map[string]string)
+		}
+		properties[strings.TrimSpace(name)] = value
+	}
+	return properties, nil
+}
+
+// buildTags reads the tag fields of an upload, each holding one or more comma-separated tags
+func buildTags(values []string) []string {
+	var tags []string
+	for _, value := range values {
+		tags = append(tags, strings.Split(value, ",")...)
+	}
+	return models.NormalizeTags(tags)
+}
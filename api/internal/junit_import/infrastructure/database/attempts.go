@@ -0,0 +1,244 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/lib/pq"
+)
+
+// maxReplacedWarnings bounds the warnings about replaced results kept for one import
+const maxReplacedWarnings = 20
+
+// importWarnings collects the results replaced by a later result of their test case that
+// was not a retry of them
+type importWarnings struct {
+	messages []string
+	omitted  int
+}
+
+func (w *importWarnings) replaced(result *models.TestCaseResult, status string) {
+	if len(w.messages) >= maxReplacedWarnings {
+		w.omitted++
+		return
+	}
+	w.messages = append(w.messages, fmt.Sprintf("test case %q of class %q was reported more than once in the build without being rerun; its earlier %s result was replaced",
+		result.Name, result.Classname, status))
+}
+
+func (w *importWarnings) list() []string {
+	if w.omitted == 0 {
+		return w.messages
+	}
+	return append(w.messages, fmt.Sprintf("%d more test cases had an earlier result replaced", w.omitted))
+}
+
+// enterReport starts to track the test cases written by the report of a result, unless
+// its report is the one of the results written before
+func (w *importWriter) enterReport(result *models.TestCaseResult) {
+	if w.written == nil || result.Report != w.report {
+		w.report = result.Report
+		w.written = make(map[int64]bool)
+	}
+}
+
+// retriesEarlierRun reports whether a result follows the earlier result of its test case in
+// the build as a retry: it is marked as rerun, or the same report wrote the earlier result.
+// Another report of the test case, such as the same test of another module or shard, is not.
+func (w *importWriter) retriesEarlierRun(testCaseID int64, result *models.TestCaseResult) bool {
+	return result.Rerun || w.written[testCaseID]
+}
+
+// saveAttempts records the attempts of a result after those recorded for its test case in
+// the build before, as foldEarlierRuns keeps them, and returns the ID of its last attempt
+func (w *importWriter) saveAttempts(ctx context.Context, testCaseID int64, result *models.TestCaseResult) (int64, error) {
+	w.enterReport(result)
+	testCaseIDs, batch := []int64{testCaseID}, []*models.TestCaseResult{result}
+	earlier, replaced, err := foldEarlierRuns(ctx, w.tx, w.buildID, testCaseIDs, []bool{w.retriesEarlierRun(testCaseID, result)})
+	if err != nil {
+		return 0, err
+	}
+	if status, ok := replaced[testCaseID]; ok {
+		w.warnings.replaced(result, status)
+	}
+	executionID, err := upsertExecution(ctx, w.tx, w.buildID, w.shard, testCaseID, earlier[0], result)
+	if err != nil {
+		return 0, err
+	}
+	w.written[testCaseID] = true
+	return executionID, insertRetries(ctx, w.tx, w.buildID, w.shard, testCaseIDs, earlier, batch)
+}
+
+// earlierAttempts returns the attempts a result reported again in the same batch, and so
+// in the same report, leaves to the later one: the result itself, following its own
+// retries, when it failed or errored. A test case that passed or was skipped ran again
+// without being retried, and nil is returned as its earlier result is replaced.
+func earlierAttempts(result *models.TestCaseResult) []*models.Attempt {
+	if result.Status != models.StatusFailed && result.Status != models.StatusError {
+		return nil
+	}
+	attempt := &models.Attempt{Status: result.Status, Time: result.Time, Failure: result.Failure}
+	return append(result.Retries, attempt)
+}
+
+// foldEarlierRuns does for the results of test cases written to a build before, by an
+// earlier batch or another import, what earlierAttempts does within a batch, before the
+// test cases are written again. For the test cases whose new result retries their earlier
+// one, a final attempt that failed or errored becomes a retried attempt, keeping its
+// failure. Every other earlier result is removed along with its own retried attempts and
+// returned with its status by test case, to be reported as replaced. It returns the number
+// of attempts kept for each test case, which the new attempts are numbered after.
+func foldEarlierRuns(ctx context.Context, tx *sql.Tx, buildID int64, testCaseIDs []int64, retries []bool) ([]int64, map[int64]string, error) {
+	var retrying []int64
+	for i, testCaseID := range testCaseIDs {
+		if retries[i] {
+			retrying = append(retrying, testCaseID)
+		}
+	}
+
+	attempts := make(map[int64]int64)
+	if len(retrying) > 0 {
+		retry := `UPDATE build_test_case_executions SET retried = TRUE
+				  WHERE build_id = $1 AND test_case_id = ANY($2::bigint[]) AND NOT retried AND status IN ('failed', 'error')
+				  RETURNING test_case_id, attempt`
+		if err := queryTestCaseValues(ctx, tx, retry, attempts, buildID, pq.Array(retrying)); err != nil {
+			return nil, nil, fmt.Errorf("failed to retry earlier executions: %w", err)
+		}
+	}
+
+	drop := `DELETE FROM build_test_case_executions e
+			 WHERE e.build_id = $1 AND e.test_case_id = ANY($2::bigint[])
+			   AND EXISTS (SELECT 1 FROM build_test_case_executions f
+			               WHERE f.build_id = e.build_id AND f.test_case_id = e.test_case_id AND NOT f.retried)
+			 RETURNING e.test_case_id, e.status, e.retried`
+	rows, err := tx.QueryContext(ctx, drop, buildID, pq.Array(testCaseIDs))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to delete earlier executions: %w", err)
+	}
+	defer rows.Close()
+
+	replaced := make(map[int64]string)
+	for rows.Next() {
+		var testCaseID int64
+		var status string
+		var retried bool
+		if err := rows.Scan(&testCaseID, &status, &retried); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan earlier execution: %w", err)
+		}
+		if !retried {
+			replaced[testCaseID] = status
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to delete earlier executions: %w", err)
+	}
+
+	earlier := make([]int64, len(testCaseIDs))
+	for i, testCaseID := range testCaseIDs {
+		earlier[i] = attempts[testCaseID]
+	}
+	return earlier, replaced, nil
+}
+
+// queryTestCaseValues runs a query returning test case and number rows into values
+func queryTestCaseValues(ctx context.Context, tx *sql.Tx, query string, values map[int64]int64, args ...interface{}) error {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var testCaseID, value int64
+		if err := rows.Scan(&testCaseID, &value); err != nil {
+			return err
+		}
+		values[testCaseID] = value
+	}
+	return rows.Err()
+}
+
+// attemptKey identifies an attempt of a test case in a build
+type attemptKey struct {
+	testCaseID int64
+	attempt    int64
+}
+
+// insertRetries records the retried attempts of a batch, numbered in the order they ran after
+// the earlier attempts of their test cases, along with their failures
+func insertRetries(ctx context.Context, tx *sql.Tx, buildID int64, shard sql.NullInt64, testCaseIDs, earlier []int64, batch []*models.TestCaseResult) error {
+	var ids, attempts []int64
+	var statuses []string
+	var times []float64
+	for i, result := range batch {
+		for n, retry := range result.Retries {
+			ids = append(ids, testCaseIDs[i])
+			attempts = append(attempts, earlier[i]+int64(n+1))
+			statuses = append(statuses, retry.Status)
+			times = append(times, retry.Time)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO build_test_case_executions (build_id, test_case_id, status, execution_time, attempt, retried, shard_index)
+			  SELECT $1, e.test_case_id, e.status, e.execution_time, e.attempt, TRUE, $6::int
+			  FROM unnest($2::bigint[], $3::int[], $4::text[], $5::float8[]) AS e(test_case_id, attempt, status, execution_time)
+			  RETURNING id, test_case_id, attempt`
+
+	executionIDs, err := queryAttemptIDs(ctx, tx, query, buildID, pq.Array(ids), pq.Array(attempts), pq.Array(statuses), pq.Array(times), shard)
+	if err != nil {
+		return fmt.Errorf("failed to create retried executions: %w", err)
+	}
+	return insertRetryFailures(ctx, tx, executionIDs, testCaseIDs, earlier, batch)
+}
+
+// queryAttemptIDs runs a query returning id, test case and attempt rows
+func queryAttemptIDs(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (map[attemptKey]int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[attemptKey]int64)
+	for rows.Next() {
+		var id int64
+		var key attemptKey
+		if err := rows.Scan(&id, &key.testCaseID, &key.attempt); err != nil {
+			return nil, err
+		}
+		ids[key] = id
+	}
+	return ids, rows.Err()
+}
+
+func insertRetryFailures(ctx context.Context, tx *sql.Tx, executionIDs map[attemptKey]int64, testCaseIDs, earlier []int64, batch []*models.TestCaseResult) error {
+	var ids []int64
+	var messages, types, details []string
+	for i, result := range batch {
+		for n, retry := range result.Retries {
+			if retry.Failure == nil {
+				continue
+			}
+			ids = append(ids, executionIDs[attemptKey{testCaseIDs[i], earlier[i] + int64(n+1)}])
+			messages = append(messages, retry.Failure.Message)
+			types = append(types, retry.Failure.Type)
+			details = append(details, retry.Failure.Details)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO failures (build_test_case_execution_id, message, type, details)
+			  SELECT * FROM unnest($1::bigint[], $2::text[], $3::text[], $4::text[])`
+
+	if _, err := tx.ExecContext(ctx, query, pq.Array(ids), pq.Array(messages), pq.Array(types), pq.Array(details)); err != nil {
+		return fmt.Errorf("failed to create failures of retried executions: %w", err)
+	}
+	return nil
+}
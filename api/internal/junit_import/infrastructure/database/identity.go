@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	identity "github.com/BennyEisner/test-results/internal/test_identity/domain/models"
+	"github.com/lib/pq"
+)
+
+// getIdentity returns the fields the test cases of a project are identified by and the
+// patterns the parameters of their names are recognized by
+func getIdentity(ctx context.Context, tx *sql.Tx, projectID int64) ([]string, identity.ParamPatterns, error) {
+	query := `SELECT fields, param_patterns FROM project_test_identities WHERE project_id = $1`
+
+	var fields, patterns pq.StringArray
+	err := tx.QueryRowContext(ctx, query, projectID).Scan(&fields, &patterns)
+	if err == sql.ErrNoRows {
+		return identity.DefaultFields, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get test identity: %w", err)
+	}
+	if fields == nil {
+		fields = identity.DefaultFields
+	}
+	compiled, err := identity.CompileParamPatterns(patterns)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compile parameter patterns: %w", err)
+	}
+	return fields, compiled, nil
+}
+
+// identify computes the fingerprint and parameters of a result reported in a suite
+func (w *importWriter) identify(suiteID int64, result *models.TestCaseResult) {
+	key := identity.TestKey{SuiteID: suiteID, Classname: result.Classname, Name: result.Name, File: result.File}
+	result.Fingerprint = identity.Fingerprint(w.identityFields, w.paramPatterns, key)
+	_, result.Params = w.paramPatterns.Split(result.Name)
+}
+
+// findTestCases looks up the test cases of results reported in a suite by their
+// fingerprints, preferring those of the suite, and returns their IDs by fingerprint.
+// Test cases matched by fingerprint
+// take the current name, classname, suite and file of their result, which differ where
+// the project does not identify test cases by them. Results without a match are looked
+// up in the suite by classname and name among the test cases imported before they had
+// fingerprints, which then get theirs.
+func findTestCases(ctx context.Context, tx *sql.Tx, projectID, suiteID int64, results []*models.TestCaseResult) (map[string]int64, error) {
+	fingerprints := make([]string, len(results))
+	for i, result := range results {
+		fingerprints[i] = result.Fingerprint
+	}
+
+	query := `SELECT DISTINCT ON (tc.fingerprint) tc.id, tc.fingerprint
+			  FROM test_cases tc
+			  JOIN test_suites ts ON ts.id = tc.suite_id
+			  WHERE ts.project_id = $1 AND tc.fingerprint = ANY($2::text[])
+			  ORDER BY tc.fingerprint, tc.suite_id <> $3, tc.id`
+	ids, err := queryFingerprintIDs(ctx, tx, query, projectID, pq.Array(fingerprints), suiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up test cases: %w", err)
+	}
+
+	var found, missing []*models.TestCaseResult
+	for _, result := range results {
+		if _, ok := ids[result.Fingerprint]; ok {
+			found = append(found, result)
+		} else {
+			missing = append(missing, result)
+		}
+	}
+	if err := updateIdentities(ctx, tx, suiteID, ids, found); err != nil {
+		return nil, err
+	}
+	if len(missing) == 0 {
+		return ids, nil
+	}
+
+	legacy, err := claimLegacyTestCases(ctx, tx, suiteID, missing)
+	if err != nil {
+		return nil, err
+	}
+	for fingerprint, id := range legacy {
+		ids[fingerprint] = id
+	}
+	return ids, nil
+}
+
+// updateIdentities gives test cases matched by fingerprint the names, parameters, suite
+// and file of their results
+func updateIdentities(ctx context.Context, tx *sql.Tx, suiteID int64, ids map[string]int64, results []*models.TestCaseResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	testCaseIDs := make([]int64, len(results))
+	classnames := make([]string, len(results))
+	names := make([]string, len(results))
+	files := make([]string, len(results))
+	params := make([]string, len(results))
+	for i, result := range results {
+		testCaseIDs[i] = ids[result.Fingerprint]
+		classnames[i], names[i], files[i], params[i] = result.Classname, result.Name, result.File, result.Params
+	}
+
+	query := `UPDATE test_cases tc
+			  SET suite_id = $1, classname = k.classname, name = k.name, file = COALESCE(NULLIF(k.file, ''), tc.file),
+			      params = NULLIF(k.params, '')
+			  FROM unnest($2::bigint[], $3::text[], $4::text[], $5::text[], $6::text[]) AS k(id, classname, name, file, params)
+			  WHERE tc.id = k.id
+			    AND (tc.suite_id <> $1 OR tc.classname <> k.classname OR tc.name <> k.name
+			         OR (k.file <> '' AND tc.file IS DISTINCT FROM k.file) OR tc.params IS DISTINCT FROM NULLIF(k.params, ''))`
+	if _, err := tx.ExecContext(ctx, query, suiteID, pq.Array(testCaseIDs), pq.Array(classnames), pq.Array(names), pq.Array(files),
+		pq.Array(params)); err != nil {
+		return fmt.Errorf("failed to update test cases: %w", err)
+	}
+	return nil
+}
+
+// claimLegacyTestCases finds the test cases without fingerprint that match results by
+// suite, classname and name, sets their fingerprints and returns their IDs by fingerprint
+func claimLegacyTestCases(ctx context.Context, tx *sql.Tx, suiteID int64, results []*models.TestCaseResult) (map[string]int64, error) {
+	classnames := make([]string, len(results))
+	names := make([]string, len(results))
+	files := make([]string, len(results))
+	fingerprints := make([]string, len(results))
+	params := make([]string, len(results))
+	for i, result := range results {
+		classnames[i], names[i], files[i], fingerprints[i] = result.Classname, result.Name, result.File, result.Fingerprint
+		params[i] = result.Params
+	}
+
+	query := `UPDATE test_cases tc SET fingerprint = m.fingerprint, file = COALESCE(NULLIF(m.file, ''), tc.file),
+			      params = NULLIF(m.params, '')
+			  FROM (
+			      SELECT DISTINCT ON (k.fingerprint) t.id, k.fingerprint, k.file, k.params
+			      FROM test_cases t
+			      JOIN unnest($2::text[], $3::text[], $4::text[], $5::text[], $6::text[]) AS k(classname, name, file, fingerprint, params)
+			        ON t.classname = k.classname AND t.name = k.name
+			      WHERE t.suite_id = $1 AND t.fingerprint IS NULL
+			      ORDER BY k.fingerprint, t.id
+			  ) m
+			  WHERE tc.id = m.id
+			  RETURNING tc.id, tc.fingerprint`
+	ids, err := queryFingerprintIDs(ctx, tx, query, suiteID, pq.Array(classnames), pq.Array(names), pq.Array(files),
+		pq.Array(fingerprints), pq.Array(params))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up test cases without fingerprint: %w", err)
+	}
+	return ids, nil
+}
+
+// queryFingerprintIDs runs a query returning id and fingerprint rows
+func queryFingerprintIDs(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (map[string]int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int64)
+	for rows.Next() {
+		var id int64
+		var fingerprint string
+		if err := rows.Scan(&id, &fingerprint); err != nil {
+			return nil, err
+		}
+		ids[fingerprint] = id
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/ports"
+	"github.com/lib/pq"
+)
+
+// SQLImportJobRepository implements the ImportJobRepository interface
+type SQLImportJobRepository struct {
+	db *sql.DB
+}
+
+// NewSQLImportJobRepository creates a new SQL import job repository
+func NewSQLImportJobRepository(db *sql.DB) ports.ImportJobRepository {
+	return &SQLImportJobRepository{db: db}
+}
+
+// jobColumns are the columns read into a job by scanJob
+const jobColumns = `id, project_id, test_suite_id, format, COALESCE(build_number, ''),
+	COALESCE(idempotency_key, ''), COALESCE(content_hash, ''), state,
+	total, passed, failed, errored, skipped, warnings, files, build_id, COALESCE(error, ''),
+	created_at, started_at, finished_at, shard_build_id, shard_index`
+
+// CreateJob stores a job against its suite. It returns nil if the suite is not part of the project
+// of the job, or if a job to the same shard that was not failed already has its idempotency key or
+// content hash.
+func (r *SQLImportJobRepository) CreateJob(ctx context.Context, job *models.ImportJob) (*models.ImportJob, error) {
+	query := `INSERT INTO import_jobs (project_id, test_suite_id, format, build_number, idempotency_key, content_hash, state,
+			  shard_build_id, shard_index)
+			  SELECT ts.project_id, ts.id, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8::int, $9::int
+			  FROM test_suites ts WHERE ts.id = $2 AND ts.project_id = $1
+			  ON CONFLICT DO NOTHING
+			  RETURNING id, created_at`
+
+	shardBuildID, shardIndex := shardColumns(job.Shard)
+	created := *job
+	err := r.db.QueryRowContext(ctx, query, job.ProjectID, job.SuiteID, job.Format, job.BuildNumber,
+		job.IdempotencyKey, job.ContentHash, job.State, shardBuildID, shardIndex).
+		Scan(&created.ID, &created.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	if created.Warnings == nil {
+		created.Warnings = []string{}
+	}
+	return &created, nil
+}
+
+// GetJob returns a job by ID, or nil if it does not exist
+func (r *SQLImportJobRepository) GetJob(ctx context.Context, id int64) (*models.ImportJob, error) {
+	query := `SELECT ` + jobColumns + ` FROM import_jobs WHERE id = $1`
+
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+	return job, nil
+}
+
+// FindJob returns the job of the suite and shard that was not failed and has the idempotency key
+// or the content hash, preferring a match on the key, or nil if there is none
+func (r *SQLImportJobRepository) FindJob(ctx context.Context, suiteID int64, shard *models.Shard, idempotencyKey, contentHash string) (*models.ImportJob, error) {
+	query := `SELECT ` + jobColumns + ` FROM import_jobs
+			  WHERE test_suite_id = $1 AND state <> $4
+			    AND shard_build_id IS NOT DISTINCT FROM $5::int AND shard_index IS NOT DISTINCT FROM $6::int
+			    AND (idempotency_key = NULLIF($2, '') OR content_hash = NULLIF($3, ''))
+			  ORDER BY idempotency_key = NULLIF($2, '') DESC NULLS LAST, id
+			  LIMIT 1`
+
+	shardBuildID, shardIndex := shardColumns(shard)
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, suiteID, idempotencyKey, contentHash, models.JobFailed,
+		shardBuildID, shardIndex))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find import job: %w", err)
+	}
+	return job, nil
+}
+
+// scanJob reads a row of jobColumns, returning nil if there is no row
+func scanJob(row *sql.Row) (*models.ImportJob, error) {
+	job := &models.ImportJob{}
+	var warnings pq.StringArray
+	var files []byte
+	var buildID, shardBuildID, shardIndex sql.NullInt64
+	err := row.Scan(
+		&job.ID, &job.ProjectID, &job.SuiteID, &job.Format, &job.BuildNumber,
+		&job.IdempotencyKey, &job.ContentHash, &job.State,
+		&job.Total, &job.Passed, &job.Failed, &job.Errored, &job.Skipped, &warnings, &files, &buildID, &job.Error,
+		&job.CreatedAt, &job.StartedAt, &job.FinishedAt, &shardBuildID, &shardIndex,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	job.Warnings = []string(warnings)
+	if err := json.Unmarshal(files, &job.Files); err != nil {
+		return nil, fmt.Errorf("failed to decode import job files: %w", err)
+	}
+	if buildID.Valid {
+		job.BuildID = &buildID.Int64
+	}
+	if shardBuildID.Valid {
+		job.Shard = &models.Shard{BuildID: shardBuildID.Int64, Index: int(shardIndex.Int64)}
+	}
+	return job, nil
+}
+
+// shardColumns returns the sharded build and shard index a job is stored with, NULL for a
+// job creating its own build
+func shardColumns(shard *models.Shard) (sql.NullInt64, sql.NullInt64) {
+	if shard == nil {
+		return sql.NullInt64{}, sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: shard.BuildID, Valid: true}, sql.NullInt64{Int64: int64(shard.Index), Valid: true}
+}
+
+// UpdateJobProgress records the state and counts of a running job
+func (r *SQLImportJobRepository) UpdateJobProgress(ctx context.Context, id int64, progress models.ImportProgress) error {
+	query := `UPDATE import_jobs
+			  SET state = $2, total = $3, passed = $4, failed = $5, errored = $6, skipped = $7,
+			      started_at = COALESCE(started_at, NOW()), updated_at = NOW()
+			  WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, progress.State,
+		progress.Total, progress.Passed, progress.Failed, progress.Errored, progress.Skipped)
+	if err != nil {
+		return fmt.Errorf("failed to update import job progress: %w", err)
+	}
+
+	return nil
+}
+
+// FinishJob records the final state, counts, warnings, build and error of a job
+func (r *SQLImportJobRepository) FinishJob(ctx context.Context, job *models.ImportJob) error {
+	query := `UPDATE import_jobs
+			  SET state = $2, total = $3, passed = $4, failed = $5, errored = $6, skipped = $7,
+			      warnings = $8, files = $9, build_id = $10, build_number = COALESCE(NULLIF($11, ''), build_number),
+			      error = NULLIF($12, ''), finished_at = NOW(), updated_at = NOW()
+			  WHERE id = $1`
+
+	warnings := job.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	files, err := json.Marshal(job.Files)
+	if err != nil {
+		return fmt.Errorf("failed to encode import job files: %w", err)
+	}
+	if job.Files == nil {
+		files = []byte("[]")
+	}
+	_, err = r.db.ExecContext(ctx, query, job.ID, job.State,
+		job.Total, job.Passed, job.Failed, job.Errored, job.Skipped,
+		pq.Array(warnings), string(files), job.BuildID, job.BuildNumber, job.Error)
+	if err != nil {
+		return fmt.Errorf("failed to finish import job: %w", err)
+	}
+
+	return nil
+}
+
+// FailUnfinishedJobs records every queued or running job as failed with the error message
+func (r *SQLImportJobRepository) FailUnfinishedJobs(ctx context.Context, message string) (int64, error) {
+	query := `UPDATE import_jobs
+			  SET state = $1, error = $2, finished_at = NOW(), updated_at = NOW()
+			  WHERE state IN ($3, $4, $5)`
+
+	res, err := r.db.ExecContext(ctx, query, models.JobFailed, message, models.JobQueued, models.JobParsing, models.JobWriting)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail unfinished import jobs: %w", err)
+	}
+	failed, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unfinished import jobs: %w", err)
+	}
+	return failed, nil
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/lib/pq"
+)
+
+// replaceExecutionLogs stores the standard output and error of a batch gzip-compressed,
+// cut to maxLogSize bytes per execution. Logs an execution had from an earlier report of
+// the same test case in the build are replaced.
+func replaceExecutionLogs(ctx context.Context, tx *sql.Tx, executionIDs []int64, batch []*models.TestCaseResult, maxLogSize int64) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM execution_logs WHERE execution_id = ANY($1)`, pq.Array(executionIDs)); err != nil {
+		return fmt.Errorf("failed to replace execution logs: %w", err)
+	}
+
+	var ids, sizes, originalSizes []int64
+	var streams []string
+	var contents [][]byte
+	var truncated []bool
+	for i, result := range batch {
+		for _, log := range models.ExecutionLogs(result, maxLogSize) {
+			content, err := compressLog(log.Text)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, executionIDs[i])
+			streams = append(streams, log.Stream)
+			contents = append(contents, content)
+			sizes = append(sizes, int64(len(log.Text)))
+			originalSizes = append(originalSizes, log.OriginalSize)
+			truncated = append(truncated, log.Truncated)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO execution_logs (execution_id, stream, content, encoding, size, original_size, truncated)
+			  SELECT l.execution_id, l.stream, l.content, 'gzip', l.size, l.original_size, l.truncated
+			  FROM unnest($1::bigint[], $2::text[], $3::bytea[], $4::bigint[], $5::bigint[], $6::boolean[])
+			       AS l(execution_id, stream, content, size, original_size, truncated)`
+
+	_, err := tx.ExecContext(ctx, query, pq.Array(ids), pq.Array(streams), pq.ByteaArray(contents),
+		pq.Array(sizes), pq.Array(originalSizes), pq.Array(truncated))
+	if err != nil {
+		return fmt.Errorf("failed to create execution logs: %w", err)
+	}
+	return nil
+}
+
+func compressLog(text string) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(text)); err != nil {
+		return nil, fmt.Errorf("failed to compress log: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress log: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,374 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/ports"
+	identity "github.com/BennyEisner/test-results/internal/test_identity/domain/models"
+)
+
+// SQLJUnitImportRepository implements the JUnitImportRepository interface
+type SQLJUnitImportRepository struct {
+	db     *sql.DB
+	limits models.ImportLimits
+}
+
+// NewSQLJUnitImportRepository creates a new SQL JUnit import repository. Pending test
+// case results are written out once they reach limits.BatchBytes, and the logs of an
+// execution are cut to limits.MaxLogSize.
+func NewSQLJUnitImportRepository(db *sql.DB, limits models.ImportLimits) ports.JUnitImportRepository {
+	return &SQLJUnitImportRepository{db: db, limits: limits}
+}
+
+// GetSuiteProjectID returns the project owning a test suite, or 0 if the suite does not exist
+func (r *SQLJUnitImportRepository) GetSuiteProjectID(ctx context.Context, suiteID int64) (int64, error) {
+	query := `SELECT project_id FROM test_suites WHERE id = $1`
+
+	var projectID int64
+	err := r.db.QueryRowContext(ctx, query, suiteID).Scan(&projectID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get test suite project: %w", err)
+	}
+
+	return projectID, nil
+}
+
+// SaveImport creates the build, suites, test cases, executions and failures of an import in one transaction
+func (r *SQLJUnitImportRepository) SaveImport(ctx context.Context, build *models.ImportBuild, suites []*models.SuiteResult) (int64, error) {
+	session, err := r.BeginImport(ctx, build)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = session.Rollback()
+	}()
+
+	for _, suite := range suites {
+		if err := writeSuite(ctx, session, suite); err != nil {
+			return 0, err
+		}
+	}
+
+	return session.Commit(ctx, build)
+}
+
+// writeSuite replays a parsed suite and its nested suites into an import session
+func writeSuite(ctx context.Context, session ports.ImportSession, suite *models.SuiteResult) error {
+	if err := session.StartSuite(ctx, suite); err != nil {
+		return err
+	}
+	for _, result := range suite.TestCases {
+		if err := session.AddTestCase(ctx, result); err != nil {
+			return err
+		}
+	}
+	for _, child := range suite.Suites {
+		if err := writeSuite(ctx, session, child); err != nil {
+			return err
+		}
+	}
+	return session.EndSuite(ctx, suite)
+}
+
+// BeginImport opens the transaction of an import and creates its build, or checks the
+// sharded build a shard is added to and removes what the shard uploaded before. The build
+// totals are written again on commit, once the whole report was read.
+func (r *SQLJUnitImportRepository) BeginImport(ctx context.Context, build *models.ImportBuild) (ports.ImportSession, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin import transaction: %w", err)
+	}
+
+	var buildID int64
+	if build.Shard != nil {
+		buildID, err = openShard(ctx, tx, build)
+	} else {
+		buildID, err = insertBuild(ctx, tx, build)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	identityFields, paramPatterns, err := getIdentity(ctx, tx, build.ProjectID)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	writer := importWriter{tx: tx, projectID: build.ProjectID, buildID: buildID, shard: shardOf(build),
+		maxLogSize: r.limits.MaxLogSize, identityFields: identityFields, paramPatterns: paramPatterns}
+	return &importSession{
+		importWriter: writer,
+		rootSuiteID:  build.SuiteID,
+		batchBytes:   r.limits.BatchBytes,
+	}, nil
+}
+
+func insertBuild(ctx context.Context, tx *sql.Tx, build *models.ImportBuild) (int64, error) {
+	query := `INSERT INTO builds (test_suite_id, build_number, ci_provider, ci_url, created_at, test_case_count, duration,
+			  commit_sha, branch, base_branch, pr_number, author, trigger)
+			  VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7,
+			  NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11, NULLIF($12, ''), NULLIF($13, '')) RETURNING id`
+
+	var id int64
+	err := tx.QueryRowContext(ctx, query,
+		build.SuiteID, build.BuildNumber, build.CIProvider, build.CIURL, build.CreatedAt, build.TestCaseCount, build.Duration,
+		build.CommitSHA, build.Branch, build.BaseBranch, build.PRNumber, build.Author, build.Trigger,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create build: %w", err)
+	}
+
+	insert := `INSERT INTO build_properties (build_id, name, value) VALUES ($1, $2, $3)`
+	for name, value := range build.Properties {
+		if _, err := tx.ExecContext(ctx, insert, id, name, value); err != nil {
+			return 0, fmt.Errorf("failed to create build property: %w", err)
+		}
+	}
+
+	tag := `INSERT INTO build_tags (build_id, name) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	for _, name := range build.Tags {
+		if _, err := tx.ExecContext(ctx, tag, id, name); err != nil {
+			return 0, fmt.Errorf("failed to tag build: %w", err)
+		}
+	}
+
+	return id, nil
+}
+
+// notQuarantined is the condition that the test case of an execution e is not in an active quarantine
+const notQuarantined = `NOT EXISTS (SELECT 1 FROM test_case_quarantines q WHERE q.test_case_id = e.test_case_id
+		AND (q.expires_at IS NULL OR q.expires_at > CURRENT_TIMESTAMP))`
+
+// derivedStatus is the status an imported build finishes with: failed when the last attempt
+// of any of its executions failed, errored when any errored and passed otherwise. The
+// executions of quarantined test cases are left out.
+const derivedStatus = `CASE
+		WHEN EXISTS (SELECT 1 FROM build_test_case_executions e WHERE e.build_id = b.id AND NOT e.retried AND e.status = 'failed'
+		             AND ` + notQuarantined + `) THEN 'failed'
+		WHEN EXISTS (SELECT 1 FROM build_test_case_executions e WHERE e.build_id = b.id AND NOT e.retried AND e.status = 'error'
+		             AND ` + notQuarantined + `) THEN 'errored'
+		ELSE 'passed' END`
+
+// updateBuildTotals writes the totals of an imported build and finishes it
+func updateBuildTotals(ctx context.Context, tx *sql.Tx, buildID int64, build *models.ImportBuild) error {
+	query := `UPDATE builds b SET created_at = $2, test_case_count = $3, duration = $4,
+			  status = ` + derivedStatus + `, started_at = $2, finished_at = CURRENT_TIMESTAMP
+			  WHERE b.id = $1`
+
+	if _, err := tx.ExecContext(ctx, query, buildID, build.CreatedAt, build.TestCaseCount, build.Duration); err != nil {
+		return fmt.Errorf("failed to update build totals: %w", err)
+	}
+
+	return nil
+}
+
+// importWriter writes the suites of one import inside its transaction
+type importWriter struct {
+	tx         *sql.Tx
+	projectID  int64
+	buildID    int64
+	shard      sql.NullInt64 // index of the shard the import adds to its build, if any
+	maxLogSize int64
+	// identityFields are the fields the project identifies test cases by, and paramPatterns
+	// the patterns it recognizes the parameters of their names by
+	identityFields []string
+	paramPatterns  identity.ParamPatterns
+	// written holds the test cases written by the report of the import being written,
+	// whose results reported again follow their earlier failed results as retries
+	report   int
+	written  map[int64]bool
+	warnings importWarnings
+}
+
+// saveResult upserts the test case for a result and records its execution, properties,
+// logs and failure, then saves its subtests as children of the test case. parentID is 0 for
+// top-level test cases. Results are written one by one here; see importSession for the
+// batched path taken by plain test cases.
+func (w *importWriter) saveResult(ctx context.Context, suiteID, parentID int64, result *models.TestCaseResult) error {
+	testCaseID, err := w.upsertTestCase(ctx, suiteID, parentID, result)
+	if err != nil {
+		return err
+	}
+
+	if err := insertTestCaseTags(ctx, w.tx, testCaseID, result.Tags); err != nil {
+		return err
+	}
+
+	executionID, err := w.saveAttempts(ctx, testCaseID, result)
+	if err != nil {
+		return err
+	}
+
+	executionIDs, batch := []int64{executionID}, []*models.TestCaseResult{result}
+	if err := replaceExecutionProperties(ctx, w.tx, executionIDs, batch); err != nil {
+		return err
+	}
+
+	if err := replaceExecutionLogs(ctx, w.tx, executionIDs, batch, w.maxLogSize); err != nil {
+		return err
+	}
+
+	if err := replaceFailures(ctx, w.tx, executionIDs, batch); err != nil {
+		return err
+	}
+
+	for _, subtest := range result.Subtests {
+		if err := w.saveResult(ctx, suiteID, testCaseID, subtest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getOrCreateChildSuite(ctx context.Context, tx *sql.Tx, projectID, parentID int64, suite *models.SuiteResult) (int64, error) {
+	query := `SELECT id FROM test_suites WHERE project_id = $1 AND parent_id = $2 AND name = $3 ORDER BY id LIMIT 1`
+
+	var id int64
+	err := tx.QueryRowContext(ctx, query, projectID, parentID, suite.Name).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to look up child test suite: %w", err)
+	}
+
+	insert := `INSERT INTO test_suites (project_id, name, parent_id, time) VALUES ($1, $2, $3, $4) RETURNING id`
+	if err := tx.QueryRowContext(ctx, insert, projectID, suite.Name, parentID, suite.Time).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to create child test suite: %w", err)
+	}
+
+	return id, nil
+}
+
+// insertSuiteRun records what a suite reported for this build, along with its properties
+func insertSuiteRun(ctx context.Context, tx *sql.Tx, buildID int64, shard sql.NullInt64, suiteID int64, suite *models.SuiteResult) error {
+	query := `INSERT INTO build_suite_runs (build_id, test_suite_id, name, hostname, started_at, tests, failures, errors, skipped, time,
+			  system_out, system_err, shard_index)
+			  VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10, NULLIF($11, ''), NULLIF($12, ''), $13) RETURNING id`
+
+	var runID int64
+	err := tx.QueryRowContext(ctx, query,
+		buildID, suiteID, suite.Name, suite.Hostname, suite.Timestamp,
+		suite.Tests, suite.Failures, suite.Errors, suite.Skipped, suite.Time,
+		suite.SystemOut, suite.SystemErr, shard,
+	).Scan(&runID)
+	if err != nil {
+		return fmt.Errorf("failed to create suite run: %w", err)
+	}
+
+	insert := `INSERT INTO build_properties (build_id, build_suite_run_id, name, value) VALUES ($1, $2, $3, $4)`
+	for _, p := range suite.Properties {
+		if _, err := tx.ExecContext(ctx, insert, buildID, runID, p.Name, p.Value); err != nil {
+			return fmt.Errorf("failed to create build property: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func insertTestCaseTags(ctx context.Context, tx *sql.Tx, testCaseID int64, tags []string) error {
+	query := `INSERT INTO test_case_tags (test_case_id, name) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, query, testCaseID, tag); err != nil {
+			return fmt.Errorf("failed to tag test case: %w", err)
+		}
+	}
+	return nil
+}
+
+// upsertTestCase finds or creates the test case of a result. Results carrying an external ID
+// are matched on it across the whole project, and the matched test case takes their current
+// name; other results are matched by their fingerprint as in findTestCases.
+func (w *importWriter) upsertTestCase(ctx context.Context, suiteID, parentID int64, result *models.TestCaseResult) (int64, error) {
+	w.identify(suiteID, result)
+	if result.ExternalID != "" {
+		id, err := findTestCaseByExternalID(ctx, w.tx, w.projectID, result)
+		if err != nil || id != 0 {
+			return id, err
+		}
+	}
+
+	ids, err := findTestCases(ctx, w.tx, w.projectID, suiteID, []*models.TestCaseResult{result})
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := ids[result.Fingerprint]; ok {
+		return id, nil
+	}
+
+	insert := `INSERT INTO test_cases (suite_id, name, classname, parent_id, external_id, file, fingerprint, params)
+			   VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, ''), NULLIF($6, ''), $7, NULLIF($8, ''))
+			   ON CONFLICT (suite_id, fingerprint) DO NOTHING
+			   RETURNING id`
+
+	var id int64
+	err = w.tx.QueryRowContext(ctx, insert, suiteID, result.Name, result.Classname, parentID, result.ExternalID,
+		result.File, result.Fingerprint, result.Params).Scan(&id)
+	if err == sql.ErrNoRows {
+		// Another import created the test case meanwhile, as in resolveTestCases
+		query := `SELECT id FROM test_cases WHERE suite_id = $1 AND fingerprint = $2`
+		err = w.tx.QueryRowContext(ctx, query, suiteID, result.Fingerprint).Scan(&id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to create test case: %w", err)
+	}
+
+	return id, nil
+}
+
+// findTestCaseByExternalID returns the project's test case with the external ID of a result, or 0
+func findTestCaseByExternalID(ctx context.Context, tx *sql.Tx, projectID int64, result *models.TestCaseResult) (int64, error) {
+	query := `SELECT tc.id FROM test_cases tc
+			  JOIN test_suites ts ON ts.id = tc.suite_id
+			  WHERE ts.project_id = $1 AND tc.external_id = $2
+			  ORDER BY tc.id LIMIT 1`
+
+	var id int64
+	err := tx.QueryRowContext(ctx, query, projectID, result.ExternalID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up test case by external ID: %w", err)
+	}
+
+	// The test case keeps its fingerprint when another test case of its suite holds the new one
+	update := `UPDATE test_cases tc SET name = $2, classname = $3, file = COALESCE(NULLIF($4, ''), file),
+			       fingerprint = CASE WHEN EXISTS (SELECT 1 FROM test_cases o WHERE o.suite_id = tc.suite_id AND o.fingerprint = $5 AND o.id <> $1)
+			                          THEN tc.fingerprint ELSE $5 END,
+			       params = NULLIF($6, '')
+			   WHERE id = $1 AND (name <> $2 OR classname <> $3 OR ($4 <> '' AND file IS DISTINCT FROM $4)
+			                      OR fingerprint IS DISTINCT FROM $5 OR params IS DISTINCT FROM NULLIF($6, ''))`
+	if _, err := tx.ExecContext(ctx, update, id, result.Name, result.Classname, result.File, result.Fingerprint, result.Params); err != nil {
+		return 0, fmt.Errorf("failed to rename test case: %w", err)
+	}
+	return id, nil
+}
+
+// upsertExecution records the final attempt of the execution, numbered after the earlier
+// attempts of its test case
+func upsertExecution(ctx context.Context, tx *sql.Tx, buildID int64, shard sql.NullInt64, testCaseID, earlier int64, result *models.TestCaseResult) (int64, error) {
+	query := `INSERT INTO build_test_case_executions (build_id, test_case_id, status, execution_time, skip_message, attempt, shard_index)
+			  VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+			  ON CONFLICT (build_id, test_case_id) WHERE NOT retried
+			  DO UPDATE SET status = EXCLUDED.status, execution_time = EXCLUDED.execution_time,
+			                skip_message = EXCLUDED.skip_message, attempt = EXCLUDED.attempt, shard_index = EXCLUDED.shard_index
+			  RETURNING id`
+
+	var id int64
+	err := tx.QueryRowContext(ctx, query,
+		buildID, testCaseID, result.Status, result.Time, result.SkipMessage, earlier+int64(len(result.Retries)+1), shard,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create execution: %w", err)
+	}
+
+	return id, nil
+}
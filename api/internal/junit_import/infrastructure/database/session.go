@@ -0,0 +1,383 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/lib/pq"
+)
+
+// importBatchSize is the number of test case results written per statement
+const importBatchSize = 500
+
+// importSession writes a streamed import. Plain test case results are buffered and
+// written in batches with multi-row statements over unnest()ed arrays; results with
+// subtests or an external ID go through saveResult one at a time. Nothing outlives
+// a batch, so the memory used does not grow with the size of the report.
+type importSession struct {
+	importWriter
+	rootSuiteID  int64
+	suiteIDs     []int64 // open suites, innermost last
+	pending      []*models.TestCaseResult
+	pendingBytes int64
+	batchBytes   int64
+}
+
+// StartSuite opens a suite; top-level suites are written to the suite the import
+// targets and nested suites become its child test suites
+func (s *importSession) StartSuite(ctx context.Context, suite *models.SuiteResult) error {
+	if err := s.flush(ctx); err != nil {
+		return err
+	}
+	if len(s.suiteIDs) == 0 {
+		s.suiteIDs = append(s.suiteIDs, s.rootSuiteID)
+		return nil
+	}
+
+	id, err := getOrCreateChildSuite(ctx, s.tx, s.projectID, s.suiteIDs[len(s.suiteIDs)-1], suite)
+	if err != nil {
+		return err
+	}
+	s.suiteIDs = append(s.suiteIDs, id)
+	return nil
+}
+
+// AddTestCase adds a result to the innermost open suite
+func (s *importSession) AddTestCase(ctx context.Context, result *models.TestCaseResult) error {
+	if len(s.suiteIDs) == 0 {
+		return fmt.Errorf("no open test suite for test case %q", result.Name)
+	}
+	if result.ExternalID != "" || len(result.Subtests) > 0 {
+		if err := s.flush(ctx); err != nil {
+			return err
+		}
+		return s.saveResult(ctx, s.suiteIDs[len(s.suiteIDs)-1], 0, result)
+	}
+
+	s.identify(s.suiteIDs[len(s.suiteIDs)-1], result)
+	s.pending = append(s.pending, result)
+	s.pendingBytes += resultSize(result)
+	if len(s.pending) >= importBatchSize || (s.batchBytes > 0 && s.pendingBytes >= s.batchBytes) {
+		return s.flush(ctx)
+	}
+	return nil
+}
+
+// EndSuite records what the innermost open suite reported for this build and closes it
+func (s *importSession) EndSuite(ctx context.Context, suite *models.SuiteResult) error {
+	if len(s.suiteIDs) == 0 {
+		return fmt.Errorf("no open test suite to end")
+	}
+	if err := s.flush(ctx); err != nil {
+		return err
+	}
+	if err := insertSuiteRun(ctx, s.tx, s.buildID, s.shard, s.suiteIDs[len(s.suiteIDs)-1], suite); err != nil {
+		return err
+	}
+	s.suiteIDs = s.suiteIDs[:len(s.suiteIDs)-1]
+	return nil
+}
+
+// Commit writes the remaining results and the build totals, then commits the import.
+// The totals of a shard are merged with those of the other shards of its build.
+func (s *importSession) Commit(ctx context.Context, build *models.ImportBuild) (int64, error) {
+	if err := s.flush(ctx); err != nil {
+		return 0, err
+	}
+	build.Warnings = append(build.Warnings, s.warnings.list()...)
+	totals := updateBuildTotals
+	if build.Shard != nil {
+		totals = recordShard
+	}
+	if err := totals(ctx, s.tx, s.buildID, build); err != nil {
+		return 0, err
+	}
+	if err := s.tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit import transaction: %w", err)
+	}
+	return s.buildID, nil
+}
+
+func (s *importSession) Rollback() error {
+	if err := s.tx.Rollback(); err != nil && !stderrors.Is(err, sql.ErrTxDone) {
+		return fmt.Errorf("failed to roll back import transaction: %w", err)
+	}
+	return nil
+}
+
+// flush writes the pending results of the innermost open suite
+func (s *importSession) flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	batch := latestResults(s.pending, s.warnings.replaced)
+	clear(s.pending)
+	s.pending = s.pending[:0]
+	s.pendingBytes = 0
+
+	testCaseIDs, err := resolveTestCases(ctx, s.tx, s.projectID, s.suiteIDs[len(s.suiteIDs)-1], batch)
+	if err != nil {
+		return err
+	}
+	s.enterReport(batch[0])
+	retries := make([]bool, len(batch))
+	for i, result := range batch {
+		retries[i] = s.retriesEarlierRun(testCaseIDs[i], result)
+	}
+	earlier, replaced, err := foldEarlierRuns(ctx, s.tx, s.buildID, testCaseIDs, retries)
+	if err != nil {
+		return err
+	}
+	for i, result := range batch {
+		if status, ok := replaced[testCaseIDs[i]]; ok {
+			s.warnings.replaced(result, status)
+		}
+		s.written[testCaseIDs[i]] = true
+	}
+	executionIDs, err := upsertExecutions(ctx, s.tx, s.buildID, s.shard, testCaseIDs, earlier, batch)
+	if err != nil {
+		return err
+	}
+	if err := insertRetries(ctx, s.tx, s.buildID, s.shard, testCaseIDs, earlier, batch); err != nil {
+		return err
+	}
+	if err := replaceFailures(ctx, s.tx, executionIDs, batch); err != nil {
+		return err
+	}
+	if err := replaceExecutionLogs(ctx, s.tx, executionIDs, batch, s.maxLogSize); err != nil {
+		return err
+	}
+	if err := replaceExecutionProperties(ctx, s.tx, executionIDs, batch); err != nil {
+		return err
+	}
+	return insertTestCaseTagBatch(ctx, s.tx, testCaseIDs, batch)
+}
+
+// latestResults keeps the last result of every test case reported more than once in a
+// batch, in the position it was first reported, as one statement may not touch a row twice.
+// The results of a batch come from one report, and a test case reported again in it after
+// it failed or errored was retried, as rerun plugins report it, and keeps its earlier runs
+// as attempts; foldEarlierRuns does the same for the runs written before. Other earlier
+// results are passed to replaced with their status.
+func latestResults(results []*models.TestCaseResult, replaced func(result *models.TestCaseResult, status string)) []*models.TestCaseResult {
+	latest := make([]*models.TestCaseResult, 0, len(results))
+	index := make(map[string]int, len(results))
+	for _, result := range results {
+		if i, seen := index[result.Fingerprint]; seen {
+			earlier := earlierAttempts(latest[i])
+			if earlier == nil {
+				replaced(result, latest[i].Status)
+			}
+			result.Retries = append(earlier, result.Retries...)
+			latest[i] = result
+			continue
+		}
+		index[result.Fingerprint] = len(latest)
+		latest = append(latest, result)
+	}
+	return latest
+}
+
+// resultSize estimates the memory held by a pending result
+func resultSize(result *models.TestCaseResult) int64 {
+	size := len(result.Name) + len(result.Classname) + len(result.File) + len(result.Fingerprint) +
+		len(result.SystemOut) + len(result.SystemErr) + len(result.SkipMessage)
+	if result.Failure != nil {
+		size += len(result.Failure.Message) + len(result.Failure.Type) + len(result.Failure.Details)
+	}
+	for _, p := range result.Properties {
+		size += len(p.Name) + len(p.Value)
+	}
+	for _, tag := range result.Tags {
+		size += len(tag)
+	}
+	for _, retry := range result.Retries {
+		if retry.Failure != nil {
+			size += len(retry.Failure.Message) + len(retry.Failure.Type) + len(retry.Failure.Details)
+		}
+	}
+	return int64(size)
+}
+
+// resolveTestCases finds or creates the test cases of a batch in one suite and returns
+// their IDs in the order of the batch. A test case another import created meanwhile, such
+// as another shard of the build, is looked up once that import committed it.
+func resolveTestCases(ctx context.Context, tx *sql.Tx, projectID, suiteID int64, batch []*models.TestCaseResult) ([]int64, error) {
+	ids, err := findTestCases(ctx, tx, projectID, suiteID, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	var classnames, names, files, fingerprints, params []string
+	for _, result := range batch {
+		if _, ok := ids[result.Fingerprint]; !ok {
+			classnames = append(classnames, result.Classname)
+			names = append(names, result.Name)
+			files = append(files, result.File)
+			fingerprints = append(fingerprints, result.Fingerprint)
+			params = append(params, result.Params)
+		}
+	}
+	if len(fingerprints) > 0 {
+		insert := `INSERT INTO test_cases (suite_id, classname, name, file, fingerprint, params)
+				   SELECT $1, k.classname, k.name, NULLIF(k.file, ''), k.fingerprint, NULLIF(k.params, '')
+				   FROM unnest($2::text[], $3::text[], $4::text[], $5::text[], $6::text[]) AS k(classname, name, file, fingerprint, params)
+				   ON CONFLICT (suite_id, fingerprint) DO NOTHING
+				   RETURNING id, fingerprint`
+		created, err := queryFingerprintIDs(ctx, tx, insert, suiteID, pq.Array(classnames), pq.Array(names), pq.Array(files),
+			pq.Array(fingerprints), pq.Array(params))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create test cases: %w", err)
+		}
+		if len(created) < len(fingerprints) {
+			query := `SELECT id, fingerprint FROM test_cases WHERE suite_id = $1 AND fingerprint = ANY($2::text[])`
+			created, err = queryFingerprintIDs(ctx, tx, query, suiteID, pq.Array(fingerprints))
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up created test cases: %w", err)
+			}
+		}
+		for fingerprint, id := range created {
+			ids[fingerprint] = id
+		}
+	}
+
+	result := make([]int64, len(batch))
+	for i, r := range batch {
+		result[i] = ids[r.Fingerprint]
+	}
+	return result, nil
+}
+
+// upsertExecutions records the final attempts of a batch, numbered after the earlier attempts
+// of their test cases, and returns their IDs in the order of the batch
+func upsertExecutions(ctx context.Context, tx *sql.Tx, buildID int64, shard sql.NullInt64, testCaseIDs, earlier []int64, batch []*models.TestCaseResult) ([]int64, error) {
+	statuses := make([]string, len(batch))
+	times := make([]float64, len(batch))
+	skipMessages := make([]string, len(batch))
+	attempts := make([]int64, len(batch))
+	for i, result := range batch {
+		statuses[i], times[i], skipMessages[i] = result.Status, result.Time, result.SkipMessage
+		attempts[i] = earlier[i] + int64(len(result.Retries)+1)
+	}
+
+	query := `INSERT INTO build_test_case_executions (build_id, test_case_id, status, execution_time, skip_message, attempt, shard_index)
+			  SELECT $1, e.test_case_id, e.status, e.execution_time, NULLIF(e.skip_message, ''), e.attempt, $7::int
+			  FROM unnest($2::bigint[], $3::text[], $4::float8[], $5::text[], $6::int[])
+			       AS e(test_case_id, status, execution_time, skip_message, attempt)
+			  ON CONFLICT (build_id, test_case_id) WHERE NOT retried
+			  DO UPDATE SET status = EXCLUDED.status, execution_time = EXCLUDED.execution_time,
+			                skip_message = EXCLUDED.skip_message, attempt = EXCLUDED.attempt, shard_index = EXCLUDED.shard_index
+			  RETURNING id, test_case_id`
+
+	rows, err := tx.QueryContext(ctx, query, buildID, pq.Array(testCaseIDs), pq.Array(statuses), pq.Array(times),
+		pq.Array(skipMessages), pq.Array(attempts), shard)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create executions: %w", err)
+	}
+	defer rows.Close()
+
+	byTestCase := make(map[int64]int64, len(batch))
+	for rows.Next() {
+		var id, testCaseID int64
+		if err := rows.Scan(&id, &testCaseID); err != nil {
+			return nil, fmt.Errorf("failed to scan execution: %w", err)
+		}
+		byTestCase[testCaseID] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to create executions: %w", err)
+	}
+
+	executionIDs := make([]int64, len(batch))
+	for i, testCaseID := range testCaseIDs {
+		executionIDs[i] = byTestCase[testCaseID]
+	}
+	return executionIDs, nil
+}
+
+// replaceFailures writes the failures of a batch in place of those recorded for its
+// executions before, so that a test case passing when reported again loses its failure
+func replaceFailures(ctx context.Context, tx *sql.Tx, executionIDs []int64, batch []*models.TestCaseResult) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM failures WHERE build_test_case_execution_id = ANY($1)`, pq.Array(executionIDs)); err != nil {
+		return fmt.Errorf("failed to replace failures: %w", err)
+	}
+
+	var ids []int64
+	var messages, types, details []string
+	for i, result := range batch {
+		if result.Failure == nil {
+			continue
+		}
+		ids = append(ids, executionIDs[i])
+		messages = append(messages, result.Failure.Message)
+		types = append(types, result.Failure.Type)
+		details = append(details, result.Failure.Details)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO failures (build_test_case_execution_id, message, type, details)
+			  SELECT * FROM unnest($1::bigint[], $2::text[], $3::text[], $4::text[])`
+
+	if _, err := tx.ExecContext(ctx, query, pq.Array(ids), pq.Array(messages), pq.Array(types), pq.Array(details)); err != nil {
+		return fmt.Errorf("failed to create failures: %w", err)
+	}
+	return nil
+}
+
+// replaceExecutionProperties writes the properties of a batch in place of those recorded
+// for its executions before
+func replaceExecutionProperties(ctx context.Context, tx *sql.Tx, executionIDs []int64, batch []*models.TestCaseResult) error {
+	query := `DELETE FROM execution_properties WHERE build_test_case_execution_id = ANY($1)`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(executionIDs)); err != nil {
+		return fmt.Errorf("failed to replace execution properties: %w", err)
+	}
+
+	var ids []int64
+	var names, values []string
+	for i, result := range batch {
+		for _, p := range result.Properties {
+			ids = append(ids, executionIDs[i])
+			names = append(names, p.Name)
+			values = append(values, p.Value)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	insert := `INSERT INTO execution_properties (build_test_case_execution_id, name, value)
+			   SELECT * FROM unnest($1::bigint[], $2::text[], $3::text[])`
+
+	if _, err := tx.ExecContext(ctx, insert, pq.Array(ids), pq.Array(names), pq.Array(values)); err != nil {
+		return fmt.Errorf("failed to create execution properties: %w", err)
+	}
+	return nil
+}
+
+func insertTestCaseTagBatch(ctx context.Context, tx *sql.Tx, testCaseIDs []int64, batch []*models.TestCaseResult) error {
+	var ids []int64
+	var names []string
+	for i, result := range batch {
+		for _, tag := range result.Tags {
+			ids = append(ids, testCaseIDs[i])
+			names = append(names, tag)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO test_case_tags (test_case_id, name)
+			  SELECT * FROM unnest($1::bigint[], $2::text[])
+			  ON CONFLICT DO NOTHING`
+
+	if _, err := tx.ExecContext(ctx, query, pq.Array(ids), pq.Array(names)); err != nil {
+		return fmt.Errorf("failed to tag test cases: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+// checkShardBuild verifies that the build a shard is uploaded to is a sharded build of
+// the suite expecting the shard and still open, and gives the import its build number.
+// The build is not locked here, so that shards can be imported at the same time; it is
+// checked again when the shard is recorded.
+func checkShardBuild(ctx context.Context, tx *sql.Tx, build *models.ImportBuild) (int64, error) {
+	query := `SELECT build_number, expected_shards, finalized_at IS NOT NULL
+			  FROM builds WHERE id = $1 AND test_suite_id = $2`
+
+	var buildNumber string
+	var expectedShards sql.NullInt64
+	var finalized bool
+	err := tx.QueryRowContext(ctx, query, build.Shard.BuildID, build.SuiteID).Scan(&buildNumber, &expectedShards, &finalized)
+	if err == sql.ErrNoRows {
+		return 0, errors.ErrBuildNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up build %d: %w", build.Shard.BuildID, err)
+	}
+
+	if !expectedShards.Valid {
+		return 0, fmt.Errorf("%w: build %d is not sharded", errors.ErrInvalidRequest, build.Shard.BuildID)
+	}
+	if int64(build.Shard.Index) >= expectedShards.Int64 {
+		return 0, fmt.Errorf("%w: shard_index must be below the %d shards build %d expects",
+			errors.ErrInvalidRequest, expectedShards.Int64, build.Shard.BuildID)
+	}
+	if finalized {
+		return 0, errors.ErrBuildFinalized
+	}
+	build.BuildNumber = buildNumber
+	return build.Shard.BuildID, nil
+}
+
+// openShard checks the sharded build a shard is uploaded to, then removes the executions
+// and suite runs the shard uploaded to it before, along with their failures, properties,
+// logs and retried attempts, so that a shard uploaded again replaces its results
+func openShard(ctx context.Context, tx *sql.Tx, build *models.ImportBuild) (int64, error) {
+	buildID, err := checkShardBuild(ctx, tx, build)
+	if err != nil {
+		return 0, err
+	}
+
+	executions := `DELETE FROM build_test_case_executions WHERE build_id = $1 AND shard_index = $2`
+	if _, err := tx.ExecContext(ctx, executions, buildID, build.Shard.Index); err != nil {
+		return 0, fmt.Errorf("failed to delete the executions of shard %d: %w", build.Shard.Index, err)
+	}
+	suiteRuns := `DELETE FROM build_suite_runs WHERE build_id = $1 AND shard_index = $2`
+	if _, err := tx.ExecContext(ctx, suiteRuns, buildID, build.Shard.Index); err != nil {
+		return 0, fmt.Errorf("failed to delete the suite runs of shard %d: %w", build.Shard.Index, err)
+	}
+	return buildID, nil
+}
+
+// shardOf returns the index of the shard an import adds to its build, or NULL for an
+// import creating its own build
+func shardOf(build *models.ImportBuild) sql.NullInt64 {
+	if build.Shard == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(build.Shard.Index), Valid: true}
+}
+
+// recordShard records the totals of an imported shard and recomputes those of its build
+// from all of its shards. A shard uploaded again replaces its totals, as openShard replaced
+// its results. As the shards run in parallel, the build lasts as long as its longest
+// shard. The build runs from its first shard and is finalized, and finished, once every
+// shard it expects was received.
+func recordShard(ctx context.Context, tx *sql.Tx, buildID int64, build *models.ImportBuild) error {
+	var finalized bool
+	lock := `SELECT finalized_at IS NOT NULL FROM builds WHERE id = $1 FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, lock, buildID).Scan(&finalized); err != nil {
+		return fmt.Errorf("failed to lock build %d: %w", buildID, err)
+	}
+	if finalized {
+		return errors.ErrBuildFinalized
+	}
+
+	shard := `INSERT INTO build_shards (build_id, shard_index, test_case_count, duration)
+			  VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (build_id, shard_index)
+			  DO UPDATE SET test_case_count = EXCLUDED.test_case_count, duration = EXCLUDED.duration,
+			                received_at = CURRENT_TIMESTAMP`
+	if _, err := tx.ExecContext(ctx, shard, buildID, build.Shard.Index, build.TestCaseCount, build.Duration); err != nil {
+		return fmt.Errorf("failed to record shard: %w", err)
+	}
+
+	totals := `UPDATE builds b SET
+				   test_case_count = (SELECT COUNT(*) FROM build_test_case_executions e WHERE e.build_id = b.id AND NOT e.retried),
+				   duration = (SELECT MAX(s.duration) FROM build_shards s WHERE s.build_id = b.id),
+				   status = CASE WHEN b.status = 'pending' THEN 'running' ELSE b.status END,
+				   started_at = COALESCE(b.started_at, CURRENT_TIMESTAMP)
+			   WHERE b.id = $1`
+	if _, err := tx.ExecContext(ctx, totals, buildID); err != nil {
+		return fmt.Errorf("failed to update build totals: %w", err)
+	}
+
+	finalize := `UPDATE builds b SET finalized_at = CURRENT_TIMESTAMP,
+				     status = CASE WHEN b.status IN ('pending', 'running') THEN ` + derivedStatus + ` ELSE b.status END,
+				     finished_at = COALESCE(b.finished_at, CURRENT_TIMESTAMP)
+				 WHERE b.id = $1 AND (SELECT COUNT(*) FROM build_shards s WHERE s.build_id = b.id) >= b.expected_shards`
+	if _, err := tx.ExecContext(ctx, finalize, buildID); err != nil {
+		return fmt.Errorf("failed to finalize build: %w", err)
+	}
+	return nil
+}
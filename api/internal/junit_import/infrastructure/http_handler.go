@@ -1,55 +1,358 @@
 package http
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"strconv"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/ports"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
 )
 
+// formatJUnit is the default report format; other formats are looked up in the parser package
+const formatJUnit = "junit"
+
+// formatAllure is the format whose reports are zip archives themselves
+const formatAllure = "allure"
+
+// formatAuto asks for the format of a report, or of each report of an archive, to be
+// detected from its content
+const formatAuto = parser.FormatAuto
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header of an upload
+const maxIdempotencyKeyLength = 255
+
 // JUnitImportHandler handles HTTP requests for JUnit imports
 type JUnitImportHandler struct {
-	Service interface{}
+	Service ports.JUnitImportService
+	Jobs    ports.ImportJobService
+	Limits  models.ImportLimits
+	// Attachments stores the files referenced by imported reports; nil leaves them out
+	Attachments ports.AttachmentUploader
+	// Renames suggests renamed test cases after an import; nil skips the detection
+	Renames ports.RenameDetector
 }
 
 // NewJUnitImportHandler creates a new JUnitImportHandler
-func NewJUnitImportHandler(service interface{}) *JUnitImportHandler {
-	return &JUnitImportHandler{Service: service}
+func NewJUnitImportHandler(service ports.JUnitImportService, jobs ports.ImportJobService, limits models.ImportLimits, attachments ports.AttachmentUploader, renames ports.RenameDetector) *JUnitImportHandler {
+	return &JUnitImportHandler{Service: service, Jobs: jobs, Limits: limits, Attachments: attachments, Renames: renames}
 }
 
-// ProcessJUnitData handles POST /import/junit
+// ProcessJUnitData handles POST /projects/{projectID}/suites/{suiteID}/junit_imports
 // @Summary Import JUnit test data
-// @Description Process and import JUnit XML test results for a specific project and test suite
+// @Description Import a test report, or an archive of test reports, as a build of the test suite in the background
 // @Tags junit-import
-// @Accept json
+// @Accept multipart/form-data
 // @Produce json
-// @Param project_id query int true "Project ID"
-// @Param suite_id query int true "Test Suite ID"
-// @Param junitData body object true "JUnit test data"
-// @Success 201 {object} object
+// @Param projectID path int true "Project ID"
+// @Param suiteID path int true "Test Suite ID"
+// @Param Idempotency-Key header string false "Key identifying the upload across retries; an upload repeating the key or the content of an earlier upload to the suite returns the earlier import job with status 200, unless that job failed"
+// @Param junitFile formData file true "Test report, or zip or tar.gz archive of test reports, which may be of different formats when the format is auto"
+// @Param format formData string false "Report format: auto (default), junit, readyapi, nunit, xunit, trx, tap, gotest, cucumber, ctrf or allure. The format is detected from the content, report by report for an archive, when auto or omitted."
+// @Param build_number formData string false "Build number (defaults to the upload time)"
+// @Param build_id formData int false "Sharded build opened with POST /builds to add the report to, with shard_index; the upload takes the build number and metadata of the build, and is rejected with status 409 once the build was finalized"
+// @Param shard_index formData int false "Index of the CI shard that produced the report, from 0; a shard uploaded again replaces its results"
+// @Param ci_provider formData string false "CI provider"
+// @Param ci_url formData string false "CI run URL"
+// @Param commit_sha formData string false "Commit SHA the build ran against"
+// @Param branch formData string false "Branch"
+// @Param base_branch formData string false "Branch a pull request merges into"
+// @Param pr_number formData int false "Pull request number"
+// @Param author formData string false "Author of the commit or pull request"
+// @Param trigger formData string false "What triggered the build, e.g. push, pull_request, schedule or manual"
+// @Param property formData []string false "Build property as name=value; may be repeated" collectionFormat(multi)
+// @Param tag formData []string false "Build tag, e.g. smoke; may be repeated or hold comma-separated tags" collectionFormat(multi)
+// @Success 200 {object} models.ImportJob "Repeated upload"
+// @Success 202 {object} models.ImportJob
+// @Header 200,202 {string} Location "URL of the import job"
 // @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 415 {object} map[string]string "Report format not recognized"
 // @Failure 500 {object} map[string]string
-// @Router /import/junit [post]
+// @Failure 503 {object} map[string]string
+// @Router /projects/{projectID}/suites/{suiteID}/junit_imports [post]
 func (h *JUnitImportHandler) ProcessJUnitData(w http.ResponseWriter, r *http.Request) {
-	projectIDStr := r.URL.Query().Get("project_id")
-	_, err := strconv.ParseInt(projectIDStr, 10, 64)
+	projectID, err := strconv.ParseInt(r.PathValue("projectID"), 10, 64)
 	if err != nil {
-		http.Error(w, "invalid project_id", http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "invalid project ID")
 		return
 	}
 
-	suiteIDStr := r.URL.Query().Get("suite_id")
-	_, err = strconv.ParseInt(suiteIDStr, 10, 64)
+	suiteID, err := strconv.ParseInt(r.PathValue("suiteID"), 10, 64)
 	if err != nil {
-		http.Error(w, "invalid suite_id", http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "invalid suite ID")
 		return
 	}
 
-	var junitData interface{}
-	if err := json.NewDecoder(r.Body).Decode(&junitData); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+	if err := r.ParseMultipartForm(h.Limits.UploadMemory); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid multipart form")
+		return
+	}
+
+	format, err := requestedFormat(r)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	upload, err := spoolUpload(r)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	job, err := h.submitImport(r, projectID, suiteID, format, upload)
+	if err != nil || job.Replayed {
+		_ = os.Remove(upload.path)
+	}
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/imports/%d", job.ID))
+	if job.Replayed {
+		respondWithJSON(w, http.StatusOK, job)
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, job)
+}
+
+// GetImportJob handles GET /imports/{id}
+// @Summary Get an import job
+// @Description Get the state (queued, parsing, writing, done or failed), progress counts, warnings and resulting build of an import job
+// @Tags junit-import
+// @Produce json
+// @Param id path int true "Import job ID"
+// @Success 200 {object} models.ImportJob
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /imports/{id} [get]
+func (h *JUnitImportHandler) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid import job ID")
+		return
+	}
+
+	job, err := h.Jobs.GetJob(r.Context(), id)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, job)
+}
+
+// requestedFormat returns the report format of an upload, rejecting formats no parser handles.
+// It returns "" when the format is to be detected from the content of the upload.
+func requestedFormat(r *http.Request) (string, error) {
+	format := r.FormValue("format")
+	if format == "" || format == formatAuto {
+		return "", nil
+	}
+	if _, ok := parser.ForFormat(format); !ok && format != formatJUnit {
+		return "", fmt.Errorf("%w: unsupported report format %q", errors.ErrInvalidRequest, format)
+	}
+	return format, nil
+}
+
+// spooledUpload is an uploaded report copied to a temporary file
+type spooledUpload struct {
+	path string
+	hash string // hex SHA-256 of the report
+}
+
+// spoolUpload copies the uploaded report to a temporary file that outlives the request,
+// hashing it on the way
+func spoolUpload(r *http.Request) (*spooledUpload, error) {
+	file, _, err := r.FormFile("junitFile")
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing junitFile", errors.ErrInvalidRequest)
+	}
+	defer file.Close()
+
+	spool, err := os.CreateTemp("", "test-results-import-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to spool upload: %w", err)
+	}
+	defer spool.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(spool, hash), file); err != nil {
+		_ = os.Remove(spool.Name())
+		return nil, fmt.Errorf("failed to spool upload: %w", err)
+	}
+	return &spooledUpload{path: spool.Name(), hash: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// submitImport queues the import of a spooled report. The task removes the spooled
+// report once it ran.
+func (h *JUnitImportHandler) submitImport(r *http.Request, projectID, suiteID int64, format string, upload *spooledUpload) (*models.ImportJob, error) {
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("%w: Idempotency-Key is longer than %d characters", errors.ErrInvalidRequest, maxIdempotencyKeyLength)
+	}
+	if format == "" {
+		detected, err := parser.DetectUploadFormat(upload.path)
+		if err != nil {
+			return nil, err
+		}
+		format = detected
+	}
+
+	metadata, err := buildMetadata(r)
+	if err != nil {
+		return nil, err
+	}
+	shard, err := buildShard(r)
+	if err != nil {
+		return nil, err
+	}
+	opts := &models.ImportOptions{
+		BuildNumber:   r.FormValue("build_number"),
+		CIProvider:    r.FormValue("ci_provider"),
+		CIURL:         r.FormValue("ci_url"),
+		BuildMetadata: metadata,
+		Shard:         shard,
+	}
+	job := &models.ImportJob{
+		ProjectID:      projectID,
+		SuiteID:        suiteID,
+		Format:         format,
+		BuildNumber:    opts.BuildNumber,
+		IdempotencyKey: key,
+		ContentHash:    upload.hash,
+		Shard:          shard,
+	}
+
+	path := upload.path
+	task := func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+		defer os.Remove(path)
+		opts.Progress = progress
+		return h.importUpload(ctx, projectID, suiteID, format, opts, path)
+	}
+	return h.Jobs.Submit(r.Context(), job, task)
+}
+
+// importUpload imports the spooled report at path. A zip or gzipped tar archive is expanded
+// and its reports imported together, except for allure results, which are always zipped.
+// Files of the archive referenced by test cases are then stored as their attachments, and
+// test cases that look renamed in the build are suggested for merging, unless the report
+// is one shard of a build, which lacks the test cases of the other shards.
+func (h *JUnitImportHandler) importUpload(ctx context.Context, projectID, suiteID int64, format string, opts *models.ImportOptions, path string) (*models.ImportResult, error) {
+	kind, err := parser.ArchiveKind(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *models.ImportResult
+	if kind != "" && format != formatAllure {
+		result, err = h.importArchive(ctx, projectID, suiteID, format, kind, opts, path)
+	} else {
+		result, err = h.importFile(ctx, projectID, suiteID, format, opts, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	h.attachFiles(ctx, result, kind, path)
+	if opts.Shard == nil {
+		h.detectRenames(ctx, result)
+	}
+	return result, nil
+}
+
+// detectRenames suggests renames for the test cases that disappeared from the imported
+// build, noting the suggestions in the warnings of the import
+func (h *JUnitImportHandler) detectRenames(ctx context.Context, result *models.ImportResult) {
+	if h.Renames == nil {
+		return
+	}
+	suggested, err := h.Renames.DetectRenames(ctx, result.BuildID)
+	if err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("renamed test cases could not be detected: %v", err))
 		return
 	}
+	if suggested > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d test cases look renamed; see the rename suggestions of project %d", suggested, result.ProjectID))
+	}
+}
+
+func (h *JUnitImportHandler) importFile(ctx context.Context, projectID, suiteID int64, format string, opts *models.ImportOptions, path string) (*models.ImportResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spooled upload: %w", err)
+	}
+	defer file.Close()
+	return h.importReport(ctx, projectID, suiteID, format, opts, file)
+}
+
+// importReport parses a report with the parser of its format and imports it.
+// JUnit reports are streamed into the import as they are parsed.
+func (h *JUnitImportHandler) importReport(ctx context.Context, projectID, suiteID int64, format string, opts *models.ImportOptions, file io.Reader) (*models.ImportResult, error) {
+	if format == formatJUnit {
+		stream := func(ctx context.Context, sink ports.JUnitSink) error {
+			return parser.StreamJUnit(ctx, file, sink, h.Limits.MaxElementSize)
+		}
+		return h.Service.StreamJUnitData(ctx, projectID, suiteID, opts, stream)
+	}
+
+	parse, ok := parser.ForFormat(format)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported report format %q", errors.ErrInvalidRequest, format)
+	}
+	suites, err := parse(file)
+	if err != nil {
+		return nil, err
+	}
+	return h.Service.ProcessReport(ctx, projectID, suiteID, opts, suites)
+}
+
+func statusForError(err error) int {
+	switch {
+	case stderrors.Is(err, errors.ErrSuiteNotFound),
+		stderrors.Is(err, errors.ErrJobNotFound),
+		stderrors.Is(err, errors.ErrBuildNotFound):
+		return http.StatusNotFound
+	case stderrors.Is(err, errors.ErrKeyConflict),
+		stderrors.Is(err, errors.ErrBuildFinalized):
+		return http.StatusConflict
+	case stderrors.Is(err, errors.ErrQueueFull):
+		return http.StatusServiceUnavailable
+	case stderrors.Is(err, errors.ErrUnknownFormat):
+		return http.StatusUnsupportedMediaType
+	case stderrors.Is(err, errors.ErrReportTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case stderrors.Is(err, errors.ErrInvalidReport),
+		stderrors.Is(err, errors.ErrEmptyReport),
+		stderrors.Is(err, errors.ErrInvalidRequest):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Helper functions for HTTP responses
+func respondWithError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
 
-	// Temporarily disabled
-	http.Error(w, "JUnit import service temporarily disabled", http.StatusServiceUnavailable)
+func respondWithJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
 }
@@ -0,0 +1,372 @@
+package parser
+
+import (
+	"archive/zip"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+// maxAllureFileSize bounds the decompressed size of a single result or container file
+const maxAllureFileSize = 64 << 20
+
+type allureResult struct {
+	UUID          string              `json:"uuid"`
+	HistoryID     string              `json:"historyId"`
+	FullName      string              `json:"fullName"`
+	Name          string              `json:"name"`
+	Status        string              `json:"status"`
+	StatusDetails allureStatusDetails `json:"statusDetails"`
+	Start         int64               `json:"start"` // epoch milliseconds
+	Stop          int64               `json:"stop"`
+	Labels        []allureLabel       `json:"labels"`
+	Parameters    []allureParameter   `json:"parameters"`
+	Steps         []allureStep        `json:"steps"`
+}
+
+type allureContainer struct {
+	Children []string     `json:"children"`
+	Befores  []allureStep `json:"befores"`
+	Afters   []allureStep `json:"afters"`
+}
+
+type allureStatusDetails struct {
+	Message string `json:"message"`
+	Trace   string `json:"trace"`
+}
+
+type allureLabel struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type allureParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// allureStep is a step of a test, or a before/after fixture of a container
+type allureStep struct {
+	Name          string              `json:"name"`
+	Status        string              `json:"status"`
+	StatusDetails allureStatusDetails `json:"statusDetails"`
+	Steps         []allureStep        `json:"steps"`
+}
+
+// ParseAllure decodes a zipped allure-results directory into normalized suites.
+// The results are returned under a single unnamed root suite standing for the
+// suite targeted by the upload; the parentSuite, suite and subSuite labels of a
+// result become nested suites below it. A result's historyId is kept as the
+// stable identity of its test case, and when a test was retried only its last
+// result is kept. Status details, the step tree and failed fixtures make up the
+// failure details. A result or container file larger than maxAllureFileSize fails
+// the import with ErrReportTooLarge.
+func ParseAllure(r io.Reader) ([]*models.SuiteResult, error) {
+	archive, closeArchive, err := openAllureArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	defer closeArchive()
+
+	results, fixtures, err := readAllureArchive(archive)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w: no *-result.json files found", errors.ErrInvalidReport)
+	}
+
+	root := &models.SuiteResult{}
+	for _, result := range latestAllureResults(results) {
+		suite := allureSuite(root, result.Labels)
+		testCase := convertAllureResult(suite.Name, result, fixtures[result.UUID])
+		suite.TestCases = append(suite.TestCases, testCase)
+		start := allureTime(result.Start)
+		suite.Timestamp = earlierTime(suite.Timestamp, start)
+		root.Timestamp = earlierTime(root.Timestamp, start)
+	}
+	finishAllureSuite(root)
+	return []*models.SuiteResult{root}, nil
+}
+
+// sizedReaderAt is a reader with random access to content of a known size
+type sizedReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// openAllureArchive opens the zip archive read by r without holding it in memory: a file
+// or another reader with random access is read in place, any other reader is first copied
+// to a temporary file. The returned func releases the temporary file.
+func openAllureArchive(r io.Reader) (*zip.Reader, func(), error) {
+	var content io.ReaderAt
+	var size int64
+	release := func() {}
+
+	switch src := r.(type) {
+	case *os.File:
+		info, err := src.Stat()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read allure results: %w", err)
+		}
+		content, size = src, info.Size()
+	case sizedReaderAt:
+		content, size = src, src.Size()
+	default:
+		spool, err := os.CreateTemp("", "test-results-allure-*")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to spool allure results: %w", err)
+		}
+		release = func() {
+			_ = spool.Close()
+			_ = os.Remove(spool.Name())
+		}
+		size, err = io.Copy(spool, r)
+		if err != nil {
+			release()
+			return nil, nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+		}
+		content = spool
+	}
+
+	archive, err := zip.NewReader(content, size)
+	if err != nil {
+		release()
+		return nil, nil, fmt.Errorf("%w: allure results must be a zip archive: %v", errors.ErrInvalidReport, err)
+	}
+	return archive, release, nil
+}
+
+// readAllureArchive decodes the result files of an archive, and the fixtures of its
+// container files keyed by the uuid of the results they apply to
+func readAllureArchive(archive *zip.Reader) ([]allureResult, map[string][]allureStep, error) {
+	var results []allureResult
+	fixtures := make(map[string][]allureStep)
+
+	for _, file := range archive.File {
+		name := path.Base(file.Name)
+		switch {
+		case strings.HasSuffix(name, "-result.json"):
+			var result allureResult
+			if err := decodeZipJSON(file, &result); err != nil {
+				return nil, nil, err
+			}
+			results = append(results, result)
+		case strings.HasSuffix(name, "-container.json"):
+			var container allureContainer
+			if err := decodeZipJSON(file, &container); err != nil {
+				return nil, nil, err
+			}
+			steps := append(append([]allureStep(nil), container.Befores...), container.Afters...)
+			for _, child := range container.Children {
+				fixtures[child] = append(fixtures[child], steps...)
+			}
+		}
+	}
+	return results, fixtures, nil
+}
+
+// decodeZipJSON decodes a file of an archive, failing with ErrReportTooLarge when it is
+// larger than maxAllureFileSize once decompressed
+func decodeZipJSON(file *zip.File, v interface{}) error {
+	if file.UncompressedSize64 > maxAllureFileSize {
+		return fmt.Errorf("%w: %s", errors.ErrReportTooLarge, file.Name)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", errors.ErrInvalidReport, file.Name, err)
+	}
+	defer rc.Close()
+
+	// The size in the header is not trusted, as the decompressed content may be larger
+	limited := &fileLimitReader{r: rc, name: file.Name, remaining: maxAllureFileSize, limited: true}
+	if err := json.NewDecoder(limited).Decode(v); err != nil {
+		if stderrors.Is(err, errors.ErrReportTooLarge) {
+			return err
+		}
+		return fmt.Errorf("%w: %s: %v", errors.ErrInvalidReport, file.Name, err)
+	}
+	return nil
+}
+
+// latestAllureResults keeps the last result of every historyId, in the order the
+// tests were first seen. Results without a historyId are all kept.
+func latestAllureResults(results []allureResult) []allureResult {
+	latest := make([]allureResult, 0, len(results))
+	index := make(map[string]int)
+	for _, result := range results {
+		if result.HistoryID == "" {
+			latest = append(latest, result)
+			continue
+		}
+		i, seen := index[result.HistoryID]
+		if !seen {
+			index[result.HistoryID] = len(latest)
+			latest = append(latest, result)
+			continue
+		}
+		if result.Stop >= latest[i].Stop {
+			latest[i] = result
+		}
+	}
+	return latest
+}
+
+// allureSuite returns the suite of root addressed by the parentSuite, suite and
+// subSuite labels, creating it when needed
+func allureSuite(root *models.SuiteResult, labels []allureLabel) *models.SuiteResult {
+	suite := root
+	for _, level := range []string{"parentSuite", "suite", "subSuite"} {
+		name := allureLabelValue(labels, level)
+		if name == "" {
+			continue
+		}
+		suite = childSuite(suite, name)
+	}
+	return suite
+}
+
+func childSuite(parent *models.SuiteResult, name string) *models.SuiteResult {
+	for _, child := range parent.Suites {
+		if child.Name == name {
+			return child
+		}
+	}
+	child := &models.SuiteResult{Name: name}
+	parent.Suites = append(parent.Suites, child)
+	return child
+}
+
+// finishAllureSuite computes the counts and time of a suite tree, bottom up
+func finishAllureSuite(suite *models.SuiteResult) {
+	countResults(suite)
+	for _, tc := range suite.TestCases {
+		suite.Time += tc.Time
+	}
+	for _, child := range suite.Suites {
+		finishAllureSuite(child)
+		addChildSuite(suite, child)
+	}
+}
+
+func convertAllureResult(suiteName string, result allureResult, fixtures []allureStep) *models.TestCaseResult {
+	testCase := &models.TestCaseResult{
+		Name:       result.Name,
+		Classname:  allureClassname(suiteName, result),
+		ExternalID: result.HistoryID,
+		Status:     allureStatus(result.Status),
+	}
+	if result.Stop > result.Start {
+		testCase.Time = float64(result.Stop-result.Start) / 1000
+	}
+	for _, label := range result.Labels {
+		if label.Name == "tag" && !containsString(testCase.Tags, label.Value) {
+			testCase.Tags = append(testCase.Tags, label.Value)
+		}
+	}
+	for _, p := range result.Parameters {
+		testCase.Properties = append(testCase.Properties, models.Property{Name: p.Name, Value: p.Value})
+	}
+
+	switch testCase.Status {
+	case models.StatusFailed, models.StatusError:
+		testCase.Failure = &models.FailureDetail{
+			Message: strings.TrimSpace(result.StatusDetails.Message),
+			Type:    result.Status,
+			Details: allureDetails(result, fixtures),
+		}
+	case models.StatusSkipped:
+		testCase.SkipMessage = strings.TrimSpace(result.StatusDetails.Message)
+	}
+	return testCase
+}
+
+// allureClassname prefers the testClass label, then the full name without the test name
+func allureClassname(suiteName string, result allureResult) string {
+	if class := allureLabelValue(result.Labels, "testClass"); class != "" {
+		return class
+	}
+	if i := strings.LastIndex(result.FullName, "."); i > 0 {
+		return result.FullName[:i]
+	}
+	return firstNonEmpty(suiteName, result.FullName)
+}
+
+// allureStatus maps an Allure status onto an execution status
+func allureStatus(status string) string {
+	switch status {
+	case "passed":
+		return models.StatusPassed
+	case "failed":
+		return models.StatusFailed
+	case "skipped":
+		return models.StatusSkipped
+	default:
+		// broken and unknown results
+		return models.StatusError
+	}
+}
+
+// allureDetails renders the trace of a result, its step tree and its failed fixtures
+func allureDetails(result allureResult, fixtures []allureStep) string {
+	var sections []string
+	if trace := strings.TrimSpace(result.StatusDetails.Trace); trace != "" {
+		sections = append(sections, trace)
+	}
+	if len(result.Steps) > 0 {
+		var b strings.Builder
+		b.WriteString("Steps:")
+		writeAllureSteps(&b, result.Steps, 1)
+		sections = append(sections, b.String())
+	}
+
+	var failed []allureStep
+	for _, fixture := range fixtures {
+		if fixture.Status != "" && fixture.Status != "passed" {
+			failed = append(failed, fixture)
+		}
+	}
+	if len(failed) > 0 {
+		var b strings.Builder
+		b.WriteString("Fixtures:")
+		writeAllureSteps(&b, failed, 1)
+		sections = append(sections, b.String())
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// writeAllureSteps writes one indented line per step, e.g. "  [failed] Open page: timeout"
+func writeAllureSteps(b *strings.Builder, steps []allureStep, depth int) {
+	for _, step := range steps {
+		b.WriteString("\n" + strings.Repeat("  ", depth) + "[" + step.Status + "] " + step.Name)
+		if message := strings.TrimSpace(step.StatusDetails.Message); message != "" && step.Status != "passed" {
+			b.WriteString(": " + message)
+		}
+		writeAllureSteps(b, step.Steps, depth+1)
+	}
+}
+
+func allureLabelValue(labels []allureLabel, name string) string {
+	for _, label := range labels {
+		if label.Name == name {
+			return strings.TrimSpace(label.Value)
+		}
+	}
+	return ""
+}
+
+func allureTime(millis int64) *time.Time {
+	if millis <= 0 {
+		return nil
+	}
+	t := time.UnixMilli(millis).UTC()
+	return &t
+}
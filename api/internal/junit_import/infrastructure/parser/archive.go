@@ -0,0 +1,261 @@
+package parser
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+// Archive kinds returned by ArchiveKind
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+// reportExtensions are the file extensions of the reports of each format inside an archive
+var reportExtensions = map[string][]string{
+	"junit":    {".xml"},
+	"readyapi": {".xml"},
+	"nunit":    {".xml"},
+	"xunit":    {".xml"},
+	"trx":      {".trx", ".xml"},
+	"gotest":   {".json", ".jsonl", ".log", ".txt"},
+	"cucumber": {".json"},
+	"ctrf":     {".json"},
+	"tap":      {".tap", ".txt", ".log"},
+}
+
+// ReportFunc receives one report file of an archive
+type ReportFunc func(name string, r io.Reader) error
+
+// DetectedReportFunc receives one report file of an archive with its format, or with the
+// error that kept it from being taken as a report of a known format
+type DetectedReportFunc func(name, format string, r io.Reader, err error) error
+
+// EntryFunc receives one file of an archive along with its size
+type EntryFunc func(name string, r io.Reader, size int64) error
+
+// ArchiveKind tells from its first bytes whether the file at path is a zip or a gzipped
+// tar archive, returning "" when it is neither
+func ArchiveKind(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, 4)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(header[:n], []byte("PK\x03\x04")):
+		return ArchiveZip, nil
+	case bytes.HasPrefix(header[:n], []byte{0x1f, 0x8b}):
+		return ArchiveTarGz, nil
+	default:
+		return "", nil
+	}
+}
+
+// WalkArchive calls fn with every report file of a format in the archive at path, in
+// archive order; an empty format stands for every format. Directories, hidden files and
+// files whose extension does not fit the format are skipped. Reading more than
+// maxFileSize bytes of a file (no limit when 0) fails with ErrReportTooLarge; an error
+// returned by fn stops the walk.
+func WalkArchive(filePath, kind, format string, maxFileSize int64, fn ReportFunc) error {
+	include := func(name string) bool {
+		return isReportFile(name, format)
+	}
+	limited := func(name string, r io.Reader, size int64) error {
+		return fn(name, &fileLimitReader{r: r, name: name, remaining: maxFileSize, limited: maxFileSize > 0})
+	}
+	return walkArchive(filePath, kind, include, limited)
+}
+
+// WalkArchiveReports calls fn with every file of the archive at path that may hold a
+// report of any format, in archive order, along with the format detected from its
+// content; a file of no recognized format is passed with ErrUnknownFormat. When format
+// is not "", the files whose extension fits it are taken as its reports instead, and the
+// other files are passed with ErrUnknownFormat. Reading more than maxFileSize bytes of a
+// report (no limit when 0) fails with ErrReportTooLarge, except for JUnit reports, which
+// are streamed rather than held in memory. An error returned by fn stops the walk.
+func WalkArchiveReports(filePath, kind, format string, maxFileSize int64, fn DetectedReportFunc) error {
+	return WalkArchive(filePath, kind, "", 0, func(name string, r io.Reader) error {
+		if format != "" {
+			if !isReportFile(name, format) {
+				return fn(name, "", r, fmt.Errorf("%w: not a %s report file", errors.ErrUnknownFormat, format))
+			}
+			return fn(name, format, limitReport(name, format, r, maxFileSize), nil)
+		}
+
+		head, err := io.ReadAll(io.LimitReader(r, sniffSize))
+		if err != nil {
+			return fn(name, "", r, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err))
+		}
+		r = io.MultiReader(bytes.NewReader(head), r)
+		detected, err := DetectFormat(bytes.NewReader(head))
+		if err != nil {
+			return fn(name, "", r, err)
+		}
+		return fn(name, detected, limitReport(name, detected, r, maxFileSize), nil)
+	})
+}
+
+// limitReport applies the size limit of a report that is parsed whole to r
+func limitReport(name, format string, r io.Reader, maxFileSize int64) io.Reader {
+	if format == "junit" || maxFileSize <= 0 {
+		return r
+	}
+	return &fileLimitReader{r: r, name: name, remaining: maxFileSize, limited: true}
+}
+
+// WalkArchiveEntries calls fn with every file of the archive at path, whatever its
+// extension, in archive order. Directories and hidden files are skipped; an error
+// returned by fn stops the walk.
+func WalkArchiveEntries(filePath, kind string, fn EntryFunc) error {
+	return walkArchive(filePath, kind, isVisibleFile, fn)
+}
+
+func walkArchive(filePath, kind string, include func(name string) bool, fn EntryFunc) error {
+	switch kind {
+	case ArchiveZip:
+		return walkZip(filePath, include, fn)
+	case ArchiveTarGz:
+		return walkTarGz(filePath, include, fn)
+	default:
+		return fmt.Errorf("%w: unsupported archive kind %q", errors.ErrInvalidReport, kind)
+	}
+}
+
+func walkZip(filePath string, include func(name string) bool, fn EntryFunc) error {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return fmt.Errorf("%w: invalid zip archive: %v", errors.ErrInvalidReport, err)
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if !file.Mode().IsRegular() || !include(file.Name) {
+			continue
+		}
+		if err := walkZipFile(file, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkZipFile(file *zip.File, fn EntryFunc) error {
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", errors.ErrInvalidReport, file.Name, err)
+	}
+	defer rc.Close()
+	return fn(file.Name, rc, int64(file.UncompressedSize64))
+}
+
+func walkTarGz(filePath string, include func(name string) bool, fn EntryFunc) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%w: invalid gzip archive: %v", errors.ErrInvalidReport, err)
+	}
+	defer gz.Close()
+
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: invalid tar archive: %v", errors.ErrInvalidReport, err)
+		}
+		if header.Typeflag != tar.TypeReg || !include(header.Name) {
+			continue
+		}
+		if err := fn(header.Name, archive, header.Size); err != nil {
+			return err
+		}
+	}
+}
+
+// isVisibleFile reports whether an archive entry is outside of hidden directories and
+// of the resource forks macOS adds to zip archives
+func isVisibleFile(name string) bool {
+	for _, segment := range strings.Split(path.Clean(name), "/") {
+		if strings.HasPrefix(segment, ".") || segment == "__MACOSX" {
+			return false
+		}
+	}
+	return true
+}
+
+// isReportFile reports whether an archive entry may hold a report of the format, or of
+// any format when format is ""
+func isReportFile(name, format string) bool {
+	if !isVisibleFile(name) {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(name))
+	if format != "" {
+		return hasExtension(reportExtensions[format], ext)
+	}
+	for _, extensions := range reportExtensions {
+		if hasExtension(extensions, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasExtension(extensions []string, ext string) bool {
+	for _, candidate := range extensions {
+		if ext == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// fileLimitReader fails with ErrReportTooLarge once more than the limit of a file was read
+type fileLimitReader struct {
+	r         io.Reader
+	name      string
+	remaining int64
+	limited   bool
+}
+
+func (l *fileLimitReader) Read(p []byte) (int, error) {
+	if !l.limited {
+		return l.r.Read(p)
+	}
+	if l.remaining < 0 {
+		return 0, fmt.Errorf("%w: %s", errors.ErrReportTooLarge, l.name)
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, fmt.Errorf("%w: %s", errors.ErrReportTooLarge, l.name)
+	}
+	return n, err
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+type ctrfReport struct {
+	ReportFormat string       `json:"reportFormat"`
+	Results      *ctrfResults `json:"results"`
+}
+
+type ctrfResults struct {
+	Tool    ctrfTool    `json:"tool"`
+	Summary ctrfSummary `json:"summary"`
+	Tests   []ctrfTest  `json:"tests"`
+}
+
+type ctrfTool struct {
+	Name string `json:"name"`
+}
+
+type ctrfSummary struct {
+	Start int64 `json:"start"` // epoch milliseconds
+	Stop  int64 `json:"stop"`
+}
+
+type ctrfTest struct {
+	Name     string   `json:"name"`
+	Status   string   `json:"status"`
+	Duration float64  `json:"duration"` // milliseconds
+	Suite    string   `json:"suite"`
+	FilePath string   `json:"filePath"`
+	Message  string   `json:"message"`
+	Trace    string   `json:"trace"`
+	Tags     []string `json:"tags"`
+	Stdout   []string `json:"stdout"`
+	Stderr   []string `json:"stderr"`
+}
+
+// ParseCTRF decodes a CTRF (Common Test Report Format) JSON report into normalized
+// suites. Tests are grouped into one suite per CTRF suite, falling back to their
+// file and then to the tool that produced the report.
+func ParseCTRF(r io.Reader) ([]*models.SuiteResult, error) {
+	var report ctrfReport
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+	}
+	if report.Results == nil {
+		return nil, fmt.Errorf("%w: missing CTRF results", errors.ErrInvalidReport)
+	}
+
+	var startedAt *time.Time
+	if report.Results.Summary.Start > 0 {
+		t := time.UnixMilli(report.Results.Summary.Start).UTC()
+		startedAt = &t
+	}
+
+	var suites []*models.SuiteResult
+	bySuite := make(map[string]*models.SuiteResult)
+	for _, test := range report.Results.Tests {
+		name := firstNonEmpty(test.Suite, test.FilePath, report.Results.Tool.Name)
+		suite, ok := bySuite[name]
+		if !ok {
+			suite = &models.SuiteResult{Name: name, Timestamp: startedAt}
+			bySuite[name] = suite
+			suites = append(suites, suite)
+		}
+		testCase := convertCTRFTest(name, test)
+		suite.TestCases = append(suite.TestCases, testCase)
+		suite.Time += testCase.Time
+	}
+	for _, suite := range suites {
+		countResults(suite)
+	}
+	return suites, nil
+}
+
+func convertCTRFTest(suiteName string, test ctrfTest) *models.TestCaseResult {
+	result := &models.TestCaseResult{
+		Name:      test.Name,
+		Classname: suiteName,
+		File:      test.FilePath,
+		Status:    ctrfStatus(test.Status),
+		Time:      test.Duration / 1000,
+		Tags:      test.Tags,
+		SystemOut: strings.TrimSpace(strings.Join(test.Stdout, "\n")),
+		SystemErr: strings.TrimSpace(strings.Join(test.Stderr, "\n")),
+	}
+
+	switch result.Status {
+	case models.StatusFailed, models.StatusError:
+		result.Failure = &models.FailureDetail{
+			Message: strings.TrimSpace(test.Message),
+			Type:    test.Status,
+			Details: strings.TrimSpace(test.Trace),
+		}
+	case models.StatusSkipped:
+		result.SkipMessage = strings.TrimSpace(test.Message)
+	}
+	return result
+}
+
+// ctrfStatus maps a CTRF status onto an execution status
+func ctrfStatus(status string) string {
+	switch status {
+	case "passed":
+		return models.StatusPassed
+	case "failed":
+		return models.StatusFailed
+	case "skipped", "pending":
+		return models.StatusSkipped
+	default:
+		return models.StatusError
+	}
+}
@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+type cucumberFeature struct {
+	URI      string            `json:"uri"`
+	Name     string            `json:"name"`
+	Tags     []cucumberTag     `json:"tags"`
+	Elements []cucumberElement `json:"elements"`
+}
+
+// cucumberElement is a scenario, a background, or a rule grouping its own elements
+type cucumberElement struct {
+	Keyword        string            `json:"keyword"`
+	Type           string            `json:"type"`
+	Name           string            `json:"name"`
+	Line           int               `json:"line"`
+	StartTimestamp string            `json:"start_timestamp"`
+	Tags           []cucumberTag     `json:"tags"`
+	Before         []cucumberStep    `json:"before"`
+	Steps          []cucumberStep    `json:"steps"`
+	After          []cucumberStep    `json:"after"`
+	Elements       []cucumberElement `json:"elements"`
+}
+
+type cucumberTag struct {
+	Name string `json:"name"`
+}
+
+type cucumberStep struct {
+	Keyword string         `json:"keyword"`
+	Name    string         `json:"name"`
+	Line    int            `json:"line"`
+	Output  []string       `json:"output"`
+	Match   cucumberMatch  `json:"match"`
+	Result  cucumberResult `json:"result"`
+}
+
+type cucumberMatch struct {
+	Location string `json:"location"`
+}
+
+type cucumberResult struct {
+	Status       string `json:"status"`
+	Duration     int64  `json:"duration"` // nanoseconds
+	ErrorMessage string `json:"error_message"`
+}
+
+// ParseCucumber decodes a Cucumber JSON report into normalized suites. The report
+// is returned as a single unnamed root suite standing for the suite targeted by
+// the upload, so that features become its child suites and rules nest below
+// their feature. Scenarios become test cases tagged with their own and inherited
+// tags; the first step that did not pass, with its keyword and line, makes up
+// the failure of a scenario.
+func ParseCucumber(r io.Reader) ([]*models.SuiteResult, error) {
+	var features []cucumberFeature
+	if err := json.NewDecoder(r).Decode(&features); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+	}
+
+	root := &models.SuiteResult{}
+	for _, feature := range features {
+		suite := convertCucumberElements(firstNonEmpty(feature.Name, feature.URI), feature.URI, tagNames(nil, feature.Tags), feature.Elements)
+		root.Suites = append(root.Suites, suite)
+		addChildSuite(root, suite)
+	}
+	return []*models.SuiteResult{root}, nil
+}
+
+// convertCucumberElements builds the suite of a feature or rule from its elements
+func convertCucumberElements(name, uri string, tags []string, elements []cucumberElement) *models.SuiteResult {
+	suite := &models.SuiteResult{Name: name}
+	names := make(map[string]int)
+	var background *cucumberElement
+
+	for i := range elements {
+		element := elements[i]
+		switch {
+		case strings.EqualFold(element.Type, "background"):
+			background = &elements[i]
+		case strings.EqualFold(element.Type, "rule") || strings.EqualFold(element.Keyword, "Rule"):
+			suite.Suites = append(suite.Suites, convertCucumberElements(element.Name, uri, tagNames(tags, element.Tags), element.Elements))
+		default:
+			testCase := convertCucumberScenario(name, uri, tags, background, element)
+			if names[testCase.Name]++; names[testCase.Name] > 1 {
+				// Scenario outline examples share the name of their outline
+				testCase.Name = fmt.Sprintf("%s (line %d)", testCase.Name, element.Line)
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+			suite.Time += testCase.Time
+			suite.Timestamp = earlierTime(suite.Timestamp, parseCucumberTime(element.StartTimestamp))
+			background = nil
+		}
+	}
+	countResults(suite)
+	for _, rule := range suite.Suites {
+		addChildSuite(suite, rule)
+	}
+	return suite
+}
+
+func convertCucumberScenario(classname, uri string, tags []string, background *cucumberElement, scenario cucumberElement) *models.TestCaseResult {
+	var steps []cucumberStep
+	steps = append(steps, scenario.Before...)
+	if background != nil {
+		steps = append(steps, background.Steps...)
+	}
+	steps = append(steps, scenario.Steps...)
+	steps = append(steps, scenario.After...)
+
+	result := &models.TestCaseResult{
+		Name:      scenario.Name,
+		Classname: classname,
+		File:      uri,
+		Status:    models.StatusPassed,
+		Tags:      tagNames(tags, scenario.Tags),
+	}
+
+	var output []string
+	skipped := len(steps) > 0
+	for _, step := range steps {
+		result.Time += float64(step.Result.Duration) / float64(time.Second)
+		output = append(output, step.Output...)
+
+		status := strings.ToLower(step.Result.Status)
+		if status != "skipped" {
+			skipped = false
+		}
+		if result.Status != models.StatusPassed || status == "passed" || status == "skipped" {
+			continue
+		}
+		result.Status = cucumberStatus(status)
+		if result.Status == models.StatusSkipped {
+			result.SkipMessage = describeCucumberStep(uri, step)
+		} else {
+			result.Failure = cucumberFailure(uri, step)
+		}
+	}
+	if skipped {
+		result.Status = models.StatusSkipped
+	}
+	result.SystemOut = strings.TrimSpace(strings.Join(output, "\n"))
+	return result
+}
+
+// cucumberStatus maps the status of the first step that did not pass onto the scenario status
+func cucumberStatus(stepStatus string) string {
+	switch stepStatus {
+	case "failed":
+		return models.StatusFailed
+	case "pending", "undefined":
+		return models.StatusSkipped
+	default:
+		// ambiguous and unknown step results
+		return models.StatusError
+	}
+}
+
+func cucumberFailure(uri string, step cucumberStep) *models.FailureDetail {
+	return &models.FailureDetail{
+		Message: describeCucumberStep(uri, step),
+		Type:    step.Result.Status,
+		Details: strings.TrimSpace(step.Result.ErrorMessage),
+	}
+}
+
+// describeCucumberStep names a step with its keyword and location, e.g.
+// "Then the balance is 10 (features/account.feature:12)". Hooks have no keyword
+// line in the feature file and are named by their code location instead.
+func describeCucumberStep(uri string, step cucumberStep) string {
+	text := strings.TrimSpace(strings.TrimSpace(step.Keyword) + " " + step.Name)
+	switch {
+	case step.Line > 0:
+		return fmt.Sprintf("%s (%s:%d)", text, uri, step.Line)
+	case step.Match.Location != "":
+		return strings.TrimSpace(text + " hook (" + step.Match.Location + ")")
+	default:
+		return text
+	}
+}
+
+// tagNames appends tags to inherited tags, without the leading @ and without duplicates
+func tagNames(inherited []string, tags []cucumberTag) []string {
+	names := append([]string(nil), inherited...)
+	for _, tag := range tags {
+		name := strings.TrimPrefix(strings.TrimSpace(tag.Name), "@")
+		if name != "" && !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func parseCucumberTime(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
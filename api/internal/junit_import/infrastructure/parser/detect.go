@@ -0,0 +1,240 @@
+package parser
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+// sniffSize is the number of leading bytes of a report DetectFormat looks at
+const sniffSize = 64 << 10
+
+// FormatAuto is the format of an archive whose reports are detected one by one
+const FormatAuto = "auto"
+
+// DetectedFormats are the formats DetectFormat and DetectUploadFormat recognize
+var DetectedFormats = []string{"junit", "nunit", "trx", "xunit", "readyapi", "tap", "cucumber", "ctrf", "gotest", "allure"}
+
+// xmlRootFormats maps the root element of an XML report to its format
+var xmlRootFormats = map[string]string{
+	"testsuites": "junit",
+	"testsuite":  "junit",
+	"test-run":   "nunit",
+	"test-suite": "nunit",
+	"TestRun":    "trx",
+	"assemblies": "xunit",
+	"assembly":   "xunit",
+	"project":    "readyapi",
+	"testSuite":  "readyapi",
+}
+
+// ctrfKeys and cucumberKeys are top-level keys only found in reports of these formats
+var (
+	ctrfKeys     = map[string]bool{"results": true, "reportFormat": true, "specVersion": true}
+	cucumberKeys = map[string]bool{"uri": true, "elements": true, "keyword": true}
+)
+
+var tapLine = regexp.MustCompile(`^(TAP version \d+|\d+\.\.\d+|(not )?ok\b|Bail out!)`)
+
+// errStopWalk ends the walk of an archive once a format was detected
+var errStopWalk = stderrors.New("stop walk")
+
+// DetectFormat identifies the format of a report from its first bytes: the root element
+// of an XML report, the leading keys of a JSON report, or the first lines of a go test
+// -json or TAP stream. Unrecognized content fails with ErrUnknownFormat.
+func DetectFormat(r io.Reader) (string, error) {
+	head, err := io.ReadAll(io.LimitReader(r, sniffSize))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+	}
+
+	text := bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(text) == 0 {
+		return "", fmt.Errorf("%w: document is empty", errors.ErrInvalidReport)
+	}
+
+	var format, seen string
+	switch text[0] {
+	case '<':
+		format, seen = xmlFormat(text)
+	case '[':
+		format, seen = jsonFormat(text, cucumberKeys, "cucumber"), "a JSON array"
+	case '{':
+		format, seen = goTestOrCTRF(text), "a JSON object"
+	default:
+		format, seen = textFormat(text), fmt.Sprintf("text starting with %q", firstLine(text))
+	}
+	if format == "" {
+		return "", unknownFormat(seen)
+	}
+	return format, nil
+}
+
+// DetectUploadFormat identifies the format of the upload spooled at path. A zip archive
+// holding allure result files is "allure". Another zip or gzipped tar archive is
+// FormatAuto, provided it holds a report of a recognized format: its reports may be of
+// different formats, which WalkArchiveReports detects one by one.
+func DetectUploadFormat(path string) (string, error) {
+	kind, err := ArchiveKind(path)
+	if err != nil {
+		return "", err
+	}
+	if kind == "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to open upload: %w", err)
+		}
+		defer file.Close()
+		return DetectFormat(file)
+	}
+
+	if kind == ArchiveZip && isAllureArchive(path) {
+		return "allure", nil
+	}
+	if err := findArchiveReport(path, kind); err != nil {
+		return "", err
+	}
+	return FormatAuto, nil
+}
+
+// findArchiveReport fails with ErrUnknownFormat unless the archive holds a report of a
+// recognized format
+func findArchiveReport(path, kind string) error {
+	found := false
+	err := WalkArchiveReports(path, kind, "", 0, func(name, format string, r io.Reader, err error) error {
+		if err != nil {
+			return nil
+		}
+		found = true
+		return errStopWalk
+	})
+	if err != nil && err != errStopWalk {
+		return err
+	}
+	if !found {
+		return unknownFormat("an archive without any recognized report")
+	}
+	return nil
+}
+
+func isAllureArchive(path string) bool {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return false
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if strings.HasSuffix(file.Name, "-result.json") {
+			return true
+		}
+	}
+	return false
+}
+
+// xmlFormat returns the format of an XML report from its root element, and the root
+// element as seen when it is not recognized
+func xmlFormat(text []byte) (string, string) {
+	root, err := firstStartElement(xml.NewDecoder(bytes.NewReader(text)))
+	if err != nil {
+		return "", "XML that could not be read"
+	}
+	return xmlRootFormats[root.Name.Local], fmt.Sprintf("XML with root element <%s>", root.Name.Local)
+}
+
+// goTestOrCTRF tells a go test -json stream, whose first event line is a JSON object
+// with an Action, from a CTRF report, whose object holds results
+func goTestOrCTRF(text []byte) string {
+	line, _, _ := bytes.Cut(text, []byte("\n"))
+	var event struct {
+		Action string `json:"Action"`
+	}
+	if json.Unmarshal(line, &event) == nil && event.Action != "" {
+		return "gotest"
+	}
+	return jsonFormat(text, ctrfKeys, "ctrf")
+}
+
+// jsonFormat returns format when one of keys is found among the leading keys of the JSON
+// object, or of the first object of the JSON array, that text starts with
+func jsonFormat(text []byte, keys map[string]bool, format string) string {
+	decoder := json.NewDecoder(bytes.NewReader(text))
+	for depth := 0; depth < 2; {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		switch token {
+		case json.Delim('['):
+			depth++
+		case json.Delim('{'):
+			return objectKeysFormat(decoder, keys, format)
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// objectKeysFormat reads the keys of the object the decoder is in, skipping their values
+func objectKeysFormat(decoder *json.Decoder, keys map[string]bool, format string) string {
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if key, ok := token.(string); ok && keys[key] {
+			return format
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+// textFormat recognizes TAP from its first line that is not a comment, and go test
+// -json output that starts with lines printed by the build
+func textFormat(text []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 4096), sniffSize)
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if first && tapLine.MatchString(line) {
+			return "tap"
+		}
+		if strings.HasPrefix(line, "{") {
+			return goTestOrCTRF([]byte(line))
+		}
+		first = false
+	}
+	return ""
+}
+
+func firstLine(text []byte) string {
+	line, _, _ := bytes.Cut(text, []byte("\n"))
+	if len(line) > 40 {
+		line = append(line[:40:40], "..."...)
+	}
+	return string(bytes.TrimSpace(line))
+}
+
+func unknownFormat(seen string) error {
+	return fmt.Errorf("%w: found %s; recognized formats are %s",
+		errors.ErrUnknownFormat, seen, strings.Join(DetectedFormats, ", "))
+}
@@ -0,0 +1,204 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+// goTestEvent is one line of the test2json stream written by go test -json
+type goTestEvent struct {
+	Time    time.Time `json:"Time"`
+	Action  string    `json:"Action"`
+	Package string    `json:"Package"`
+	Test    string    `json:"Test"`
+	Elapsed float64   `json:"Elapsed"`
+	Output  string    `json:"Output"`
+}
+
+// goTestPackage accumulates the events of one package
+type goTestPackage struct {
+	suite  *models.SuiteResult
+	tests  map[string]*models.TestCaseResult
+	order  []string
+	output map[string]*strings.Builder
+}
+
+// ParseGoTest decodes the event stream of go test -json into normalized suites.
+// Every package becomes one suite and is the classname of its tests; subtests
+// ("TestParent/case") become children of their parent test. The output of a
+// failed test is kept as its failure details. Lines that are not JSON events,
+// such as build errors printed around the stream, are ignored.
+func ParseGoTest(r io.Reader) ([]*models.SuiteResult, error) {
+	reader := bufio.NewReader(r)
+	packages := make(map[string]*goTestPackage)
+	var order []string
+	events := 0
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if event, ok := decodeGoTestEvent(line); ok {
+			events++
+			pkg, seen := packages[event.Package]
+			if !seen {
+				pkg = newGoTestPackage(event.Package)
+				packages[event.Package] = pkg
+				order = append(order, event.Package)
+			}
+			pkg.apply(event)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+		}
+	}
+	if events == 0 {
+		return nil, fmt.Errorf("%w: no go test events found", errors.ErrInvalidReport)
+	}
+
+	suites := make([]*models.SuiteResult, 0, len(order))
+	for _, name := range order {
+		suites = append(suites, packages[name].finish())
+	}
+	return suites, nil
+}
+
+func decodeGoTestEvent(line []byte) (goTestEvent, bool) {
+	var event goTestEvent
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return event, false
+	}
+	if err := json.Unmarshal(line, &event); err != nil || event.Action == "" {
+		return event, false
+	}
+	return event, true
+}
+
+func newGoTestPackage(name string) *goTestPackage {
+	return &goTestPackage{
+		suite: &models.SuiteResult{Name: name},
+		tests: make(map[string]*models.TestCaseResult),
+		// Output of the package itself is kept under the empty test name
+		output: map[string]*strings.Builder{"": {}},
+	}
+}
+
+// apply folds one event into the package
+func (p *goTestPackage) apply(event goTestEvent) {
+	if !event.Time.IsZero() && (p.suite.Timestamp == nil || event.Time.Before(*p.suite.Timestamp)) {
+		t := event.Time.UTC()
+		p.suite.Timestamp = &t
+	}
+
+	if event.Test == "" {
+		p.applyPackageEvent(event)
+		return
+	}
+
+	test := p.test(event.Test)
+	switch event.Action {
+	case "output":
+		p.output[event.Test].WriteString(event.Output)
+	case "pass":
+		test.Status, test.Time = models.StatusPassed, event.Elapsed
+	case "fail":
+		test.Status, test.Time = models.StatusFailed, event.Elapsed
+	case "skip":
+		test.Status, test.Time = models.StatusSkipped, event.Elapsed
+	}
+}
+
+func (p *goTestPackage) applyPackageEvent(event goTestEvent) {
+	switch event.Action {
+	case "output":
+		p.output[""].WriteString(event.Output)
+	case "pass", "fail", "skip":
+		p.suite.Time = event.Elapsed
+	}
+}
+
+func (p *goTestPackage) test(name string) *models.TestCaseResult {
+	if test, ok := p.tests[name]; ok {
+		return test
+	}
+	// A test without a final event was still running when the stream ended
+	test := &models.TestCaseResult{Name: name, Classname: p.suite.Name, Status: models.StatusError}
+	p.tests[name] = test
+	p.order = append(p.order, name)
+	p.output[name] = &strings.Builder{}
+	return test
+}
+
+// finish attaches the collected output and nests subtests under their parents
+func (p *goTestPackage) finish() *models.SuiteResult {
+	p.suite.SystemOut = strings.TrimSpace(p.output[""].String())
+
+	for _, name := range p.order {
+		test := p.tests[name]
+		attachGoTestOutput(test, p.output[name].String())
+
+		parent, ok := p.tests[parentTestName(name)]
+		if ok {
+			parent.Subtests = append(parent.Subtests, test)
+		} else {
+			p.suite.TestCases = append(p.suite.TestCases, test)
+		}
+	}
+	countResults(p.suite)
+	return p.suite
+}
+
+// parentTestName returns the name of the test a subtest runs under, or "" for top-level tests
+func parentTestName(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// attachGoTestOutput stores the output of a test without the framework's
+// "=== RUN" style markers: as failure details when it did not pass, and
+// as its stdout otherwise
+func attachGoTestOutput(test *models.TestCaseResult, output string) {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "=== ") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	text := strings.Join(lines, "\n")
+
+	switch test.Status {
+	case models.StatusFailed, models.StatusError:
+		test.Failure = &models.FailureDetail{Message: goTestMessage(lines), Details: text}
+	case models.StatusSkipped:
+		test.SkipMessage = goTestMessage(lines)
+		test.SystemOut = text
+	default:
+		test.SystemOut = text
+	}
+}
+
+// goTestMessage returns the first line logged by the test itself, skipping the
+// "--- FAIL" style result lines
+func goTestMessage(lines []string) string {
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--- ") {
+			continue
+		}
+		return trimmed
+	}
+	return ""
+}
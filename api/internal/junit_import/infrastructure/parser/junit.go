@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+// ParseJUnit decodes a JUnit XML report. Both a <testsuites> root and a bare
+// <testsuite> root are accepted; the latter is wrapped into a single-suite report.
+func ParseJUnit(r io.Reader) (*models.JUnitTestSuites, error) {
+	decoder := xml.NewDecoder(r)
+
+	root, err := firstStartElement(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	switch root.Name.Local {
+	case "testsuites":
+		var suites models.JUnitTestSuites
+		if err := decoder.DecodeElement(&suites, &root); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+		}
+		return &suites, nil
+	case "testsuite":
+		var suite models.JUnitTestSuite
+		if err := decoder.DecodeElement(&suite, &root); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+		}
+		return &models.JUnitTestSuites{Name: suite.Name, TestSuites: []models.JUnitTestSuite{suite}}, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected root element <%s>", errors.ErrInvalidReport, root.Name.Local)
+	}
+}
+
+// firstStartElement skips the XML prolog, comments and whitespace up to the root element
+func firstStartElement(decoder *xml.Decoder) (xml.StartElement, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return xml.StartElement{}, fmt.Errorf("%w: document is empty", errors.ErrInvalidReport)
+			}
+			return xml.StartElement{}, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
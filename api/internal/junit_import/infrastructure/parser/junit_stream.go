@@ -0,0 +1,207 @@
+package parser
+
+import (
+	"context"
+	"encoding/xml"
+	stderrors "errors"
+	"fmt"
+	"io"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/ports"
+)
+
+// StreamJUnit parses a JUnit XML report token by token and hands its suites and test
+// cases to sink as they are read, so that the memory used does not grow with the size
+// of the report. Every <testcase>, <properties>, <system-out> and <system-err> element
+// is decoded on its own and may take at most maxElementSize bytes (no limit when 0);
+// a larger element fails the import with ErrReportTooLarge. Like ParseJUnit, both a
+// <testsuites> and a bare <testsuite> root are accepted.
+func StreamJUnit(ctx context.Context, r io.Reader, sink ports.JUnitSink, maxElementSize int64) error {
+	input := &elementLimitReader{r: r, limit: maxElementSize}
+	s := &junitStream{decoder: xml.NewDecoder(input), input: input, sink: sink}
+
+	root, err := firstStartElement(s.decoder)
+	if err != nil {
+		return s.invalid(err)
+	}
+
+	switch root.Name.Local {
+	case "testsuites":
+		return s.streamSuites(ctx)
+	case "testsuite":
+		return s.streamSuite(ctx, root)
+	default:
+		return fmt.Errorf("%w: unexpected root element <%s>", errors.ErrInvalidReport, root.Name.Local)
+	}
+}
+
+// junitStream walks the tokens of one report
+type junitStream struct {
+	decoder *xml.Decoder
+	input   *elementLimitReader
+	sink    ports.JUnitSink
+}
+
+// streamSuites streams the <testsuite> children of a <testsuites> root
+func (s *junitStream) streamSuites(ctx context.Context) error {
+	for {
+		token, err := s.next()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if err := s.streamChild(ctx, nil, t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// streamSuite streams a <testsuite> whose start element was just read
+func (s *junitStream) streamSuite(ctx context.Context, start xml.StartElement) error {
+	suite := &models.JUnitTestSuite{}
+	if err := decodeAttributes(start, suite); err != nil {
+		return s.invalid(err)
+	}
+	if err := s.sink.StartSuite(ctx, suite); err != nil {
+		return err
+	}
+
+	for {
+		token, err := s.next()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if err := s.streamChild(ctx, suite, t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return s.sink.EndSuite(ctx, suite)
+		}
+	}
+}
+
+// streamChild handles an element nested in suite, which is nil directly below a <testsuites> root
+func (s *junitStream) streamChild(ctx context.Context, suite *models.JUnitTestSuite, start xml.StartElement) error {
+	if start.Name.Local == "testsuite" {
+		return s.streamSuite(ctx, start)
+	}
+	if suite == nil {
+		return s.skip()
+	}
+
+	switch start.Name.Local {
+	case "testcase":
+		var testCase models.JUnitTestCase
+		if err := s.decode(&testCase, start); err != nil {
+			return err
+		}
+		return s.sink.AddTestCase(ctx, &testCase)
+	case "properties":
+		var properties struct {
+			Properties []models.JUnitProperty `xml:"property"`
+		}
+		if err := s.decode(&properties, start); err != nil {
+			return err
+		}
+		suite.Properties = append(suite.Properties, properties.Properties...)
+		return nil
+	case "system-out":
+		return s.decodeText(&suite.SystemOut, start)
+	case "system-err":
+		return s.decodeText(&suite.SystemErr, start)
+	default:
+		return s.skip()
+	}
+}
+
+func (s *junitStream) next() (xml.Token, error) {
+	s.input.reset()
+	token, err := s.decoder.Token()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: unexpected end of document", errors.ErrInvalidReport)
+	}
+	if err != nil {
+		return nil, s.invalid(err)
+	}
+	return token, nil
+}
+
+func (s *junitStream) decode(v interface{}, start xml.StartElement) error {
+	if err := s.decoder.DecodeElement(v, &start); err != nil {
+		return s.invalid(err)
+	}
+	return nil
+}
+
+// decodeText appends the text of an element to text
+func (s *junitStream) decodeText(text *string, start xml.StartElement) error {
+	var value string
+	if err := s.decode(&value, start); err != nil {
+		return err
+	}
+	*text += value
+	return nil
+}
+
+func (s *junitStream) skip() error {
+	if err := s.decoder.Skip(); err != nil {
+		return s.invalid(err)
+	}
+	return nil
+}
+
+// invalid reports a decoding error as an invalid report, unless the memory limit was hit
+func (s *junitStream) invalid(err error) error {
+	if stderrors.Is(err, errors.ErrReportTooLarge) || stderrors.Is(err, errors.ErrInvalidReport) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+}
+
+// decodeAttributes decodes the attributes of a start element into v without reading its content
+func decodeAttributes(start xml.StartElement, v interface{}) error {
+	tokens := &tokenList{start, start.End()}
+	return xml.NewTokenDecoder(tokens).Decode(v)
+}
+
+// tokenList replays a fixed list of tokens
+type tokenList []xml.Token
+
+func (t *tokenList) Token() (xml.Token, error) {
+	if len(*t) == 0 {
+		return nil, io.EOF
+	}
+	token := (*t)[0]
+	*t = (*t)[1:]
+	return token, nil
+}
+
+// elementLimitReader fails once more than limit bytes were read since its last reset.
+// The stream resets it before every token it reads, which bounds what the decoder
+// buffers for a single token or decoded element.
+type elementLimitReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *elementLimitReader) Read(p []byte) (int, error) {
+	if l.limit > 0 && l.read > l.limit {
+		return 0, fmt.Errorf("%w: an element is larger than %d bytes", errors.ErrReportTooLarge, l.limit)
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	return n, err
+}
+
+func (l *elementLimitReader) reset() {
+	l.read = 0
+}
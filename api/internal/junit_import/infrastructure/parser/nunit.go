@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+type nunitTestRun struct {
+	TestSuites []nunitTestSuite `xml:"test-suite"`
+}
+
+type nunitTestSuite struct {
+	Type       string           `xml:"type,attr"`
+	Name       string           `xml:"name,attr"`
+	FullName   string           `xml:"fullname,attr"`
+	StartTime  string           `xml:"start-time,attr"`
+	Duration   float64          `xml:"duration,attr"`
+	Properties []nunitProperty  `xml:"properties>property"`
+	Output     string           `xml:"output"`
+	TestSuites []nunitTestSuite `xml:"test-suite"`
+	TestCases  []nunitTestCase  `xml:"test-case"`
+}
+
+type nunitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	FullName   string          `xml:"fullname,attr"`
+	ClassName  string          `xml:"classname,attr"`
+	Result     string          `xml:"result,attr"`
+	Label      string          `xml:"label,attr"`
+	Duration   float64         `xml:"duration,attr"`
+	Properties []nunitProperty `xml:"properties>property"`
+	Failure    *nunitMessage   `xml:"failure"`
+	Reason     *nunitMessage   `xml:"reason"`
+	Output     string          `xml:"output"`
+}
+
+type nunitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type nunitMessage struct {
+	Message    string `xml:"message"`
+	StackTrace string `xml:"stack-trace"`
+}
+
+// nunitMethodSuites are NUnit suites that group the cases of a single test
+// method; their cases are reported as part of the enclosing fixture
+var nunitMethodSuites = map[string]bool{
+	"ParameterizedMethod": true,
+	"Theory":              true,
+	"GenericMethod":       true,
+}
+
+// ParseNUnit decodes an NUnit 3 XML report into normalized suites. Every
+// fixture becomes one suite; the assembly and namespace suites above it
+// only group fixtures and are not reported.
+func ParseNUnit(r io.Reader) ([]*models.SuiteResult, error) {
+	decoder := xml.NewDecoder(r)
+
+	root, err := firstStartElement(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	var run nunitTestRun
+	switch root.Name.Local {
+	case "test-run":
+		if err := decoder.DecodeElement(&run, &root); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+		}
+	case "test-suite":
+		var suite nunitTestSuite
+		if err := decoder.DecodeElement(&suite, &root); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+		}
+		run.TestSuites = []nunitTestSuite{suite}
+	default:
+		return nil, fmt.Errorf("%w: unexpected root element <%s>", errors.ErrInvalidReport, root.Name.Local)
+	}
+
+	var suites []*models.SuiteResult
+	for _, suite := range run.TestSuites {
+		suites = appendNUnitSuites(suites, suite)
+	}
+	return suites, nil
+}
+
+// appendNUnitSuites appends a suite for every NUnit suite holding test cases, depth first
+func appendNUnitSuites(suites []*models.SuiteResult, suite nunitTestSuite) []*models.SuiteResult {
+	cases := suite.TestCases
+	var children []nunitTestSuite
+	for _, child := range suite.TestSuites {
+		if nunitMethodSuites[child.Type] {
+			cases = append(cases, child.TestCases...)
+			continue
+		}
+		children = append(children, child)
+	}
+
+	if len(cases) > 0 {
+		suites = append(suites, convertNUnitSuite(suite, cases))
+	}
+	for _, child := range children {
+		suites = appendNUnitSuites(suites, child)
+	}
+	return suites
+}
+
+func convertNUnitSuite(suite nunitTestSuite, cases []nunitTestCase) *models.SuiteResult {
+	name := firstNonEmpty(suite.FullName, suite.Name)
+	result := &models.SuiteResult{
+		Name:       name,
+		Timestamp:  parseNUnitTime(suite.StartTime),
+		Time:       suite.Duration,
+		Properties: convertNUnitProperties(suite.Properties),
+		SystemOut:  strings.TrimSpace(suite.Output),
+	}
+	for _, tc := range cases {
+		result.TestCases = append(result.TestCases, convertNUnitTestCase(name, tc))
+	}
+	countResults(result)
+	return result
+}
+
+func convertNUnitTestCase(suiteName string, tc nunitTestCase) *models.TestCaseResult {
+	result := &models.TestCaseResult{
+		Name:       tc.Name,
+		Classname:  firstNonEmpty(tc.ClassName, suiteName),
+		Status:     nunitStatus(tc.Result, tc.Label),
+		Time:       tc.Duration,
+		Properties: convertNUnitProperties(tc.Properties),
+		SystemOut:  strings.TrimSpace(tc.Output),
+	}
+
+	switch result.Status {
+	case models.StatusFailed, models.StatusError:
+		result.Failure = &models.FailureDetail{Type: firstNonEmpty(tc.Label, tc.Result)}
+		if tc.Failure != nil {
+			result.Failure.Message = strings.TrimSpace(tc.Failure.Message)
+			result.Failure.Details = strings.TrimSpace(tc.Failure.StackTrace)
+		}
+	case models.StatusSkipped:
+		if tc.Reason != nil {
+			result.SkipMessage = strings.TrimSpace(tc.Reason.Message)
+		}
+	}
+	return result
+}
+
+// nunitStatus maps an NUnit result and its label onto an execution status
+func nunitStatus(result, label string) string {
+	switch result {
+	case "Passed", "Warning":
+		return models.StatusPassed
+	case "Failed":
+		switch label {
+		case "Error", "Invalid", "Cancelled":
+			return models.StatusError
+		}
+		return models.StatusFailed
+	default:
+		// Skipped, Ignored, Explicit and Inconclusive cases did not produce a verdict
+		return models.StatusSkipped
+	}
+}
+
+func convertNUnitProperties(properties []nunitProperty) []models.Property {
+	if len(properties) == 0 {
+		return nil
+	}
+	result := make([]models.Property, 0, len(properties))
+	for _, p := range properties {
+		result = append(result, models.Property{Name: p.Name, Value: p.Value})
+	}
+	return result
+}
+
+// parseNUnitTime parses an NUnit start time, e.g. "2024-05-01 10:00:00Z"
+func parseNUnitTime(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01-02 15:04:05Z07:00", time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"io"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+)
+
+// ParseFunc decodes a report of one format straight into normalized suites
+type ParseFunc func(r io.Reader) ([]*models.SuiteResult, error)
+
+// normalizedParsers are the parsers of every format other than JUnit, keyed by format name
+var normalizedParsers = map[string]ParseFunc{
+	"readyapi": ParseReadyAPI,
+	"nunit":    ParseNUnit,
+	"xunit":    ParseXUnit,
+	"trx":      ParseTRX,
+	"gotest":   ParseGoTest,
+	"cucumber": ParseCucumber,
+	"ctrf":     ParseCTRF,
+	"tap":      ParseTAP,
+	"allure":   ParseAllure,
+}
+
+// ForFormat returns the parser of a report format
+func ForFormat(format string) (ParseFunc, bool) {
+	parse, ok := normalizedParsers[format]
+	return parse, ok
+}
+
+// countResults fills the per-status counts of a suite from its own test cases and their subtests
+func countResults(suite *models.SuiteResult) {
+	suite.Tests, suite.Failures, suite.Errors, suite.Skipped = 0, 0, 0, 0
+	countTestCases(suite, suite.TestCases)
+}
+
+func countTestCases(suite *models.SuiteResult, testCases []*models.TestCaseResult) {
+	for _, tc := range testCases {
+		suite.Tests++
+		switch tc.Status {
+		case models.StatusFailed:
+			suite.Failures++
+		case models.StatusError:
+			suite.Errors++
+		case models.StatusSkipped:
+			suite.Skipped++
+		}
+		countTestCases(suite, tc.Subtests)
+	}
+}
+
+// addChildSuite adds the counts, time and start of a nested suite to its parent
+func addChildSuite(parent, child *models.SuiteResult) {
+	parent.Tests += child.Tests
+	parent.Failures += child.Failures
+	parent.Errors += child.Errors
+	parent.Skipped += child.Skipped
+	parent.Time += child.Time
+	parent.Timestamp = earlierTime(parent.Timestamp, child.Timestamp)
+}
+
+func earlierTime(current, candidate *time.Time) *time.Time {
+	if candidate != nil && (current == nil || candidate.Before(*current)) {
+		return candidate
+	}
+	return current
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+// readyAPIProject is the root of a ReadyAPI / SoapUI XML report
+type readyAPIProject struct {
+	Name       string              `xml:"name,attr"`
+	TestSuites []readyAPITestSuite `xml:"testSuite"`
+}
+
+type readyAPITestSuite struct {
+	Name      string             `xml:"name,attr"`
+	Status    string             `xml:"status,attr"`
+	TimeTaken float64            `xml:"timeTaken,attr"`
+	TestCases []readyAPITestCase `xml:"testCase"`
+}
+
+type readyAPITestCase struct {
+	Name      string             `xml:"name,attr"`
+	Status    string             `xml:"status,attr"`
+	TimeTaken float64            `xml:"timeTaken,attr"`
+	Reason    string             `xml:"reason"`
+	TestSteps []readyAPITestStep `xml:"testStep"`
+}
+
+type readyAPITestStep struct {
+	Name       string              `xml:"name,attr"`
+	Type       string              `xml:"type,attr"`
+	Status     string              `xml:"status,attr"`
+	TimeTaken  float64             `xml:"timeTaken,attr"`
+	Messages   []string            `xml:"message"`
+	Assertions []readyAPIAssertion `xml:"assertion"`
+}
+
+type readyAPIAssertion struct {
+	Name     string   `xml:"name,attr"`
+	Status   string   `xml:"status,attr"`
+	Messages []string `xml:"message"`
+}
+
+// ParseReadyAPI decodes a ReadyAPI / SoapUI XML report into normalized suites.
+// Both a <project> root and a bare <testSuite> root are accepted. Test cases
+// are classed by their test suite, and the failed steps of a test case, with
+// their assertion messages, are rolled into the details of its failure.
+func ParseReadyAPI(r io.Reader) ([]*models.SuiteResult, error) {
+	decoder := xml.NewDecoder(r)
+
+	root, err := firstStartElement(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	var project readyAPIProject
+	switch root.Name.Local {
+	case "project":
+		if err := decoder.DecodeElement(&project, &root); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+		}
+	case "testSuite":
+		var suite readyAPITestSuite
+		if err := decoder.DecodeElement(&suite, &root); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+		}
+		project.TestSuites = []readyAPITestSuite{suite}
+	default:
+		return nil, fmt.Errorf("%w: unexpected root element <%s>", errors.ErrInvalidReport, root.Name.Local)
+	}
+
+	suites := make([]*models.SuiteResult, 0, len(project.TestSuites))
+	for _, suite := range project.TestSuites {
+		suites = append(suites, convertReadyAPISuite(suite))
+	}
+	return suites, nil
+}
+
+func convertReadyAPISuite(suite readyAPITestSuite) *models.SuiteResult {
+	result := &models.SuiteResult{
+		Name: suite.Name,
+		Time: suite.TimeTaken / 1000,
+	}
+	for _, tc := range suite.TestCases {
+		result.TestCases = append(result.TestCases, convertReadyAPITestCase(suite.Name, tc))
+	}
+	countResults(result)
+	return result
+}
+
+func convertReadyAPITestCase(suiteName string, tc readyAPITestCase) *models.TestCaseResult {
+	result := &models.TestCaseResult{
+		Name:      tc.Name,
+		Classname: suiteName,
+		Status:    readyAPIStatus(tc),
+		Time:      tc.TimeTaken / 1000,
+	}
+	if result.Status == models.StatusFailed || result.Status == models.StatusError {
+		result.Failure = readyAPIFailure(tc)
+	}
+	return result
+}
+
+// readyAPIStatus maps a ReadyAPI test case status onto an execution status. Test cases
+// without a recognised status are failed when one of their steps failed.
+func readyAPIStatus(tc readyAPITestCase) string {
+	switch strings.ToUpper(strings.TrimSpace(tc.Status)) {
+	case "FINISHED", "OK", "PASS", "PASSED":
+		return models.StatusPassed
+	case "FAILED", "FAIL":
+		return models.StatusFailed
+	case "CANCELED", "CANCELLED", "ERROR":
+		return models.StatusError
+	case "SKIPPED", "DISABLED":
+		return models.StatusSkipped
+	}
+	for _, step := range tc.TestSteps {
+		if isReadyAPIFailure(step.Status) {
+			return models.StatusFailed
+		}
+	}
+	return models.StatusPassed
+}
+
+func isReadyAPIFailure(status string) bool {
+	switch strings.ToUpper(strings.TrimSpace(status)) {
+	case "FAILED", "FAIL", "ERROR":
+		return true
+	}
+	return false
+}
+
+// readyAPIFailure builds the failure of a test case from its failed steps. The first
+// failed assertion, or failing step when it has none, provides message and type.
+func readyAPIFailure(tc readyAPITestCase) *models.FailureDetail {
+	failure := &models.FailureDetail{Message: strings.TrimSpace(tc.Reason)}
+
+	var sections []string
+	for _, step := range tc.TestSteps {
+		if !isReadyAPIFailure(step.Status) {
+			continue
+		}
+		if failure.Type == "" {
+			failure.Type, failure.Message = firstStepFailure(step, failure.Message)
+		}
+		sections = append(sections, describeFailedStep(step))
+	}
+	failure.Details = strings.Join(sections, "\n\n")
+
+	if failure.Message == "" {
+		failure.Message = "test case " + strings.ToLower(strings.TrimSpace(tc.Status))
+	}
+	return failure
+}
+
+func firstStepFailure(step readyAPITestStep, fallback string) (string, string) {
+	for _, assertion := range step.Assertions {
+		if isReadyAPIFailure(assertion.Status) {
+			return assertion.Name, firstNonEmpty(joinMessages(assertion.Messages), fallback)
+		}
+	}
+	return step.Type, firstNonEmpty(joinMessages(step.Messages), fallback)
+}
+
+// describeFailedStep renders a failed step and its failed assertions
+func describeFailedStep(step readyAPITestStep) string {
+	var b strings.Builder
+	b.WriteString("Step: " + step.Name)
+	if step.Type != "" {
+		b.WriteString(" [" + step.Type + "]")
+	}
+	if message := joinMessages(step.Messages); message != "" {
+		b.WriteString("\n" + message)
+	}
+	for _, assertion := range step.Assertions {
+		if !isReadyAPIFailure(assertion.Status) {
+			continue
+		}
+		b.WriteString("\n- Assertion " + assertion.Name + " failed")
+		if message := joinMessages(assertion.Messages); message != "" {
+			b.WriteString(": " + message)
+		}
+	}
+	return b.String()
+}
+
+func joinMessages(messages []string) string {
+	trimmed := make([]string, 0, len(messages))
+	for _, m := range messages {
+		if m = strings.TrimSpace(m); m != "" {
+			trimmed = append(trimmed, m)
+		}
+	}
+	return strings.Join(trimmed, "; ")
+}
@@ -0,0 +1,201 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+// tapSubtestIndent is the indentation of the lines of a TAP subtest below its parent
+const tapSubtestIndent = "    "
+
+var (
+	tapTestPoint = regexp.MustCompile(`^(not ok|ok)\b(?:\s+(\d+))?(?:\s+-)?\s*(.*)$`)
+	tapDirective = regexp.MustCompile(`(?i)\s*(?:^|[^\\])#\s*(skip|todo)\S*\s*(.*)$`)
+)
+
+// tapParser walks the lines of a TAP stream
+type tapParser struct {
+	lines  []string
+	pos    int
+	bailed bool
+}
+
+// ParseTAP decodes a TAP (Test Anything Protocol) stream, version 12 to 14, into
+// normalized suites. TAP has no suites, so its test points are returned under a
+// single unnamed root suite standing for the suite targeted by the upload.
+// Indented subtests become subtests of the test point that ends them. SKIP and
+// TODO directives skip a test point, the YAML diagnostics of a failed test point
+// make up its failure, and a "Bail out!" is kept as an errored test case.
+func ParseTAP(r io.Reader) ([]*models.SuiteResult, error) {
+	lines, err := readTAPLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &tapParser{lines: lines}
+	results := p.block("")
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w: no TAP test points found", errors.ErrInvalidReport)
+	}
+
+	root := &models.SuiteResult{TestCases: results}
+	for _, result := range results {
+		root.Time += result.Time
+	}
+	countResults(root)
+	return []*models.SuiteResult{root}, nil
+}
+
+func readTAPLines(r io.Reader) ([]string, error) {
+	var lines []string
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lines = append(lines, strings.TrimRight(line, "\r\n"))
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+		}
+	}
+}
+
+// block parses the lines indented by indent, up to the first line indented less, and
+// returns their test points. Test points of a deeper subtest are collected until the
+// test point that ends the subtest.
+func (p *tapParser) block(indent string) []*models.TestCaseResult {
+	var results, subtests []*models.TestCaseResult
+	for p.pos < len(p.lines) && !p.bailed {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			p.pos++
+			continue
+		}
+		if !strings.HasPrefix(line, indent) {
+			break
+		}
+		rest := line[len(indent):]
+		if strings.HasPrefix(rest, tapSubtestIndent) {
+			subtests = append(subtests, p.block(indent+tapSubtestIndent)...)
+			continue
+		}
+
+		p.pos++
+		if match := tapTestPoint.FindStringSubmatch(rest); match != nil {
+			result := tapResult(match)
+			result.Subtests, subtests = subtests, nil
+			p.diagnostics(indent, result)
+			results = append(results, result)
+		} else if strings.HasPrefix(rest, "Bail out!") {
+			results = append(results, tapBailOut(rest))
+			p.bailed = true
+		}
+	}
+	return append(results, subtests...)
+}
+
+// diagnostics reads the YAML block following a test point, if any, into its time and failure
+func (p *tapParser) diagnostics(indent string, result *models.TestCaseResult) {
+	if p.pos >= len(p.lines) || strings.TrimSpace(p.lines[p.pos]) != "---" || !strings.HasPrefix(p.lines[p.pos], indent+"  ") {
+		return
+	}
+	p.pos++
+
+	var yaml []string
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		p.pos++
+		if strings.TrimSpace(line) == "..." {
+			break
+		}
+		yaml = append(yaml, strings.TrimPrefix(line, indent+"  "))
+	}
+	applyTAPDiagnostics(result, yaml)
+}
+
+func applyTAPDiagnostics(result *models.TestCaseResult, yaml []string) {
+	values := tapYAMLValues(yaml)
+	if ms, err := strconv.ParseFloat(values["duration_ms"], 64); err == nil {
+		result.Time = ms / 1000
+	}
+	if result.Failure == nil {
+		return
+	}
+
+	if message := values["message"]; message != "" && !strings.HasPrefix(message, "|") && !strings.HasPrefix(message, ">") {
+		result.Failure.Message = message
+	}
+	result.Failure.Type = values["severity"]
+	result.Failure.Details = strings.Join(yaml, "\n")
+}
+
+// tapYAMLValues returns the values of the top-level keys of a YAML diagnostics block,
+// the keys indented least
+func tapYAMLValues(yaml []string) map[string]string {
+	top := -1
+	for _, line := range yaml {
+		if indent := len(line) - len(strings.TrimLeft(line, " ")); strings.TrimSpace(line) != "" && (top < 0 || indent < top) {
+			top = indent
+		}
+	}
+
+	values := make(map[string]string)
+	for _, line := range yaml {
+		if top < 0 || len(line) <= top || line[top] == ' ' {
+			continue
+		}
+		if key, value, ok := strings.Cut(line[top:], ":"); ok {
+			values[key] = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return values
+}
+
+// tapResult converts a matched test point line into a test case
+func tapResult(match []string) *models.TestCaseResult {
+	ok := match[1] == "ok"
+	description := match[3]
+
+	var directive, reason string
+	if loc := tapDirective.FindStringSubmatchIndex(description); loc != nil {
+		directive = strings.ToLower(description[loc[2]:loc[3]])
+		reason = strings.TrimSpace(description[loc[4]:loc[5]])
+		description = strings.TrimSpace(description[:loc[0]+strings.Index(description[loc[0]:], "#")])
+	}
+
+	name := strings.ReplaceAll(description, `\#`, "#")
+	if name == "" {
+		name = strings.TrimSpace("test " + match[2])
+	}
+	result := &models.TestCaseResult{Name: name, Status: models.StatusPassed}
+
+	switch {
+	case directive == "skip":
+		result.Status, result.SkipMessage = models.StatusSkipped, reason
+	case directive == "todo" && !ok:
+		result.Status, result.SkipMessage = models.StatusSkipped, strings.TrimSpace("TODO "+reason)
+	case !ok:
+		result.Status = models.StatusFailed
+		result.Failure = &models.FailureDetail{Message: name}
+	}
+	return result
+}
+
+func tapBailOut(line string) *models.TestCaseResult {
+	reason := strings.TrimSpace(strings.TrimPrefix(line, "Bail out!"))
+	return &models.TestCaseResult{
+		Name:    "Bail out!",
+		Status:  models.StatusError,
+		Failure: &models.FailureDetail{Message: reason, Type: "bail out"},
+	}
+}
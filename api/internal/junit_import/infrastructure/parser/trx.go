@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+type trxTestRun struct {
+	Name        string              `xml:"name,attr"`
+	Times       trxTimes            `xml:"Times"`
+	Results     []trxUnitTestResult `xml:"Results>UnitTestResult"`
+	Definitions []trxUnitTest       `xml:"TestDefinitions>UnitTest"`
+}
+
+type trxTimes struct {
+	Start string `xml:"start,attr"`
+}
+
+type trxUnitTestResult struct {
+	TestID       string    `xml:"testId,attr"`
+	TestName     string    `xml:"testName,attr"`
+	ComputerName string    `xml:"computerName,attr"`
+	Duration     string    `xml:"duration,attr"`
+	Outcome      string    `xml:"outcome,attr"`
+	Output       trxOutput `xml:"Output"`
+}
+
+type trxOutput struct {
+	StdOut    string       `xml:"StdOut"`
+	StdErr    string       `xml:"StdErr"`
+	ErrorInfo trxErrorInfo `xml:"ErrorInfo"`
+}
+
+type trxErrorInfo struct {
+	Message    string `xml:"Message"`
+	StackTrace string `xml:"StackTrace"`
+}
+
+type trxUnitTest struct {
+	ID         string        `xml:"id,attr"`
+	TestMethod trxTestMethod `xml:"TestMethod"`
+}
+
+type trxTestMethod struct {
+	ClassName string `xml:"className,attr"`
+}
+
+// ParseTRX decodes a Visual Studio / MSTest .trx report into normalized suites.
+// Results are grouped into one suite per test class; the class of a result
+// comes from its test definition.
+func ParseTRX(r io.Reader) ([]*models.SuiteResult, error) {
+	decoder := xml.NewDecoder(r)
+
+	root, err := firstStartElement(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if root.Name.Local != "TestRun" {
+		return nil, fmt.Errorf("%w: unexpected root element <%s>", errors.ErrInvalidReport, root.Name.Local)
+	}
+
+	var run trxTestRun
+	if err := decoder.DecodeElement(&run, &root); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+	}
+
+	classes := make(map[string]string, len(run.Definitions))
+	for _, def := range run.Definitions {
+		classes[def.ID] = trxClassName(def.TestMethod.ClassName)
+	}
+
+	startedAt := parseTRXTime(run.Times.Start)
+	var suites []*models.SuiteResult
+	byClass := make(map[string]*models.SuiteResult)
+	for _, result := range run.Results {
+		className := firstNonEmpty(classes[result.TestID], run.Name)
+		suite, ok := byClass[className]
+		if !ok {
+			suite = &models.SuiteResult{Name: className, Hostname: result.ComputerName, Timestamp: startedAt}
+			byClass[className] = suite
+			suites = append(suites, suite)
+		}
+		testCase := convertTRXResult(className, result)
+		suite.TestCases = append(suite.TestCases, testCase)
+		suite.Time += testCase.Time
+	}
+	for _, suite := range suites {
+		countResults(suite)
+	}
+	return suites, nil
+}
+
+func convertTRXResult(className string, result trxUnitTestResult) *models.TestCaseResult {
+	testCase := &models.TestCaseResult{
+		Name:      result.TestName,
+		Classname: className,
+		Status:    trxStatus(result.Outcome),
+		Time:      parseTRXDuration(result.Duration),
+		SystemOut: strings.TrimSpace(result.Output.StdOut),
+		SystemErr: strings.TrimSpace(result.Output.StdErr),
+	}
+
+	message := strings.TrimSpace(result.Output.ErrorInfo.Message)
+	switch testCase.Status {
+	case models.StatusFailed, models.StatusError:
+		testCase.Failure = &models.FailureDetail{
+			Message: message,
+			Type:    result.Outcome,
+			Details: strings.TrimSpace(result.Output.ErrorInfo.StackTrace),
+		}
+	case models.StatusSkipped:
+		testCase.SkipMessage = message
+	}
+	return testCase
+}
+
+// trxStatus maps a TRX outcome onto an execution status
+func trxStatus(outcome string) string {
+	switch outcome {
+	case "Passed", "PassedButRunAborted", "Completed", "Warning":
+		return models.StatusPassed
+	case "Failed":
+		return models.StatusFailed
+	case "Error", "Timeout", "Aborted":
+		return models.StatusError
+	default:
+		// NotExecuted, NotRunnable, Inconclusive, Pending and similar outcomes have no verdict
+		return models.StatusSkipped
+	}
+}
+
+// trxClassName strips the assembly qualification from a TRX class name,
+// e.g. "Shop.Tests.CartTests, Shop.Tests, Version=1.0.0.0"
+func trxClassName(className string) string {
+	if i := strings.Index(className, ","); i >= 0 {
+		className = className[:i]
+	}
+	return strings.TrimSpace(className)
+}
+
+// parseTRXDuration parses a TRX duration such as "00:00:01.2345678" into seconds
+func parseTRXDuration(value string) float64 {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0
+	}
+	hours, errH := strconv.ParseFloat(parts[0], 64)
+	minutes, errM := strconv.ParseFloat(parts[1], 64)
+	seconds, errS := strconv.ParseFloat(parts[2], 64)
+	if errH != nil || errM != nil || errS != nil {
+		return 0
+	}
+	return hours*3600 + minutes*60 + seconds
+}
+
+func parseTRXTime(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(value))
+	if err != nil {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
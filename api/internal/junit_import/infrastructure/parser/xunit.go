@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+)
+
+type xunitAssemblies struct {
+	Assemblies []xunitAssembly `xml:"assembly"`
+}
+
+type xunitAssembly struct {
+	Name        string            `xml:"name,attr"`
+	RunDate     string            `xml:"run-date,attr"`
+	RunTime     string            `xml:"run-time,attr"`
+	Collections []xunitCollection `xml:"collection"`
+}
+
+type xunitCollection struct {
+	Name  string      `xml:"name,attr"`
+	Time  float64     `xml:"time,attr"`
+	Tests []xunitTest `xml:"test"`
+}
+
+type xunitTest struct {
+	Name    string        `xml:"name,attr"`
+	Type    string        `xml:"type,attr"`
+	Method  string        `xml:"method,attr"`
+	Time    float64       `xml:"time,attr"`
+	Result  string        `xml:"result,attr"`
+	Traits  []xunitTrait  `xml:"traits>trait"`
+	Output  string        `xml:"output"`
+	Reason  string        `xml:"reason"`
+	Failure *xunitFailure `xml:"failure"`
+}
+
+type xunitTrait struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type xunitFailure struct {
+	ExceptionType string `xml:"exception-type,attr"`
+	Message       string `xml:"message"`
+	StackTrace    string `xml:"stack-trace"`
+}
+
+// ParseXUnit decodes an xUnit.net v2 XML report into normalized suites.
+// Every test collection becomes one suite, and traits become test properties.
+func ParseXUnit(r io.Reader) ([]*models.SuiteResult, error) {
+	decoder := xml.NewDecoder(r)
+
+	root, err := firstStartElement(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	var report xunitAssemblies
+	switch root.Name.Local {
+	case "assemblies":
+		if err := decoder.DecodeElement(&report, &root); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+		}
+	case "assembly":
+		var assembly xunitAssembly
+		if err := decoder.DecodeElement(&assembly, &root); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInvalidReport, err)
+		}
+		report.Assemblies = []xunitAssembly{assembly}
+	default:
+		return nil, fmt.Errorf("%w: unexpected root element <%s>", errors.ErrInvalidReport, root.Name.Local)
+	}
+
+	var suites []*models.SuiteResult
+	for _, assembly := range report.Assemblies {
+		startedAt := parseXUnitRunTime(assembly.RunDate, assembly.RunTime)
+		for _, collection := range assembly.Collections {
+			suite := convertXUnitCollection(collection)
+			suite.Timestamp = startedAt
+			suites = append(suites, suite)
+		}
+	}
+	return suites, nil
+}
+
+func convertXUnitCollection(collection xunitCollection) *models.SuiteResult {
+	result := &models.SuiteResult{
+		Name: collection.Name,
+		Time: collection.Time,
+	}
+	for _, test := range collection.Tests {
+		result.TestCases = append(result.TestCases, convertXUnitTest(collection.Name, test))
+	}
+	countResults(result)
+	return result
+}
+
+func convertXUnitTest(collectionName string, test xunitTest) *models.TestCaseResult {
+	result := &models.TestCaseResult{
+		Name:      test.Name,
+		Classname: firstNonEmpty(test.Type, collectionName),
+		Status:    xunitStatus(test.Result),
+		Time:      test.Time,
+		SystemOut: strings.TrimSpace(test.Output),
+	}
+	for _, trait := range test.Traits {
+		result.Properties = append(result.Properties, models.Property{Name: trait.Name, Value: trait.Value})
+	}
+
+	switch result.Status {
+	case models.StatusFailed:
+		result.Failure = &models.FailureDetail{}
+		if test.Failure != nil {
+			result.Failure.Message = strings.TrimSpace(test.Failure.Message)
+			result.Failure.Type = test.Failure.ExceptionType
+			result.Failure.Details = strings.TrimSpace(test.Failure.StackTrace)
+		}
+	case models.StatusSkipped:
+		result.SkipMessage = strings.TrimSpace(test.Reason)
+	}
+	return result
+}
+
+// xunitStatus maps an xUnit result (Pass, Fail, Skip or NotRun) onto an execution status
+func xunitStatus(result string) string {
+	switch result {
+	case "Pass":
+		return models.StatusPassed
+	case "Fail":
+		return models.StatusFailed
+	default:
+		return models.StatusSkipped
+	}
+}
+
+// parseXUnitRunTime combines the run-date and run-time of an assembly; they carry no zone and are taken as UTC
+func parseXUnitRunTime(date, clock string) *time.Time {
+	if date == "" {
+		return nil
+	}
+	value := strings.TrimSpace(date + " " + clock)
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package application
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	importErrors "github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// zipFiles builds a zip archive from name/content pairs, in order
+func zipFiles(t *testing.T, files ...string) *bytes.Reader {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for i := 0; i+1 < len(files); i += 2 {
+		f, err := w.Create(files[i])
+		assert.NoError(t, err)
+		_, err = f.Write([]byte(files[i+1]))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestParseAllure(t *testing.T) {
+	t.Run("results directory", func(t *testing.T) {
+		archive := zipFiles(t,
+			"allure-results/a-result.json", `{
+  "uuid": "a", "historyId": "h-login", "name": "testLogin", "fullName": "com.example.LoginTest.testLogin",
+  "status": "broken", "start": 1714557600000, "stop": 1714557601000,
+  "statusDetails": {"message": "NoSuchElement", "trace": "at LoginTest.java:42"},
+  "labels": [
+    {"name": "parentSuite", "value": "Web"}, {"name": "suite", "value": "Auth"},
+    {"name": "testClass", "value": "com.example.LoginTest"}, {"name": "tag", "value": "smoke"}
+  ],
+  "parameters": [{"name": "browser", "value": "firefox"}],
+  "steps": [
+    {"name": "Open page", "status": "passed"},
+    {"name": "Submit form", "status": "broken", "statusDetails": {"message": "NoSuchElement"},
+     "steps": [{"name": "Click login", "status": "broken"}]}
+  ]
+}`,
+			"allure-results/b-result.json", `{
+  "uuid": "b", "historyId": "h-logout", "name": "testLogout", "fullName": "com.example.LoginTest.testLogout",
+  "status": "failed", "start": 1714557601000, "stop": 1714557601500,
+  "labels": [{"name": "parentSuite", "value": "Web"}, {"name": "suite", "value": "Auth"}]
+}`,
+			"allure-results/c-result.json", `{
+  "uuid": "c", "historyId": "h-logout", "name": "testLogout", "fullName": "com.example.LoginTest.testLogout",
+  "status": "passed", "start": 1714557602000, "stop": 1714557602250,
+  "labels": [{"name": "parentSuite", "value": "Web"}, {"name": "suite", "value": "Auth"}]
+}`,
+			"allure-results/x-container.json", `{"children": ["a"], "befores": [{"name": "startBrowser", "status": "passed"}],
+  "afters": [{"name": "closeBrowser", "status": "broken", "statusDetails": {"message": "session gone"}}]}`,
+			"allure-results/screenshot-attachment.png", "png",
+		)
+
+		suites, err := parser.ParseAllure(archive)
+
+		assert.NoError(t, err)
+		root := suites[0]
+		assert.Equal(t, 2, root.Tests)
+		assert.Equal(t, 1, root.Errors)
+		assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), *root.Timestamp)
+
+		auth := root.Suites[0].Suites[0]
+		assert.Equal(t, "Web", root.Suites[0].Name)
+		assert.Equal(t, "Auth", auth.Name)
+		assert.Equal(t, 1.25, auth.Time)
+		assert.Equal(t, 2, root.Suites[0].Tests)
+
+		login := auth.TestCases[0]
+		assert.Equal(t, "h-login", login.ExternalID)
+		assert.Equal(t, "com.example.LoginTest", login.Classname)
+		assert.Equal(t, models.StatusError, login.Status)
+		assert.Equal(t, []string{"smoke"}, login.Tags)
+		assert.Equal(t, []models.Property{{Name: "browser", Value: "firefox"}}, login.Properties)
+		assert.Equal(t, "NoSuchElement", login.Failure.Message)
+		assert.Equal(t, "broken", login.Failure.Type)
+		assert.Equal(t, "at LoginTest.java:42\n\n"+
+			"Steps:\n  [passed] Open page\n  [broken] Submit form: NoSuchElement\n    [broken] Click login\n\n"+
+			"Fixtures:\n  [broken] closeBrowser: session gone", login.Failure.Details)
+
+		logout := auth.TestCases[1]
+		assert.Equal(t, "com.example.LoginTest", logout.Classname)
+		assert.Equal(t, models.StatusPassed, logout.Status, "the retried result is kept")
+		assert.Equal(t, 0.25, logout.Time)
+	})
+
+	t.Run("streamed archive", func(t *testing.T) {
+		// A reader without random access is spooled before the archive is read
+		archive := struct{ io.Reader }{zipFiles(t, "a-result.json", `{"uuid": "a", "name": "testLogin", "status": "passed"}`)}
+
+		suites, err := parser.ParseAllure(archive)
+
+		assert.NoError(t, err)
+		assert.Len(t, suites, 1)
+		assert.Equal(t, 1, suites[0].Tests)
+	})
+
+	t.Run("result file too large", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := zip.NewWriter(&buf)
+		f, err := w.Create("a-result.json")
+		assert.NoError(t, err)
+		_, err = io.WriteString(f, `{"uuid": "a", "name": "`)
+		assert.NoError(t, err)
+		chunk := bytes.Repeat([]byte("x"), 1<<20)
+		for i := 0; i <= 64; i++ {
+			_, err = f.Write(chunk)
+			assert.NoError(t, err)
+		}
+		_, err = io.WriteString(f, `"}`)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		_, err = parser.ParseAllure(bytes.NewReader(buf.Bytes()))
+
+		assert.True(t, errors.Is(err, importErrors.ErrReportTooLarge))
+	})
+
+	t.Run("not a zip archive", func(t *testing.T) {
+		_, err := parser.ParseAllure(strings.NewReader(`{"uuid": "a"}`))
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+
+	t.Run("no results", func(t *testing.T) {
+		_, err := parser.ParseAllure(zipFiles(t, "readme.txt", "empty"))
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+}
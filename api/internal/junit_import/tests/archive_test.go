@@ -0,0 +1,219 @@
+package application
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	importErrors "github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// tarGzFiles builds a gzipped tar archive from name/content pairs, in order
+func tarGzFiles(t *testing.T, files ...string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	for i := 0; i+1 < len(files); i += 2 {
+		assert.NoError(t, w.WriteHeader(&tar.Header{Name: files[i], Mode: 0o644, Size: int64(len(files[i+1])), Typeflag: tar.TypeReg}))
+		_, err := w.Write([]byte(files[i+1]))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+	assert.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// writeUpload stores an upload in a temporary file and returns its path
+func writeUpload(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "upload")
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// walkedFiles returns the contents of the report files WalkArchive visits, keyed by name
+func walkedFiles(t *testing.T, path, kind, format string) (map[string]string, []string) {
+	contents := make(map[string]string)
+	var names []string
+	err := parser.WalkArchive(path, kind, format, 0, func(name string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		names = append(names, name)
+		contents[name] = string(data)
+		return err
+	})
+	assert.NoError(t, err)
+	return contents, names
+}
+
+func TestArchiveKind(t *testing.T) {
+	zipped, err := io.ReadAll(zipFiles(t, "a.xml", "<testsuite/>"))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"zip", zipped, parser.ArchiveZip},
+		{"tar.gz", tarGzFiles(t, "a.xml", "<testsuite/>"), parser.ArchiveTarGz},
+		{"xml report", []byte(`<?xml version="1.0"?><testsuites/>`), ""},
+		{"tiny file", []byte("P"), ""},
+		{"empty file", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, err := parser.ArchiveKind(writeUpload(t, tt.data))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, kind)
+		})
+	}
+}
+
+func TestWalkArchive(t *testing.T) {
+	files := []string{
+		"module-a/TEST-a.xml", "<testsuite name=\"a\"/>",
+		"module-b/TEST-b.XML", "<testsuite name=\"b\"/>",
+		"module-b/output.log", "not a report",
+		".hidden/TEST-c.xml", "<testsuite/>",
+		"__MACOSX/module-a/._TEST-a.xml", "resource fork",
+	}
+
+	t.Run("zip", func(t *testing.T) {
+		zipped, err := io.ReadAll(zipFiles(t, files...))
+		assert.NoError(t, err)
+
+		contents, names := walkedFiles(t, writeUpload(t, zipped), parser.ArchiveZip, "junit")
+
+		assert.Equal(t, []string{"module-a/TEST-a.xml", "module-b/TEST-b.XML"}, names)
+		assert.Equal(t, "<testsuite name=\"b\"/>", contents["module-b/TEST-b.XML"])
+	})
+
+	t.Run("tar.gz", func(t *testing.T) {
+		contents, names := walkedFiles(t, writeUpload(t, tarGzFiles(t, files...)), parser.ArchiveTarGz, "junit")
+
+		assert.Equal(t, []string{"module-a/TEST-a.xml", "module-b/TEST-b.XML"}, names)
+		assert.Equal(t, "<testsuite name=\"a\"/>", contents["module-a/TEST-a.xml"])
+	})
+
+	t.Run("extensions of the format", func(t *testing.T) {
+		archive := tarGzFiles(t, "go.json", "{}", "report.xml", "<x/>", "go.log", "{}")
+
+		_, names := walkedFiles(t, writeUpload(t, archive), parser.ArchiveTarGz, "gotest")
+
+		assert.Equal(t, []string{"go.json", "go.log"}, names)
+	})
+
+	t.Run("file over the size limit", func(t *testing.T) {
+		path := writeUpload(t, tarGzFiles(t, "small.json", "{}", "large.json", "[1, 2, 3, 4, 5, 6, 7, 8]"))
+
+		results := make(map[string]error)
+		err := parser.WalkArchive(path, parser.ArchiveTarGz, "ctrf", 8, func(name string, r io.Reader) error {
+			_, err := io.ReadAll(r)
+			results[name] = err
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, results["small.json"])
+		assert.True(t, errors.Is(results["large.json"], importErrors.ErrReportTooLarge))
+	})
+
+	t.Run("error of fn stops the walk", func(t *testing.T) {
+		path := writeUpload(t, tarGzFiles(t, "a.xml", "<a/>", "b.xml", "<b/>"))
+		stop := errors.New("stop")
+
+		visited := 0
+		err := parser.WalkArchive(path, parser.ArchiveTarGz, "junit", 0, func(name string, r io.Reader) error {
+			visited++
+			return stop
+		})
+
+		assert.Equal(t, stop, err)
+		assert.Equal(t, 1, visited)
+	})
+
+	t.Run("corrupt archive", func(t *testing.T) {
+		path := writeUpload(t, []byte{0x1f, 0x8b, 0x08, 0x00, 0x01})
+
+		err := parser.WalkArchive(path, parser.ArchiveTarGz, "junit", 0, func(name string, r io.Reader) error {
+			return nil
+		})
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+}
+
+// walkedReport is a report file WalkArchiveReports visits, with its detected format
+type walkedReport struct {
+	name    string
+	format  string
+	content string
+	err     error
+}
+
+func walkedReports(t *testing.T, path, kind, format string, maxFileSize int64) []walkedReport {
+	var reports []walkedReport
+	err := parser.WalkArchiveReports(path, kind, format, maxFileSize, func(name, format string, r io.Reader, err error) error {
+		report := walkedReport{name: name, format: format, err: err}
+		if err == nil {
+			data, readErr := io.ReadAll(r)
+			report.content, report.err = string(data), readErr
+		}
+		reports = append(reports, report)
+		return nil
+	})
+	assert.NoError(t, err)
+	return reports
+}
+
+func TestWalkArchiveReports(t *testing.T) {
+	junit := `<testsuite name="a"><testcase name="t"/></testsuite>`
+	nunit := `<?xml version="1.0"?><test-run/>`
+	ctrf := `{"results": {"tests": []}}`
+	files := []string{
+		"junit/TEST-a.xml", junit,
+		"ctrf/report.json", ctrf,
+		"nunit/TestResult.xml", nunit,
+		"junit/TEST-a.txt", "Tests run: 1, Failures: 0",
+		"screenshots/a.png", "\x89PNG",
+	}
+	zipped, err := io.ReadAll(zipFiles(t, files...))
+	assert.NoError(t, err)
+	path := writeUpload(t, zipped)
+
+	t.Run("mixed formats", func(t *testing.T) {
+		reports := walkedReports(t, path, parser.ArchiveZip, "", 0)
+
+		assert.Len(t, reports, 4)
+		assert.Equal(t, walkedReport{name: "junit/TEST-a.xml", format: "junit", content: junit}, reports[0])
+		assert.Equal(t, walkedReport{name: "ctrf/report.json", format: "ctrf", content: ctrf}, reports[1])
+		assert.Equal(t, walkedReport{name: "nunit/TestResult.xml", format: "nunit", content: nunit}, reports[2])
+		assert.Equal(t, "junit/TEST-a.txt", reports[3].name)
+		assert.True(t, errors.Is(reports[3].err, importErrors.ErrUnknownFormat))
+	})
+
+	t.Run("requested format", func(t *testing.T) {
+		reports := walkedReports(t, path, parser.ArchiveZip, "junit", 0)
+
+		assert.Len(t, reports, 4)
+		assert.Equal(t, walkedReport{name: "junit/TEST-a.xml", format: "junit", content: junit}, reports[0])
+		assert.True(t, errors.Is(reports[1].err, importErrors.ErrUnknownFormat))
+		assert.Contains(t, reports[1].err.Error(), "not a junit report file")
+		assert.Equal(t, walkedReport{name: "nunit/TestResult.xml", format: "junit", content: nunit}, reports[2])
+		assert.True(t, errors.Is(reports[3].err, importErrors.ErrUnknownFormat))
+	})
+
+	t.Run("size limit spares JUnit reports", func(t *testing.T) {
+		reports := walkedReports(t, path, parser.ArchiveZip, "", 16)
+
+		assert.NoError(t, reports[0].err)
+		assert.True(t, errors.Is(reports[1].err, importErrors.ErrReportTooLarge))
+		assert.True(t, errors.Is(reports[2].err, importErrors.ErrReportTooLarge))
+	})
+}
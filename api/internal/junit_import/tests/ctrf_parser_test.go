@@ -0,0 +1,56 @@
+package application
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	importErrors "github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCTRF(t *testing.T) {
+	t.Run("tests grouped by suite", func(t *testing.T) {
+		report := `{
+  "reportFormat": "CTRF",
+  "specVersion": "0.0.0",
+  "results": {
+    "tool": {"name": "jest"},
+    "summary": {"tests": 3, "passed": 1, "failed": 1, "skipped": 1, "start": 1714557600000, "stop": 1714557601500},
+    "tests": [
+      {"name": "logs in", "status": "passed", "duration": 1200, "suite": "auth", "tags": ["smoke"], "stdout": ["ok"]},
+      {"name": "logs out", "status": "failed", "duration": 300, "suite": "auth",
+       "message": "expected 200", "trace": "at logout.spec.ts:12", "stderr": ["warn", "boom"]},
+      {"name": "renders", "status": "pending", "duration": 0, "message": "todo"}
+    ]
+  }
+}`
+
+		suites, err := parser.ParseCTRF(strings.NewReader(report))
+
+		assert.NoError(t, err)
+		assert.Len(t, suites, 2)
+		auth := suites[0]
+		assert.Equal(t, "auth", auth.Name)
+		assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), *auth.Timestamp)
+		assert.Equal(t, 1.5, auth.Time)
+		assert.Equal(t, 2, auth.Tests)
+		assert.Equal(t, []string{"smoke"}, auth.TestCases[0].Tags)
+		assert.Equal(t, "ok", auth.TestCases[0].SystemOut)
+		assert.Equal(t, &models.FailureDetail{Message: "expected 200", Type: "failed", Details: "at logout.spec.ts:12"}, auth.TestCases[1].Failure)
+		assert.Equal(t, "warn\nboom", auth.TestCases[1].SystemErr)
+
+		assert.Equal(t, "jest", suites[1].Name)
+		assert.Equal(t, models.StatusSkipped, suites[1].TestCases[0].Status)
+		assert.Equal(t, "todo", suites[1].TestCases[0].SkipMessage)
+	})
+
+	t.Run("missing results", func(t *testing.T) {
+		_, err := parser.ParseCTRF(strings.NewReader(`[]`))
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+}
@@ -0,0 +1,89 @@
+package application
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	importErrors "github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCucumber(t *testing.T) {
+	t.Run("features, rules and scenarios", func(t *testing.T) {
+		report := `[
+  {
+    "uri": "features/account.feature",
+    "name": "Account",
+    "tags": [{"name": "@banking"}],
+    "elements": [
+      {"type": "background", "keyword": "Background", "steps": [
+        {"keyword": "Given ", "name": "a customer", "line": 4, "result": {"status": "passed", "duration": 1000000000}}
+      ]},
+      {"type": "scenario", "keyword": "Scenario", "name": "Deposit", "line": 6,
+       "start_timestamp": "2024-05-01T10:00:00.000Z", "tags": [{"name": "@smoke"}, {"name": "@banking"}],
+       "steps": [
+        {"keyword": "When ", "name": "they deposit 10", "line": 7, "result": {"status": "passed", "duration": 500000000}},
+        {"keyword": "Then ", "name": "the balance is 10", "line": 8,
+         "result": {"status": "failed", "duration": 500000000, "error_message": "expected 10 but was 0\n\tat Steps.balance"}},
+        {"keyword": "And ", "name": "a receipt is sent", "line": 9, "result": {"status": "skipped"}}
+      ]},
+      {"type": "rule", "keyword": "Rule", "name": "Overdraft", "tags": [{"name": "@overdraft"}], "elements": [
+        {"type": "scenario", "name": "Withdraw too much", "line": 14, "steps": [
+          {"keyword": "When ", "name": "they withdraw 100", "line": 15, "result": {"status": "undefined"}}
+        ]},
+        {"type": "scenario", "name": "Withdraw too much", "line": 20, "steps": [
+          {"keyword": "When ", "name": "they withdraw 200", "line": 21, "result": {"status": "passed"}}
+        ], "after": [
+          {"match": {"location": "Hooks.cleanup()"}, "result": {"status": "failed", "error_message": "db locked"}}
+        ]}
+      ]}
+    ]
+  }
+]`
+
+		suites, err := parser.ParseCucumber(strings.NewReader(report))
+
+		assert.NoError(t, err)
+		assert.Len(t, suites, 1)
+		root := suites[0]
+		assert.Equal(t, 3, root.Tests)
+		assert.Equal(t, 2, root.Failures)
+		assert.Equal(t, 1, root.Skipped)
+		assert.Len(t, root.Suites, 1)
+
+		feature := root.Suites[0]
+		assert.Equal(t, "Account", feature.Name)
+		assert.Equal(t, 3, feature.Tests)
+		assert.Len(t, feature.TestCases, 1)
+
+		deposit := feature.TestCases[0]
+		assert.Equal(t, "Account", deposit.Classname)
+		assert.Equal(t, "features/account.feature", deposit.File)
+		assert.Equal(t, models.StatusFailed, deposit.Status)
+		assert.Equal(t, 2.0, deposit.Time)
+		assert.Equal(t, []string{"banking", "smoke"}, deposit.Tags)
+		assert.Equal(t, &models.FailureDetail{
+			Message: "Then the balance is 10 (features/account.feature:8)",
+			Type:    "failed",
+			Details: "expected 10 but was 0\n\tat Steps.balance",
+		}, deposit.Failure)
+
+		rule := feature.Suites[0]
+		assert.Equal(t, "Overdraft", rule.Name)
+		assert.Len(t, rule.TestCases, 2)
+		assert.Equal(t, models.StatusSkipped, rule.TestCases[0].Status)
+		assert.Equal(t, "When they withdraw 100 (features/account.feature:15)", rule.TestCases[0].SkipMessage)
+		assert.Equal(t, []string{"banking", "overdraft"}, rule.TestCases[0].Tags)
+		assert.Equal(t, "Withdraw too much (line 20)", rule.TestCases[1].Name)
+		assert.Equal(t, "hook (Hooks.cleanup())", rule.TestCases[1].Failure.Message)
+	})
+
+	t.Run("not a cucumber report", func(t *testing.T) {
+		_, err := parser.ParseCucumber(strings.NewReader(`{"results": {}}`))
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+}
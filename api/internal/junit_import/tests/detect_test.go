@@ -0,0 +1,106 @@
+package application
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	importErrors "github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		want   string
+	}{
+		{"junit testsuites", `<?xml version="1.0" encoding="UTF-8"?>` + "\n<testsuites><testsuite name=\"a\"/></testsuites>", "junit"},
+		{"junit testsuite with BOM", "\xef\xbb\xbf<testsuite name=\"a\" tests=\"1\">", "junit"},
+		{"junit after a comment", "<!-- generated --><testsuites>", "junit"},
+		{"nunit", `<test-run id="2" testcasecount="1">`, "nunit"},
+		{"trx", `<TestRun id="x" xmlns="http://microsoft.com/schemas/VisualStudio/TeamTest/2010">`, "trx"},
+		{"xunit", `<assemblies timestamp="05/01/2024 10:00:00">`, "xunit"},
+		{"readyapi", `<project name="Petstore"><testSuite name="a"/></project>`, "readyapi"},
+		{"tap", "TAP version 13\n1..1\nok 1 - a\n", "tap"},
+		{"tap without version", "# comment\n1..2\nok 1\nnot ok 2\n", "tap"},
+		{"cucumber", `[{"uri": "features/login.feature", "id": "login", "elements": []}]`, "cucumber"},
+		{"ctrf", `{"reportFormat": "CTRF", "results": {"tool": {"name": "jest"}, "tests": []}}`, "ctrf"},
+		{"ctrf with results first", `{"results": {"tool": {"name": "jest"}}}`, "ctrf"},
+		{"gotest", `{"Time":"2024-05-01T10:00:00Z","Action":"start","Package":"example.com/calc"}` + "\n", "gotest"},
+		{"gotest after build output", "# example.com/calc\n" + `{"Action":"pass","Package":"example.com/calc"}`, "gotest"},
+		{"truncated ctrf", `{"results": {"tests": [` + strings.Repeat(`{"name":"a"},`, 10000), "ctrf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := parser.DetectFormat(strings.NewReader(tt.report))
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, format)
+		})
+	}
+
+	unknown := []struct {
+		name   string
+		report string
+		seen   string
+	}{
+		{"unknown XML root", `<html><body/></html>`, "XML with root element <html>"},
+		{"unknown JSON object", `{"name": "report", "tests": []}`, "a JSON object"},
+		{"unknown JSON array", `[1, 2, 3]`, "a JSON array"},
+		{"plain text", "hello world\n", `text starting with "hello world"`},
+	}
+	for _, tt := range unknown {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := parser.DetectFormat(strings.NewReader(tt.report))
+
+			assert.Equal(t, "", format)
+			assert.True(t, errors.Is(err, importErrors.ErrUnknownFormat))
+			assert.Contains(t, err.Error(), tt.seen)
+			assert.Contains(t, err.Error(), "recognized formats are junit, nunit, trx, xunit, readyapi, tap, cucumber, ctrf, gotest, allure")
+		})
+	}
+
+	t.Run("empty report", func(t *testing.T) {
+		_, err := parser.DetectFormat(strings.NewReader(" \n"))
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+}
+
+func TestDetectUploadFormat(t *testing.T) {
+	zipped := func(files ...string) []byte {
+		data, err := io.ReadAll(zipFiles(t, files...))
+		assert.NoError(t, err)
+		return data
+	}
+
+	tests := []struct {
+		name   string
+		upload []byte
+		want   string
+	}{
+		{"single report", []byte("TAP version 14\nok 1\n"), "tap"},
+		{"allure results", zipped("a-result.json", `{"uuid": "a"}`, "x-container.json", `{}`), "allure"},
+		{"zip of reports", zipped("notes.txt", "hello", "module-a/TEST-a.xml", "<testsuite/>"), parser.FormatAuto},
+		{"tar.gz of reports", tarGzFiles(t, "results/run.trx", `<TestRun/>`), parser.FormatAuto},
+		{"archive of mixed formats", zipped("TEST-a.xml", "<testsuite/>", "report.json", `{"results": {}}`), parser.FormatAuto},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := parser.DetectUploadFormat(writeUpload(t, tt.upload))
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, format)
+		})
+	}
+
+	t.Run("archive without reports", func(t *testing.T) {
+		_, err := parser.DetectUploadFormat(writeUpload(t, zipped("readme.md", "# hi")))
+
+		assert.True(t, errors.Is(err, importErrors.ErrUnknownFormat))
+		assert.Contains(t, err.Error(), "an archive without any recognized report")
+	})
+}
@@ -0,0 +1,150 @@
+package application
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	importErrors "github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNUnit(t *testing.T) {
+	report := `<?xml version="1.0" encoding="utf-8"?>
+<test-run id="2" result="Failed" total="4">
+  <test-suite type="Assembly" name="Shop.Tests.dll" fullname="/build/Shop.Tests.dll">
+    <test-suite type="TestSuite" name="Shop" fullname="Shop">
+      <test-suite type="TestFixture" name="CartTests" fullname="Shop.CartTests" classname="Shop.CartTests"
+                  start-time="2024-05-01 10:00:00Z" duration="0.5">
+        <properties><property name="Category" value="unit"/></properties>
+        <test-case name="AddsItem" fullname="Shop.CartTests.AddsItem" classname="Shop.CartTests" result="Passed" duration="0.1">
+          <output><![CDATA[added 1 item]]></output>
+        </test-case>
+        <test-case name="RemovesItem" classname="Shop.CartTests" result="Failed" label="Error" duration="0.2">
+          <failure><message><![CDATA[NullReferenceException]]></message><stack-trace><![CDATA[at Cart.Remove()]]></stack-trace></failure>
+        </test-case>
+        <test-case name="Discount" classname="Shop.CartTests" result="Skipped" label="Ignored">
+          <reason><message><![CDATA[pricing service not ready]]></message></reason>
+        </test-case>
+        <test-suite type="ParameterizedMethod" name="Totals" fullname="Shop.CartTests.Totals">
+          <test-case name="Totals(1,2)" classname="Shop.CartTests" result="Failed" duration="0.1">
+            <failure><message>Expected 3 but was 4</message></failure>
+          </test-case>
+        </test-suite>
+      </test-suite>
+    </test-suite>
+  </test-suite>
+</test-run>`
+
+	suites, err := parser.ParseNUnit(strings.NewReader(report))
+
+	assert.NoError(t, err)
+	assert.Len(t, suites, 1)
+	suite := suites[0]
+	assert.Equal(t, "Shop.CartTests", suite.Name)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), *suite.Timestamp)
+	assert.Equal(t, []models.Property{{Name: "Category", Value: "unit"}}, suite.Properties)
+	assert.Equal(t, 4, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Equal(t, 1, suite.Errors)
+	assert.Equal(t, 1, suite.Skipped)
+
+	cases := suite.TestCases
+	assert.Equal(t, "added 1 item", cases[0].SystemOut)
+	assert.Equal(t, models.StatusError, cases[1].Status)
+	assert.Equal(t, &models.FailureDetail{Message: "NullReferenceException", Type: "Error", Details: "at Cart.Remove()"}, cases[1].Failure)
+	assert.Equal(t, models.StatusSkipped, cases[2].Status)
+	assert.Equal(t, "pricing service not ready", cases[2].SkipMessage)
+	assert.Equal(t, "Totals(1,2)", cases[3].Name)
+	assert.Equal(t, models.StatusFailed, cases[3].Status)
+}
+
+func TestParseXUnit(t *testing.T) {
+	report := `<assemblies>
+  <assembly name="/build/Shop.Tests.dll" run-date="2024-05-01" run-time="10:00:00">
+    <collection name="Test collection for Shop.CartTests" time="0.3">
+      <test name="Shop.CartTests.AddsItem" type="Shop.CartTests" method="AddsItem" time="0.1" result="Pass">
+        <traits><trait name="Category" value="unit"/></traits>
+      </test>
+      <test name="Shop.CartTests.RemovesItem" type="Shop.CartTests" method="RemovesItem" time="0.2" result="Fail">
+        <output>removing</output>
+        <failure exception-type="Xunit.Sdk.EqualException"><message>Assert.Equal() Failure</message><stack-trace>at CartTests.RemovesItem()</stack-trace></failure>
+      </test>
+      <test name="Shop.CartTests.Discount" type="Shop.CartTests" method="Discount" time="0" result="Skip">
+        <reason><![CDATA[pricing service not ready]]></reason>
+      </test>
+    </collection>
+  </assembly>
+</assemblies>`
+
+	suites, err := parser.ParseXUnit(strings.NewReader(report))
+
+	assert.NoError(t, err)
+	assert.Len(t, suites, 1)
+	assert.Equal(t, "Test collection for Shop.CartTests", suites[0].Name)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), *suites[0].Timestamp)
+
+	cases := suites[0].TestCases
+	assert.Equal(t, "Shop.CartTests", cases[0].Classname)
+	assert.Equal(t, []models.Property{{Name: "Category", Value: "unit"}}, cases[0].Properties)
+	assert.Equal(t, models.StatusFailed, cases[1].Status)
+	assert.Equal(t, "removing", cases[1].SystemOut)
+	assert.Equal(t, &models.FailureDetail{Message: "Assert.Equal() Failure", Type: "Xunit.Sdk.EqualException", Details: "at CartTests.RemovesItem()"}, cases[1].Failure)
+	assert.Equal(t, "pricing service not ready", cases[2].SkipMessage)
+}
+
+func TestParseTRX(t *testing.T) {
+	report := `<?xml version="1.0" encoding="UTF-8"?>
+<TestRun id="1" name="build@agent 2024-05-01" xmlns="http://microsoft.com/schemas/VisualStudio/TeamTest/2010">
+  <Times creation="2024-05-01T10:00:00.0000000+02:00" start="2024-05-01T10:00:01.0000000+02:00"/>
+  <Results>
+    <UnitTestResult testId="a" testName="AddsItem" computerName="agent-7" duration="00:00:00.2500000" outcome="Passed">
+      <Output><StdOut>cart created</StdOut></Output>
+    </UnitTestResult>
+    <UnitTestResult testId="b" testName="RemovesItem" computerName="agent-7" duration="00:00:01.5000000" outcome="Failed">
+      <Output>
+        <StdOut>removing item</StdOut>
+        <ErrorInfo><Message>Assert.AreEqual failed</Message><StackTrace>at CartTests.RemovesItem()</StackTrace></ErrorInfo>
+      </Output>
+    </UnitTestResult>
+    <UnitTestResult testId="c" testName="Ping" computerName="agent-7" duration="00:00:00" outcome="NotExecuted">
+      <Output><ErrorInfo><Message>Test is ignored</Message></ErrorInfo></Output>
+    </UnitTestResult>
+  </Results>
+  <TestDefinitions>
+    <UnitTest id="a"><TestMethod className="Shop.CartTests, Shop.Tests, Version=1.0.0.0" name="AddsItem"/></UnitTest>
+    <UnitTest id="b"><TestMethod className="Shop.CartTests, Shop.Tests, Version=1.0.0.0" name="RemovesItem"/></UnitTest>
+    <UnitTest id="c"><TestMethod className="Shop.HealthTests" name="Ping"/></UnitTest>
+  </TestDefinitions>
+</TestRun>`
+
+	suites, err := parser.ParseTRX(strings.NewReader(report))
+
+	assert.NoError(t, err)
+	assert.Len(t, suites, 2)
+	cart := suites[0]
+	assert.Equal(t, "Shop.CartTests", cart.Name)
+	assert.Equal(t, "agent-7", cart.Hostname)
+	assert.Equal(t, time.Date(2024, 5, 1, 8, 0, 1, 0, time.UTC), *cart.Timestamp)
+	assert.Equal(t, 1.75, cart.Time)
+	assert.Equal(t, 2, cart.Tests)
+	assert.Equal(t, 1, cart.Failures)
+
+	assert.Equal(t, "cart created", cart.TestCases[0].SystemOut)
+	assert.Equal(t, "removing item", cart.TestCases[1].SystemOut)
+	assert.Equal(t, "Assert.AreEqual failed", cart.TestCases[1].Failure.Message)
+	assert.Equal(t, "at CartTests.RemovesItem()", cart.TestCases[1].Failure.Details)
+
+	assert.Equal(t, "Shop.HealthTests", suites[1].Name)
+	assert.Equal(t, models.StatusSkipped, suites[1].TestCases[0].Status)
+	assert.Equal(t, "Test is ignored", suites[1].TestCases[0].SkipMessage)
+
+	t.Run("unexpected root", func(t *testing.T) {
+		_, err := parser.ParseTRX(strings.NewReader(`<testsuites/>`))
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+}
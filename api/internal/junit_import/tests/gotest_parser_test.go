@@ -0,0 +1,80 @@
+package application
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	importErrors "github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGoTest(t *testing.T) {
+	t.Run("event stream with subtests", func(t *testing.T) {
+		stream := `{"Time":"2024-05-01T10:00:00Z","Action":"start","Package":"example.com/calc"}
+{"Time":"2024-05-01T10:00:00.1Z","Action":"run","Package":"example.com/calc","Test":"TestAdd"}
+{"Time":"2024-05-01T10:00:00.1Z","Action":"output","Package":"example.com/calc","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Time":"2024-05-01T10:00:00.2Z","Action":"output","Package":"example.com/calc","Test":"TestAdd","Output":"--- PASS: TestAdd (0.10s)\n"}
+{"Time":"2024-05-01T10:00:00.2Z","Action":"pass","Package":"example.com/calc","Test":"TestAdd","Elapsed":0.1}
+{"Time":"2024-05-01T10:00:00.2Z","Action":"run","Package":"example.com/calc","Test":"TestDiv"}
+{"Time":"2024-05-01T10:00:00.2Z","Action":"run","Package":"example.com/calc","Test":"TestDiv/by_zero"}
+{"Time":"2024-05-01T10:00:00.3Z","Action":"output","Package":"example.com/calc","Test":"TestDiv/by_zero","Output":"    calc_test.go:21: expected error, got 0\n"}
+{"Time":"2024-05-01T10:00:00.3Z","Action":"output","Package":"example.com/calc","Test":"TestDiv/by_zero","Output":"    --- FAIL: TestDiv/by_zero (0.00s)\n"}
+{"Time":"2024-05-01T10:00:00.3Z","Action":"fail","Package":"example.com/calc","Test":"TestDiv/by_zero","Elapsed":0}
+{"Time":"2024-05-01T10:00:00.3Z","Action":"run","Package":"example.com/calc","Test":"TestDiv/negative"}
+{"Time":"2024-05-01T10:00:00.3Z","Action":"output","Package":"example.com/calc","Test":"TestDiv/negative","Output":"    calc_test.go:30: not implemented\n"}
+{"Time":"2024-05-01T10:00:00.3Z","Action":"skip","Package":"example.com/calc","Test":"TestDiv/negative","Elapsed":0}
+{"Time":"2024-05-01T10:00:00.4Z","Action":"fail","Package":"example.com/calc","Test":"TestDiv","Elapsed":0.2}
+{"Time":"2024-05-01T10:00:00.5Z","Action":"output","Package":"example.com/calc","Output":"FAIL\n"}
+{"Time":"2024-05-01T10:00:00.5Z","Action":"fail","Package":"example.com/calc","Elapsed":0.5}
+`
+
+		suites, err := parser.ParseGoTest(strings.NewReader(stream))
+
+		assert.NoError(t, err)
+		assert.Len(t, suites, 1)
+		suite := suites[0]
+		assert.Equal(t, "example.com/calc", suite.Name)
+		assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), *suite.Timestamp)
+		assert.Equal(t, 0.5, suite.Time)
+		assert.Equal(t, "FAIL", suite.SystemOut)
+		assert.Equal(t, 4, suite.Tests)
+		assert.Equal(t, 2, suite.Failures)
+		assert.Equal(t, 1, suite.Skipped)
+
+		assert.Len(t, suite.TestCases, 2)
+		add := suite.TestCases[0]
+		assert.Equal(t, models.StatusPassed, add.Status)
+		assert.Equal(t, "example.com/calc", add.Classname)
+		assert.Equal(t, "--- PASS: TestAdd (0.10s)", add.SystemOut)
+
+		div := suite.TestCases[1]
+		assert.Equal(t, models.StatusFailed, div.Status)
+		assert.Len(t, div.Subtests, 2)
+		byZero := div.Subtests[0]
+		assert.Equal(t, "TestDiv/by_zero", byZero.Name)
+		assert.Equal(t, "calc_test.go:21: expected error, got 0", byZero.Failure.Message)
+		assert.Equal(t, "    calc_test.go:21: expected error, got 0\n    --- FAIL: TestDiv/by_zero (0.00s)", byZero.Failure.Details)
+		assert.Equal(t, models.StatusSkipped, div.Subtests[1].Status)
+		assert.Equal(t, "calc_test.go:30: not implemented", div.Subtests[1].SkipMessage)
+	})
+
+	t.Run("non-event lines are ignored", func(t *testing.T) {
+		stream := "# example.com/calc\nwarning: something\n" +
+			`{"Action":"pass","Package":"example.com/calc","Test":"TestAdd","Elapsed":0.01}`
+
+		suites, err := parser.ParseGoTest(strings.NewReader(stream))
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusPassed, suites[0].TestCases[0].Status)
+	})
+
+	t.Run("no events", func(t *testing.T) {
+		_, err := parser.ParseGoTest(strings.NewReader("<testsuites/>"))
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+}
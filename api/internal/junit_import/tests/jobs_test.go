@@ -0,0 +1,461 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BennyEisner/test-results/internal/junit_import/application"
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	importErrors "github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockImportJobRepository is a mock implementation of ImportJobRepository
+type MockImportJobRepository struct {
+	mock.Mock
+}
+
+func (m *MockImportJobRepository) CreateJob(ctx context.Context, job *models.ImportJob) (*models.ImportJob, error) {
+	args := m.Called(ctx, job)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ImportJob), args.Error(1)
+}
+
+func (m *MockImportJobRepository) GetJob(ctx context.Context, id int64) (*models.ImportJob, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ImportJob), args.Error(1)
+}
+
+func (m *MockImportJobRepository) FindJob(ctx context.Context, suiteID int64, shard *models.Shard, idempotencyKey, contentHash string) (*models.ImportJob, error) {
+	args := m.Called(ctx, suiteID, shard, idempotencyKey, contentHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ImportJob), args.Error(1)
+}
+
+func (m *MockImportJobRepository) UpdateJobProgress(ctx context.Context, id int64, progress models.ImportProgress) error {
+	args := m.Called(ctx, id, progress)
+	return args.Error(0)
+}
+
+func (m *MockImportJobRepository) FinishJob(ctx context.Context, job *models.ImportJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockImportJobRepository) FailUnfinishedJobs(ctx context.Context, message string) (int64, error) {
+	args := m.Called(ctx, message)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func queuedJob(id int64) *models.ImportJob {
+	return &models.ImportJob{ID: id, ProjectID: 1, SuiteID: 2, Format: "junit", State: models.JobQueued}
+}
+
+func TestImportJobService_Submit(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("runs the task and records its outcome", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+
+		mockRepo.On("CreateJob", ctx, mock.MatchedBy(func(job *models.ImportJob) bool {
+			return job.State == models.JobQueued && job.SuiteID == 2
+		})).Return(queuedJob(5), nil).Once()
+		mockRepo.On("UpdateJobProgress", mock.Anything, int64(5), models.ImportProgress{State: models.JobParsing}).Return(nil).Once()
+		mockRepo.On("UpdateJobProgress", mock.Anything, int64(5), models.ImportProgress{State: models.JobWriting, Total: 3, Passed: 3}).Return(nil).Once()
+		mockRepo.On("FinishJob", mock.Anything, mock.MatchedBy(func(job *models.ImportJob) bool {
+			return job.ID == 5 && job.State == models.JobDone && *job.BuildID == 10 && job.BuildNumber == "42" &&
+				job.Total == 3 && job.Passed == 3 && len(job.Warnings) == 1 && job.Error == ""
+		})).Return(nil).Once()
+
+		task := func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+			progress(models.ImportProgress{State: models.JobWriting, Total: 3, Passed: 3})
+			return &models.ImportResult{BuildID: 10, BuildNumber: "42", Total: 3, Passed: 3, Warnings: []string{"odd"}}, nil
+		}
+		job, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2, Format: "junit"}, task)
+		service.Close()
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), job.ID)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("failed task", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+
+		mockRepo.On("CreateJob", ctx, mock.Anything).Return(queuedJob(6), nil).Once()
+		mockRepo.On("UpdateJobProgress", mock.Anything, int64(6), mock.Anything).Return(nil)
+		mockRepo.On("FinishJob", mock.Anything, mock.MatchedBy(func(job *models.ImportJob) bool {
+			return job.State == models.JobFailed && job.BuildID == nil && job.Error == "invalid test report: bad XML"
+		})).Return(nil).Once()
+
+		task := func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+			return nil, errors.New("invalid test report: bad XML")
+		}
+		_, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2}, task)
+		service.Close()
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("panicking task fails the job", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+
+		mockRepo.On("CreateJob", ctx, mock.Anything).Return(queuedJob(7), nil).Once()
+		mockRepo.On("UpdateJobProgress", mock.Anything, int64(7), mock.Anything).Return(nil)
+		mockRepo.On("FinishJob", mock.Anything, mock.MatchedBy(func(job *models.ImportJob) bool {
+			return job.State == models.JobFailed && job.Error == "import panicked: boom"
+		})).Return(nil).Once()
+
+		task := func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+			panic("boom")
+		}
+		_, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2}, task)
+		service.Close()
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("timed out task fails the job", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 10*time.Millisecond)
+
+		mockRepo.On("CreateJob", ctx, mock.Anything).Return(queuedJob(7), nil).Once()
+		mockRepo.On("UpdateJobProgress", mock.Anything, int64(7), mock.Anything).Return(nil)
+		mockRepo.On("FinishJob", mock.Anything, mock.MatchedBy(func(job *models.ImportJob) bool {
+			return job.State == models.JobFailed && job.Error == "import timed out after 10ms"
+		})).Return(nil).Once()
+
+		task := func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		_, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2}, task)
+		service.Close()
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("suite not in project", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+		defer service.Close()
+
+		mockRepo.On("CreateJob", ctx, mock.Anything).Return(nil, nil).Once()
+
+		job, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2}, func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+			return nil, nil
+		})
+
+		assert.Equal(t, importErrors.ErrSuiteNotFound, err)
+		assert.Nil(t, job)
+	})
+
+	t.Run("full queue", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		blocking := func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+			close(started)
+			<-release
+			return &models.ImportResult{BuildID: 1}, nil
+		}
+		waiting := func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+			return &models.ImportResult{BuildID: 2}, nil
+		}
+
+		mockRepo.On("CreateJob", ctx, mock.Anything).Return(queuedJob(1), nil).Once()
+		mockRepo.On("CreateJob", ctx, mock.Anything).Return(queuedJob(2), nil).Once()
+		mockRepo.On("CreateJob", ctx, mock.Anything).Return(queuedJob(3), nil).Once()
+		mockRepo.On("UpdateJobProgress", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		mockRepo.On("FinishJob", mock.Anything, mock.MatchedBy(func(job *models.ImportJob) bool {
+			return job.ID == 3 && job.State == models.JobFailed && job.Error == importErrors.ErrQueueFull.Error()
+		})).Return(nil).Once()
+		mockRepo.On("FinishJob", mock.Anything, mock.MatchedBy(func(job *models.ImportJob) bool {
+			return job.ID != 3 && job.State == models.JobDone
+		})).Return(nil).Twice()
+
+		_, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2}, blocking)
+		assert.NoError(t, err)
+		<-started
+		_, err = service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2}, waiting)
+		assert.NoError(t, err)
+
+		job, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2}, waiting)
+
+		assert.Equal(t, importErrors.ErrQueueFull, err)
+		assert.Nil(t, job)
+		close(release)
+		service.Close()
+		mockRepo.AssertExpectations(t)
+	})
+
+	noTask := func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+		t.Error("repeated upload was imported again")
+		return nil, nil
+	}
+	var noShard *models.Shard
+	doneJob := func(key, hash string) *models.ImportJob {
+		buildID := int64(10)
+		return &models.ImportJob{ID: 4, ProjectID: 1, SuiteID: 2, IdempotencyKey: key, ContentHash: hash,
+			State: models.JobDone, BuildID: &buildID}
+	}
+
+	t.Run("repeated upload returns the earlier job", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+
+		mockRepo.On("FindJob", ctx, int64(2), noShard, "", "abc").Return(doneJob("", "abc"), nil).Once()
+
+		job, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2, ContentHash: "abc"}, noTask)
+		service.Close()
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), job.ID)
+		assert.True(t, job.Replayed)
+		assert.Equal(t, int64(10), *job.BuildID)
+		mockRepo.AssertNotCalled(t, "CreateJob", mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("repeated idempotency key with the same report", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+
+		mockRepo.On("FindJob", ctx, int64(2), noShard, "42-abc", "abc").Return(doneJob("42-abc", "abc"), nil).Once()
+
+		job, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2, IdempotencyKey: "42-abc", ContentHash: "abc"}, noTask)
+		service.Close()
+
+		assert.NoError(t, err)
+		assert.True(t, job.Replayed)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("idempotency key reused for a different report", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+		defer service.Close()
+
+		mockRepo.On("FindJob", ctx, int64(2), noShard, "42", "def").Return(doneJob("42", "abc"), nil).Once()
+
+		job, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2, IdempotencyKey: "42", ContentHash: "def"}, noTask)
+
+		assert.Equal(t, importErrors.ErrKeyConflict, err)
+		assert.Nil(t, job)
+	})
+
+	t.Run("repeated upload stored concurrently", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+
+		mockRepo.On("FindJob", ctx, int64(2), noShard, "", "abc").Return(nil, nil).Once()
+		mockRepo.On("CreateJob", ctx, mock.Anything).Return(nil, nil).Once()
+		mockRepo.On("FindJob", ctx, int64(2), noShard, "", "abc").Return(doneJob("", "abc"), nil).Once()
+
+		job, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2, ContentHash: "abc"}, noTask)
+		service.Close()
+
+		assert.NoError(t, err)
+		assert.True(t, job.Replayed)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("earlier upload to the suite under another project", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+		defer service.Close()
+
+		mockRepo.On("FindJob", ctx, int64(2), noShard, "", "abc").Return(doneJob("", "abc"), nil).Once()
+
+		job, err := service.Submit(ctx, &models.ImportJob{ProjectID: 3, SuiteID: 2, ContentHash: "abc"}, noTask)
+
+		assert.Equal(t, importErrors.ErrSuiteNotFound, err)
+		assert.Nil(t, job)
+	})
+
+	t.Run("identical reports of different shards", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+
+		shard := &models.Shard{BuildID: 10, Index: 1}
+		mockRepo.On("FindJob", ctx, int64(2), shard, "", "abc").Return(nil, nil).Once()
+		mockRepo.On("CreateJob", ctx, mock.MatchedBy(func(job *models.ImportJob) bool {
+			return job.Shard == shard
+		})).Return(queuedJob(8), nil).Once()
+
+		job, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2, ContentHash: "abc", Shard: shard}, func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+			return &models.ImportResult{BuildID: 10}, nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(8), job.ID)
+		assert.False(t, job.Replayed)
+		mockRepo.On("UpdateJobProgress", mock.Anything, int64(8), mock.Anything).Return(nil)
+		mockRepo.On("FinishJob", mock.Anything, mock.Anything).Return(nil)
+		service.Close()
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid job", func(t *testing.T) {
+		service := application.NewImportJobService(new(MockImportJobRepository), 1, 1, 0)
+		defer service.Close()
+
+		job, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1}, nil)
+
+		assert.Equal(t, importErrors.ErrInvalidRequest, err)
+		assert.Nil(t, job)
+	})
+}
+
+func TestImportJobService_GetJob(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+		defer service.Close()
+
+		mockRepo.On("GetJob", ctx, int64(5)).Return(queuedJob(5), nil).Once()
+
+		job, err := service.GetJob(ctx, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.JobQueued, job.State)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+		defer service.Close()
+
+		mockRepo.On("GetJob", ctx, int64(9)).Return(nil, nil).Once()
+
+		job, err := service.GetJob(ctx, 9)
+
+		assert.Equal(t, importErrors.ErrJobNotFound, err)
+		assert.Nil(t, job)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+		defer service.Close()
+
+		mockRepo.On("GetJob", ctx, int64(9)).Return(nil, errors.New("db down")).Once()
+
+		job, err := service.GetJob(ctx, 9)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "db down")
+		assert.Nil(t, job)
+	})
+}
+
+func TestImportJobService_Shutdown(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stops the running and queued jobs when ctx is done", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+
+		mockRepo.On("CreateJob", ctx, mock.Anything).Return(queuedJob(5), nil).Once()
+		mockRepo.On("CreateJob", ctx, mock.Anything).Return(queuedJob(6), nil).Once()
+		mockRepo.On("UpdateJobProgress", mock.Anything, int64(5), mock.Anything).Return(nil)
+		mockRepo.On("FinishJob", mock.Anything, mock.MatchedBy(func(job *models.ImportJob) bool {
+			return job.State == models.JobFailed && job.Error == importErrors.ErrJobInterrupted.Error()
+		})).Return(nil).Twice()
+
+		started := make(chan struct{})
+		running := func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		queued := func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+			t.Error("the queued job must not run after the shutdown")
+			return nil, nil
+		}
+		_, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2}, running)
+		assert.NoError(t, err)
+		<-started
+		_, err = service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2}, queued)
+		assert.NoError(t, err)
+
+		shutdownCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		err = service.Shutdown(shutdownCtx)
+
+		assert.Equal(t, context.Canceled, err)
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "UpdateJobProgress", mock.Anything, int64(6), mock.Anything)
+	})
+
+	t.Run("refuses jobs once shut down", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+
+		mockRepo.On("CreateJob", ctx, mock.Anything).Return(queuedJob(5), nil).Once()
+		mockRepo.On("FinishJob", ctx, mock.MatchedBy(func(job *models.ImportJob) bool {
+			return job.State == models.JobFailed && job.Error == importErrors.ErrQueueFull.Error()
+		})).Return(nil).Once()
+
+		assert.NoError(t, service.Shutdown(ctx))
+		job, err := service.Submit(ctx, &models.ImportJob{ProjectID: 1, SuiteID: 2}, func(ctx context.Context, progress models.ProgressFunc) (*models.ImportResult, error) {
+			return nil, nil
+		})
+
+		assert.Equal(t, importErrors.ErrQueueFull, err)
+		assert.Nil(t, job)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestImportJobService_FailInterruptedJobs(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+		defer service.Close()
+
+		mockRepo.On("FailUnfinishedJobs", ctx, importErrors.ErrJobInterrupted.Error()).Return(int64(3), nil).Once()
+
+		failed, err := service.FailInterruptedJobs(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), failed)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo := new(MockImportJobRepository)
+		service := application.NewImportJobService(mockRepo, 1, 1, 0)
+		defer service.Close()
+
+		mockRepo.On("FailUnfinishedJobs", ctx, mock.Anything).Return(int64(0), errors.New("db down")).Once()
+
+		_, err := service.FailInterruptedJobs(ctx)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "db down")
+	})
+}
@@ -0,0 +1,209 @@
+package application
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	importErrors "github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJUnit(t *testing.T) {
+	t.Run("testsuites root", func(t *testing.T) {
+		report := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="all">
+  <testsuite name="math">
+    <testcase name="TestAdd" classname="math" time="0.01"/>
+    <testcase name="TestDiv" classname="math" time="0.02">
+      <failure message="division by zero" type="panic">trace</failure>
+    </testcase>
+  </testsuite>
+</testsuites>`
+
+		suites, err := parser.ParseJUnit(strings.NewReader(report))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "all", suites.Name)
+		assert.Len(t, suites.TestSuites, 1)
+		assert.Len(t, suites.TestSuites[0].TestCases, 2)
+		assert.Equal(t, "division by zero", suites.TestSuites[0].TestCases[1].Failures[0].Message)
+		assert.Equal(t, "trace", suites.TestSuites[0].TestCases[1].Failures[0].Value)
+	})
+
+	t.Run("nested suites with properties and output", func(t *testing.T) {
+		report := `<testsuites>
+  <testsuite name="root" hostname="ci-host" timestamp="2024-05-01T10:00:00" tests="2" failures="1">
+    <properties>
+      <property name="java.version" value="21"/>
+      <property name="notes">multi
+line</property>
+    </properties>
+    <testsuite name="child">
+      <testcase name="TestA">
+        <failure message="one"/>
+        <failure message="two"/>
+        <system-out>hello</system-out>
+      </testcase>
+    </testsuite>
+    <system-err>warning</system-err>
+  </testsuite>
+</testsuites>`
+
+		suites, err := parser.ParseJUnit(strings.NewReader(report))
+
+		assert.NoError(t, err)
+		root := suites.TestSuites[0]
+		assert.Equal(t, "ci-host", root.Hostname)
+		assert.Equal(t, "2024-05-01T10:00:00", root.Timestamp)
+		assert.Equal(t, 1, root.Failures)
+		assert.Len(t, root.Properties, 2)
+		assert.Equal(t, "21", root.Properties[0].Value)
+		assert.Equal(t, "multi\nline", root.Properties[1].Text)
+		assert.Equal(t, "warning", root.SystemErr)
+		assert.Len(t, root.TestSuites, 1)
+		assert.Len(t, root.TestSuites[0].TestCases[0].Failures, 2)
+		assert.Equal(t, "hello", root.TestSuites[0].TestCases[0].SystemOut)
+	})
+
+	t.Run("surefire reruns", func(t *testing.T) {
+		report := `<testsuite name="com.example.CartTest">
+  <testcase name="testCheckout" classname="com.example.CartTest" time="1.2">
+    <flakyFailure message="expected 3" type="java.lang.AssertionError">
+      <stackTrace>at CartTest.testCheckout</stackTrace>
+    </flakyFailure>
+  </testcase>
+  <testcase name="testRefund" classname="com.example.CartTest" time="0.4">
+    <failure message="refused" type="java.lang.AssertionError">first</failure>
+    <rerunFailure message="refused again" type="java.lang.AssertionError" time="0.3">
+      <stackTrace>second</stackTrace>
+    </rerunFailure>
+  </testcase>
+</testsuite>`
+
+		suites, err := parser.ParseJUnit(strings.NewReader(report))
+
+		assert.NoError(t, err)
+		cases := suites.TestSuites[0].TestCases
+		if assert.Len(t, cases[0].FlakyFailures, 1) {
+			assert.Equal(t, "expected 3", cases[0].FlakyFailures[0].Message)
+			assert.Equal(t, "at CartTest.testCheckout", cases[0].FlakyFailures[0].StackTrace)
+		}
+		if assert.Len(t, cases[1].RerunFailures, 1) {
+			assert.Equal(t, 0.3, cases[1].RerunFailures[0].Time)
+			assert.Equal(t, "second", cases[1].RerunFailures[0].StackTrace)
+		}
+	})
+
+	t.Run("pytest reruns", func(t *testing.T) {
+		report := `<testsuite name="pytest">
+  <testcase name="test_pay" classname="tests.test_checkout" time="0.5">
+    <rerun message="AssertionError: declined">trace</rerun>
+  </testcase>
+</testsuite>`
+
+		suites, err := parser.ParseJUnit(strings.NewReader(report))
+
+		assert.NoError(t, err)
+		if assert.Len(t, suites.TestSuites[0].TestCases[0].Reruns, 1) {
+			assert.Equal(t, "AssertionError: declined", suites.TestSuites[0].TestCases[0].Reruns[0].Message)
+			assert.Equal(t, "trace", suites.TestSuites[0].TestCases[0].Reruns[0].Value)
+		}
+	})
+
+	t.Run("bare testsuite root", func(t *testing.T) {
+		report := `<testsuite name="pytest"><testcase name="test_ok" classname="tests.test_app"/></testsuite>`
+
+		suites, err := parser.ParseJUnit(strings.NewReader(report))
+
+		assert.NoError(t, err)
+		assert.Len(t, suites.TestSuites, 1)
+		assert.Equal(t, "pytest", suites.TestSuites[0].Name)
+		assert.Equal(t, "test_ok", suites.TestSuites[0].TestCases[0].Name)
+	})
+
+	t.Run("unexpected root", func(t *testing.T) {
+		_, err := parser.ParseJUnit(strings.NewReader(`<html></html>`))
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+
+	t.Run("empty document", func(t *testing.T) {
+		_, err := parser.ParseJUnit(strings.NewReader(""))
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+}
+
+func TestParseReadyAPI(t *testing.T) {
+	t.Run("project with failed assertions", func(t *testing.T) {
+		report := `<?xml version="1.0" encoding="UTF-8"?>
+<project name="Petstore">
+  <testSuite name="Pets" timeTaken="1500">
+    <testCase name="Get pet" status="FINISHED" timeTaken="500">
+      <testStep name="GET /pet/1" type="REST Request" status="OK"/>
+    </testCase>
+    <testCase name="Create pet" status="FAILED" timeTaken="1000">
+      <testStep name="POST /pet" type="REST Request" status="FAILED">
+        <assertion name="Valid HTTP Status Codes" status="FAILED">
+          <message>Response status code: 500 is not in acceptable list</message>
+        </assertion>
+        <assertion name="Contains" status="VALID"/>
+        <assertion name="JsonPath Match" status="FAILED">
+          <message>id expected 1 but was null</message>
+        </assertion>
+      </testStep>
+      <testStep name="Cleanup" type="Groovy Script" status="FAILED">
+        <message>NullPointerException</message>
+      </testStep>
+    </testCase>
+    <testCase name="Delete pet" status="CANCELED"/>
+  </testSuite>
+</project>`
+
+		suites, err := parser.ParseReadyAPI(strings.NewReader(report))
+
+		assert.NoError(t, err)
+		assert.Len(t, suites, 1)
+		assert.Equal(t, "Pets", suites[0].Name)
+		assert.Equal(t, 1.5, suites[0].Time)
+		assert.Equal(t, 3, suites[0].Tests)
+		assert.Equal(t, 1, suites[0].Failures)
+		assert.Equal(t, 1, suites[0].Errors)
+
+		cases := suites[0].TestCases
+		assert.Equal(t, models.StatusPassed, cases[0].Status)
+		assert.Equal(t, "Pets", cases[0].Classname)
+		assert.Equal(t, 0.5, cases[0].Time)
+		assert.Nil(t, cases[0].Failure)
+
+		assert.Equal(t, models.StatusFailed, cases[1].Status)
+		assert.Equal(t, "Valid HTTP Status Codes", cases[1].Failure.Type)
+		assert.Equal(t, "Response status code: 500 is not in acceptable list", cases[1].Failure.Message)
+		assert.Equal(t, "Step: POST /pet [REST Request]\n"+
+			"- Assertion Valid HTTP Status Codes failed: Response status code: 500 is not in acceptable list\n"+
+			"- Assertion JsonPath Match failed: id expected 1 but was null\n\n"+
+			"Step: Cleanup [Groovy Script]\nNullPointerException", cases[1].Failure.Details)
+
+		assert.Equal(t, models.StatusError, cases[2].Status)
+		assert.Equal(t, "test case canceled", cases[2].Failure.Message)
+	})
+
+	t.Run("bare test suite derives status from steps", func(t *testing.T) {
+		report := `<testSuite name="Orders"><testCase name="List"><testStep name="GET /orders" status="FAILED"/></testCase></testSuite>`
+
+		suites, err := parser.ParseReadyAPI(strings.NewReader(report))
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusFailed, suites[0].TestCases[0].Status)
+		assert.Equal(t, "Step: GET /orders", suites[0].TestCases[0].Failure.Details)
+	})
+
+	t.Run("unexpected root", func(t *testing.T) {
+		_, err := parser.ParseReadyAPI(strings.NewReader(`<testsuites/>`))
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+}
@@ -0,0 +1,408 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/ports"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/database"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// schemaFile is the schema the database tests create their tables from
+const schemaFile = "../../../../db/schema.sql"
+
+// openTestDB connects to the PostgreSQL database of TEST_DATABASE_URL and creates the
+// tables of schemaFile in a schema of their own, dropped when the test ends. Tests of
+// the SQL repository are skipped when the variable is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	schema, err := os.ReadFile(schemaFile)
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+
+	admin, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	name := fmt.Sprintf("junit_import_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + name); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec("DROP SCHEMA " + name + " CASCADE")
+		_ = admin.Close()
+	})
+
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+	db, err := sql.Open("postgres", url+separator+"search_path="+name)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+	return db
+}
+
+// createSuite creates a project with one test suite and returns their IDs
+func createSuite(t *testing.T, db *sql.DB) (int64, int64) {
+	var projectID, suiteID int64
+	if err := db.QueryRow(`INSERT INTO projects (name) VALUES ('checkout') RETURNING id`).Scan(&projectID); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	query := `INSERT INTO test_suites (project_id, name, time) VALUES ($1, 'checkout', 0) RETURNING id`
+	if err := db.QueryRow(query, projectID).Scan(&suiteID); err != nil {
+		t.Fatalf("failed to create test suite: %v", err)
+	}
+	return projectID, suiteID
+}
+
+// executionRow is an attempt of a test case in a build as stored
+type executionRow struct {
+	Attempt int
+	Status  string
+	Retried bool
+	Failure string
+}
+
+// executionRows returns the attempts of a test case in a build, oldest first
+func executionRows(t *testing.T, db *sql.DB, buildID int64, name string) []executionRow {
+	query := `SELECT e.attempt, e.status, e.retried, COALESCE(f.message, '')
+			  FROM build_test_case_executions e
+			  JOIN test_cases tc ON tc.id = e.test_case_id
+			  LEFT JOIN failures f ON f.build_test_case_execution_id = e.id
+			  WHERE e.build_id = $1 AND tc.name = $2
+			  ORDER BY e.attempt`
+
+	rows, err := db.Query(query, buildID, name)
+	if err != nil {
+		t.Fatalf("failed to query executions: %v", err)
+	}
+	defer rows.Close()
+
+	var executions []executionRow
+	for rows.Next() {
+		var row executionRow
+		if err := rows.Scan(&row.Attempt, &row.Status, &row.Retried, &row.Failure); err != nil {
+			t.Fatalf("failed to scan execution: %v", err)
+		}
+		executions = append(executions, row)
+	}
+	return executions
+}
+
+func buildStatus(t *testing.T, db *sql.DB, buildID int64) string {
+	var status string
+	if err := db.QueryRow(`SELECT status FROM builds WHERE id = $1`, buildID).Scan(&status); err != nil {
+		t.Fatalf("failed to query build: %v", err)
+	}
+	return status
+}
+
+// openShardedBuild creates a pending build of the suite expecting shards and returns its ID
+func openShardedBuild(t *testing.T, db *sql.DB, suiteID int64, shards int) int64 {
+	var buildID int64
+	open := `INSERT INTO builds (test_suite_id, build_number, ci_provider, status, expected_shards)
+			 VALUES ($1, '7', 'unknown', 'pending', $2) RETURNING id`
+	if err := db.QueryRow(open, suiteID, shards).Scan(&buildID); err != nil {
+		t.Fatalf("failed to open sharded build: %v", err)
+	}
+	return buildID
+}
+
+func TestSQLJUnitImportRepository_Reruns(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	projectID, suiteID := createSuite(t, db)
+
+	failed := func(name, message string) *models.TestCaseResult {
+		return &models.TestCaseResult{Name: name, Classname: "Checkout", Status: models.StatusFailed, Time: 1,
+			Failure: &models.FailureDetail{Message: message}}
+	}
+	passed := func(name string) *models.TestCaseResult {
+		return &models.TestCaseResult{Name: name, Classname: "Checkout", Status: models.StatusPassed, Time: 1}
+	}
+
+	t.Run("rerun in a later batch", func(t *testing.T) {
+		// Every result is written out on its own, so that each rerun lands in a later batch
+		repo := database.NewSQLJUnitImportRepository(db, models.ImportLimits{BatchBytes: 1})
+		build := &models.ImportBuild{ProjectID: projectID, SuiteID: suiteID, BuildNumber: "1", CIProvider: "unknown", CreatedAt: time.Now()}
+		suite := &models.SuiteResult{Name: "checkout", TestCases: []*models.TestCaseResult{
+			failed("testPay", "timeout"),
+			passed("testRefund"),
+			failed("testPay", "declined"),
+			passed("testPay"),
+			passed("testRefund"),
+		}}
+
+		buildID, err := repo.SaveImport(ctx, build, []*models.SuiteResult{suite})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []executionRow{
+			{Attempt: 1, Status: models.StatusFailed, Retried: true, Failure: "timeout"},
+			{Attempt: 2, Status: models.StatusFailed, Retried: true, Failure: "declined"},
+			{Attempt: 3, Status: models.StatusPassed},
+		}, executionRows(t, db, buildID, "testPay"))
+		assert.Equal(t, []executionRow{{Attempt: 1, Status: models.StatusPassed}}, executionRows(t, db, buildID, "testRefund"))
+		assert.Equal(t, "passed", buildStatus(t, db, buildID))
+	})
+
+	t.Run("rerun in the same batch", func(t *testing.T) {
+		repo := database.NewSQLJUnitImportRepository(db, models.ImportLimits{})
+		build := &models.ImportBuild{ProjectID: projectID, SuiteID: suiteID, BuildNumber: "2", CIProvider: "unknown", CreatedAt: time.Now()}
+		suite := &models.SuiteResult{Name: "checkout", TestCases: []*models.TestCaseResult{
+			failed("testPay", "timeout"),
+			passed("testPay"),
+		}}
+
+		buildID, err := repo.SaveImport(ctx, build, []*models.SuiteResult{suite})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []executionRow{
+			{Attempt: 1, Status: models.StatusFailed, Retried: true, Failure: "timeout"},
+			{Attempt: 2, Status: models.StatusPassed},
+		}, executionRows(t, db, buildID, "testPay"))
+	})
+}
+
+func TestSQLJUnitImportRepository_RepeatedResults(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	projectID, suiteID := createSuite(t, db)
+
+	result := func(name, status string, report int) *models.TestCaseResult {
+		result := &models.TestCaseResult{Name: name, Classname: "Checkout", Status: status, Time: 1, Report: report}
+		if status == models.StatusFailed {
+			result.Failure = &models.FailureDetail{Message: "declined"}
+		}
+		return result
+	}
+	save := func(repo ports.JUnitImportRepository, number string, suites ...*models.SuiteResult) (*models.ImportBuild, int64) {
+		build := &models.ImportBuild{ProjectID: projectID, SuiteID: suiteID, BuildNumber: number, CIProvider: "unknown", CreatedAt: time.Now()}
+		buildID, err := repo.SaveImport(ctx, build, suites)
+		assert.NoError(t, err)
+		return build, buildID
+	}
+	repo := database.NewSQLJUnitImportRepository(db, models.ImportLimits{})
+
+	t.Run("reported again by another report", func(t *testing.T) {
+		build, buildID := save(repo, "1",
+			&models.SuiteResult{Name: "unit", TestCases: []*models.TestCaseResult{
+				result("testPay", models.StatusPassed, 0),
+				result("testRefund", models.StatusFailed, 0),
+			}},
+			&models.SuiteResult{Name: "integration", TestCases: []*models.TestCaseResult{
+				result("testPay", models.StatusFailed, 1),
+				result("testRefund", models.StatusPassed, 1),
+			}},
+		)
+
+		assert.Equal(t, []executionRow{{Attempt: 1, Status: models.StatusFailed, Failure: "declined"}}, executionRows(t, db, buildID, "testPay"))
+		assert.Equal(t, []executionRow{{Attempt: 1, Status: models.StatusPassed}}, executionRows(t, db, buildID, "testRefund"))
+		if assert.Len(t, build.Warnings, 2) {
+			assert.Contains(t, build.Warnings[0], `"testPay"`)
+			assert.Contains(t, build.Warnings[0], "earlier passed result was replaced")
+			assert.Contains(t, build.Warnings[1], `"testRefund"`)
+			assert.Contains(t, build.Warnings[1], "earlier failed result was replaced")
+		}
+	})
+
+	t.Run("rerun reported by another report", func(t *testing.T) {
+		rerun := result("testPay", models.StatusPassed, 1)
+		rerun.Rerun = true
+		build, buildID := save(repo, "2",
+			&models.SuiteResult{Name: "first run", TestCases: []*models.TestCaseResult{result("testPay", models.StatusFailed, 0)}},
+			&models.SuiteResult{Name: "rerun", TestCases: []*models.TestCaseResult{rerun}},
+		)
+
+		assert.Equal(t, []executionRow{
+			{Attempt: 1, Status: models.StatusFailed, Retried: true, Failure: "declined"},
+			{Attempt: 2, Status: models.StatusPassed},
+		}, executionRows(t, db, buildID, "testPay"))
+		assert.Empty(t, build.Warnings)
+	})
+
+	for i, limits := range []models.ImportLimits{{}, {BatchBytes: 1}} {
+		t.Run(fmt.Sprintf("passed result reported again by the same report in batches of %d bytes", limits.BatchBytes), func(t *testing.T) {
+			build, buildID := save(database.NewSQLJUnitImportRepository(db, limits), fmt.Sprintf("%d", 3+i),
+				&models.SuiteResult{Name: "checkout", TestCases: []*models.TestCaseResult{
+					result("testPay", models.StatusPassed, 0),
+					result("testPay", models.StatusFailed, 0),
+				}},
+			)
+
+			assert.Equal(t, []executionRow{{Attempt: 1, Status: models.StatusFailed, Failure: "declined"}}, executionRows(t, db, buildID, "testPay"))
+			assert.Len(t, build.Warnings, 1)
+		})
+	}
+}
+
+func TestSQLJUnitImportRepository_ShardUploadedAgain(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	projectID, suiteID := createSuite(t, db)
+	repo := database.NewSQLJUnitImportRepository(db, models.ImportLimits{})
+
+	buildID := openShardedBuild(t, db, suiteID, 2)
+
+	upload := func(index int, results ...*models.TestCaseResult) {
+		build := &models.ImportBuild{ProjectID: projectID, SuiteID: suiteID, CIProvider: "unknown", CreatedAt: time.Now(),
+			Shard: &models.Shard{BuildID: buildID, Index: index}, TestCaseCount: len(results)}
+		suite := &models.SuiteResult{Name: fmt.Sprintf("shard %d", index), TestCases: results}
+		_, err := repo.SaveImport(ctx, build, []*models.SuiteResult{suite})
+		assert.NoError(t, err)
+	}
+	result := func(name, status string) *models.TestCaseResult {
+		result := &models.TestCaseResult{Name: name, Classname: "Checkout", Status: status, Time: 1,
+			Properties: []models.Property{{Name: "browser", Value: "firefox"}}}
+		if status == models.StatusFailed {
+			result.Failure = &models.FailureDetail{Message: "declined"}
+		}
+		return result
+	}
+
+	upload(1, result("testPay", models.StatusFailed), result("testRefund", models.StatusFailed))
+	upload(1, result("testPay", models.StatusPassed), result("testCancel", models.StatusPassed))
+
+	assert.Equal(t, []executionRow{{Attempt: 1, Status: models.StatusPassed}}, executionRows(t, db, buildID, "testPay"))
+	assert.Empty(t, executionRows(t, db, buildID, "testRefund"))
+	assert.Equal(t, []executionRow{{Attempt: 1, Status: models.StatusPassed}}, executionRows(t, db, buildID, "testCancel"))
+
+	var testCaseCount, suiteRuns, properties int
+	counts := `SELECT b.test_case_count,
+			       (SELECT COUNT(*) FROM build_suite_runs r WHERE r.build_id = b.id),
+			       (SELECT COUNT(*) FROM execution_properties p
+			        JOIN build_test_case_executions e ON e.id = p.build_test_case_execution_id WHERE e.build_id = b.id)
+			   FROM builds b WHERE b.id = $1`
+	if err := db.QueryRow(counts, buildID).Scan(&testCaseCount, &suiteRuns, &properties); err != nil {
+		t.Fatalf("failed to count build results: %v", err)
+	}
+	assert.Equal(t, 2, testCaseCount)
+	assert.Equal(t, 1, suiteRuns)
+	assert.Equal(t, 2, properties)
+	assert.Equal(t, "running", buildStatus(t, db, buildID))
+
+	upload(0, result("testSearch", models.StatusPassed))
+
+	assert.Equal(t, "passed", buildStatus(t, db, buildID))
+}
+
+func TestSQLJUnitImportRepository_ShardsReportingTheSameTest(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	projectID, suiteID := createSuite(t, db)
+	repo := database.NewSQLJUnitImportRepository(db, models.ImportLimits{})
+	buildID := openShardedBuild(t, db, suiteID, 2)
+
+	var warnings []string
+	for index, status := range []string{models.StatusPassed, models.StatusSkipped} {
+		build := &models.ImportBuild{ProjectID: projectID, SuiteID: suiteID, CIProvider: "unknown", CreatedAt: time.Now(),
+			Shard: &models.Shard{BuildID: buildID, Index: index}, TestCaseCount: 1}
+		suite := &models.SuiteResult{Name: fmt.Sprintf("shard %d", index), TestCases: []*models.TestCaseResult{
+			{Name: "testPay", Classname: "Checkout", Status: status, Time: 1},
+		}}
+		_, err := repo.SaveImport(ctx, build, []*models.SuiteResult{suite})
+		assert.NoError(t, err)
+		warnings = append(warnings, build.Warnings...)
+	}
+
+	assert.Equal(t, []executionRow{{Attempt: 1, Status: models.StatusSkipped}}, executionRows(t, db, buildID, "testPay"))
+	assert.Equal(t, []string{`test case "testPay" of class "Checkout" was reported more than once in the build without being rerun; its earlier passed result was replaced`}, warnings)
+}
+
+func TestSQLJUnitImportRepository_ShardDuration(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	projectID, suiteID := createSuite(t, db)
+	repo := database.NewSQLJUnitImportRepository(db, models.ImportLimits{})
+	buildID := openShardedBuild(t, db, suiteID, 2)
+
+	for index, duration := range []float64{30, 50} {
+		build := &models.ImportBuild{ProjectID: projectID, SuiteID: suiteID, CIProvider: "unknown", CreatedAt: time.Now(),
+			Shard: &models.Shard{BuildID: buildID, Index: index}, TestCaseCount: 1, Duration: duration}
+		suite := &models.SuiteResult{Name: fmt.Sprintf("shard %d", index), TestCases: []*models.TestCaseResult{
+			{Name: fmt.Sprintf("test%d", index), Classname: "Checkout", Status: models.StatusPassed, Time: duration},
+		}}
+		_, err := repo.SaveImport(ctx, build, []*models.SuiteResult{suite})
+		assert.NoError(t, err)
+	}
+
+	var duration float64
+	if err := db.QueryRow(`SELECT duration FROM builds WHERE id = $1`, buildID).Scan(&duration); err != nil {
+		t.Fatalf("failed to query build: %v", err)
+	}
+	assert.Equal(t, 50.0, duration)
+}
+
+func TestSQLJUnitImportRepository_ConcurrentShards(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	projectID, suiteID := createSuite(t, db)
+	repo := database.NewSQLJUnitImportRepository(db, models.ImportLimits{})
+	buildID := openShardedBuild(t, db, suiteID, 2)
+
+	shard := func(index int) *models.ImportBuild {
+		return &models.ImportBuild{ProjectID: projectID, SuiteID: suiteID, CIProvider: "unknown", CreatedAt: time.Now(),
+			Shard: &models.Shard{BuildID: buildID, Index: index}, TestCaseCount: 1}
+	}
+	write := func(build *models.ImportBuild) (ports.ImportSession, error) {
+		session, err := repo.BeginImport(ctx, build)
+		if err != nil {
+			return nil, err
+		}
+		suite := &models.SuiteResult{Name: "checkout", TestCases: []*models.TestCaseResult{
+			{Name: "testPay", Classname: "Checkout", Status: models.StatusPassed, Time: 1},
+		}}
+		for _, step := range []func() error{
+			func() error { return session.StartSuite(ctx, suite) },
+			func() error { return session.AddTestCase(ctx, suite.TestCases[0]) },
+			func() error { return session.EndSuite(ctx, suite) },
+		} {
+			if err := step(); err != nil {
+				_ = session.Rollback()
+				return nil, err
+			}
+		}
+		return session, nil
+	}
+
+	// The first shard creates the test case without committing it, the second one adds
+	// the same test case meanwhile and waits for the first shard to commit
+	first, err := write(shard(0))
+	if err != nil {
+		t.Fatalf("failed to write the first shard: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		second, err := write(shard(1))
+		if err == nil {
+			_, err = second.Commit(ctx, shard(1))
+		}
+		done <- err
+	}()
+	_, err = first.Commit(ctx, shard(0))
+
+	assert.NoError(t, err)
+	assert.NoError(t, <-done)
+	var testCases int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM test_cases WHERE suite_id = $1 AND name = 'testPay'`, suiteID).Scan(&testCases); err != nil {
+		t.Fatalf("failed to count test cases: %v", err)
+	}
+	assert.Equal(t, 1, testCases)
+}
@@ -0,0 +1,526 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BennyEisner/test-results/internal/junit_import/application"
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	importErrors "github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/ports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockJUnitImportRepository is a mock implementation of JUnitImportRepository
+type MockJUnitImportRepository struct {
+	mock.Mock
+}
+
+func (m *MockJUnitImportRepository) GetSuiteProjectID(ctx context.Context, suiteID int64) (int64, error) {
+	args := m.Called(ctx, suiteID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockJUnitImportRepository) SaveImport(ctx context.Context, build *models.ImportBuild, suites []*models.SuiteResult) (int64, error) {
+	args := m.Called(ctx, build, suites)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockJUnitImportRepository) BeginImport(ctx context.Context, build *models.ImportBuild) (ports.ImportSession, error) {
+	args := m.Called(ctx, build)
+	session, _ := args.Get(0).(ports.ImportSession)
+	return session, args.Error(1)
+}
+
+func sampleReport() *models.JUnitTestSuites {
+	return &models.JUnitTestSuites{
+		TestSuites: []models.JUnitTestSuite{
+			{
+				Name: "com.example.LoginTest",
+				TestCases: []models.JUnitTestCase{
+					{Name: "testValidLogin", Classname: "com.example.LoginTest", Time: 1.5},
+					{Name: "testInvalidLogin", Classname: "com.example.LoginTest", Time: 0.5,
+						Failures: []models.JUnitFailure{{Message: "expected 401", Type: "AssertionError", Value: "stack"}}},
+					{Name: "testTimeout", Time: 2,
+						Errors: []models.JUnitError{{Message: "timeout", Type: "TimeoutException"}}},
+					{Name: "testSSO", Classname: "com.example.LoginTest",
+						Skipped: &models.JUnitSkipped{Message: "not configured"}},
+				},
+			},
+		},
+	}
+}
+
+func TestJUnitImportService_ProcessJUnitData(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("SaveImport", ctx, mock.MatchedBy(func(build *models.ImportBuild) bool {
+			return build.ProjectID == 1 && build.SuiteID == 2 && build.BuildNumber == "42" &&
+				build.CIProvider == "unknown" && build.TestCaseCount == 4 && build.Duration == 4
+		}), mock.MatchedBy(func(suites []*models.SuiteResult) bool {
+			if len(suites) != 1 {
+				return false
+			}
+			results := suites[0].TestCases
+			return len(results) == 4 &&
+				results[1].Status == models.StatusFailed && results[1].Failure.Message == "expected 401" &&
+				results[2].Status == models.StatusError && results[2].Classname == "com.example.LoginTest" &&
+				results[3].Status == models.StatusSkipped && results[3].Failure == nil &&
+				results[3].SkipMessage == "not configured"
+		})).Return(int64(10), nil).Once()
+
+		result, err := service.ProcessJUnitData(ctx, 1, 2, &models.ImportOptions{BuildNumber: "42"}, sampleReport())
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.ImportResult{
+			BuildID:     10,
+			ProjectID:   1,
+			SuiteID:     2,
+			BuildNumber: "42",
+			Total:       4,
+			Passed:      1,
+			Failed:      1,
+			Errored:     1,
+			Skipped:     1,
+			Duration:    4,
+		}, result)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("nested suites, properties and timestamp", func(t *testing.T) {
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+
+		report := &models.JUnitTestSuites{
+			TestSuites: []models.JUnitTestSuite{
+				{
+					Name:       "integration",
+					Timestamp:  "2024-05-01T10:00:00",
+					Time:       12.5,
+					Hostname:   "runner-1",
+					Properties: []models.JUnitProperty{{Name: "env", Value: "staging"}, {Name: "region", Text: " eu "}},
+					SystemOut:  "  booting  ",
+					TestSuites: []models.JUnitTestSuite{
+						{
+							Name: "checkout",
+							TestCases: []models.JUnitTestCase{
+								{Name: "testPay", Time: 3, Failures: []models.JUnitFailure{
+									{Message: "first", Type: "AssertionError", Value: "a"},
+									{Message: "second", Value: "b"},
+								}},
+							},
+						},
+					},
+					TestCases: []models.JUnitTestCase{
+						{Name: "testHealth", Classname: "Health", SystemOut: "ok\n"},
+					},
+				},
+			},
+		}
+		startedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("SaveImport", ctx, mock.MatchedBy(func(build *models.ImportBuild) bool {
+			return build.CreatedAt.Equal(startedAt) && build.Duration == 12.5 && build.TestCaseCount == 2
+		}), mock.MatchedBy(func(suites []*models.SuiteResult) bool {
+			top := suites[0]
+			if top.Hostname != "runner-1" || top.SystemOut != "booting" || len(top.Properties) != 2 ||
+				top.Properties[1].Value != "eu" || len(top.Suites) != 1 || top.TestCases[0].SystemOut != "ok" {
+				return false
+			}
+			nested := top.Suites[0].TestCases[0]
+			return top.Suites[0].Name == "checkout" && nested.Classname == "checkout" &&
+				nested.Status == models.StatusFailed && nested.Failure.Message == "first" &&
+				nested.Failure.Details == "AssertionError: first\na\n\nsecond\nb"
+		})).Return(int64(11), nil).Once()
+
+		result, err := service.ProcessJUnitData(ctx, 1, 2, nil, report)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, result.Total)
+		assert.Equal(t, 1, result.Failed)
+		assert.Equal(t, 12.5, result.Duration)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("normalized report", func(t *testing.T) {
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+
+		suites := []*models.SuiteResult{
+			{Name: "Pets", TestCases: []*models.TestCaseResult{
+				{Name: "Get pet", Classname: "Pets", Status: models.StatusPassed, Time: 0.5},
+				{Name: "Create pet", Classname: "Pets", Status: models.StatusFailed, Time: 1,
+					Failure: &models.FailureDetail{Message: "status 500"},
+					Subtests: []*models.TestCaseResult{
+						{Name: "Create pet/duplicate", Classname: "Pets", Status: models.StatusFailed, Time: 1},
+					}},
+			}},
+		}
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("SaveImport", ctx, mock.MatchedBy(func(build *models.ImportBuild) bool {
+			return build.TestCaseCount == 3 && build.Duration == 1.5 && build.CIProvider == "ReadyAPI"
+		}), suites).Return(int64(12), nil).Once()
+
+		result, err := service.ProcessReport(ctx, 1, 2, &models.ImportOptions{CIProvider: "ReadyAPI"}, suites)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(12), result.BuildID)
+		assert.Equal(t, 1, result.Passed)
+		assert.Equal(t, 2, result.Failed)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("build metadata", func(t *testing.T) {
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+		pr := int64(7)
+		metadata := models.BuildMetadata{
+			CommitSHA: "4f2a9c1e", Branch: "feature/login", BaseBranch: "main", PRNumber: &pr,
+			Author: "octocat", Trigger: "pull_request", Properties: map[string]string{"environment": "staging"},
+			Tags: []string{"smoke", "api"},
+		}
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("SaveImport", ctx, mock.MatchedBy(func(build *models.ImportBuild) bool {
+			return assert.ObjectsAreEqual(metadata, build.BuildMetadata)
+		}), mock.Anything).Return(int64(12), nil).Once()
+
+		_, err := service.ProcessJUnitData(ctx, 1, 2, &models.ImportOptions{BuildMetadata: metadata}, sampleReport())
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("shard of a build", func(t *testing.T) {
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+		shard := &models.Shard{BuildID: 12, Index: 3}
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("SaveImport", ctx, mock.MatchedBy(func(build *models.ImportBuild) bool {
+			return build.Shard == shard && build.TestCaseCount == 4
+		}), mock.Anything).Return(int64(12), nil).Once()
+
+		result, err := service.ProcessJUnitData(ctx, 1, 2, &models.ImportOptions{Shard: shard}, sampleReport())
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(12), result.BuildID)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("suite belongs to another project", func(t *testing.T) {
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(7), nil).Once()
+
+		result, err := service.ProcessJUnitData(ctx, 1, 2, nil, sampleReport())
+
+		assert.Equal(t, importErrors.ErrSuiteNotFound, err)
+		assert.Nil(t, result)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("empty report", func(t *testing.T) {
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+
+		result, err := service.ProcessJUnitData(ctx, 1, 2, nil, &models.JUnitTestSuites{})
+
+		assert.Equal(t, importErrors.ErrEmptyReport, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		service := application.NewJUnitImportService(new(MockJUnitImportRepository))
+
+		result, err := service.ProcessJUnitData(ctx, 0, 2, nil, sampleReport())
+
+		assert.Equal(t, importErrors.ErrInvalidRequest, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("SaveImport", ctx, mock.Anything, mock.Anything).Return(int64(0), errors.New("db down")).Once()
+
+		result, err := service.ProcessJUnitData(ctx, 1, 2, nil, sampleReport())
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "db down")
+		assert.Nil(t, result)
+	})
+}
+
+func TestJUnitImportService_AttachmentReferences(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockJUnitImportRepository)
+	service := application.NewJUnitImportService(mockRepo)
+
+	report := &models.JUnitTestSuites{
+		TestSuites: []models.JUnitTestSuite{
+			{
+				Name: "ui",
+				TestCases: []models.JUnitTestCase{
+					{Name: "testCheckout", Classname: "ui.Checkout", Failures: []models.JUnitFailure{{Message: "button missing"}},
+						SystemOut: "[[ATTACHMENT|/ci/work/screenshots/checkout.png]]\nsaved [[ATTACHMENT|/ci/work/checkout.har]]",
+						SystemErr: "[[ATTACHMENT|/ci/work/screenshots/checkout.png]]"},
+					{Name: "testLogin", Classname: "ui.Login", SystemOut: "no attachments here"},
+				},
+			},
+		},
+	}
+
+	mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+	mockRepo.On("SaveImport", ctx, mock.Anything, mock.Anything).Return(int64(10), nil).Once()
+
+	result, err := service.ProcessJUnitData(ctx, 1, 2, nil, report)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.AttachmentRef{
+		{Classname: "ui.Checkout", Name: "testCheckout", Path: "/ci/work/screenshots/checkout.png"},
+		{Classname: "ui.Checkout", Name: "testCheckout", Path: "/ci/work/checkout.har"},
+	}, result.Attachments)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestJUnitImportService_PropertyTags(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockJUnitImportRepository)
+	service := application.NewJUnitImportService(mockRepo)
+
+	report := &models.JUnitTestSuites{
+		TestSuites: []models.JUnitTestSuite{
+			{
+				Name: "api",
+				TestCases: []models.JUnitTestCase{
+					{Name: "testHealth", Classname: "api.Health", File: "api/health_test.go", Properties: []models.JUnitProperty{
+						{Name: "tag", Value: "smoke"},
+						{Name: "tags", Value: "api, @regression,smoke,"},
+						{Name: "owner", Value: "platform"},
+					}},
+					{Name: "testOrders", Classname: "api.Orders"},
+				},
+			},
+		},
+	}
+
+	var saved []*models.SuiteResult
+	mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+	mockRepo.On("SaveImport", ctx, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(2).([]*models.SuiteResult)
+	}).Return(int64(10), nil).Once()
+
+	_, err := service.ProcessJUnitData(ctx, 1, 2, nil, report)
+
+	assert.NoError(t, err)
+	if assert.Len(t, saved, 1) && assert.Len(t, saved[0].TestCases, 2) {
+		assert.Equal(t, []string{"smoke", "api", "regression"}, saved[0].TestCases[0].Tags)
+		assert.Equal(t, "api/health_test.go", saved[0].TestCases[0].File)
+		assert.Empty(t, saved[0].TestCases[1].Tags)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestJUnitImportService_SurefireReruns(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockJUnitImportRepository)
+	service := application.NewJUnitImportService(mockRepo)
+
+	report := &models.JUnitTestSuites{
+		TestSuites: []models.JUnitTestSuite{
+			{
+				Name: "com.example.CartTest",
+				TestCases: []models.JUnitTestCase{
+					{Name: "testCheckout", Classname: "com.example.CartTest", Time: 1.2,
+						FlakyFailures: []models.JUnitRerun{{Message: "expected 3", Type: "AssertionError", StackTrace: "trace"}},
+						FlakyErrors:   []models.JUnitRerun{{Message: "timeout", Type: "TimeoutException"}}},
+					{Name: "testRefund", Classname: "com.example.CartTest", Time: 0.4,
+						Failures:      []models.JUnitFailure{{Message: "refused", Type: "AssertionError", Value: "first"}},
+						RerunFailures: []models.JUnitRerun{{Message: "refused again", Time: 0.3}, {Message: "still refused", Time: 0.2}}},
+					{Name: "testTotal", Classname: "com.example.CartTest"},
+				},
+			},
+		},
+	}
+
+	var saved []*models.SuiteResult
+	mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+	mockRepo.On("SaveImport", ctx, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(2).([]*models.SuiteResult)
+	}).Return(int64(10), nil).Once()
+
+	_, err := service.ProcessJUnitData(ctx, 1, 2, nil, report)
+
+	assert.NoError(t, err)
+	if !assert.Len(t, saved, 1) || !assert.Len(t, saved[0].TestCases, 3) {
+		return
+	}
+
+	flaky := saved[0].TestCases[0]
+	assert.Equal(t, models.StatusPassed, flaky.Status)
+	assert.Nil(t, flaky.Failure)
+	if assert.Len(t, flaky.Retries, 2) {
+		assert.Equal(t, models.StatusFailed, flaky.Retries[0].Status)
+		assert.Equal(t, &models.FailureDetail{Message: "expected 3", Type: "AssertionError", Details: "trace"}, flaky.Retries[0].Failure)
+		assert.Equal(t, models.StatusError, flaky.Retries[1].Status)
+	}
+
+	failing := saved[0].TestCases[1]
+	assert.Equal(t, models.StatusFailed, failing.Status)
+	assert.Equal(t, "still refused", failing.Failure.Message)
+	assert.Equal(t, 0.2, failing.Time)
+	if assert.Len(t, failing.Retries, 2) {
+		assert.Equal(t, &models.Attempt{Status: models.StatusFailed, Time: 0.4,
+			Failure: &models.FailureDetail{Message: "refused", Type: "AssertionError", Details: "first"}}, failing.Retries[0])
+		assert.Equal(t, "refused again", failing.Retries[1].Failure.Message)
+	}
+
+	assert.Empty(t, saved[0].TestCases[2].Retries)
+	assert.True(t, flaky.Rerun)
+	assert.True(t, failing.Rerun)
+	assert.False(t, saved[0].TestCases[2].Rerun)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestJUnitImportService_PytestReruns(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockJUnitImportRepository)
+	service := application.NewJUnitImportService(mockRepo)
+
+	report := &models.JUnitTestSuites{
+		TestSuites: []models.JUnitTestSuite{
+			{
+				Name: "pytest",
+				TestCases: []models.JUnitTestCase{
+					{Name: "test_pay", Classname: "tests.test_checkout", Time: 0.5,
+						Reruns: []models.JUnitRerun{{Message: "declined", Value: "trace"}}},
+					{Name: "test_refund", Classname: "tests.test_checkout",
+						Properties: []models.JUnitProperty{{Name: "rerun", Value: "1"}}},
+					{Name: "test_cancel", Classname: "tests.test_checkout"},
+				},
+			},
+		},
+	}
+
+	var saved []*models.SuiteResult
+	mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+	mockRepo.On("SaveImport", ctx, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(2).([]*models.SuiteResult)
+	}).Return(int64(10), nil).Once()
+
+	_, err := service.ProcessJUnitData(ctx, 1, 2, nil, report)
+
+	assert.NoError(t, err)
+	if !assert.Len(t, saved, 1) || !assert.Len(t, saved[0].TestCases, 3) {
+		return
+	}
+	pay := saved[0].TestCases[0]
+	assert.Equal(t, models.StatusPassed, pay.Status)
+	assert.Equal(t, []*models.Attempt{{Status: models.StatusFailed,
+		Failure: &models.FailureDetail{Message: "declined", Details: "trace"}}}, pay.Retries)
+	assert.True(t, pay.Rerun)
+	assert.True(t, saved[0].TestCases[1].Rerun)
+	assert.False(t, saved[0].TestCases[2].Rerun)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestJUnitImportService_RepositoryWarnings(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockJUnitImportRepository)
+	service := application.NewJUnitImportService(mockRepo)
+	suites := []*models.SuiteResult{{Name: "checkout", TestCases: []*models.TestCaseResult{
+		{Name: "testPay", Classname: "Checkout", Status: models.StatusPassed},
+	}}}
+
+	mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+	mockRepo.On("SaveImport", ctx, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		build := args.Get(1).(*models.ImportBuild)
+		build.Warnings = append(build.Warnings, "replaced")
+	}).Return(int64(10), nil).Once()
+
+	result, err := service.ProcessReport(ctx, 1, 2, nil, suites)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"replaced"}, result.Warnings)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		expected []string
+	}{
+		{name: "trimmed", tags: []string{" smoke ", "@nightly"}, expected: []string{"smoke", "nightly"}},
+		{name: "repeated", tags: []string{"smoke", "@smoke", "api"}, expected: []string{"smoke", "api"}},
+		{name: "empty and overlong", tags: []string{"", " @ ", strings.Repeat("x", models.MaxTagLength+1)}, expected: nil},
+		{name: "none", tags: nil, expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, models.NormalizeTags(tt.tags))
+		})
+	}
+}
+
+func TestExecutionLogs(t *testing.T) {
+	t.Run("logs within the limit are kept whole", func(t *testing.T) {
+		logs := models.ExecutionLogs(&models.TestCaseResult{SystemOut: "started", SystemErr: "warning"}, 100)
+
+		assert.Equal(t, []models.ExecutionLog{
+			{Stream: models.LogStdout, Text: "started", OriginalSize: 7},
+			{Stream: models.LogStderr, Text: "warning", OriginalSize: 7},
+		}, logs)
+	})
+
+	t.Run("empty streams are left out", func(t *testing.T) {
+		logs := models.ExecutionLogs(&models.TestCaseResult{SystemErr: "boom"}, 100)
+
+		assert.Equal(t, []models.ExecutionLog{{Stream: models.LogStderr, Text: "boom", OriginalSize: 4}}, logs)
+	})
+
+	t.Run("a long log keeps its beginning and end", func(t *testing.T) {
+		out := strings.Repeat("a", 50) + strings.Repeat("b", 100) + strings.Repeat("c", 50)
+
+		logs := models.ExecutionLogs(&models.TestCaseResult{SystemOut: out}, 100)
+
+		assert.Len(t, logs, 1)
+		assert.True(t, logs[0].Truncated)
+		assert.Equal(t, int64(200), logs[0].OriginalSize)
+		assert.Equal(t, strings.Repeat("a", 50)+"\n\n[... 100 bytes truncated ...]\n\n"+strings.Repeat("c", 50), logs[0].Text)
+	})
+
+	t.Run("a short stream leaves its share to the other", func(t *testing.T) {
+		logs := models.ExecutionLogs(&models.TestCaseResult{SystemOut: strings.Repeat("o", 200), SystemErr: strings.Repeat("e", 10)}, 100)
+
+		assert.True(t, logs[0].Truncated)
+		assert.Contains(t, logs[0].Text, "[... 110 bytes truncated ...]")
+		assert.False(t, logs[1].Truncated)
+	})
+
+	t.Run("characters are not split", func(t *testing.T) {
+		logs := models.ExecutionLogs(&models.TestCaseResult{SystemOut: strings.Repeat("é", 100)}, 51)
+
+		assert.True(t, logs[0].Truncated)
+		assert.True(t, utf8.ValidString(logs[0].Text))
+	})
+}
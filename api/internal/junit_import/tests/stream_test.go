@@ -0,0 +1,556 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BennyEisner/test-results/internal/junit_import/application"
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	importErrors "github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/domain/ports"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// recordingSession is an ImportSession that records what it is given
+type recordingSession struct {
+	events     []string
+	suites     []*models.SuiteResult
+	results    []*models.TestCaseResult
+	committed  *models.ImportBuild
+	rolledBack bool
+}
+
+func (s *recordingSession) StartSuite(ctx context.Context, suite *models.SuiteResult) error {
+	s.events = append(s.events, "start "+suite.Name)
+	return nil
+}
+
+func (s *recordingSession) AddTestCase(ctx context.Context, result *models.TestCaseResult) error {
+	s.events = append(s.events, "case "+result.Classname+"."+result.Name)
+	s.results = append(s.results, result)
+	return nil
+}
+
+func (s *recordingSession) EndSuite(ctx context.Context, suite *models.SuiteResult) error {
+	s.events = append(s.events, "end "+suite.Name)
+	s.suites = append(s.suites, suite)
+	return nil
+}
+
+func (s *recordingSession) Commit(ctx context.Context, build *models.ImportBuild) (int64, error) {
+	s.committed = build
+	return 20, nil
+}
+
+func (s *recordingSession) Rollback() error {
+	if s.committed == nil {
+		s.rolledBack = true
+	}
+	return nil
+}
+
+func streamString(report string, maxElementSize int64) ports.JUnitStream {
+	return func(ctx context.Context, sink ports.JUnitSink) error {
+		return parser.StreamJUnit(ctx, strings.NewReader(report), sink, maxElementSize)
+	}
+}
+
+func TestJUnitImportService_StreamJUnitData(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		report := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="all">
+  <testsuite name="integration" timestamp="2024-05-01T10:00:00" time="12.5" hostname="runner-1">
+    <properties>
+      <property name="env" value="staging"/>
+    </properties>
+    <testcase name="testHealth" classname="Health" time="1">
+      <system-out>ok
+</system-out>
+    </testcase>
+    <testsuite name="checkout" timestamp="2024-05-01T09:59:00">
+      <testcase name="testPay" time="3">
+        <failure message="declined" type="AssertionError">trace</failure>
+      </testcase>
+      <testcase name="testRefund"><skipped message="not ready"/></testcase>
+    </testsuite>
+    <system-out>  booting  </system-out>
+  </testsuite>
+  <testsuite name="unit">
+    <testcase name="testAdd" classname="math" time="0.5"/>
+    <testcase name="testDiv" classname="math" time="0.25"><error message="panic"/></testcase>
+  </testsuite>
+</testsuites>`
+
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+		session := &recordingSession{}
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("BeginImport", ctx, mock.MatchedBy(func(build *models.ImportBuild) bool {
+			return build.ProjectID == 1 && build.SuiteID == 2 && build.BuildNumber == "42"
+		})).Return(session, nil).Once()
+
+		result, err := service.StreamJUnitData(ctx, 1, 2, &models.ImportOptions{BuildNumber: "42"}, streamString(report, 0))
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.ImportResult{
+			BuildID:     20,
+			ProjectID:   1,
+			SuiteID:     2,
+			BuildNumber: "42",
+			Total:       5,
+			Passed:      2,
+			Failed:      1,
+			Errored:     1,
+			Skipped:     1,
+			Duration:    13.25,
+		}, result)
+		assert.Equal(t, []string{
+			"start integration",
+			"case Health.testHealth",
+			"start checkout",
+			"case checkout.testPay",
+			"case checkout.testRefund",
+			"end checkout",
+			"end integration",
+			"start unit",
+			"case math.testAdd",
+			"case math.testDiv",
+			"end unit",
+		}, session.events)
+
+		assert.Equal(t, "ok", session.results[0].SystemOut)
+		assert.Equal(t, "declined", session.results[1].Failure.Message)
+		assert.Equal(t, "not ready", session.results[2].SkipMessage)
+		integration := session.suites[1]
+		assert.Equal(t, "booting", integration.SystemOut)
+		assert.Equal(t, []models.Property{{Name: "env", Value: "staging"}}, integration.Properties)
+		assert.Equal(t, "runner-1", integration.Hostname)
+
+		assert.Equal(t, 5, session.committed.TestCaseCount)
+		assert.Equal(t, 13.25, session.committed.Duration)
+		assert.True(t, session.committed.CreatedAt.Equal(time.Date(2024, 5, 1, 9, 59, 0, 0, time.UTC)))
+		assert.False(t, session.rolledBack)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("warnings and progress", func(t *testing.T) {
+		report := `<testsuites>
+  <testsuite name="declared" tests="3">
+    <testcase name="testA"/>
+    <testcase classname="declared"/>
+  </testsuite>
+</testsuites>`
+
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+		session := &recordingSession{}
+		var progress []models.ImportProgress
+		opts := &models.ImportOptions{Progress: func(p models.ImportProgress) {
+			progress = append(progress, p)
+		}}
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("BeginImport", ctx, mock.Anything).Return(session, nil).Once()
+
+		result, err := service.StreamJUnitData(ctx, 1, 2, opts, streamString(report, 0))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			`test case without a name in suite "declared"`,
+			`suite "declared" declares 3 tests but contains 2`,
+		}, result.Warnings)
+		assert.Equal(t, []models.ImportProgress{{State: models.JobWriting, Total: 2, Passed: 2}}, progress)
+	})
+
+	t.Run("empty report is rolled back", func(t *testing.T) {
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+		session := &recordingSession{}
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("BeginImport", ctx, mock.Anything).Return(session, nil).Once()
+
+		result, err := service.StreamJUnitData(ctx, 1, 2, nil, streamString(`<testsuite name="none"/>`, 0))
+
+		assert.Equal(t, importErrors.ErrEmptyReport, err)
+		assert.Nil(t, result)
+		assert.Nil(t, session.committed)
+		assert.True(t, session.rolledBack)
+	})
+
+	t.Run("invalid report is rolled back", func(t *testing.T) {
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+		session := &recordingSession{}
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("BeginImport", ctx, mock.Anything).Return(session, nil).Once()
+
+		result, err := service.StreamJUnitData(ctx, 1, 2, nil, streamString(`<testsuite name="cut"><testcase name="a"/>`, 0))
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+		assert.Nil(t, result)
+		assert.Len(t, session.results, 1)
+		assert.Nil(t, session.committed)
+		assert.True(t, session.rolledBack)
+	})
+
+	t.Run("suite belongs to another project", func(t *testing.T) {
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(7), nil).Once()
+
+		result, err := service.StreamJUnitData(ctx, 1, 2, nil, streamString(`<testsuite/>`, 0))
+
+		assert.Equal(t, importErrors.ErrSuiteNotFound, err)
+		assert.Nil(t, result)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestJUnitImportService_StreamReports(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("JUnit report and parsed suites of another format", func(t *testing.T) {
+		startedAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+		parsed := &models.SuiteResult{
+			Name:      "ctrf",
+			Timestamp: &startedAt,
+			TestCases: []*models.TestCaseResult{
+				{Name: "pays", Classname: "checkout", Status: models.StatusPassed, Time: 2},
+				{Classname: "checkout", Status: models.StatusFailed, Time: 1},
+			},
+			Suites: []*models.SuiteResult{{
+				Name:      "nested",
+				TestCases: []*models.TestCaseResult{{Name: "refunds", Status: models.StatusSkipped}},
+			}},
+		}
+		stream := func(ctx context.Context, sink ports.ReportSink) error {
+			report := `<testsuite name="junit" time="4"><testcase name="testAdd" classname="math"/></testsuite>`
+			if err := parser.StreamJUnit(ctx, strings.NewReader(report), sink, 0); err != nil {
+				return err
+			}
+			return sink.AddSuite(ctx, parsed)
+		}
+
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+		session := &recordingSession{}
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("BeginImport", ctx, mock.Anything).Return(session, nil).Once()
+
+		result, err := service.StreamReports(ctx, 1, 2, nil, stream)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 4, result.Total)
+		assert.Equal(t, 2, result.Passed)
+		assert.Equal(t, 1, result.Failed)
+		assert.Equal(t, 1, result.Skipped)
+		assert.Equal(t, 7.0, result.Duration)
+		assert.Equal(t, []string{`test case of class "checkout" without a name`}, result.Warnings)
+		assert.Equal(t, []string{
+			"start junit",
+			"case math.testAdd",
+			"end junit",
+			"start ctrf",
+			"case checkout.pays",
+			"case checkout.",
+			"start nested",
+			"case .refunds",
+			"end nested",
+			"end ctrf",
+		}, session.events)
+		assert.Equal(t, 4, session.committed.TestCaseCount)
+		assert.True(t, session.committed.CreatedAt.Equal(startedAt))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("parsed suite inside of a streamed suite", func(t *testing.T) {
+		stream := func(ctx context.Context, sink ports.ReportSink) error {
+			if err := sink.StartSuite(ctx, &models.JUnitTestSuite{Name: "open"}); err != nil {
+				return err
+			}
+			return sink.AddSuite(ctx, &models.SuiteResult{Name: "ctrf"})
+		}
+
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+		session := &recordingSession{}
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("BeginImport", ctx, mock.Anything).Return(session, nil).Once()
+
+		result, err := service.StreamReports(ctx, 1, 2, nil, stream)
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+		assert.Nil(t, result)
+		assert.True(t, session.rolledBack)
+	})
+
+	t.Run("results numbered by report", func(t *testing.T) {
+		stream := func(ctx context.Context, sink ports.ReportSink) error {
+			for _, report := range []string{
+				`<testsuite name="shard"><testcase name="testAdd" classname="math"/></testsuite>`,
+				`<testsuite name="shard"><testcase name="testAdd" classname="math"/></testsuite>`,
+			} {
+				if err := sink.StartReport(ctx); err != nil {
+					return err
+				}
+				if err := parser.StreamJUnit(ctx, strings.NewReader(report), sink, 0); err != nil {
+					return err
+				}
+			}
+			if err := sink.StartReport(ctx); err != nil {
+				return err
+			}
+			return sink.AddSuite(ctx, &models.SuiteResult{Name: "ctrf", TestCases: []*models.TestCaseResult{
+				{Name: "testAdd", Classname: "math", Status: models.StatusPassed},
+			}})
+		}
+
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+		session := &recordingSession{}
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("BeginImport", ctx, mock.Anything).Return(session, nil).Once()
+
+		_, err := service.StreamReports(ctx, 1, 2, nil, stream)
+
+		assert.NoError(t, err)
+		if assert.Len(t, session.results, 3) {
+			assert.Equal(t, 1, session.results[0].Report)
+			assert.Equal(t, 2, session.results[1].Report)
+			assert.Equal(t, 3, session.results[2].Report)
+		}
+	})
+
+	t.Run("report started inside of a streamed suite", func(t *testing.T) {
+		stream := func(ctx context.Context, sink ports.ReportSink) error {
+			if err := sink.StartSuite(ctx, &models.JUnitTestSuite{Name: "open"}); err != nil {
+				return err
+			}
+			return sink.StartReport(ctx)
+		}
+
+		mockRepo := new(MockJUnitImportRepository)
+		service := application.NewJUnitImportService(mockRepo)
+		session := &recordingSession{}
+
+		mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil).Once()
+		mockRepo.On("BeginImport", ctx, mock.Anything).Return(session, nil).Once()
+
+		result, err := service.StreamReports(ctx, 1, 2, nil, stream)
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+		assert.Nil(t, result)
+		assert.True(t, session.rolledBack)
+	})
+}
+
+func TestStreamJUnit(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("element larger than the limit", func(t *testing.T) {
+		report := `<testsuite name="big"><testcase name="a"><system-out>` + strings.Repeat("x", 64<<10) +
+			`</system-out></testcase></testsuite>`
+
+		err := parser.StreamJUnit(ctx, strings.NewReader(report), &discardSink{}, 16<<10)
+
+		assert.True(t, errors.Is(err, importErrors.ErrReportTooLarge))
+	})
+
+	t.Run("elements within the limit", func(t *testing.T) {
+		var report strings.Builder
+		report.WriteString(`<testsuite name="many">`)
+		for i := 0; i < 200; i++ {
+			fmt.Fprintf(&report, `<testcase name="t%d"><system-out>%s</system-out></testcase>`, i, strings.Repeat("x", 1<<10))
+		}
+		report.WriteString(`</testsuite>`)
+		sink := &discardSink{}
+
+		err := parser.StreamJUnit(ctx, strings.NewReader(report.String()), sink, 16<<10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, sink.testCases)
+	})
+
+	t.Run("unexpected root", func(t *testing.T) {
+		err := parser.StreamJUnit(ctx, strings.NewReader(`<report/>`), &discardSink{}, 0)
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+
+	t.Run("sink error stops the stream", func(t *testing.T) {
+		sink := &discardSink{err: errors.New("db down")}
+
+		err := parser.StreamJUnit(ctx, strings.NewReader(`<testsuite><testcase name="a"/><testcase name="b"/></testsuite>`), sink, 0)
+
+		assert.EqualError(t, err, "db down")
+		assert.Equal(t, 1, sink.testCases)
+	})
+}
+
+// TestStreamJUnit_ConstantMemory checks that the heap used while streaming a report
+// does not grow with the number of test cases it holds
+func TestStreamJUnit_ConstantMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("streams large generated reports")
+	}
+
+	small := peakStreamHeap(t, 20000)
+	large := peakStreamHeap(t, 200000)
+
+	assert.Less(t, large, small+(8<<20), "peak heap grew from %d to %d bytes", small, large)
+}
+
+func BenchmarkStreamJUnit(b *testing.B) {
+	for _, cases := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("testcases=%d", cases), func(b *testing.B) {
+			b.ReportAllocs()
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				if heap := peakStreamHeap(b, cases); heap > peak {
+					peak = heap
+				}
+			}
+			b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+		})
+	}
+}
+
+// peakStreamHeap imports a generated report of the given size through the service and
+// returns the largest heap growth seen while it was streamed
+func peakStreamHeap(tb testing.TB, cases int) uint64 {
+	tb.Helper()
+	ctx := context.Background()
+
+	mockRepo := new(MockJUnitImportRepository)
+	service := application.NewJUnitImportService(mockRepo)
+	session := &heapSamplingSession{}
+	mockRepo.On("GetSuiteProjectID", ctx, int64(2)).Return(int64(1), nil)
+	mockRepo.On("BeginImport", ctx, mock.Anything).Return(session, nil)
+
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	session.baseline = stats.HeapAlloc
+
+	limits := models.NewImportLimits(0, 0)
+	stream := func(ctx context.Context, sink ports.JUnitSink) error {
+		return parser.StreamJUnit(ctx, &junitGenerator{cases: cases}, sink, limits.MaxElementSize)
+	}
+	result, err := service.StreamJUnitData(ctx, 1, 2, nil, stream)
+	if err != nil {
+		tb.Fatalf("stream failed: %v", err)
+	}
+	if result.Total != cases {
+		tb.Fatalf("imported %d of %d test cases", result.Total, cases)
+	}
+	return session.peak
+}
+
+// heapSamplingSession discards what it is given and samples the heap every 1000 test cases
+type heapSamplingSession struct {
+	count    int
+	baseline uint64
+	peak     uint64
+}
+
+func (s *heapSamplingSession) StartSuite(ctx context.Context, suite *models.SuiteResult) error {
+	return nil
+}
+
+func (s *heapSamplingSession) AddTestCase(ctx context.Context, result *models.TestCaseResult) error {
+	if s.count++; s.count%1000 == 0 {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc > s.baseline && stats.HeapAlloc-s.baseline > s.peak {
+			s.peak = stats.HeapAlloc - s.baseline
+		}
+	}
+	return nil
+}
+
+func (s *heapSamplingSession) EndSuite(ctx context.Context, suite *models.SuiteResult) error {
+	return nil
+}
+
+func (s *heapSamplingSession) Commit(ctx context.Context, build *models.ImportBuild) (int64, error) {
+	return 1, nil
+}
+
+func (s *heapSamplingSession) Rollback() error {
+	return nil
+}
+
+// discardSink counts the test cases it receives and fails them with err, if set
+type discardSink struct {
+	testCases int
+	err       error
+}
+
+func (s *discardSink) StartSuite(ctx context.Context, suite *models.JUnitTestSuite) error {
+	return nil
+}
+
+func (s *discardSink) AddTestCase(ctx context.Context, testCase *models.JUnitTestCase) error {
+	s.testCases++
+	return s.err
+}
+
+func (s *discardSink) EndSuite(ctx context.Context, suite *models.JUnitTestSuite) error {
+	return nil
+}
+
+// junitGenerator produces a JUnit report with the given number of test cases while it
+// is read, so that the report itself never sits in memory. One test case in ten fails.
+type junitGenerator struct {
+	cases int
+	next  int
+	buf   bytes.Buffer
+	done  bool
+}
+
+func (g *junitGenerator) Read(p []byte) (int, error) {
+	for g.buf.Len() < len(p) && !g.done {
+		g.fill()
+	}
+	if g.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return g.buf.Read(p)
+}
+
+func (g *junitGenerator) fill() {
+	if g.next == 0 {
+		g.buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<testsuites><testsuite name="generated" timestamp="2024-05-01T10:00:00">` + "\n")
+	}
+	if g.next < g.cases {
+		suite := g.next / 1000
+		if g.next%10 == 0 {
+			fmt.Fprintf(&g.buf, `<testcase name="test%d" classname="pkg.Suite%d" time="0.01"><failure message="expected true" type="AssertionError">at pkg.Suite%d.test%d(Suite.java:42)</failure><system-out>running test %d</system-out></testcase>`+"\n", g.next, suite, suite, g.next, g.next)
+		} else {
+			fmt.Fprintf(&g.buf, `<testcase name="test%d" classname="pkg.Suite%d" time="0.01"/>`+"\n", g.next, suite)
+		}
+		g.next++
+	}
+	if g.next == g.cases {
+		g.buf.WriteString(`</testsuite></testsuites>` + "\n")
+		g.done = true
+	}
+}
@@ -0,0 +1,116 @@
+package application
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	models "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	importErrors "github.com/BennyEisner/test-results/internal/junit_import/domain/errors"
+	"github.com/BennyEisner/test-results/internal/junit_import/infrastructure/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTAP(t *testing.T) {
+	t.Run("test points, directives and diagnostics", func(t *testing.T) {
+		stream := `TAP version 13
+1..5
+# setting up
+ok 1 - Input file opened
+not ok 2 - First line of the input valid
+  ---
+  message: 'First line invalid'
+  severity: fail
+  duration_ms: 12.5
+  data:
+    got: 'Flirble'
+    expect: 'Fnible'
+  ...
+ok 3 - Read the rest of the file # SKIP no rest
+not ok 4 - Summarized correctly # TODO Not written yet
+ok 5 handles \# in names
+`
+
+		suites, err := parser.ParseTAP(strings.NewReader(stream))
+
+		assert.NoError(t, err)
+		assert.Len(t, suites, 1)
+		suite := suites[0]
+		assert.Equal(t, "", suite.Name)
+		assert.Equal(t, 5, suite.Tests)
+		assert.Equal(t, 1, suite.Failures)
+		assert.Equal(t, 2, suite.Skipped)
+		assert.Equal(t, 0.0125, suite.Time)
+
+		cases := suite.TestCases
+		assert.Len(t, cases, 5)
+		assert.Equal(t, "Input file opened", cases[0].Name)
+		assert.Equal(t, models.StatusPassed, cases[0].Status)
+
+		failed := cases[1]
+		assert.Equal(t, models.StatusFailed, failed.Status)
+		assert.Equal(t, 0.0125, failed.Time)
+		assert.Equal(t, "First line invalid", failed.Failure.Message)
+		assert.Equal(t, "fail", failed.Failure.Type)
+		assert.Contains(t, failed.Failure.Details, "got: 'Flirble'")
+
+		assert.Equal(t, "Read the rest of the file", cases[2].Name)
+		assert.Equal(t, models.StatusSkipped, cases[2].Status)
+		assert.Equal(t, "no rest", cases[2].SkipMessage)
+		assert.Equal(t, models.StatusSkipped, cases[3].Status)
+		assert.Equal(t, "TODO Not written yet", cases[3].SkipMessage)
+		assert.Equal(t, "handles # in names", cases[4].Name)
+	})
+
+	t.Run("subtests", func(t *testing.T) {
+		stream := `TAP version 14
+# Subtest: parser
+    1..2
+    ok 1 - reads headers
+    not ok 2 - reads rows
+      ---
+      message: row 3 is short
+      ...
+not ok 1 - parser
+ok 2 - writer
+`
+
+		suites, err := parser.ParseTAP(strings.NewReader(stream))
+
+		assert.NoError(t, err)
+		cases := suites[0].TestCases
+		assert.Len(t, cases, 2)
+		assert.Equal(t, "parser", cases[0].Name)
+		assert.Equal(t, models.StatusFailed, cases[0].Status)
+		assert.Len(t, cases[0].Subtests, 2)
+		assert.Equal(t, "reads rows", cases[0].Subtests[1].Name)
+		assert.Equal(t, "row 3 is short", cases[0].Subtests[1].Failure.Message)
+		assert.Equal(t, "writer", cases[1].Name)
+		assert.Equal(t, 4, suites[0].Tests)
+	})
+
+	t.Run("bail out", func(t *testing.T) {
+		stream := "1..3\nok 1 - first\nBail out! database is down\nok 2 - never read\n"
+
+		suites, err := parser.ParseTAP(strings.NewReader(stream))
+
+		assert.NoError(t, err)
+		cases := suites[0].TestCases
+		assert.Len(t, cases, 2)
+		assert.Equal(t, models.StatusError, cases[1].Status)
+		assert.Equal(t, "database is down", cases[1].Failure.Message)
+	})
+
+	t.Run("unnamed test point", func(t *testing.T) {
+		suites, err := parser.ParseTAP(strings.NewReader("ok 7\n"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "test 7", suites[0].TestCases[0].Name)
+	})
+
+	t.Run("no test points", func(t *testing.T) {
+		_, err := parser.ParseTAP(strings.NewReader("TAP version 13\n1..0\n"))
+
+		assert.True(t, errors.Is(err, importErrors.ErrInvalidReport))
+	})
+}
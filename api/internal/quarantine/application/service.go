@@ -0,0 +1,151 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/BennyEisner/test-results/internal/quarantine/domain/errors"
+	"github.com/BennyEisner/test-results/internal/quarantine/domain/models"
+	"github.com/BennyEisner/test-results/internal/quarantine/domain/ports"
+)
+
+// QuarantineService implements the QuarantineService interface
+type QuarantineService struct {
+	repo ports.QuarantineRepository
+}
+
+func NewQuarantineService(repo ports.QuarantineRepository) ports.QuarantineService {
+	return &QuarantineService{repo: repo}
+}
+
+// GetQuarantine returns a quarantine, whether active or expired
+func (s *QuarantineService) GetQuarantine(ctx context.Context, id int64) (*models.Quarantine, error) {
+	if id <= 0 {
+		return nil, errors.ErrQuarantineNotFound
+	}
+	quarantine, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quarantine %d: %w", id, err)
+	}
+	if quarantine == nil {
+		return nil, errors.ErrQuarantineNotFound
+	}
+	return quarantine, nil
+}
+
+// GetQuarantines returns the active quarantines of a project, and the expired ones if asked
+func (s *QuarantineService) GetQuarantines(ctx context.Context, projectID int64, includeExpired bool) ([]*models.Quarantine, error) {
+	if projectID <= 0 {
+		return nil, errors.ErrInvalidProjectID
+	}
+	quarantines, err := s.repo.GetByProjectID(ctx, projectID, includeExpired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quarantines of project %d: %w", projectID, err)
+	}
+	if quarantines == nil {
+		quarantines = []*models.Quarantine{}
+	}
+	return quarantines, nil
+}
+
+// CreateQuarantine quarantines a test case of a project. A test case has one quarantine
+// at a time; an expired one is replaced.
+func (s *QuarantineService) CreateQuarantine(ctx context.Context, projectID int64, input *models.QuarantineInput) (*models.Quarantine, error) {
+	if projectID <= 0 {
+		return nil, errors.ErrInvalidProjectID
+	}
+	quarantine, err := newQuarantine(input)
+	if err != nil {
+		return nil, err
+	}
+
+	testCaseProjectID, err := s.repo.GetTestCaseProjectID(ctx, input.TestCaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up test case %d: %w", input.TestCaseID, err)
+	}
+	if testCaseProjectID != projectID {
+		return nil, errors.ErrTestCaseNotFound
+	}
+
+	quarantine.TestCaseID = input.TestCaseID
+	created, err := s.repo.Create(ctx, quarantine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quarantine: %w", err)
+	}
+	if !created {
+		return nil, errors.ErrAlreadyQuarantined
+	}
+	return s.GetQuarantine(ctx, quarantine.ID)
+}
+
+// UpdateQuarantine changes who quarantined a test case, why, until when and the issue
+// linked to it
+func (s *QuarantineService) UpdateQuarantine(ctx context.Context, id int64, input *models.QuarantineInput) (*models.Quarantine, error) {
+	if id <= 0 {
+		return nil, errors.ErrQuarantineNotFound
+	}
+	quarantine, err := newQuarantine(input)
+	if err != nil {
+		return nil, err
+	}
+
+	quarantine.ID = id
+	updated, err := s.repo.Update(ctx, quarantine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update quarantine %d: %w", id, err)
+	}
+	if !updated {
+		return nil, errors.ErrQuarantineNotFound
+	}
+	return s.GetQuarantine(ctx, id)
+}
+
+// DeleteQuarantine lifts a quarantine
+func (s *QuarantineService) DeleteQuarantine(ctx context.Context, id int64) error {
+	if id <= 0 {
+		return errors.ErrQuarantineNotFound
+	}
+	deleted, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete quarantine %d: %w", id, err)
+	}
+	if !deleted {
+		return errors.ErrQuarantineNotFound
+	}
+	return nil
+}
+
+// newQuarantine checks the input of a quarantine: who quarantined the test case and why
+// are required, the issue must be an http(s) URL and the expiry in the future
+func newQuarantine(input *models.QuarantineInput) (*models.Quarantine, error) {
+	if input == nil {
+		return nil, errors.ErrInvalidQuarantine
+	}
+	quarantine := &models.Quarantine{
+		QuarantinedBy: strings.TrimSpace(input.QuarantinedBy),
+		Reason:        strings.TrimSpace(input.Reason),
+		IssueURL:      strings.TrimSpace(input.IssueURL),
+		ExpiresAt:     input.ExpiresAt,
+	}
+
+	switch {
+	case quarantine.QuarantinedBy == "":
+		return nil, fmt.Errorf("%w: quarantined_by is required", errors.ErrInvalidQuarantine)
+	case quarantine.Reason == "":
+		return nil, fmt.Errorf("%w: reason is required", errors.ErrInvalidQuarantine)
+	case quarantine.IssueURL != "" && !isHTTPURL(quarantine.IssueURL):
+		return nil, fmt.Errorf("%w: issue_url must be an http or https URL", errors.ErrInvalidQuarantine)
+	case quarantine.ExpiresAt != nil && !quarantine.ExpiresAt.After(time.Now()):
+		return nil, fmt.Errorf("%w: expires_at must be in the future", errors.ErrInvalidQuarantine)
+	}
+	return quarantine, nil
+}
+
+// isHTTPURL reports whether value is an absolute http or https URL
+func isHTTPURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
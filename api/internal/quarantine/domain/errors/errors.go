@@ -0,0 +1,11 @@
+package errors
+
+import "errors"
+
+var (
+	ErrQuarantineNotFound = errors.New("quarantine not found")
+	ErrTestCaseNotFound   = errors.New("test case not found")
+	ErrAlreadyQuarantined = errors.New("test case is already quarantined")
+	ErrInvalidQuarantine  = errors.New("invalid quarantine")
+	ErrInvalidProjectID   = errors.New("invalid project ID")
+)
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Quarantine takes a test case out of the pass rate metrics and the statuses of the
+// builds of its project while its executions are kept. It is active until it expires
+// or is lifted by deleting it.
+type Quarantine struct {
+	ID            int64      `json:"id"`
+	TestCaseID    int64      `json:"test_case_id"`
+	TestCaseName  string     `json:"test_case_name"`
+	ClassName     string     `json:"class_name"`
+	QuarantinedBy string     `json:"quarantined_by"`
+	Reason        string     `json:"reason"`
+	IssueURL      string     `json:"issue_url,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Active        bool       `json:"active"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// QuarantineInput describes a quarantine to create or update; the test case of an
+// existing quarantine does not change
+type QuarantineInput struct {
+	TestCaseID    int64      `json:"test_case_id"`
+	QuarantinedBy string     `json:"quarantined_by"`
+	Reason        string     `json:"reason"`
+	IssueURL      string     `json:"issue_url"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+}
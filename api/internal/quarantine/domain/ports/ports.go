@@ -0,0 +1,32 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/BennyEisner/test-results/internal/quarantine/domain/models"
+)
+
+// QuarantineRepository defines the interface for quarantine data access
+type QuarantineRepository interface {
+	// GetTestCaseProjectID returns the project of a test case, or 0 if it does not exist
+	GetTestCaseProjectID(ctx context.Context, testCaseID int64) (int64, error)
+	GetByID(ctx context.Context, id int64) (*models.Quarantine, error)
+	// GetByProjectID returns the quarantines of a project, the expired ones included if asked
+	GetByProjectID(ctx context.Context, projectID int64, includeExpired bool) ([]*models.Quarantine, error)
+	// Create quarantines a test case, replacing an expired quarantine of it, and reports
+	// false where the test case is already quarantined
+	Create(ctx context.Context, quarantine *models.Quarantine) (bool, error)
+	// Update changes a quarantine and reports whether it exists
+	Update(ctx context.Context, quarantine *models.Quarantine) (bool, error)
+	// Delete lifts a quarantine and reports whether it existed
+	Delete(ctx context.Context, id int64) (bool, error)
+}
+
+// QuarantineService defines the interface for quarantine business logic
+type QuarantineService interface {
+	GetQuarantine(ctx context.Context, id int64) (*models.Quarantine, error)
+	GetQuarantines(ctx context.Context, projectID int64, includeExpired bool) ([]*models.Quarantine, error)
+	CreateQuarantine(ctx context.Context, projectID int64, input *models.QuarantineInput) (*models.Quarantine, error)
+	UpdateQuarantine(ctx context.Context, id int64, input *models.QuarantineInput) (*models.Quarantine, error)
+	DeleteQuarantine(ctx context.Context, id int64) error
+}
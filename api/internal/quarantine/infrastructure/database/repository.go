@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/BennyEisner/test-results/internal/quarantine/domain/models"
+	"github.com/BennyEisner/test-results/internal/quarantine/domain/ports"
+)
+
+// SQLQuarantineRepository implements the QuarantineRepository interface
+type SQLQuarantineRepository struct {
+	db *sql.DB
+}
+
+// NewSQLQuarantineRepository creates a new SQL quarantine repository
+func NewSQLQuarantineRepository(db *sql.DB) ports.QuarantineRepository {
+	return &SQLQuarantineRepository{db: db}
+}
+
+// quarantineColumns are the columns a quarantine is scanned from by scanQuarantine
+const quarantineColumns = `q.id, q.test_case_id, tc.name, tc.classname, q.quarantined_by, q.reason,
+		COALESCE(q.issue_url, ''), q.expires_at, q.expires_at IS NULL OR q.expires_at > CURRENT_TIMESTAMP,
+		q.created_at, q.updated_at`
+
+// GetTestCaseProjectID returns the project of a test case, or 0 if the test case does not exist
+func (r *SQLQuarantineRepository) GetTestCaseProjectID(ctx context.Context, testCaseID int64) (int64, error) {
+	query := `SELECT ts.project_id FROM test_cases tc JOIN test_suites ts ON ts.id = tc.suite_id WHERE tc.id = $1`
+
+	var projectID int64
+	err := r.db.QueryRowContext(ctx, query, testCaseID).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return projectID, nil
+}
+
+// GetByID returns a quarantine, or nil if it does not exist
+func (r *SQLQuarantineRepository) GetByID(ctx context.Context, id int64) (*models.Quarantine, error) {
+	query := `SELECT ` + quarantineColumns + `
+			  FROM test_case_quarantines q
+			  JOIN test_cases tc ON tc.id = q.test_case_id
+			  WHERE q.id = $1`
+
+	quarantine, err := scanQuarantine(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quarantine: %w", err)
+	}
+	return quarantine, nil
+}
+
+// GetByProjectID returns the quarantines of the test cases of a project, newest first
+func (r *SQLQuarantineRepository) GetByProjectID(ctx context.Context, projectID int64, includeExpired bool) ([]*models.Quarantine, error) {
+	query := `SELECT ` + quarantineColumns + `
+			  FROM test_case_quarantines q
+			  JOIN test_cases tc ON tc.id = q.test_case_id
+			  JOIN test_suites ts ON ts.id = tc.suite_id
+			  WHERE ts.project_id = $1 AND ($2 OR q.expires_at IS NULL OR q.expires_at > CURRENT_TIMESTAMP)
+			  ORDER BY q.created_at DESC, q.id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, projectID, includeExpired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quarantines: %w", err)
+	}
+	defer rows.Close()
+
+	var quarantines []*models.Quarantine
+	for rows.Next() {
+		quarantine, err := scanQuarantine(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan quarantine: %w", err)
+		}
+		quarantines = append(quarantines, quarantine)
+	}
+	return quarantines, rows.Err()
+}
+
+// Create quarantines a test case and sets the ID of the quarantine. An expired quarantine
+// of the test case is replaced; an active one is left as it is.
+func (r *SQLQuarantineRepository) Create(ctx context.Context, quarantine *models.Quarantine) (bool, error) {
+	query := `INSERT INTO test_case_quarantines AS q (test_case_id, quarantined_by, reason, issue_url, expires_at)
+			  VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+			  ON CONFLICT (test_case_id) DO UPDATE
+			  SET quarantined_by = EXCLUDED.quarantined_by, reason = EXCLUDED.reason, issue_url = EXCLUDED.issue_url,
+			      expires_at = EXCLUDED.expires_at, created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			  WHERE q.expires_at IS NOT NULL AND q.expires_at <= CURRENT_TIMESTAMP
+			  RETURNING q.id`
+
+	err := r.db.QueryRowContext(ctx, query, quarantine.TestCaseID, quarantine.QuarantinedBy, quarantine.Reason,
+		quarantine.IssueURL, quarantine.ExpiresAt).Scan(&quarantine.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Update changes who quarantined a test case, why, the linked issue and the expiry
+func (r *SQLQuarantineRepository) Update(ctx context.Context, quarantine *models.Quarantine) (bool, error) {
+	query := `UPDATE test_case_quarantines
+			  SET quarantined_by = $2, reason = $3, issue_url = NULLIF($4, ''), expires_at = $5, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, quarantine.ID, quarantine.QuarantinedBy, quarantine.Reason,
+		quarantine.IssueURL, quarantine.ExpiresAt)
+	return affectedRow(result, err)
+}
+
+// Delete lifts a quarantine
+func (r *SQLQuarantineRepository) Delete(ctx context.Context, id int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM test_case_quarantines WHERE id = $1`, id)
+	return affectedRow(result, err)
+}
+
+// scanQuarantine scans a row of quarantineColumns
+func scanQuarantine(row interface{ Scan(...interface{}) error }) (*models.Quarantine, error) {
+	var quarantine models.Quarantine
+	var expiresAt sql.NullTime
+	err := row.Scan(&quarantine.ID, &quarantine.TestCaseID, &quarantine.TestCaseName, &quarantine.ClassName,
+		&quarantine.QuarantinedBy, &quarantine.Reason, &quarantine.IssueURL, &expiresAt, &quarantine.Active,
+		&quarantine.CreatedAt, &quarantine.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		quarantine.ExpiresAt = &expiresAt.Time
+	}
+	return &quarantine, nil
+}
+
+// affectedRow reports whether a statement changed a row
+func affectedRow(result sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
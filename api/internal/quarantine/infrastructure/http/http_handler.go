@@ -0,0 +1,206 @@
+package http
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"strconv"
+
+	"github.com/BennyEisner/test-results/internal/quarantine/domain/errors"
+	"github.com/BennyEisner/test-results/internal/quarantine/domain/models"
+	"github.com/BennyEisner/test-results/internal/quarantine/domain/ports"
+)
+
+// QuarantineHandler handles HTTP requests for test case quarantines
+type QuarantineHandler struct {
+	Service ports.QuarantineService
+}
+
+// NewQuarantineHandler creates a new QuarantineHandler
+func NewQuarantineHandler(service ports.QuarantineService) *QuarantineHandler {
+	return &QuarantineHandler{Service: service}
+}
+
+// GetQuarantines handles GET /projects/{projectID}/quarantines
+// @Summary List the quarantined test cases of a project
+// @Description List the active quarantines of a project, newest first. Quarantined test cases keep their executions but count in neither pass rate metrics nor build statuses.
+// @Tags quarantines
+// @Produce json
+// @Param projectID path int true "Project ID"
+// @Param include_expired query bool false "Include expired quarantines"
+// @Success 200 {array} models.Quarantine
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{projectID}/quarantines [get]
+func (h *QuarantineHandler) GetQuarantines(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(r.PathValue("projectID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid project ID")
+		return
+	}
+	includeExpired, _ := strconv.ParseBool(r.URL.Query().Get("include_expired"))
+
+	ctx := r.Context()
+	quarantines, err := h.Service.GetQuarantines(ctx, projectID, includeExpired)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, quarantines)
+}
+
+// CreateQuarantine handles POST /projects/{projectID}/quarantines
+// @Summary Quarantine a test case
+// @Description Quarantine a test case of a project: who quarantined it and why are required; the quarantine may link an issue and expire. A test case has one quarantine at a time.
+// @Tags quarantines
+// @Accept json
+// @Produce json
+// @Param projectID path int true "Project ID"
+// @Param quarantine body models.QuarantineInput true "Quarantine"
+// @Success 201 {object} models.Quarantine
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{projectID}/quarantines [post]
+func (h *QuarantineHandler) CreateQuarantine(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(r.PathValue("projectID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid project ID")
+		return
+	}
+
+	var input models.QuarantineInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	ctx := r.Context()
+	quarantine, err := h.Service.CreateQuarantine(ctx, projectID, &input)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, quarantine)
+}
+
+// GetQuarantine handles GET /quarantines/{id}
+// @Summary Get a quarantine
+// @Description Get a quarantine, whether active or expired
+// @Tags quarantines
+// @Produce json
+// @Param id path int true "Quarantine ID"
+// @Success 200 {object} models.Quarantine
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /quarantines/{id} [get]
+func (h *QuarantineHandler) GetQuarantine(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid quarantine ID")
+		return
+	}
+
+	ctx := r.Context()
+	quarantine, err := h.Service.GetQuarantine(ctx, id)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, quarantine)
+}
+
+// UpdateQuarantine handles PUT /quarantines/{id}
+// @Summary Update a quarantine
+// @Description Change who quarantined a test case, why, the linked issue and the expiry. The test case of a quarantine does not change.
+// @Tags quarantines
+// @Accept json
+// @Produce json
+// @Param id path int true "Quarantine ID"
+// @Param quarantine body models.QuarantineInput true "Quarantine"
+// @Success 200 {object} models.Quarantine
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /quarantines/{id} [put]
+func (h *QuarantineHandler) UpdateQuarantine(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid quarantine ID")
+		return
+	}
+
+	var input models.QuarantineInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	ctx := r.Context()
+	quarantine, err := h.Service.UpdateQuarantine(ctx, id, &input)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, quarantine)
+}
+
+// DeleteQuarantine handles DELETE /quarantines/{id}
+// @Summary Lift a quarantine
+// @Description Delete a quarantine; the test case counts in metrics and build statuses again
+// @Tags quarantines
+// @Param id path int true "Quarantine ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /quarantines/{id} [delete]
+func (h *QuarantineHandler) DeleteQuarantine(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid quarantine ID")
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.Service.DeleteQuarantine(ctx, id); err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func statusForError(err error) int {
+	switch {
+	case stderrors.Is(err, errors.ErrQuarantineNotFound),
+		stderrors.Is(err, errors.ErrTestCaseNotFound):
+		return http.StatusNotFound
+	case stderrors.Is(err, errors.ErrAlreadyQuarantined):
+		return http.StatusConflict
+	case stderrors.Is(err, errors.ErrInvalidQuarantine),
+		stderrors.Is(err, errors.ErrInvalidProjectID):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Helper functions for HTTP responses
+func respondWithError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondWithJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,244 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BennyEisner/test-results/internal/quarantine/application"
+	domain "github.com/BennyEisner/test-results/internal/quarantine/domain/errors"
+	"github.com/BennyEisner/test-results/internal/quarantine/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockQuarantineRepository struct {
+	mock.Mock
+}
+
+func (m *MockQuarantineRepository) GetTestCaseProjectID(ctx context.Context, testCaseID int64) (int64, error) {
+	args := m.Called(ctx, testCaseID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuarantineRepository) GetByID(ctx context.Context, id int64) (*models.Quarantine, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Quarantine), args.Error(1)
+}
+
+func (m *MockQuarantineRepository) GetByProjectID(ctx context.Context, projectID int64, includeExpired bool) ([]*models.Quarantine, error) {
+	args := m.Called(ctx, projectID, includeExpired)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Quarantine), args.Error(1)
+}
+
+func (m *MockQuarantineRepository) Create(ctx context.Context, quarantine *models.Quarantine) (bool, error) {
+	args := m.Called(ctx, quarantine)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockQuarantineRepository) Update(ctx context.Context, quarantine *models.Quarantine) (bool, error) {
+	args := m.Called(ctx, quarantine)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockQuarantineRepository) Delete(ctx context.Context, id int64) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestQuarantineService_GetQuarantines(t *testing.T) {
+	tests := []struct {
+		name          string
+		projectID     int64
+		setupMock     func(*MockQuarantineRepository)
+		expectedError error
+		expectedCount int
+	}{
+		{
+			name:      "success",
+			projectID: 1,
+			setupMock: func(repo *MockQuarantineRepository) {
+				repo.On("GetByProjectID", mock.Anything, int64(1), false).Return([]*models.Quarantine{{ID: 1}, {ID: 2}}, nil)
+			},
+			expectedCount: 2,
+		},
+		{
+			name:      "none",
+			projectID: 1,
+			setupMock: func(repo *MockQuarantineRepository) {
+				repo.On("GetByProjectID", mock.Anything, int64(1), false).Return(nil, nil)
+			},
+			expectedCount: 0,
+		},
+		{
+			name:          "invalid project id",
+			projectID:     0,
+			setupMock:     func(repo *MockQuarantineRepository) {},
+			expectedError: domain.ErrInvalidProjectID,
+		},
+		{
+			name:      "database error",
+			projectID: 1,
+			setupMock: func(repo *MockQuarantineRepository) {
+				repo.On("GetByProjectID", mock.Anything, int64(1), false).Return(nil, errors.New("database error"))
+			},
+			expectedError: errors.New("failed to get quarantines of project 1: database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuarantineRepository)
+			tt.setupMock(mockRepo)
+
+			service := application.NewQuarantineService(mockRepo)
+			quarantines, err := service.GetQuarantines(context.Background(), tt.projectID, false)
+
+			if tt.expectedError != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, quarantines)
+				assert.Len(t, quarantines, tt.expectedCount)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestQuarantineService_CreateQuarantine(t *testing.T) {
+	future := time.Now().Add(24 * time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name          string
+		input         *models.QuarantineInput
+		setupMock     func(*MockQuarantineRepository)
+		expectedError error
+	}{
+		{
+			name: "success",
+			input: &models.QuarantineInput{
+				TestCaseID: 7, QuarantinedBy: "alice", Reason: "flaky on CI",
+				IssueURL: "https://issues.example.com/42", ExpiresAt: &future,
+			},
+			setupMock: func(repo *MockQuarantineRepository) {
+				repo.On("GetTestCaseProjectID", mock.Anything, int64(7)).Return(int64(1), nil)
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(q *models.Quarantine) bool {
+					return q.TestCaseID == 7 && q.QuarantinedBy == "alice" && q.Reason == "flaky on CI"
+				})).Run(func(args mock.Arguments) {
+					args.Get(1).(*models.Quarantine).ID = 3
+				}).Return(true, nil)
+				repo.On("GetByID", mock.Anything, int64(3)).Return(&models.Quarantine{ID: 3, TestCaseID: 7, Active: true}, nil)
+			},
+		},
+		{
+			name:  "test case of another project",
+			input: &models.QuarantineInput{TestCaseID: 7, QuarantinedBy: "alice", Reason: "flaky"},
+			setupMock: func(repo *MockQuarantineRepository) {
+				repo.On("GetTestCaseProjectID", mock.Anything, int64(7)).Return(int64(2), nil)
+			},
+			expectedError: domain.ErrTestCaseNotFound,
+		},
+		{
+			name:  "already quarantined",
+			input: &models.QuarantineInput{TestCaseID: 7, QuarantinedBy: "alice", Reason: "flaky"},
+			setupMock: func(repo *MockQuarantineRepository) {
+				repo.On("GetTestCaseProjectID", mock.Anything, int64(7)).Return(int64(1), nil)
+				repo.On("Create", mock.Anything, mock.Anything).Return(false, nil)
+			},
+			expectedError: domain.ErrAlreadyQuarantined,
+		},
+		{
+			name:          "missing reason",
+			input:         &models.QuarantineInput{TestCaseID: 7, QuarantinedBy: "alice", Reason: "  "},
+			setupMock:     func(repo *MockQuarantineRepository) {},
+			expectedError: domain.ErrInvalidQuarantine,
+		},
+		{
+			name:          "issue is not a URL",
+			input:         &models.QuarantineInput{TestCaseID: 7, QuarantinedBy: "alice", Reason: "flaky", IssueURL: "JIRA-42"},
+			setupMock:     func(repo *MockQuarantineRepository) {},
+			expectedError: domain.ErrInvalidQuarantine,
+		},
+		{
+			name:          "expiry in the past",
+			input:         &models.QuarantineInput{TestCaseID: 7, QuarantinedBy: "alice", Reason: "flaky", ExpiresAt: &past},
+			setupMock:     func(repo *MockQuarantineRepository) {},
+			expectedError: domain.ErrInvalidQuarantine,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuarantineRepository)
+			tt.setupMock(mockRepo)
+
+			service := application.NewQuarantineService(mockRepo)
+			quarantine, err := service.CreateQuarantine(context.Background(), 1, tt.input)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(3), quarantine.ID)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestQuarantineService_UpdateQuarantine(t *testing.T) {
+	t.Run("not found", func(t *testing.T) {
+		mockRepo := new(MockQuarantineRepository)
+		mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(q *models.Quarantine) bool {
+			return q.ID == 9
+		})).Return(false, nil)
+
+		service := application.NewQuarantineService(mockRepo)
+		_, err := service.UpdateQuarantine(context.Background(), 9, &models.QuarantineInput{QuarantinedBy: "bob", Reason: "still flaky"})
+
+		assert.ErrorIs(t, err, domain.ErrQuarantineNotFound)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestQuarantineService_DeleteQuarantine(t *testing.T) {
+	tests := []struct {
+		name          string
+		deleted       bool
+		expectedError error
+	}{
+		{name: "success", deleted: true},
+		{name: "not found", deleted: false, expectedError: domain.ErrQuarantineNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuarantineRepository)
+			mockRepo.On("Delete", mock.Anything, int64(4)).Return(tt.deleted, nil)
+
+			service := application.NewQuarantineService(mockRepo)
+			err := service.DeleteQuarantine(context.Background(), 4)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package application
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/BennyEisner/test-results/internal/search/domain/models"
 	"github.com/BennyEisner/test-results/internal/search/domain/ports"
@@ -17,12 +18,19 @@ func NewSearchService(repo ports.SearchRepository) ports.SearchService {
 	return &SearchService{repo: repo}
 }
 
-func (s *SearchService) Search(ctx context.Context, query string) ([]*models.SearchResult, error) {
+func (s *SearchService) Search(ctx context.Context, query string, tags []string) ([]*models.SearchResult, error) {
 	if query == "" {
 		return []*models.SearchResult{}, nil
 	}
 
-	results, err := s.repo.Search(ctx, query)
+	var names []string
+	for _, tag := range tags {
+		if name := strings.TrimPrefix(strings.TrimSpace(tag), "@"); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	results, err := s.repo.Search(ctx, query, names)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search: %w", err)
 	}
@@ -8,10 +8,12 @@ import (
 
 // SearchRepository defines the interface for search data access
 type SearchRepository interface {
-	Search(ctx context.Context, query string) ([]*models.SearchResult, error)
+	// Search finds the entities whose name matches query; when tags are given, only the
+	// builds carrying all of them are found
+	Search(ctx context.Context, query string, tags []string) ([]*models.SearchResult, error)
 }
 
 // SearchService defines the interface for search business logic
 type SearchService interface {
-	Search(ctx context.Context, query string) ([]*models.SearchResult, error)
+	Search(ctx context.Context, query string, tags []string) ([]*models.SearchResult, error)
 }
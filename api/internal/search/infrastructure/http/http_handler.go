@@ -19,11 +19,12 @@ func NewSearchHandler(service ports.SearchService) *SearchHandler {
 
 // Search handles GET /search
 // @Summary Search across all entities
-// @Description Search for projects, test suites, builds, and other entities by name
+// @Description Search for projects, test suites, builds, and other entities by name. Tags narrow the results to the builds carrying all of them.
 // @Tags search
 // @Accept json
 // @Produce json
 // @Param q query string true "Search query"
+// @Param tag query []string false "Build tag; repeat to require several" collectionFormat(multi)
 // @Success 200 {array} models.SearchResult
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -35,7 +36,7 @@ func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	results, err := h.Service.Search(r.Context(), query)
+	results, err := h.Service.Search(r.Context(), query, r.URL.Query()["tag"])
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -7,6 +7,7 @@ import (
 
 	"github.com/BennyEisner/test-results/internal/search/domain/models"
 	"github.com/BennyEisner/test-results/internal/search/domain/ports"
+	"github.com/lib/pq"
 )
 
 type SQLSearchRepository struct {
@@ -17,23 +18,29 @@ func NewSQLSearchRepository(db *sql.DB) ports.SearchRepository {
 	return &SQLSearchRepository{db: db}
 }
 
-func (r *SQLSearchRepository) Search(ctx context.Context, query string) ([]*models.SearchResult, error) {
+// Search finds projects, test suites and builds by name. Projects and test suites carry
+// no tags, so only builds are found when tags are given.
+func (r *SQLSearchRepository) Search(ctx context.Context, query string, tags []string) ([]*models.SearchResult, error) {
 	searchQuery := `
 		SELECT 'project' as type, p.id, p.name, '/projects/' || p.id as url, NULL::integer as project_id, NULL::integer as suite_id
 		FROM projects p
-		WHERE p.name ILIKE $1
+		WHERE p.name ILIKE $1 AND cardinality($2::text[]) = 0
 		UNION ALL
 		SELECT 'test_suite' as type, ts.id, ts.name, '/projects/' || ts.project_id || '/suites/' || ts.id as url, ts.project_id, NULL::integer as suite_id
 		FROM test_suites ts
-		WHERE ts.name ILIKE $1
+		WHERE ts.name ILIKE $1 AND cardinality($2::text[]) = 0
 		UNION ALL
 		SELECT 'build' as type, b.id, b.build_number as name, '/projects/' || ts.project_id || '/suites/' || b.test_suite_id || '/builds/' || b.id as url, ts.project_id, b.test_suite_id
 		FROM builds b
 		JOIN test_suites ts ON b.test_suite_id = ts.id
 		WHERE b.build_number ILIKE $1
+		AND ARRAY(SELECT t.name FROM build_tags t WHERE t.build_id = b.id) @> $2::text[]
 	`
 
-	rows, err := r.db.QueryContext(ctx, searchQuery, fmt.Sprintf("%%%s%%", query))
+	if tags == nil {
+		tags = []string{}
+	}
+	rows, err := r.db.QueryContext(ctx, searchQuery, fmt.Sprintf("%%%s%%", query), pq.Array(tags))
 	if err != nil {
 		return nil, err
 	}
@@ -19,8 +19,8 @@ type MockSearchService struct {
 	mock.Mock
 }
 
-func (m *MockSearchService) Search(ctx context.Context, query string) ([]*models.SearchResult, error) {
-	args := m.Called(ctx, query)
+func (m *MockSearchService) Search(ctx context.Context, query string, tags []string) ([]*models.SearchResult, error) {
+	args := m.Called(ctx, query, tags)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -79,7 +79,7 @@ func TestSearchHandler_Search(t *testing.T) {
 
 			// Set up mock expectations (only for non-empty queries)
 			if tt.query != "" {
-				mockService.On("Search", mock.Anything, tt.query).Return(tt.mockResults, tt.mockError)
+				mockService.On("Search", mock.Anything, tt.query, []string(nil)).Return(tt.mockResults, tt.mockError)
 			}
 
 			// Call handler
@@ -108,6 +108,22 @@ func TestSearchHandler_Search(t *testing.T) {
 	}
 }
 
+func TestSearchHandler_Search_Tags(t *testing.T) {
+	mockService := new(MockSearchService)
+	handler := httphandler.NewSearchHandler(mockService)
+	results := []*models.SearchResult{{Type: "build", ID: 3, Name: "42", URL: "/projects/1/suites/2/builds/3"}}
+
+	req := httptest.NewRequest("GET", "/api/search?q=42&tag=smoke&tag=nightly", nil)
+	w := httptest.NewRecorder()
+
+	mockService.On("Search", mock.Anything, "42", []string{"smoke", "nightly"}).Return(results, nil)
+
+	handler.Search(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
 func TestSearchHandler_Search_NoQuery(t *testing.T) {
 	mockService := new(MockSearchService)
 	handler := httphandler.NewSearchHandler(mockService)
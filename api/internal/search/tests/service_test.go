@@ -16,8 +16,8 @@ type MockSearchRepository struct {
 	mock.Mock
 }
 
-func (m *MockSearchRepository) Search(ctx context.Context, query string) ([]*models.SearchResult, error) {
-	args := m.Called(ctx, query)
+func (m *MockSearchRepository) Search(ctx context.Context, query string, tags []string) ([]*models.SearchResult, error) {
+	args := m.Called(ctx, query, tags)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -28,6 +28,8 @@ func TestSearchService_Search(t *testing.T) {
 	tests := []struct {
 		name            string
 		query           string
+		tags            []string
+		expectedTags    []string
 		mockResults     []*models.SearchResult
 		mockError       error
 		expectedResults []*models.SearchResult
@@ -47,6 +49,18 @@ func TestSearchService_Search(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			name:         "tags are trimmed",
+			query:        "42",
+			tags:         []string{" smoke", "@nightly", " "},
+			expectedTags: []string{"smoke", "nightly"},
+			mockResults: []*models.SearchResult{
+				{Type: "build", ID: 3, Name: "42", URL: "/projects/1/suites/2/builds/3"},
+			},
+			expectedResults: []*models.SearchResult{
+				{Type: "build", ID: 3, Name: "42", URL: "/projects/1/suites/2/builds/3"},
+			},
+		},
 		{
 			name:            "empty query returns empty results",
 			query:           "",
@@ -71,10 +85,10 @@ func TestSearchService_Search(t *testing.T) {
 			service := application.NewSearchService(mockRepo)
 
 			if tt.query != "" {
-				mockRepo.On("Search", mock.Anything, tt.query).Return(tt.mockResults, tt.mockError)
+				mockRepo.On("Search", mock.Anything, tt.query, tt.expectedTags).Return(tt.mockResults, tt.mockError)
 			}
 
-			results, err := service.Search(context.Background(), tt.query)
+			results, err := service.Search(context.Background(), tt.query, tt.tags)
 
 			if tt.expectedError != nil {
 				assert.Error(t, err)
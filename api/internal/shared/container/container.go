@@ -1,10 +1,17 @@
 package container
 
 import (
+	"context"
 	"database/sql"
 	"log/slog"
 	"net/http"
+	"time"
 
+	attachmentApp "github.com/BennyEisner/test-results/internal/attachment/application"
+	attachmentModels "github.com/BennyEisner/test-results/internal/attachment/domain/models"
+	attachmentPorts "github.com/BennyEisner/test-results/internal/attachment/domain/ports"
+	attachmentDB "github.com/BennyEisner/test-results/internal/attachment/infrastructure/database"
+	attachmentHTTP "github.com/BennyEisner/test-results/internal/attachment/infrastructure/http"
 	authApp "github.com/BennyEisner/test-results/internal/auth/application"
 	authDB "github.com/BennyEisner/test-results/internal/auth/infrastructure/database"
 	authHTTP "github.com/BennyEisner/test-results/internal/auth/infrastructure/http"
@@ -20,16 +27,29 @@ import (
 	failureApp "github.com/BennyEisner/test-results/internal/failure/application"
 	failureDB "github.com/BennyEisner/test-results/internal/failure/infrastructure/database"
 	failureHTTP "github.com/BennyEisner/test-results/internal/failure/infrastructure/http"
+	junitImportApp "github.com/BennyEisner/test-results/internal/junit_import/application"
+	junitImportModels "github.com/BennyEisner/test-results/internal/junit_import/domain"
+	junitImportHTTP "github.com/BennyEisner/test-results/internal/junit_import/infrastructure"
+	junitImportDB "github.com/BennyEisner/test-results/internal/junit_import/infrastructure/database"
 	projectApp "github.com/BennyEisner/test-results/internal/project/application"
 	projectDB "github.com/BennyEisner/test-results/internal/project/infrastructure/database"
 	projectHTTP "github.com/BennyEisner/test-results/internal/project/infrastructure/http"
+	quarantineApp "github.com/BennyEisner/test-results/internal/quarantine/application"
+	quarantineDB "github.com/BennyEisner/test-results/internal/quarantine/infrastructure/database"
+	quarantineHTTP "github.com/BennyEisner/test-results/internal/quarantine/infrastructure/http"
 	searchApp "github.com/BennyEisner/test-results/internal/search/application"
 	searchDB "github.com/BennyEisner/test-results/internal/search/infrastructure"
 	searchHTTP "github.com/BennyEisner/test-results/internal/search/infrastructure/http"
 	"github.com/BennyEisner/test-results/internal/shared/middleware"
+	tagApp "github.com/BennyEisner/test-results/internal/tag/application"
+	tagDB "github.com/BennyEisner/test-results/internal/tag/infrastructure/database"
+	tagHTTP "github.com/BennyEisner/test-results/internal/tag/infrastructure/http"
 	testCaseApp "github.com/BennyEisner/test-results/internal/test_case/application"
 	testCaseDB "github.com/BennyEisner/test-results/internal/test_case/infrastructure/database"
 	testCaseHTTP "github.com/BennyEisner/test-results/internal/test_case/infrastructure/http"
+	testIdentityApp "github.com/BennyEisner/test-results/internal/test_identity/application"
+	testIdentityDB "github.com/BennyEisner/test-results/internal/test_identity/infrastructure/database"
+	testIdentityHTTP "github.com/BennyEisner/test-results/internal/test_identity/infrastructure/http"
 	testSuiteApp "github.com/BennyEisner/test-results/internal/test_suite/application"
 	testSuiteDB "github.com/BennyEisner/test-results/internal/test_suite/infrastructure/database"
 	testSuiteHTTP "github.com/BennyEisner/test-results/internal/test_suite/infrastructure/http"
@@ -42,8 +62,29 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-// NewRouter creates and configures the HTTP router with all handlers
-func NewRouter(db *sql.DB, frontendURL string) http.Handler {
+// ImportConfig holds the settings of report imports
+type ImportConfig struct {
+	MemoryLimit int64         // memory ceiling of one import in bytes; 0 selects the default
+	Workers     int           // imports run at the same time; 0 selects the default
+	QueueSize   int           // imports waiting for a worker; 0 selects the default
+	MaxLogSize  int64         // size of the logs stored per execution in bytes; 0 selects the default
+	JobTimeout  time.Duration // time an import may run before it is stopped; 0 selects the default
+}
+
+// AttachmentConfig holds the settings of attachments
+type AttachmentConfig struct {
+	Store   attachmentPorts.BlobStore // where the content of attachments is kept
+	Quota   int64                     // default total size of the attachments of a project in bytes; 0 selects the default
+	MaxSize int64                     // size limit of one attachment in bytes; 0 selects the default
+}
+
+// NewRouter creates and configures the HTTP router with all handlers. The returned func
+// stops the background work of the handlers once the server no longer serves requests,
+// waiting for the running imports to finish until ctx is done.
+func NewRouter(db *sql.DB, frontendURL string, importConfig ImportConfig, attachmentConfig AttachmentConfig) (http.Handler, func(ctx context.Context) error) {
+	importLimits := junitImportModels.NewImportLimits(importConfig.MemoryLimit, importConfig.MaxLogSize)
+	attachmentLimits := attachmentModels.NewLimits(attachmentConfig.Quota, attachmentConfig.MaxSize)
+
 	mux := http.NewServeMux()
 
 	// --- Standard Kubernetes health endpoints ---
@@ -121,6 +162,12 @@ func NewRouter(db *sql.DB, frontendURL string) http.Handler {
 	testCaseRepo := testCaseDB.NewSQLTestCaseRepository(db)
 	userConfigRepo := userConfigDB.NewSQLUserConfigRepository(db)
 	searchRepo := searchDB.NewSQLSearchRepository(db)
+	junitImportRepo := junitImportDB.NewSQLJUnitImportRepository(db, importLimits)
+	importJobRepo := junitImportDB.NewSQLImportJobRepository(db)
+	attachmentRepo := attachmentDB.NewSQLAttachmentRepository(db)
+	tagRepo := tagDB.NewSQLTagRepository(db)
+	testIdentityRepo := testIdentityDB.NewSQLTestIdentityRepository(db)
+	quarantineRepo := quarantineDB.NewSQLQuarantineRepository(db)
 
 	// Wire up services
 	authService := authApp.NewAuthService(authRepo)
@@ -132,8 +179,29 @@ func NewRouter(db *sql.DB, frontendURL string) http.Handler {
 	testSuiteService := testSuiteApp.NewTestSuiteService(testSuiteRepo)
 	testCaseService := testCaseApp.NewTestCaseService(testCaseRepo)
 	userConfigService := userConfigApp.NewUserConfigService(userConfigRepo)
-	dashboardService := dashboardApp.NewDashboardService(buildRepo, buildExecRepo)
+	dashboardService := dashboardApp.NewDashboardService(buildRepo, buildExecRepo, buildExecService)
 	searchService := searchApp.NewSearchService(searchRepo)
+	junitImportService := junitImportApp.NewJUnitImportService(junitImportRepo)
+	importJobService := junitImportApp.NewImportJobService(importJobRepo, importConfig.Workers, importConfig.QueueSize, importConfig.JobTimeout)
+	attachmentService := attachmentApp.NewAttachmentService(attachmentRepo, attachmentConfig.Store, attachmentLimits)
+	tagService := tagApp.NewTagService(tagRepo)
+	testIdentityService := testIdentityApp.NewTestIdentityService(testIdentityRepo)
+	quarantineService := quarantineApp.NewQuarantineService(quarantineRepo)
+
+	// The imports queued or running when the server last stopped were lost with it
+	if failed, err := importJobService.FailInterruptedJobs(context.Background()); err != nil {
+		slog.Error("failed to fail interrupted import jobs", "error", err)
+	} else if failed > 0 {
+		slog.Warn("failed import jobs interrupted by a restart", "jobs", failed)
+	}
+
+	// Finalize the sharded builds whose shards did not all arrive before their deadline
+	finalizerCtx, stopFinalizer := context.WithCancel(context.Background())
+	go buildApp.RunShardFinalizer(finalizerCtx, buildService, time.Minute)
+	shutdown := func(ctx context.Context) error {
+		stopFinalizer()
+		return importJobService.Shutdown(ctx)
+	}
 
 	// Wire up HTTP handlers
 	authHandler := authHTTP.NewAuthHandler(authService, frontendURL)
@@ -147,6 +215,11 @@ func NewRouter(db *sql.DB, frontendURL string) http.Handler {
 	userConfigHandler := userConfigHTTP.NewUserConfigHandler(userConfigService)
 	dashboardHandler := dashboardHTTP.NewDashboardHandler(dashboardService)
 	searchHandler := searchHTTP.NewSearchHandler(searchService)
+	junitImportHandler := junitImportHTTP.NewJUnitImportHandler(junitImportService, importJobService, importLimits, attachmentService, testIdentityService)
+	attachmentHandler := attachmentHTTP.NewAttachmentHandler(attachmentService)
+	tagHandler := tagHTTP.NewTagHandler(tagService)
+	testIdentityHandler := testIdentityHTTP.NewTestIdentityHandler(testIdentityService)
+	quarantineHandler := quarantineHTTP.NewQuarantineHandler(quarantineService)
 
 
 	// Wire up middleware
@@ -155,7 +228,7 @@ func NewRouter(db *sql.DB, frontendURL string) http.Handler {
 	// --- API subrouter ---
 	apiMux := http.NewServeMux()
 	registerRoutes(apiMux, projectHandler, buildHandler,
-	  buildExecHandler, failureHandler, userHandler, testSuiteHandler, testCaseHandler, userConfigHandler, authMiddleware, dashboardHandler, searchHandler)
+	  buildExecHandler, failureHandler, userHandler, testSuiteHandler, testCaseHandler, userConfigHandler, authMiddleware, dashboardHandler, searchHandler, junitImportHandler, attachmentHandler, tagHandler, testIdentityHandler, quarantineHandler)
 
 	mux.Handle("/api/", http.StripPrefix("/api", apiMux))
 
@@ -165,7 +238,7 @@ func NewRouter(db *sql.DB, frontendURL string) http.Handler {
 	// Apply middleware
 	logger := slog.Default()
 	corsMiddleware := middleware.Cors(frontendURL)
-	return corsMiddleware(middleware.LoggingMiddleware(logger)(mux))
+	return corsMiddleware(middleware.LoggingMiddleware(logger)(mux)), shutdown
 }
 
 // registerRoutes registers all HTTP routes
@@ -181,6 +254,11 @@ func registerRoutes(mux *http.ServeMux,
 	authMiddleware *authMiddleware.AuthMiddleware,
 	dashboardHandler *dashboardHTTP.DashboardHandler,
 	searchHandler *searchHTTP.SearchHandler,
+	junitImportHandler *junitImportHTTP.JUnitImportHandler,
+	attachmentHandler *attachmentHTTP.AttachmentHandler,
+	tagHandler *tagHTTP.TagHandler,
+	testIdentityHandler *testIdentityHTTP.TestIdentityHandler,
+	quarantineHandler *quarantineHTTP.QuarantineHandler,
 ) {
 	// Search routes
 	mux.Handle("GET /search", authMiddleware.RequireAuth(http.HandlerFunc(searchHandler.Search)))
@@ -216,10 +294,21 @@ func registerRoutes(mux *http.ServeMux,
 	mux.HandleFunc("POST /builds", buildHandler.CreateBuild)
 	mux.HandleFunc("PUT /builds/{id}", buildHandler.UpdateBuild)
 	mux.HandleFunc("DELETE /builds/{id}", buildHandler.DeleteBuild)
+	mux.HandleFunc("POST /builds/{id}/finalize", buildHandler.FinalizeBuild)
+	mux.HandleFunc("POST /builds/{id}/start", buildHandler.StartBuild)
+	mux.HandleFunc("POST /builds/{id}/finish", buildHandler.FinishBuild)
+
+	// JUnit import routes
+	mux.HandleFunc("POST /projects/{projectID}/suites/{suiteID}/junit_imports", junitImportHandler.ProcessJUnitData)
+	mux.HandleFunc("GET /imports/{id}", junitImportHandler.GetImportJob)
 
 	// Build Test Case Execution routes
 	mux.HandleFunc("GET /builds/{buildID}/executions", buildExecHandler.GetExecutionsByBuildID)
+	mux.HandleFunc("GET /builds/{buildID}/executions/grouped", buildExecHandler.GetExecutionGroups)
+	mux.HandleFunc("GET /builds/{id}/report.ctrf.json", buildExecHandler.GetCTRFReport)
+	mux.HandleFunc("GET /projects/{projectID}/flaky-tests", buildExecHandler.GetFlakyTests)
 	mux.HandleFunc("GET /executions/{id}", buildExecHandler.GetExecutionByID)
+	mux.HandleFunc("GET /executions/{id}/logs", buildExecHandler.GetExecutionLog)
 	mux.HandleFunc("POST /builds/{buildID}/executions", buildExecHandler.CreateExecution)
 	mux.HandleFunc("PUT /executions/{id}", buildExecHandler.UpdateExecution)
 	mux.HandleFunc("DELETE /executions/{id}", buildExecHandler.DeleteExecution)
@@ -231,6 +320,38 @@ func registerRoutes(mux *http.ServeMux,
 	mux.HandleFunc("PUT /failures/{id}", failureHandler.UpdateFailure)
 	mux.HandleFunc("DELETE /failures/{id}", failureHandler.DeleteFailure)
 
+	// Attachment routes
+	mux.HandleFunc("POST /executions/{id}/attachments", attachmentHandler.UploadAttachment)
+	mux.HandleFunc("GET /executions/{id}/attachments", attachmentHandler.GetAttachmentsByExecution)
+	mux.HandleFunc("GET /attachments/{id}", attachmentHandler.DownloadAttachment)
+	mux.HandleFunc("DELETE /attachments/{id}", attachmentHandler.DeleteAttachment)
+	mux.HandleFunc("GET /projects/{projectID}/attachment_quota", attachmentHandler.GetQuota)
+	mux.HandleFunc("PUT /projects/{projectID}/attachment_quota", attachmentHandler.SetQuota)
+
+	// Tag routes
+	mux.HandleFunc("GET /projects/{projectID}/tags", tagHandler.GetProjectTags)
+	mux.HandleFunc("GET /builds/{id}/tags", tagHandler.GetBuildTags)
+	mux.HandleFunc("POST /builds/{id}/tags", tagHandler.AddBuildTags)
+	mux.HandleFunc("DELETE /builds/{id}/tags/{name}", tagHandler.RemoveBuildTag)
+	mux.HandleFunc("GET /test-cases/{id}/tags", tagHandler.GetTestCaseTags)
+	mux.HandleFunc("POST /test-cases/{id}/tags", tagHandler.AddTestCaseTags)
+	mux.HandleFunc("DELETE /test-cases/{id}/tags/{name}", tagHandler.RemoveTestCaseTag)
+
+	// Test identity routes
+	mux.HandleFunc("GET /projects/{projectID}/test_identity", testIdentityHandler.GetIdentity)
+	mux.HandleFunc("PUT /projects/{projectID}/test_identity", testIdentityHandler.SetIdentity)
+	mux.HandleFunc("POST /test-cases/merge", testIdentityHandler.MergeTestCases)
+	mux.HandleFunc("GET /projects/{projectID}/rename_suggestions", testIdentityHandler.GetRenameSuggestions)
+	mux.HandleFunc("POST /rename_suggestions/{id}/accept", testIdentityHandler.AcceptRenameSuggestion)
+	mux.HandleFunc("DELETE /rename_suggestions/{id}", testIdentityHandler.DismissRenameSuggestion)
+
+	// Quarantine routes
+	mux.HandleFunc("GET /projects/{projectID}/quarantines", quarantineHandler.GetQuarantines)
+	mux.HandleFunc("POST /projects/{projectID}/quarantines", quarantineHandler.CreateQuarantine)
+	mux.HandleFunc("GET /quarantines/{id}", quarantineHandler.GetQuarantine)
+	mux.HandleFunc("PUT /quarantines/{id}", quarantineHandler.UpdateQuarantine)
+	mux.HandleFunc("DELETE /quarantines/{id}", quarantineHandler.DeleteQuarantine)
+
 	// User routes
 	mux.HandleFunc("GET /user/{id}", userHandler.GetUserByID)
 	mux.HandleFunc("GET /user/username/{username}", userHandler.GetUserByUsername)
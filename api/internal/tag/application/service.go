@@ -0,0 +1,124 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/BennyEisner/test-results/internal/tag/domain/errors"
+	"github.com/BennyEisner/test-results/internal/tag/domain/models"
+	"github.com/BennyEisner/test-results/internal/tag/domain/ports"
+)
+
+// TagService implements the TagService interface
+type TagService struct {
+	repo ports.TagRepository
+}
+
+func NewTagService(repo ports.TagRepository) ports.TagService {
+	return &TagService{repo: repo}
+}
+
+// GetTags returns the tags of a build or test case
+func (s *TagService) GetTags(ctx context.Context, target models.Target, id int64) ([]string, error) {
+	if err := s.checkTarget(ctx, target, id); err != nil {
+		return nil, err
+	}
+	tags, err := s.repo.GetTags(ctx, target, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	return tags, nil
+}
+
+// AddTags tags a build or test case and returns all of its tags
+func (s *TagService) AddTags(ctx context.Context, target models.Target, id int64, names []string) ([]string, error) {
+	names, err := normalizeTags(names)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTarget(ctx, target, id); err != nil {
+		return nil, err
+	}
+	if err := s.repo.AddTags(ctx, target, id, names); err != nil {
+		return nil, fmt.Errorf("failed to add tags: %w", err)
+	}
+	return s.GetTags(ctx, target, id)
+}
+
+// RemoveTag removes a tag from a build or test case
+func (s *TagService) RemoveTag(ctx context.Context, target models.Target, id int64, name string) error {
+	if err := s.checkTarget(ctx, target, id); err != nil {
+		return err
+	}
+	removed, err := s.repo.RemoveTag(ctx, target, id, normalizeTag(name))
+	if err != nil {
+		return fmt.Errorf("failed to remove tag: %w", err)
+	}
+	if !removed {
+		return errors.ErrTagNotFound
+	}
+	return nil
+}
+
+// GetProjectTags returns the tags used in a project with the number of builds and test cases carrying them
+func (s *TagService) GetProjectTags(ctx context.Context, projectID int64) ([]*models.Tag, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+	tags, err := s.repo.GetProjectTags(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags of project: %w", err)
+	}
+	if tags == nil {
+		tags = []*models.Tag{}
+	}
+	return tags, nil
+}
+
+// checkTarget fails with ErrBuildNotFound or ErrTestCaseNotFound unless the build or test case exists
+func (s *TagService) checkTarget(ctx context.Context, target models.Target, id int64) error {
+	notFound := errors.ErrBuildNotFound
+	if target == models.TargetTestCase {
+		notFound = errors.ErrTestCaseNotFound
+	}
+	if id <= 0 {
+		return notFound
+	}
+	exists, err := s.repo.TargetExists(ctx, target, id)
+	if err != nil {
+		return fmt.Errorf("failed to look up %s %d: %w", target, id, err)
+	}
+	if !exists {
+		return notFound
+	}
+	return nil
+}
+
+// normalizeTags trims the tags to add, drops repeated ones and checks the rest
+func normalizeTags(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: no tags given", errors.ErrInvalidTag)
+	}
+	var normalized []string
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = normalizeTag(name)
+		if name == "" || len(name) > models.MaxTagLength || strings.Contains(name, ",") {
+			return nil, fmt.Errorf("%w: tags must be 1 to %d characters without commas", errors.ErrInvalidTag, models.MaxTagLength)
+		}
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeTag trims a tag and the @ of a Cucumber-style tag
+func normalizeTag(name string) string {
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(name), "@"))
+}
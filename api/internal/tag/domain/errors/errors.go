@@ -0,0 +1,10 @@
+package errors
+
+import "errors"
+
+var (
+	ErrTagNotFound      = errors.New("tag not found")
+	ErrBuildNotFound    = errors.New("build not found")
+	ErrTestCaseNotFound = errors.New("test case not found")
+	ErrInvalidTag       = errors.New("invalid tag")
+)
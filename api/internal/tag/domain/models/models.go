@@ -0,0 +1,20 @@
+package models
+
+// Target is the kind of entity a tag is attached to
+type Target string
+
+// Tag targets
+const (
+	TargetBuild    Target = "build"
+	TargetTestCase Target = "test_case"
+)
+
+// MaxTagLength bounds the name of a tag
+const MaxTagLength = 100
+
+// Tag is a label used in a project, with the number of builds and test cases carrying it
+type Tag struct {
+	Name      string `json:"name"`
+	Builds    int64  `json:"builds"`
+	TestCases int64  `json:"test_cases"`
+}
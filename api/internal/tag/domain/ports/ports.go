@@ -0,0 +1,31 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/BennyEisner/test-results/internal/tag/domain/models"
+)
+
+// TagRepository defines the interface for tag data access. Tags are read and written
+// for one build or test case, the target identified by its ID.
+type TagRepository interface {
+	// TargetExists reports whether the build or test case exists
+	TargetExists(ctx context.Context, target models.Target, id int64) (bool, error)
+	// GetTags returns the tags of a build or test case in alphabetical order
+	GetTags(ctx context.Context, target models.Target, id int64) ([]string, error)
+	// AddTags tags a build or test case; tags it already carries are ignored
+	AddTags(ctx context.Context, target models.Target, id int64, names []string) error
+	// RemoveTag removes a tag from a build or test case and reports whether it carried it
+	RemoveTag(ctx context.Context, target models.Target, id int64, name string) (bool, error)
+	// GetProjectTags returns the tags used by the builds and test cases of a project
+	GetProjectTags(ctx context.Context, projectID int64) ([]*models.Tag, error)
+}
+
+// TagService defines the interface for tag business logic
+type TagService interface {
+	GetTags(ctx context.Context, target models.Target, id int64) ([]string, error)
+	// AddTags tags a build or test case and returns all of its tags
+	AddTags(ctx context.Context, target models.Target, id int64, names []string) ([]string, error)
+	RemoveTag(ctx context.Context, target models.Target, id int64, name string) error
+	GetProjectTags(ctx context.Context, projectID int64) ([]*models.Tag, error)
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/BennyEisner/test-results/internal/tag/domain/models"
+	"github.com/BennyEisner/test-results/internal/tag/domain/ports"
+	"github.com/lib/pq"
+)
+
+// tagTable is the table holding the tags of a target, the column naming the tagged
+// entity in it and the table of the entities
+type tagTable struct {
+	table  string
+	column string
+	owner  string
+}
+
+var tagTables = map[models.Target]tagTable{
+	models.TargetBuild:    {table: "build_tags", column: "build_id", owner: "builds"},
+	models.TargetTestCase: {table: "test_case_tags", column: "test_case_id", owner: "test_cases"},
+}
+
+// SQLTagRepository implements the TagRepository interface
+type SQLTagRepository struct {
+	db *sql.DB
+}
+
+// NewSQLTagRepository creates a new SQL tag repository
+func NewSQLTagRepository(db *sql.DB) ports.TagRepository {
+	return &SQLTagRepository{db: db}
+}
+
+func tableOf(target models.Target) (tagTable, error) {
+	table, ok := tagTables[target]
+	if !ok {
+		return tagTable{}, fmt.Errorf("unknown tag target: %s", target)
+	}
+	return table, nil
+}
+
+// TargetExists reports whether the build or test case exists
+func (r *SQLTagRepository) TargetExists(ctx context.Context, target models.Target, id int64) (bool, error) {
+	table, err := tableOf(target)
+	if err != nil {
+		return false, err
+	}
+	query := `SELECT EXISTS (SELECT 1 FROM ` + table.owner + ` WHERE id = $1)`
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up %s: %w", target, err)
+	}
+	return exists, nil
+}
+
+// GetTags returns the tags of a build or test case in alphabetical order
+func (r *SQLTagRepository) GetTags(ctx context.Context, target models.Target, id int64) ([]string, error) {
+	table, err := tableOf(target)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT name FROM ` + table.table + ` WHERE ` + table.column + ` = $1 ORDER BY name`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, name)
+	}
+	return tags, rows.Err()
+}
+
+// AddTags tags a build or test case, ignoring the tags it already carries
+func (r *SQLTagRepository) AddTags(ctx context.Context, target models.Target, id int64, names []string) error {
+	table, err := tableOf(target)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO ` + table.table + ` (` + table.column + `, name)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT DO NOTHING`
+
+	if _, err := r.db.ExecContext(ctx, query, id, pq.Array(names)); err != nil {
+		return fmt.Errorf("failed to add tags: %w", err)
+	}
+	return nil
+}
+
+// RemoveTag removes a tag from a build or test case and reports whether it carried it
+func (r *SQLTagRepository) RemoveTag(ctx context.Context, target models.Target, id int64, name string) (bool, error) {
+	table, err := tableOf(target)
+	if err != nil {
+		return false, err
+	}
+	query := `DELETE FROM ` + table.table + ` WHERE ` + table.column + ` = $1 AND name = $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove tag: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// GetProjectTags returns the tags of the builds and test cases of a project with the
+// number of each carrying them, in alphabetical order
+func (r *SQLTagRepository) GetProjectTags(ctx context.Context, projectID int64) ([]*models.Tag, error) {
+	query := `
+		SELECT name, SUM(builds)::bigint, SUM(test_cases)::bigint
+		FROM (
+			SELECT bt.name, COUNT(*) AS builds, 0 AS test_cases
+			FROM build_tags bt
+			JOIN builds b ON b.id = bt.build_id
+			JOIN test_suites ts ON ts.id = b.test_suite_id
+			WHERE ts.project_id = $1
+			GROUP BY bt.name
+			UNION ALL
+			SELECT tt.name, 0, COUNT(*)
+			FROM test_case_tags tt
+			JOIN test_cases tc ON tc.id = tt.test_case_id
+			JOIN test_suites ts ON ts.id = tc.suite_id
+			WHERE ts.project_id = $1
+			GROUP BY tt.name
+		) tags
+		GROUP BY name
+		ORDER BY name`
+
+	rows, err := r.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags of project: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []*models.Tag
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.Name, &tag.Builds, &tag.TestCases); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, &tag)
+	}
+	return tags, rows.Err()
+}
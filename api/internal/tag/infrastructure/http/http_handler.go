@@ -0,0 +1,242 @@
+package http
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"strconv"
+
+	"github.com/BennyEisner/test-results/internal/tag/domain/errors"
+	"github.com/BennyEisner/test-results/internal/tag/domain/models"
+	"github.com/BennyEisner/test-results/internal/tag/domain/ports"
+)
+
+// TagHandler handles HTTP requests for the tags of builds and test cases
+type TagHandler struct {
+	Service ports.TagService
+}
+
+// NewTagHandler creates a new TagHandler
+func NewTagHandler(service ports.TagService) *TagHandler {
+	return &TagHandler{Service: service}
+}
+
+// AddTagsRequest lists the tags to add to a build or test case
+type AddTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// GetProjectTags handles GET /projects/{projectID}/tags
+// @Summary List the tags of a project
+// @Description List the tags used by the builds and test cases of a project, with the number of builds and test cases carrying each
+// @Tags tags
+// @Produce json
+// @Param projectID path int true "Project ID"
+// @Success 200 {array} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{projectID}/tags [get]
+func (h *TagHandler) GetProjectTags(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(r.PathValue("projectID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid project ID")
+		return
+	}
+
+	ctx := r.Context()
+	tags, err := h.Service.GetProjectTags(ctx, projectID)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tags)
+}
+
+// GetBuildTags handles GET /builds/{id}/tags
+// @Summary List the tags of a build
+// @Description List the tags of a build in alphabetical order
+// @Tags tags
+// @Produce json
+// @Param id path int true "Build ID"
+// @Success 200 {array} string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /builds/{id}/tags [get]
+func (h *TagHandler) GetBuildTags(w http.ResponseWriter, r *http.Request) {
+	h.getTags(w, r, models.TargetBuild)
+}
+
+// AddBuildTags handles POST /builds/{id}/tags
+// @Summary Tag a build
+// @Description Add tags to a build; tags it already carries are ignored. Returns all tags of the build.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "Build ID"
+// @Param tags body AddTagsRequest true "Tags to add"
+// @Success 200 {array} string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /builds/{id}/tags [post]
+func (h *TagHandler) AddBuildTags(w http.ResponseWriter, r *http.Request) {
+	h.addTags(w, r, models.TargetBuild)
+}
+
+// RemoveBuildTag handles DELETE /builds/{id}/tags/{name}
+// @Summary Remove a tag from a build
+// @Description Remove a tag from a build
+// @Tags tags
+// @Param id path int true "Build ID"
+// @Param name path string true "Tag"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /builds/{id}/tags/{name} [delete]
+func (h *TagHandler) RemoveBuildTag(w http.ResponseWriter, r *http.Request) {
+	h.removeTag(w, r, models.TargetBuild)
+}
+
+// GetTestCaseTags handles GET /test-cases/{id}/tags
+// @Summary List the tags of a test case
+// @Description List the tags of a test case in alphabetical order
+// @Tags tags
+// @Produce json
+// @Param id path int true "Test Case ID"
+// @Success 200 {array} string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /test-cases/{id}/tags [get]
+func (h *TagHandler) GetTestCaseTags(w http.ResponseWriter, r *http.Request) {
+	h.getTags(w, r, models.TargetTestCase)
+}
+
+// AddTestCaseTags handles POST /test-cases/{id}/tags
+// @Summary Tag a test case
+// @Description Add tags to a test case; tags it already carries are ignored. Returns all tags of the test case.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "Test Case ID"
+// @Param tags body AddTagsRequest true "Tags to add"
+// @Success 200 {array} string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /test-cases/{id}/tags [post]
+func (h *TagHandler) AddTestCaseTags(w http.ResponseWriter, r *http.Request) {
+	h.addTags(w, r, models.TargetTestCase)
+}
+
+// RemoveTestCaseTag handles DELETE /test-cases/{id}/tags/{name}
+// @Summary Remove a tag from a test case
+// @Description Remove a tag from a test case
+// @Tags tags
+// @Param id path int true "Test Case ID"
+// @Param name path string true "Tag"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /test-cases/{id}/tags/{name} [delete]
+func (h *TagHandler) RemoveTestCaseTag(w http.ResponseWriter, r *http.Request) {
+	h.removeTag(w, r, models.TargetTestCase)
+}
+
+func (h *TagHandler) getTags(w http.ResponseWriter, r *http.Request, target models.Target) {
+	id, ok := parseTargetID(w, r, target)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	tags, err := h.Service.GetTags(ctx, target, id)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tags)
+}
+
+func (h *TagHandler) addTags(w http.ResponseWriter, r *http.Request, target models.Target) {
+	id, ok := parseTargetID(w, r, target)
+	if !ok {
+		return
+	}
+
+	var input AddTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	ctx := r.Context()
+	tags, err := h.Service.AddTags(ctx, target, id, input.Tags)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tags)
+}
+
+func (h *TagHandler) removeTag(w http.ResponseWriter, r *http.Request, target models.Target) {
+	id, ok := parseTargetID(w, r, target)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.Service.RemoveTag(ctx, target, id, r.PathValue("name")); err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseTargetID reads the ID of the build or test case of a request, responding with
+// an error when it is invalid
+func parseTargetID(w http.ResponseWriter, r *http.Request, target models.Target) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		message := "invalid build ID"
+		if target == models.TargetTestCase {
+			message = "invalid test case ID"
+		}
+		respondWithError(w, http.StatusBadRequest, message)
+		return 0, false
+	}
+	return id, true
+}
+
+func statusForError(err error) int {
+	switch {
+	case stderrors.Is(err, errors.ErrTagNotFound),
+		stderrors.Is(err, errors.ErrBuildNotFound),
+		stderrors.Is(err, errors.ErrTestCaseNotFound):
+		return http.StatusNotFound
+	case stderrors.Is(err, errors.ErrInvalidTag):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Helper functions for HTTP responses
+func respondWithError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondWithJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
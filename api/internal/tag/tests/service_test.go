@@ -0,0 +1,197 @@
+package application
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/BennyEisner/test-results/internal/tag/application"
+	"github.com/BennyEisner/test-results/internal/tag/domain/errors"
+	"github.com/BennyEisner/test-results/internal/tag/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockTagRepository is a mock implementation of TagRepository
+type MockTagRepository struct {
+	mock.Mock
+}
+
+func (m *MockTagRepository) TargetExists(ctx context.Context, target models.Target, id int64) (bool, error) {
+	args := m.Called(ctx, target, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTagRepository) GetTags(ctx context.Context, target models.Target, id int64) ([]string, error) {
+	args := m.Called(ctx, target, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockTagRepository) AddTags(ctx context.Context, target models.Target, id int64, names []string) error {
+	args := m.Called(ctx, target, id, names)
+	return args.Error(0)
+}
+
+func (m *MockTagRepository) RemoveTag(ctx context.Context, target models.Target, id int64, name string) (bool, error) {
+	args := m.Called(ctx, target, id, name)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTagRepository) GetProjectTags(ctx context.Context, projectID int64) ([]*models.Tag, error) {
+	args := m.Called(ctx, projectID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Tag), args.Error(1)
+}
+
+func TestTagService_GetTags(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockTagRepository)
+		service := application.NewTagService(mockRepo)
+
+		mockRepo.On("TargetExists", ctx, models.TargetBuild, int64(3)).Return(true, nil).Once()
+		mockRepo.On("GetTags", ctx, models.TargetBuild, int64(3)).Return([]string{"nightly", "smoke"}, nil).Once()
+
+		tags, err := service.GetTags(ctx, models.TargetBuild, 3)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"nightly", "smoke"}, tags)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("no tags", func(t *testing.T) {
+		mockRepo := new(MockTagRepository)
+		service := application.NewTagService(mockRepo)
+
+		mockRepo.On("TargetExists", ctx, models.TargetTestCase, int64(5)).Return(true, nil).Once()
+		mockRepo.On("GetTags", ctx, models.TargetTestCase, int64(5)).Return(nil, nil).Once()
+
+		tags, err := service.GetTags(ctx, models.TargetTestCase, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{}, tags)
+	})
+
+	t.Run("missing target", func(t *testing.T) {
+		mockRepo := new(MockTagRepository)
+		service := application.NewTagService(mockRepo)
+
+		mockRepo.On("TargetExists", ctx, models.TargetBuild, int64(3)).Return(false, nil).Once()
+		mockRepo.On("TargetExists", ctx, models.TargetTestCase, int64(3)).Return(false, nil).Once()
+
+		_, err := service.GetTags(ctx, models.TargetBuild, 3)
+		assert.Equal(t, errors.ErrBuildNotFound, err)
+
+		_, err = service.GetTags(ctx, models.TargetTestCase, 3)
+		assert.Equal(t, errors.ErrTestCaseNotFound, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestTagService_AddTags(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("tags are normalized", func(t *testing.T) {
+		mockRepo := new(MockTagRepository)
+		service := application.NewTagService(mockRepo)
+
+		mockRepo.On("TargetExists", ctx, models.TargetTestCase, int64(5)).Return(true, nil)
+		mockRepo.On("AddTags", ctx, models.TargetTestCase, int64(5), []string{"smoke", "regression"}).Return(nil).Once()
+		mockRepo.On("GetTags", ctx, models.TargetTestCase, int64(5)).Return([]string{"api", "regression", "smoke"}, nil).Once()
+
+		tags, err := service.AddTags(ctx, models.TargetTestCase, 5, []string{" smoke", "@regression", "smoke"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"api", "regression", "smoke"}, tags)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid tags", func(t *testing.T) {
+		for name, tags := range map[string][]string{
+			"none":  nil,
+			"empty": {"smoke", " "},
+			"comma": {"smoke,api"},
+		} {
+			service := application.NewTagService(new(MockTagRepository))
+
+			_, err := service.AddTags(ctx, models.TargetBuild, 3, tags)
+
+			assert.True(t, stderrors.Is(err, errors.ErrInvalidTag), name)
+		}
+	})
+
+	t.Run("missing build", func(t *testing.T) {
+		mockRepo := new(MockTagRepository)
+		service := application.NewTagService(mockRepo)
+
+		mockRepo.On("TargetExists", ctx, models.TargetBuild, int64(3)).Return(false, nil).Once()
+
+		_, err := service.AddTags(ctx, models.TargetBuild, 3, []string{"smoke"})
+
+		assert.Equal(t, errors.ErrBuildNotFound, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestTagService_RemoveTag(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockTagRepository)
+		service := application.NewTagService(mockRepo)
+
+		mockRepo.On("TargetExists", ctx, models.TargetBuild, int64(3)).Return(true, nil).Once()
+		mockRepo.On("RemoveTag", ctx, models.TargetBuild, int64(3), "smoke").Return(true, nil).Once()
+
+		err := service.RemoveTag(ctx, models.TargetBuild, 3, "@smoke")
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("tag not carried", func(t *testing.T) {
+		mockRepo := new(MockTagRepository)
+		service := application.NewTagService(mockRepo)
+
+		mockRepo.On("TargetExists", ctx, models.TargetBuild, int64(3)).Return(true, nil).Once()
+		mockRepo.On("RemoveTag", ctx, models.TargetBuild, int64(3), "nightly").Return(false, nil).Once()
+
+		err := service.RemoveTag(ctx, models.TargetBuild, 3, "nightly")
+
+		assert.Equal(t, errors.ErrTagNotFound, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestTagService_GetProjectTags(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockTagRepository)
+		service := application.NewTagService(mockRepo)
+		tags := []*models.Tag{{Name: "regression", TestCases: 12}, {Name: "smoke", Builds: 4, TestCases: 3}}
+
+		mockRepo.On("GetProjectTags", ctx, int64(1)).Return(tags, nil).Once()
+
+		result, err := service.GetProjectTags(ctx, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, tags, result)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid project", func(t *testing.T) {
+		service := application.NewTagService(new(MockTagRepository))
+
+		_, err := service.GetProjectTags(ctx, 0)
+
+		assert.Error(t, err)
+	})
+}
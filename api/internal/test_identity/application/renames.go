@@ -0,0 +1,111 @@
+package application
+
+import (
+	"math"
+	"sort"
+
+	"github.com/BennyEisner/test-results/internal/test_identity/domain/models"
+)
+
+const (
+	// maxRenameCandidates bounds the test cases compared on either side; a build where more
+	// disappeared or appeared was restructured rather than renamed and is not looked at
+	maxRenameCandidates = 1000
+	// maxDurationDifference is the largest difference of the durations of a renamed test
+	// case, relative to the longer one
+	maxDurationDifference = 0.25
+	// durationSlack is a difference in seconds at which durations always match, as those
+	// of fast tests vary by more than maxDurationDifference
+	durationSlack = 0.05
+	// maxPositionShift is the largest difference of the positions of a renamed test case in
+	// its builds, relative to their sizes
+	maxPositionShift = 0.05
+	// positionSlack is a number of positions by which a renamed test case may always move,
+	// as one position is a large shift in a small build
+	positionSlack = 2
+)
+
+// matchRenames pairs test cases that disappeared with test cases that appeared where their
+// durations and positions match. Every test case is paired at most once, the closest
+// matches first; the score of a pair averages how close the durations, positions and
+// names are.
+func matchRenames(disappeared, appeared []*models.RenameCandidate) []*models.RenameSuggestion {
+	if len(disappeared) > maxRenameCandidates || len(appeared) > maxRenameCandidates {
+		return nil
+	}
+
+	var suggestions []*models.RenameSuggestion
+	for _, old := range disappeared {
+		for _, renamed := range appeared {
+			if score, ok := renameScore(old, renamed); ok {
+				suggestions = append(suggestions, &models.RenameSuggestion{Old: old.TestCase, New: renamed.TestCase, Score: score})
+			}
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Score > suggestions[j].Score
+	})
+
+	var matched []*models.RenameSuggestion
+	paired := make(map[int64]bool)
+	for _, suggestion := range suggestions {
+		if paired[suggestion.Old.ID] || paired[suggestion.New.ID] {
+			continue
+		}
+		paired[suggestion.Old.ID] = true
+		paired[suggestion.New.ID] = true
+		matched = append(matched, suggestion)
+	}
+	return matched
+}
+
+// renameScore scores a test case that appeared as the rename of one that disappeared,
+// reporting false unless their durations and positions match
+func renameScore(old, renamed *models.RenameCandidate) (float64, bool) {
+	durationDiff := math.Abs(old.Duration - renamed.Duration)
+	durationShift := 0.0
+	if longest := math.Max(old.Duration, renamed.Duration); longest > 0 {
+		durationShift = durationDiff / longest
+	}
+	if durationDiff > durationSlack && durationShift > maxDurationDifference {
+		return 0, false
+	}
+
+	positionDiff := old.Position - renamed.Position
+	if positionDiff < 0 {
+		positionDiff = -positionDiff
+	}
+	positionShift := math.Abs(relativePosition(old) - relativePosition(renamed))
+	if positionDiff > positionSlack && positionShift > maxPositionShift {
+		return 0, false
+	}
+
+	score := (1 - durationShift + 1 - positionShift + nameSimilarity(old.TestCase, renamed.TestCase)) / 3
+	return math.Round(score*1000) / 1000, true
+}
+
+// relativePosition places the execution of a candidate between 0 and 1 in its build
+func relativePosition(candidate *models.RenameCandidate) float64 {
+	if candidate.Total <= 1 {
+		return 0
+	}
+	return float64(candidate.Position) / float64(candidate.Total-1)
+}
+
+// nameSimilarity is the share of the longer of two full test names taken up by the prefix
+// and suffix they have in common, which stays high when part of a name was changed
+func nameSimilarity(a, b models.TestCase) float64 {
+	x := []rune(a.Classname + "." + a.Name)
+	y := []rune(b.Classname + "." + b.Name)
+	shorter := min(len(x), len(y))
+
+	prefix := 0
+	for prefix < shorter && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < shorter-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	return float64(prefix+suffix) / float64(max(len(x), len(y)))
+}
@@ -0,0 +1,184 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/BennyEisner/test-results/internal/test_identity/domain/errors"
+	"github.com/BennyEisner/test-results/internal/test_identity/domain/models"
+	"github.com/BennyEisner/test-results/internal/test_identity/domain/ports"
+)
+
+// TestIdentityService implements the TestIdentityService interface
+type TestIdentityService struct {
+	repo ports.TestIdentityRepository
+}
+
+func NewTestIdentityService(repo ports.TestIdentityRepository) ports.TestIdentityService {
+	return &TestIdentityService{repo: repo}
+}
+
+// GetIdentity returns the fields the test cases of a project are identified by and the
+// patterns their parameters are recognized by
+func (s *TestIdentityService) GetIdentity(ctx context.Context, projectID int64) (*models.Identity, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	identity, err := s.repo.GetIdentity(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get test identity of project %d: %w", projectID, err)
+	}
+	if identity == nil {
+		identity = &models.Identity{ProjectID: projectID}
+	}
+	if identity.Fields == nil {
+		identity.Fields, identity.Default = models.DefaultFields, true
+	}
+	return identity, nil
+}
+
+// SetIdentity configures the fields the test cases of a project are identified by and the
+// patterns their parameters are recognized by, and recomputes their fingerprints and
+// parameters. Test cases whose fingerprints become equal are not merged; they can be
+// merged through MergeTestCases.
+func (s *TestIdentityService) SetIdentity(ctx context.Context, projectID int64, fields, paramPatterns []string) (*models.Identity, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	identity := &models.Identity{ProjectID: projectID}
+	if len(fields) > 0 {
+		normalized, ok := models.NormalizeFields(fields)
+		if !ok {
+			return nil, fmt.Errorf("%w: fields must include %q and be among %v", errors.ErrInvalidIdentity, models.FieldName, models.Fields)
+		}
+		identity.Fields = normalized
+	}
+	for _, pattern := range paramPatterns {
+		if _, err := models.CompileParamPatterns([]string{pattern}); err != nil {
+			return nil, fmt.Errorf("%w: invalid parameter pattern %q: %v", errors.ErrInvalidIdentity, pattern, err)
+		}
+		if strings.TrimSpace(pattern) != "" {
+			identity.ParamPatterns = append(identity.ParamPatterns, pattern)
+		}
+	}
+
+	if err := s.repo.SetIdentity(ctx, identity); err != nil {
+		return nil, fmt.Errorf("failed to set test identity of project %d: %w", projectID, err)
+	}
+	return s.GetIdentity(ctx, projectID)
+}
+
+// MergeTestCases merges the history of the source test case into the target test case
+// of the same project and deletes the source
+func (s *TestIdentityService) MergeTestCases(ctx context.Context, request models.MergeRequest) error {
+	if request.SourceID <= 0 || request.TargetID <= 0 {
+		return fmt.Errorf("%w: source_id and target_id are required", errors.ErrInvalidMerge)
+	}
+	if request.SourceID == request.TargetID {
+		return fmt.Errorf("%w: a test case cannot be merged into itself", errors.ErrInvalidMerge)
+	}
+
+	sourceProject, err := s.testCaseProjectID(ctx, request.SourceID)
+	if err != nil {
+		return err
+	}
+	targetProject, err := s.testCaseProjectID(ctx, request.TargetID)
+	if err != nil {
+		return err
+	}
+	if sourceProject != targetProject {
+		return fmt.Errorf("%w: test cases %d and %d belong to different projects", errors.ErrInvalidMerge, request.SourceID, request.TargetID)
+	}
+
+	if err := s.repo.MergeTestCases(ctx, request.SourceID, request.TargetID); err != nil {
+		return fmt.Errorf("failed to merge test case %d into %d: %w", request.SourceID, request.TargetID, err)
+	}
+	return nil
+}
+
+// testCaseProjectID returns the project of a test case, failing with ErrTestCaseNotFound if it does not exist
+func (s *TestIdentityService) testCaseProjectID(ctx context.Context, id int64) (int64, error) {
+	projectID, err := s.repo.GetTestCaseProjectID(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up test case %d: %w", id, err)
+	}
+	if projectID == 0 {
+		return 0, fmt.Errorf("%w: %d", errors.ErrTestCaseNotFound, id)
+	}
+	return projectID, nil
+}
+
+// DetectRenames compares a build with the previous build of its suite and suggests that
+// test cases which disappeared were renamed to test cases which appeared, where their
+// duration and position in the build match
+func (s *TestIdentityService) DetectRenames(ctx context.Context, buildID int64) (int, error) {
+	projectID, err := s.repo.GetBuildProjectID(ctx, buildID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up build %d: %w", buildID, err)
+	}
+	if projectID == 0 {
+		return 0, errors.ErrBuildNotFound
+	}
+
+	disappeared, appeared, err := s.repo.GetRenameCandidates(ctx, buildID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rename candidates of build %d: %w", buildID, err)
+	}
+
+	suggestions := matchRenames(disappeared, appeared)
+	if len(suggestions) == 0 {
+		return 0, nil
+	}
+	for _, suggestion := range suggestions {
+		suggestion.ProjectID = projectID
+		suggestion.BuildID = buildID
+	}
+	saved, err := s.repo.SaveSuggestions(ctx, suggestions)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save rename suggestions of build %d: %w", buildID, err)
+	}
+	return saved, nil
+}
+
+// GetSuggestions returns the rename suggestions of a project
+func (s *TestIdentityService) GetSuggestions(ctx context.Context, projectID int64) ([]*models.RenameSuggestion, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+	suggestions, err := s.repo.GetSuggestions(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rename suggestions of project %d: %w", projectID, err)
+	}
+	if suggestions == nil {
+		suggestions = []*models.RenameSuggestion{}
+	}
+	return suggestions, nil
+}
+
+// AcceptSuggestion merges the history of the old test case of a suggestion into the new
+// one, which removes the suggestion along with the old test case
+func (s *TestIdentityService) AcceptSuggestion(ctx context.Context, id int64) error {
+	suggestion, err := s.repo.GetSuggestion(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get rename suggestion %d: %w", id, err)
+	}
+	if suggestion == nil {
+		return errors.ErrSuggestionNotFound
+	}
+	return s.MergeTestCases(ctx, models.MergeRequest{SourceID: suggestion.Old.ID, TargetID: suggestion.New.ID})
+}
+
+// DismissSuggestion deletes a rename suggestion
+func (s *TestIdentityService) DismissSuggestion(ctx context.Context, id int64) error {
+	deleted, err := s.repo.DeleteSuggestion(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete rename suggestion %d: %w", id, err)
+	}
+	if !deleted {
+		return errors.ErrSuggestionNotFound
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package errors
+
+import "errors"
+
+var (
+	ErrTestCaseNotFound   = errors.New("test case not found")
+	ErrBuildNotFound      = errors.New("build not found")
+	ErrSuggestionNotFound = errors.New("rename suggestion not found")
+	ErrInvalidIdentity    = errors.New("invalid test identity")
+	ErrInvalidMerge       = errors.New("invalid merge")
+)
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields a test case can be identified by
+const (
+	FieldSuite     = "suite"     // test suite the test case is reported in
+	FieldClassname = "classname" // classname of the test case
+	FieldName      = "name"      // name of the test case without its parameters
+	FieldFile      = "file"      // source file reported for the test case
+	FieldParams    = "params"    // parameters at the end of the name, e.g. "[1-2]" of "test_add[1-2]"
+)
+
+// Fields lists the identity fields in the order they enter a fingerprint
+var Fields = []string{FieldSuite, FieldClassname, FieldName, FieldFile, FieldParams}
+
+// DefaultFields identify a test case by its suite, classname and full name, as test
+// cases were identified before fingerprints
+var DefaultFields = []string{FieldSuite, FieldClassname, FieldName, FieldParams}
+
+// Identity is the set of fields the test cases of a project are identified by, and the
+// patterns the parameters of their names are recognized by
+type Identity struct {
+	ProjectID int64    `json:"project_id"`
+	Fields    []string `json:"fields"`
+	// Default is set when the project uses DefaultFields
+	Default bool `json:"default"`
+	// ParamPatterns are regular expressions matching the parameters of a test name, tried
+	// in order; none select the brackets and parentheses of SplitParams
+	ParamPatterns []string `json:"param_patterns"`
+}
+
+// TestKey holds what a fingerprint is computed from
+type TestKey struct {
+	SuiteID   int64
+	Classname string
+	Name      string // full name, including parameters
+	File      string
+}
+
+// Fingerprint returns the hex SHA-256 of the values of the given fields of a test case,
+// its parameters split off by patterns. Test cases with the same fingerprint in a project
+// share one history.
+func Fingerprint(fields []string, patterns ParamPatterns, key TestKey) string {
+	name, params := patterns.Split(key.Name)
+	values := map[string]string{
+		FieldSuite:     strconv.FormatInt(key.SuiteID, 10),
+		FieldClassname: key.Classname,
+		FieldName:      name,
+		FieldFile:      key.File,
+		FieldParams:    params,
+	}
+
+	selected := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		selected[field] = true
+	}
+	hash := sha256.New()
+	for _, field := range Fields {
+		if selected[field] {
+			hash.Write([]byte(field + "=" + values[field]))
+			hash.Write([]byte{0})
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// SplitParams splits the parameters off the end of a test name, given in brackets as
+// by pytest and JUnit 5 ("test_add[1-2]") or in parentheses as by NUnit ("Add(1,2)")
+func SplitParams(name string) (base, params string) {
+	if len(name) < 2 {
+		return name, ""
+	}
+	closing := name[len(name)-1]
+	var opening byte
+	switch closing {
+	case ']':
+		opening = '['
+	case ')':
+		opening = '('
+	default:
+		return name, ""
+	}
+
+	depth := 0
+	for i := len(name) - 1; i > 0; i-- {
+		switch name[i] {
+		case closing:
+			depth++
+		case opening:
+			depth--
+		}
+		if depth == 0 {
+			return name[:i], name[i:]
+		}
+	}
+	return name, ""
+}
+
+// ParamPatterns recognize the parameters of test names, such as "case-17" of
+// "TestParse/case-17"
+type ParamPatterns []*regexp.Regexp
+
+// CompileParamPatterns compiles the parameter patterns of a project, leaving out blank ones
+func CompileParamPatterns(patterns []string) (ParamPatterns, error) {
+	var compiled ParamPatterns
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Split splits the parameters off a test name. The first pattern matching after the
+// start of the name marks where its parameters begin; without patterns, the name is
+// split as by SplitParams.
+func (p ParamPatterns) Split(name string) (base, params string) {
+	if len(p) == 0 {
+		return SplitParams(name)
+	}
+	for _, re := range p {
+		if loc := re.FindStringIndex(name); loc != nil && loc[0] > 0 {
+			return name[:loc[0]], name[loc[0]:]
+		}
+	}
+	return name, ""
+}
+
+// NormalizeFields orders identity fields as in Fields and drops repeated ones. It
+// reports false when a field is unknown or the name is not among them.
+func NormalizeFields(fields []string) ([]string, bool) {
+	selected := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !isField(field) {
+			return nil, false
+		}
+		selected[field] = true
+	}
+	if !selected[FieldName] {
+		return nil, false
+	}
+
+	normalized := make([]string, 0, len(selected))
+	for _, field := range Fields {
+		if selected[field] {
+			normalized = append(normalized, field)
+		}
+	}
+	return normalized, true
+}
+
+func isField(field string) bool {
+	for _, f := range Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeRequest names two test cases whose histories are merged; the source test case
+// is merged into the target and deleted
+type MergeRequest struct {
+	SourceID int64 `json:"source_id"`
+	TargetID int64 `json:"target_id"`
+}
+
+// TestCase is a test case as far as renames are concerned
+type TestCase struct {
+	ID        int64  `json:"id"`
+	SuiteID   int64  `json:"suite_id"`
+	Classname string `json:"classname"`
+	Name      string `json:"name"`
+}
+
+// RenameCandidate is a test case that disappeared from a build compared to the build
+// before it, or that appeared in it for the first time, with its execution there
+type RenameCandidate struct {
+	TestCase
+	Duration float64
+	Position int // index of the execution among those of its build
+	Total    int // number of executions of its build
+}
+
+// RenameSuggestion proposes that a test case which disappeared from a build was renamed
+// to one that appeared in it. Accepting it merges the history of the old test case into
+// the new one.
+type RenameSuggestion struct {
+	ID        int64     `json:"id"`
+	ProjectID int64     `json:"project_id"`
+	BuildID   int64     `json:"build_id"`
+	Old       TestCase  `json:"old"`
+	New       TestCase  `json:"new"`
+	Score     float64   `json:"score"` // from 0 to 1, higher for closer matches
+	CreatedAt time.Time `json:"created_at"`
+}
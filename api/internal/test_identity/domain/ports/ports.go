@@ -0,0 +1,51 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/BennyEisner/test-results/internal/test_identity/domain/models"
+)
+
+// TestIdentityRepository defines the interface for test identity data access
+type TestIdentityRepository interface {
+	// GetIdentity returns the identity configured for a project, or nil when it uses the
+	// default; its fields are nil when only parameter patterns are configured
+	GetIdentity(ctx context.Context, projectID int64) (*models.Identity, error)
+	// SetIdentity configures the identity of a project, nil fields selecting the default
+	// ones, and recomputes the fingerprints and parameters of its test cases
+	SetIdentity(ctx context.Context, identity *models.Identity) error
+	// GetTestCaseProjectID returns the project of a test case, or 0 if the test case does not exist
+	GetTestCaseProjectID(ctx context.Context, testCaseID int64) (int64, error)
+	// MergeTestCases moves the executions, tags and subtests of the source test case to the
+	// target and deletes the source. Where both ran in the same build, the target's execution is kept.
+	MergeTestCases(ctx context.Context, sourceID, targetID int64) error
+	// GetBuildProjectID returns the project of a build, or 0 if the build does not exist
+	GetBuildProjectID(ctx context.Context, buildID int64) (int64, error)
+	// GetRenameCandidates returns the test cases that ran in the previous build of the suite of
+	// a build but not in it, and those that ran for the first time in it
+	GetRenameCandidates(ctx context.Context, buildID int64) (disappeared, appeared []*models.RenameCandidate, err error)
+	// SaveSuggestions stores rename suggestions; pairs of test cases suggested before are left out
+	SaveSuggestions(ctx context.Context, suggestions []*models.RenameSuggestion) (int, error)
+	// GetSuggestions returns the rename suggestions of a project, newest first
+	GetSuggestions(ctx context.Context, projectID int64) ([]*models.RenameSuggestion, error)
+	// GetSuggestion returns a rename suggestion, or nil if it does not exist
+	GetSuggestion(ctx context.Context, id int64) (*models.RenameSuggestion, error)
+	// DeleteSuggestion deletes a rename suggestion and reports whether it existed
+	DeleteSuggestion(ctx context.Context, id int64) (bool, error)
+}
+
+// TestIdentityService defines the interface for test identity business logic
+type TestIdentityService interface {
+	GetIdentity(ctx context.Context, projectID int64) (*models.Identity, error)
+	// SetIdentity configures the identity fields and parameter patterns of a project; no
+	// fields select the default ones
+	SetIdentity(ctx context.Context, projectID int64, fields, paramPatterns []string) (*models.Identity, error)
+	MergeTestCases(ctx context.Context, request models.MergeRequest) error
+	// DetectRenames stores suggestions for the test cases that look renamed in a build and
+	// returns the number of new suggestions
+	DetectRenames(ctx context.Context, buildID int64) (int, error)
+	GetSuggestions(ctx context.Context, projectID int64) ([]*models.RenameSuggestion, error)
+	// AcceptSuggestion merges the old test case of a suggestion into the new one
+	AcceptSuggestion(ctx context.Context, id int64) error
+	DismissSuggestion(ctx context.Context, id int64) error
+}
@@ -0,0 +1,368 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/BennyEisner/test-results/internal/test_identity/domain/models"
+	"github.com/BennyEisner/test-results/internal/test_identity/domain/ports"
+	"github.com/lib/pq"
+)
+
+// SQLTestIdentityRepository implements the TestIdentityRepository interface
+type SQLTestIdentityRepository struct {
+	db *sql.DB
+}
+
+// NewSQLTestIdentityRepository creates a new SQL test identity repository
+func NewSQLTestIdentityRepository(db *sql.DB) ports.TestIdentityRepository {
+	return &SQLTestIdentityRepository{db: db}
+}
+
+// GetIdentity returns the identity configured for a project, or nil when there is none
+func (r *SQLTestIdentityRepository) GetIdentity(ctx context.Context, projectID int64) (*models.Identity, error) {
+	query := `SELECT fields, param_patterns FROM project_test_identities WHERE project_id = $1`
+
+	var fields, patterns pq.StringArray
+	err := r.db.QueryRowContext(ctx, query, projectID).Scan(&fields, &patterns)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get test identity: %w", err)
+	}
+	return &models.Identity{ProjectID: projectID, Fields: fields, ParamPatterns: patterns}, nil
+}
+
+// SetIdentity configures the identity of a project and recomputes the fingerprints and
+// parameters of its test cases in one transaction. A project left with the default fields
+// and no parameter patterns has no configured identity.
+func (r *SQLTestIdentityRepository) SetIdentity(ctx context.Context, identity *models.Identity) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if identity.Fields == nil && len(identity.ParamPatterns) == 0 {
+		_, err = tx.ExecContext(ctx, `DELETE FROM project_test_identities WHERE project_id = $1`, identity.ProjectID)
+	} else {
+		query := `
+			INSERT INTO project_test_identities (project_id, fields, param_patterns) VALUES ($1, $2, $3)
+			ON CONFLICT (project_id) DO UPDATE SET fields = EXCLUDED.fields, param_patterns = EXCLUDED.param_patterns`
+		_, err = tx.ExecContext(ctx, query, identity.ProjectID, pq.Array(identity.Fields), pq.Array(identity.ParamPatterns))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set test identity: %w", err)
+	}
+
+	fields := identity.Fields
+	if fields == nil {
+		fields = models.DefaultFields
+	}
+	patterns, err := models.CompileParamPatterns(identity.ParamPatterns)
+	if err != nil {
+		return fmt.Errorf("failed to compile parameter patterns: %w", err)
+	}
+	if err := updateFingerprints(ctx, tx, identity.ProjectID, fields, patterns); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit test identity: %w", err)
+	}
+	return nil
+}
+
+// suiteFingerprint is a fingerprint within one suite, which only one test case may hold
+type suiteFingerprint struct {
+	suiteID     int64
+	fingerprint string
+}
+
+// updateFingerprints recomputes the fingerprints and parameters of the test cases of a project.
+// A suite keeps one test case per fingerprint: a test case getting the fingerprint of an older
+// test case of its suite is left without one, as imports would only match the older one.
+func updateFingerprints(ctx context.Context, tx *sql.Tx, projectID int64, fields []string, patterns models.ParamPatterns) error {
+	query := `
+		SELECT tc.id, tc.suite_id, tc.classname, tc.name, COALESCE(tc.file, '')
+		FROM test_cases tc
+		JOIN test_suites ts ON ts.id = tc.suite_id
+		WHERE ts.project_id = $1
+		ORDER BY tc.id`
+
+	rows, err := tx.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to get test cases: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var fingerprints, params []string
+	taken := make(map[suiteFingerprint]bool)
+	for rows.Next() {
+		var id int64
+		var key models.TestKey
+		if err := rows.Scan(&id, &key.SuiteID, &key.Classname, &key.Name, &key.File); err != nil {
+			return fmt.Errorf("failed to scan test case: %w", err)
+		}
+		_, p := patterns.Split(key.Name)
+		fingerprint := models.Fingerprint(fields, patterns, key)
+		inSuite := suiteFingerprint{suiteID: key.SuiteID, fingerprint: fingerprint}
+		if taken[inSuite] {
+			fingerprint = ""
+		}
+		taken[inSuite] = true
+		ids = append(ids, id)
+		fingerprints = append(fingerprints, fingerprint)
+		params = append(params, p)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get test cases: %w", err)
+	}
+	rows.Close()
+
+	// The fingerprints are cleared first, as two test cases of a suite may swap theirs
+	reset := `UPDATE test_cases SET fingerprint = NULL WHERE id = ANY($1::bigint[])`
+	if _, err := tx.ExecContext(ctx, reset, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to clear fingerprints: %w", err)
+	}
+	update := `
+		UPDATE test_cases tc SET fingerprint = NULLIF(k.fingerprint, ''), params = NULLIF(k.params, '')
+		FROM unnest($1::bigint[], $2::text[], $3::text[]) AS k(id, fingerprint, params)
+		WHERE tc.id = k.id`
+	if _, err := tx.ExecContext(ctx, update, pq.Array(ids), pq.Array(fingerprints), pq.Array(params)); err != nil {
+		return fmt.Errorf("failed to update fingerprints: %w", err)
+	}
+	return nil
+}
+
+// GetTestCaseProjectID returns the project of a test case, or 0 if the test case does not exist
+func (r *SQLTestIdentityRepository) GetTestCaseProjectID(ctx context.Context, testCaseID int64) (int64, error) {
+	query := `SELECT ts.project_id FROM test_cases tc JOIN test_suites ts ON ts.id = tc.suite_id WHERE tc.id = $1`
+	return r.queryProjectID(ctx, query, testCaseID)
+}
+
+// GetBuildProjectID returns the project of a build, or 0 if the build does not exist
+func (r *SQLTestIdentityRepository) GetBuildProjectID(ctx context.Context, buildID int64) (int64, error) {
+	query := `SELECT ts.project_id FROM builds b JOIN test_suites ts ON ts.id = b.test_suite_id WHERE b.id = $1`
+	return r.queryProjectID(ctx, query, buildID)
+}
+
+func (r *SQLTestIdentityRepository) queryProjectID(ctx context.Context, query string, id int64) (int64, error) {
+	var projectID int64
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return projectID, nil
+}
+
+// MergeTestCases moves the history of the source test case to the target and deletes the
+// source in one transaction. The target takes the external ID of the source if it has none,
+// so that imports identifying the test case by it find the target.
+func (r *SQLTestIdentityRepository) MergeTestCases(ctx context.Context, sourceID, targetID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	statements := []struct {
+		query string
+		what  string
+	}{
+		{`UPDATE test_cases SET parent_id = (SELECT parent_id FROM test_cases WHERE id = $1) WHERE id = $2 AND parent_id = $1`, "reparent target"},
+		{`DELETE FROM build_test_case_executions s
+		  WHERE s.test_case_id = $1
+		    AND EXISTS (SELECT 1 FROM build_test_case_executions t WHERE t.test_case_id = $2 AND t.build_id = s.build_id)`, "drop overlapping executions"},
+		{`UPDATE build_test_case_executions SET test_case_id = $2 WHERE test_case_id = $1`, "move executions"},
+		{`INSERT INTO test_case_tags (test_case_id, name)
+		  SELECT $2, name FROM test_case_tags WHERE test_case_id = $1
+		  ON CONFLICT DO NOTHING`, "move tags"},
+		{`UPDATE test_cases SET parent_id = $2 WHERE parent_id = $1`, "move subtests"},
+		{`UPDATE test_cases t SET external_id = s.external_id
+		  FROM test_cases s
+		  WHERE t.id = $2 AND s.id = $1 AND t.external_id IS NULL`, "move external ID"},
+		{`UPDATE test_case_quarantines SET test_case_id = $2
+		  WHERE test_case_id = $1 AND NOT EXISTS (SELECT 1 FROM test_case_quarantines WHERE test_case_id = $2)`, "move quarantine"},
+		{`DELETE FROM test_cases WHERE id = $1`, "delete source"},
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement.query, sourceID, targetID); err != nil {
+			return fmt.Errorf("failed to %s: %w", statement.what, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit merge: %w", err)
+	}
+	return nil
+}
+
+// candidateQuery selects the executions of build $1 with their position in it, leaving out
+// test cases that ran in build $2
+const candidateQuery = `
+	SELECT tc.id, tc.suite_id, tc.classname, tc.name, COALESCE(e.execution_time, 0), e.position, e.total
+	FROM (
+		SELECT test_case_id, execution_time,
+		       ROW_NUMBER() OVER (ORDER BY id) - 1 AS position, COUNT(*) OVER () AS total
+		FROM build_test_case_executions
+		WHERE build_id = $1 AND NOT retried
+	) e
+	JOIN test_cases tc ON tc.id = e.test_case_id
+	WHERE NOT EXISTS (SELECT 1 FROM build_test_case_executions o WHERE o.build_id = $2 AND o.test_case_id = e.test_case_id)`
+
+// GetRenameCandidates returns the test cases that ran in the previous build of the suite
+// of a build but not in it, and those that ran in no build before it
+func (r *SQLTestIdentityRepository) GetRenameCandidates(ctx context.Context, buildID int64) ([]*models.RenameCandidate, []*models.RenameCandidate, error) {
+	query := `
+		SELECT b.id FROM builds b
+		WHERE b.test_suite_id = (SELECT test_suite_id FROM builds WHERE id = $1) AND b.id < $1
+		ORDER BY b.id DESC LIMIT 1`
+
+	var previousID int64
+	err := r.db.QueryRowContext(ctx, query, buildID).Scan(&previousID)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get previous build: %w", err)
+	}
+
+	disappeared, err := r.queryCandidates(ctx, candidateQuery, previousID, buildID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(disappeared) == 0 {
+		return nil, nil, nil
+	}
+
+	firstRun := candidateQuery + `
+	  AND NOT EXISTS (SELECT 1 FROM build_test_case_executions o WHERE o.test_case_id = e.test_case_id AND o.build_id < $1)`
+	appeared, err := r.queryCandidates(ctx, firstRun, buildID, previousID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return disappeared, appeared, nil
+}
+
+func (r *SQLTestIdentityRepository) queryCandidates(ctx context.Context, query string, args ...interface{}) ([]*models.RenameCandidate, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rename candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []*models.RenameCandidate
+	for rows.Next() {
+		c := &models.RenameCandidate{}
+		if err := rows.Scan(&c.ID, &c.SuiteID, &c.Classname, &c.Name, &c.Duration, &c.Position, &c.Total); err != nil {
+			return nil, fmt.Errorf("failed to scan rename candidate: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get rename candidates: %w", err)
+	}
+	return candidates, nil
+}
+
+// SaveSuggestions stores rename suggestions and returns how many were new
+func (r *SQLTestIdentityRepository) SaveSuggestions(ctx context.Context, suggestions []*models.RenameSuggestion) (int, error) {
+	query := `
+		INSERT INTO test_case_rename_suggestions (project_id, build_id, old_test_case_id, new_test_case_id, score)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (old_test_case_id, new_test_case_id) DO NOTHING`
+
+	saved := 0
+	for _, s := range suggestions {
+		result, err := r.db.ExecContext(ctx, query, s.ProjectID, s.BuildID, s.Old.ID, s.New.ID, s.Score)
+		if err != nil {
+			return saved, fmt.Errorf("failed to save rename suggestion: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			saved += int(n)
+		}
+	}
+	return saved, nil
+}
+
+const suggestionColumns = `
+	SELECT s.id, s.project_id, s.build_id, s.score, s.created_at,
+	       o.id, o.suite_id, o.classname, o.name,
+	       n.id, n.suite_id, n.classname, n.name
+	FROM test_case_rename_suggestions s
+	JOIN test_cases o ON o.id = s.old_test_case_id
+	JOIN test_cases n ON n.id = s.new_test_case_id`
+
+// GetSuggestions returns the rename suggestions of a project, newest first
+func (r *SQLTestIdentityRepository) GetSuggestions(ctx context.Context, projectID int64) ([]*models.RenameSuggestion, error) {
+	rows, err := r.db.QueryContext(ctx, suggestionColumns+` WHERE s.project_id = $1 ORDER BY s.id DESC`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rename suggestions: %w", err)
+	}
+	defer rows.Close()
+
+	var suggestions []*models.RenameSuggestion
+	for rows.Next() {
+		suggestion, err := scanSuggestion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rename suggestion: %w", err)
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get rename suggestions: %w", err)
+	}
+	return suggestions, nil
+}
+
+// GetSuggestion returns a rename suggestion by its ID, or nil if it does not exist
+func (r *SQLTestIdentityRepository) GetSuggestion(ctx context.Context, id int64) (*models.RenameSuggestion, error) {
+	suggestion, err := scanSuggestion(r.db.QueryRowContext(ctx, suggestionColumns+` WHERE s.id = $1`, id))
+	if err != nil {
+		if stderrors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get rename suggestion: %w", err)
+	}
+	return suggestion, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSuggestion(row rowScanner) (*models.RenameSuggestion, error) {
+	s := &models.RenameSuggestion{}
+	err := row.Scan(&s.ID, &s.ProjectID, &s.BuildID, &s.Score, &s.CreatedAt,
+		&s.Old.ID, &s.Old.SuiteID, &s.Old.Classname, &s.Old.Name,
+		&s.New.ID, &s.New.SuiteID, &s.New.Classname, &s.New.Name)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DeleteSuggestion deletes a rename suggestion and reports whether it existed
+func (r *SQLTestIdentityRepository) DeleteSuggestion(ctx context.Context, id int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM test_case_rename_suggestions WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete rename suggestion: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete rename suggestion: %w", err)
+	}
+	return rows > 0, nil
+}
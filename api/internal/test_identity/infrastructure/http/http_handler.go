@@ -0,0 +1,224 @@
+package http
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"strconv"
+
+	"github.com/BennyEisner/test-results/internal/test_identity/domain/errors"
+	"github.com/BennyEisner/test-results/internal/test_identity/domain/models"
+	"github.com/BennyEisner/test-results/internal/test_identity/domain/ports"
+)
+
+// TestIdentityHandler handles HTTP requests for test identities, merges and rename suggestions
+type TestIdentityHandler struct {
+	Service ports.TestIdentityService
+}
+
+// NewTestIdentityHandler creates a new TestIdentityHandler
+func NewTestIdentityHandler(service ports.TestIdentityService) *TestIdentityHandler {
+	return &TestIdentityHandler{Service: service}
+}
+
+// SetIdentityRequest lists the fields to identify the test cases of a project by and the
+// patterns to recognize the parameters of their names by
+type SetIdentityRequest struct {
+	Fields        []string `json:"fields"`
+	ParamPatterns []string `json:"param_patterns"`
+}
+
+// GetIdentity handles GET /projects/{projectID}/test_identity
+// @Summary Get the test identity of a project
+// @Description Get the fields the test cases of a project are identified by across builds and the patterns the parameters of their names are recognized by
+// @Tags test-identity
+// @Produce json
+// @Param projectID path int true "Project ID"
+// @Success 200 {object} models.Identity
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{projectID}/test_identity [get]
+func (h *TestIdentityHandler) GetIdentity(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(r.PathValue("projectID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid project ID")
+		return
+	}
+
+	ctx := r.Context()
+	identity, err := h.Service.GetIdentity(ctx, projectID)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, identity)
+}
+
+// SetIdentity handles PUT /projects/{projectID}/test_identity
+// @Summary Set the test identity of a project
+// @Description Set the fields the test cases of a project are identified by: suite, classname, name, file and params, the parameters at the end of a name such as "[1-2]". The name is required; leaving params out folds the variants of a parameterized test into one test case. No fields select the default of suite, classname, name and params. Parameter patterns are regular expressions tried in order: the parameters of a name start where the first pattern matches after its beginning, e.g. "/" splits "TestParse/case-17" into the test TestParse and its parameters "/case-17". No patterns select brackets and parentheses at the end of the name. The fingerprints and parameters of existing test cases are recomputed; test cases whose fingerprints become equal keep their histories until they are merged.
+// @Tags test-identity
+// @Accept json
+// @Produce json
+// @Param projectID path int true "Project ID"
+// @Param identity body SetIdentityRequest true "Identity fields and parameter patterns"
+// @Success 200 {object} models.Identity
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{projectID}/test_identity [put]
+func (h *TestIdentityHandler) SetIdentity(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(r.PathValue("projectID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid project ID")
+		return
+	}
+
+	var input SetIdentityRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	ctx := r.Context()
+	identity, err := h.Service.SetIdentity(ctx, projectID, input.Fields, input.ParamPatterns)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, identity)
+}
+
+// MergeTestCases handles POST /test-cases/merge
+// @Summary Merge two test cases
+// @Description Merge the history of the source test case into the target test case of the same project: its executions, tags and subtests move to the target and the source is deleted. In builds where both ran, the execution of the target is kept.
+// @Tags test-identity
+// @Accept json
+// @Param merge body models.MergeRequest true "Test cases to merge"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /test-cases/merge [post]
+func (h *TestIdentityHandler) MergeTestCases(w http.ResponseWriter, r *http.Request) {
+	var input models.MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.Service.MergeTestCases(ctx, input); err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRenameSuggestions handles GET /projects/{projectID}/rename_suggestions
+// @Summary List the rename suggestions of a project
+// @Description List test cases that look renamed, newest first. After every import, a test case that ran in the previous build of the suite but not in the imported one is suggested as renamed to a test case that ran for the first time in it, where their durations and positions in the builds match.
+// @Tags test-identity
+// @Produce json
+// @Param projectID path int true "Project ID"
+// @Success 200 {array} models.RenameSuggestion
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{projectID}/rename_suggestions [get]
+func (h *TestIdentityHandler) GetRenameSuggestions(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(r.PathValue("projectID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid project ID")
+		return
+	}
+
+	ctx := r.Context()
+	suggestions, err := h.Service.GetSuggestions(ctx, projectID)
+	if err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, suggestions)
+}
+
+// AcceptRenameSuggestion handles POST /rename_suggestions/{id}/accept
+// @Summary Accept a rename suggestion
+// @Description Merge the history of the old test case of a rename suggestion into the new test case
+// @Tags test-identity
+// @Param id path int true "Rename Suggestion ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rename_suggestions/{id}/accept [post]
+func (h *TestIdentityHandler) AcceptRenameSuggestion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid rename suggestion ID")
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.Service.AcceptSuggestion(ctx, id); err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DismissRenameSuggestion handles DELETE /rename_suggestions/{id}
+// @Summary Dismiss a rename suggestion
+// @Description Delete a rename suggestion, leaving both test cases as they are
+// @Tags test-identity
+// @Param id path int true "Rename Suggestion ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rename_suggestions/{id} [delete]
+func (h *TestIdentityHandler) DismissRenameSuggestion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid rename suggestion ID")
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.Service.DismissSuggestion(ctx, id); err != nil {
+		respondWithError(w, statusForError(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func statusForError(err error) int {
+	switch {
+	case stderrors.Is(err, errors.ErrTestCaseNotFound),
+		stderrors.Is(err, errors.ErrBuildNotFound),
+		stderrors.Is(err, errors.ErrSuggestionNotFound):
+		return http.StatusNotFound
+	case stderrors.Is(err, errors.ErrInvalidIdentity),
+		stderrors.Is(err, errors.ErrInvalidMerge):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Helper functions for HTTP responses
+func respondWithError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondWithJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,488 @@
+package application
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/BennyEisner/test-results/internal/test_identity/application"
+	"github.com/BennyEisner/test-results/internal/test_identity/domain/errors"
+	"github.com/BennyEisner/test-results/internal/test_identity/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockTestIdentityRepository is a mock implementation of TestIdentityRepository
+type MockTestIdentityRepository struct {
+	mock.Mock
+}
+
+func (m *MockTestIdentityRepository) GetIdentity(ctx context.Context, projectID int64) (*models.Identity, error) {
+	args := m.Called(ctx, projectID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Identity), args.Error(1)
+}
+
+func (m *MockTestIdentityRepository) SetIdentity(ctx context.Context, identity *models.Identity) error {
+	args := m.Called(ctx, identity)
+	return args.Error(0)
+}
+
+func (m *MockTestIdentityRepository) GetTestCaseProjectID(ctx context.Context, testCaseID int64) (int64, error) {
+	args := m.Called(ctx, testCaseID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockTestIdentityRepository) MergeTestCases(ctx context.Context, sourceID, targetID int64) error {
+	args := m.Called(ctx, sourceID, targetID)
+	return args.Error(0)
+}
+
+func (m *MockTestIdentityRepository) GetBuildProjectID(ctx context.Context, buildID int64) (int64, error) {
+	args := m.Called(ctx, buildID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockTestIdentityRepository) GetRenameCandidates(ctx context.Context, buildID int64) ([]*models.RenameCandidate, []*models.RenameCandidate, error) {
+	args := m.Called(ctx, buildID)
+	disappeared, _ := args.Get(0).([]*models.RenameCandidate)
+	appeared, _ := args.Get(1).([]*models.RenameCandidate)
+	return disappeared, appeared, args.Error(2)
+}
+
+func (m *MockTestIdentityRepository) SaveSuggestions(ctx context.Context, suggestions []*models.RenameSuggestion) (int, error) {
+	args := m.Called(ctx, suggestions)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockTestIdentityRepository) GetSuggestions(ctx context.Context, projectID int64) ([]*models.RenameSuggestion, error) {
+	args := m.Called(ctx, projectID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.RenameSuggestion), args.Error(1)
+}
+
+func (m *MockTestIdentityRepository) GetSuggestion(ctx context.Context, id int64) (*models.RenameSuggestion, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RenameSuggestion), args.Error(1)
+}
+
+func (m *MockTestIdentityRepository) DeleteSuggestion(ctx context.Context, id int64) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestSplitParams(t *testing.T) {
+	for name, want := range map[string][2]string{
+		"test_add[1-2]":        {"test_add", "[1-2]"},
+		"Add(1,2)":             {"Add", "(1,2)"},
+		"add(int, int)[1]":     {"add(int, int)", "[1]"},
+		"test_nested[a[b]]":    {"test_nested", "[a[b]]"},
+		"test_plain":           {"test_plain", ""},
+		"[1]":                  {"[1]", ""},
+		"test_unbalanced]":     {"test_unbalanced]", ""},
+		"TestSum/negative_sum": {"TestSum/negative_sum", ""},
+	} {
+		base, params := models.SplitParams(name)
+		assert.Equal(t, want, [2]string{base, params}, name)
+	}
+}
+
+func TestParamPatterns(t *testing.T) {
+	t.Run("first matching pattern splits the name", func(t *testing.T) {
+		patterns, err := models.CompileParamPatterns([]string{`/`, "", `\[`})
+		assert.NoError(t, err)
+
+		for name, want := range map[string][2]string{
+			"TestParse/case-17":        {"TestParse", "/case-17"},
+			"test_login[chrome-admin]": {"test_login", "[chrome-admin]"},
+			"TestSum/negative[1]":      {"TestSum", "/negative[1]"},
+			"/leading":                 {"/leading", ""},
+			"test_plain":               {"test_plain", ""},
+		} {
+			base, params := patterns.Split(name)
+			assert.Equal(t, want, [2]string{base, params}, name)
+		}
+	})
+
+	t.Run("no patterns split as SplitParams", func(t *testing.T) {
+		base, params := models.ParamPatterns(nil).Split("Add(1,2)")
+		assert.Equal(t, [2]string{"Add", "(1,2)"}, [2]string{base, params})
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := models.CompileParamPatterns([]string{`[`})
+		assert.Error(t, err)
+	})
+}
+
+func TestFingerprint(t *testing.T) {
+	key := models.TestKey{SuiteID: 3, Classname: "tests.test_math", Name: "test_add[1-2]", File: "tests/test_math.py"}
+
+	t.Run("stable and hex encoded", func(t *testing.T) {
+		fingerprint := models.Fingerprint(models.DefaultFields, nil, key)
+		assert.Len(t, fingerprint, 64)
+		assert.Equal(t, fingerprint, models.Fingerprint(models.DefaultFields, nil, key))
+	})
+
+	t.Run("changes with the fields it covers", func(t *testing.T) {
+		moved := key
+		moved.SuiteID = 4
+		assert.NotEqual(t, models.Fingerprint(models.DefaultFields, nil, key), models.Fingerprint(models.DefaultFields, nil, moved))
+
+		fields := []string{models.FieldClassname, models.FieldName, models.FieldParams}
+		assert.Equal(t, models.Fingerprint(fields, nil, key), models.Fingerprint(fields, nil, moved))
+	})
+
+	t.Run("leaving params out folds parameterized variants", func(t *testing.T) {
+		other := key
+		other.Name = "test_add[3-4]"
+		fields := []string{models.FieldSuite, models.FieldClassname, models.FieldName}
+
+		assert.Equal(t, models.Fingerprint(fields, nil, key), models.Fingerprint(fields, nil, other))
+		assert.NotEqual(t, models.Fingerprint(models.DefaultFields, nil, key), models.Fingerprint(models.DefaultFields, nil, other))
+	})
+
+	t.Run("parameter patterns select the params", func(t *testing.T) {
+		patterns, _ := models.CompileParamPatterns([]string{`/`})
+		first := models.TestKey{SuiteID: 3, Classname: "parse", Name: "TestParse/case-17"}
+		second := first
+		second.Name = "TestParse/case-18"
+		fields := []string{models.FieldSuite, models.FieldClassname, models.FieldName}
+
+		assert.Equal(t, models.Fingerprint(fields, patterns, first), models.Fingerprint(fields, patterns, second))
+		assert.NotEqual(t, models.Fingerprint(fields, nil, first), models.Fingerprint(fields, nil, second))
+	})
+
+	t.Run("field order does not matter", func(t *testing.T) {
+		fields := []string{models.FieldName, models.FieldFile}
+		reversed := []string{models.FieldFile, models.FieldName}
+		assert.Equal(t, models.Fingerprint(fields, nil, key), models.Fingerprint(reversed, nil, key))
+	})
+}
+
+func TestTestIdentityService_GetIdentity(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("default", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("GetIdentity", ctx, int64(1)).Return(nil, nil).Once()
+
+		identity, err := service.GetIdentity(ctx, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.Identity{ProjectID: 1, Fields: models.DefaultFields, Default: true}, identity)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("GetIdentity", ctx, int64(1)).Return(&models.Identity{ProjectID: 1, Fields: []string{"classname", "name"}}, nil).Once()
+
+		identity, err := service.GetIdentity(ctx, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.Identity{ProjectID: 1, Fields: []string{"classname", "name"}}, identity)
+	})
+
+	t.Run("parameter patterns with the default fields", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("GetIdentity", ctx, int64(1)).Return(&models.Identity{ProjectID: 1, ParamPatterns: []string{"/"}}, nil).Once()
+
+		identity, err := service.GetIdentity(ctx, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.Identity{ProjectID: 1, Fields: models.DefaultFields, Default: true, ParamPatterns: []string{"/"}}, identity)
+	})
+}
+
+func TestTestIdentityService_SetIdentity(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fields are normalized", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("SetIdentity", ctx, &models.Identity{ProjectID: 1, Fields: []string{"classname", "name", "file"}}).Return(nil).Once()
+		mockRepo.On("GetIdentity", ctx, int64(1)).Return(&models.Identity{ProjectID: 1, Fields: []string{"classname", "name", "file"}}, nil).Once()
+
+		identity, err := service.SetIdentity(ctx, 1, []string{"file", " Name", "classname", "name"}, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"classname", "name", "file"}, identity.Fields)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("no fields select the default", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("SetIdentity", ctx, &models.Identity{ProjectID: 1}).Return(nil).Once()
+		mockRepo.On("GetIdentity", ctx, int64(1)).Return(nil, nil).Once()
+
+		identity, err := service.SetIdentity(ctx, 1, nil, nil)
+
+		assert.NoError(t, err)
+		assert.True(t, identity.Default)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("parameter patterns", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("SetIdentity", ctx, &models.Identity{ProjectID: 1, ParamPatterns: []string{"/", `\[`}}).Return(nil).Once()
+		mockRepo.On("GetIdentity", ctx, int64(1)).Return(&models.Identity{ProjectID: 1, ParamPatterns: []string{"/", `\[`}}, nil).Once()
+
+		identity, err := service.SetIdentity(ctx, 1, nil, []string{"/", " ", `\[`})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/", `\[`}, identity.ParamPatterns)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid parameter pattern", func(t *testing.T) {
+		service := application.NewTestIdentityService(new(MockTestIdentityRepository))
+
+		_, err := service.SetIdentity(ctx, 1, nil, []string{"[a-"})
+
+		assert.True(t, stderrors.Is(err, errors.ErrInvalidIdentity))
+	})
+
+	t.Run("invalid fields", func(t *testing.T) {
+		for name, fields := range map[string][]string{
+			"unknown field": {"name", "module"},
+			"no name":       {"suite", "classname"},
+		} {
+			service := application.NewTestIdentityService(new(MockTestIdentityRepository))
+
+			_, err := service.SetIdentity(ctx, 1, fields, nil)
+
+			assert.True(t, stderrors.Is(err, errors.ErrInvalidIdentity), name)
+		}
+	})
+}
+
+func TestTestIdentityService_MergeTestCases(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("GetTestCaseProjectID", ctx, int64(7)).Return(int64(1), nil).Once()
+		mockRepo.On("GetTestCaseProjectID", ctx, int64(9)).Return(int64(1), nil).Once()
+		mockRepo.On("MergeTestCases", ctx, int64(7), int64(9)).Return(nil).Once()
+
+		err := service.MergeTestCases(ctx, models.MergeRequest{SourceID: 7, TargetID: 9})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		for name, request := range map[string]models.MergeRequest{
+			"missing source": {TargetID: 9},
+			"same test case": {SourceID: 9, TargetID: 9},
+		} {
+			service := application.NewTestIdentityService(new(MockTestIdentityRepository))
+
+			err := service.MergeTestCases(ctx, request)
+
+			assert.True(t, stderrors.Is(err, errors.ErrInvalidMerge), name)
+		}
+	})
+
+	t.Run("missing test case", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("GetTestCaseProjectID", ctx, int64(7)).Return(int64(0), nil).Once()
+
+		err := service.MergeTestCases(ctx, models.MergeRequest{SourceID: 7, TargetID: 9})
+
+		assert.True(t, stderrors.Is(err, errors.ErrTestCaseNotFound))
+		mockRepo.AssertNotCalled(t, "MergeTestCases", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("different projects", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("GetTestCaseProjectID", ctx, int64(7)).Return(int64(1), nil).Once()
+		mockRepo.On("GetTestCaseProjectID", ctx, int64(9)).Return(int64(2), nil).Once()
+
+		err := service.MergeTestCases(ctx, models.MergeRequest{SourceID: 7, TargetID: 9})
+
+		assert.True(t, stderrors.Is(err, errors.ErrInvalidMerge))
+		mockRepo.AssertNotCalled(t, "MergeTestCases", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func candidate(id int64, name string, duration float64, position, total int) *models.RenameCandidate {
+	return &models.RenameCandidate{
+		TestCase: models.TestCase{ID: id, SuiteID: 3, Classname: "tests.test_checkout", Name: name},
+		Duration: duration,
+		Position: position,
+		Total:    total,
+	}
+}
+
+func TestTestIdentityService_DetectRenames(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("matching duration and position", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		disappeared := []*models.RenameCandidate{
+			candidate(1, "test_pay_by_card", 2.0, 10, 100),
+			candidate(2, "test_refund", 0.5, 60, 100),
+		}
+		appeared := []*models.RenameCandidate{
+			candidate(11, "test_pay_with_card", 2.1, 10, 101),
+			candidate(12, "test_refund_partially", 8.0, 61, 101), // far slower
+			candidate(13, "test_gift_card", 2.0, 90, 101),        // elsewhere in the build
+		}
+		mockRepo.On("GetBuildProjectID", ctx, int64(5)).Return(int64(1), nil).Once()
+		mockRepo.On("GetRenameCandidates", ctx, int64(5)).Return(disappeared, appeared, nil).Once()
+		mockRepo.On("SaveSuggestions", ctx, mock.MatchedBy(func(suggestions []*models.RenameSuggestion) bool {
+			return len(suggestions) == 1 &&
+				suggestions[0].Old.ID == 1 && suggestions[0].New.ID == 11 &&
+				suggestions[0].ProjectID == 1 && suggestions[0].BuildID == 5 &&
+				suggestions[0].Score > 0.8 && suggestions[0].Score <= 1
+		})).Return(1, nil).Once()
+
+		suggested, err := service.DetectRenames(ctx, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, suggested)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("each test case is paired once, closest first", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		disappeared := []*models.RenameCandidate{
+			candidate(1, "test_login", 0.01, 0, 4),
+			candidate(2, "test_logout", 0.01, 1, 4),
+		}
+		appeared := []*models.RenameCandidate{
+			candidate(11, "test_sign_out", 0.01, 1, 4),
+			candidate(12, "test_sign_in", 0.01, 0, 4),
+		}
+		mockRepo.On("GetBuildProjectID", ctx, int64(5)).Return(int64(1), nil).Once()
+		mockRepo.On("GetRenameCandidates", ctx, int64(5)).Return(disappeared, appeared, nil).Once()
+		mockRepo.On("SaveSuggestions", ctx, mock.MatchedBy(func(suggestions []*models.RenameSuggestion) bool {
+			pairs := make(map[int64]int64)
+			for _, s := range suggestions {
+				pairs[s.Old.ID] = s.New.ID
+			}
+			return len(suggestions) == 2 && pairs[1] == 12 && pairs[2] == 11
+		})).Return(2, nil).Once()
+
+		suggested, err := service.DetectRenames(ctx, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, suggested)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("nothing disappeared", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("GetBuildProjectID", ctx, int64(5)).Return(int64(1), nil).Once()
+		mockRepo.On("GetRenameCandidates", ctx, int64(5)).Return(nil, nil, nil).Once()
+
+		suggested, err := service.DetectRenames(ctx, 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, suggested)
+		mockRepo.AssertNotCalled(t, "SaveSuggestions", mock.Anything, mock.Anything)
+	})
+
+	t.Run("missing build", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("GetBuildProjectID", ctx, int64(5)).Return(int64(0), nil).Once()
+
+		_, err := service.DetectRenames(ctx, 5)
+
+		assert.Equal(t, errors.ErrBuildNotFound, err)
+	})
+}
+
+func TestTestIdentityService_Suggestions(t *testing.T) {
+	ctx := context.Background()
+	suggestion := &models.RenameSuggestion{
+		ID:        4,
+		ProjectID: 1,
+		Old:       models.TestCase{ID: 7, Name: "test_pay_by_card"},
+		New:       models.TestCase{ID: 9, Name: "test_pay_with_card"},
+	}
+
+	t.Run("accept merges the old test case into the new one", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("GetSuggestion", ctx, int64(4)).Return(suggestion, nil).Once()
+		mockRepo.On("GetTestCaseProjectID", ctx, int64(7)).Return(int64(1), nil).Once()
+		mockRepo.On("GetTestCaseProjectID", ctx, int64(9)).Return(int64(1), nil).Once()
+		mockRepo.On("MergeTestCases", ctx, int64(7), int64(9)).Return(nil).Once()
+
+		err := service.AcceptSuggestion(ctx, 4)
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("accept missing suggestion", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("GetSuggestion", ctx, int64(4)).Return(nil, nil).Once()
+
+		err := service.AcceptSuggestion(ctx, 4)
+
+		assert.Equal(t, errors.ErrSuggestionNotFound, err)
+	})
+
+	t.Run("dismiss", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("DeleteSuggestion", ctx, int64(4)).Return(true, nil).Once()
+		mockRepo.On("DeleteSuggestion", ctx, int64(5)).Return(false, nil).Once()
+
+		assert.NoError(t, service.DismissSuggestion(ctx, 4))
+		assert.Equal(t, errors.ErrSuggestionNotFound, service.DismissSuggestion(ctx, 5))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		mockRepo := new(MockTestIdentityRepository)
+		service := application.NewTestIdentityService(mockRepo)
+
+		mockRepo.On("GetSuggestions", ctx, int64(1)).Return(nil, nil).Once()
+
+		suggestions, err := service.GetSuggestions(ctx, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.RenameSuggestion{}, suggestions)
+	})
+}
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// reportExtensions are the file extensions collected from directories for each report type
+var reportExtensions = map[string][]string{
+	"junit":    {".xml"},
+	"readyapi": {".xml"},
+	"nunit":    {".xml"},
+	"xunit":    {".xml"},
+	"trx":      {".trx", ".xml"},
+	"gotest":   {".json", ".jsonl", ".log", ".txt"},
+	"cucumber": {".json"},
+	"ctrf":     {".json"},
+	"tap":      {".tap", ".txt", ".log"},
+}
+
+// attachmentMarker is the convention of the Jenkins JUnit attachments plugin: a test
+// case references a file by printing [[ATTACHMENT|path]] to its output
+var attachmentMarker = regexp.MustCompile(`\[\[ATTACHMENT\|([^\]\r\n]+)\]\]`)
+
+// resolveReportFiles turns the --file values into the paths to upload. Glob patterns are
+// expanded, where a ** segment matches any number of directories, and directories are
+// searched for files with the extensions of the report type. An allure-results directory
+// is uploaded as a whole.
+func resolveReportFiles(patterns []string, reportType string) ([]string, error) {
+	if reportType == "allure" {
+		if len(patterns) != 1 {
+			return nil, fmt.Errorf("--type allure takes a single allure-results directory")
+		}
+		return patterns, nil
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := expandReportPattern(pattern, reportType)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				paths = append(paths, match)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// expandReportPattern returns the report files a single --file value stands for
+func expandReportPattern(pattern, reportType string) ([]string, error) {
+	matches := []string{pattern}
+	if strings.ContainsAny(pattern, "*?[") {
+		var err error
+		if matches, err = globFiles(pattern); err != nil {
+			return nil, fmt.Errorf("invalid --file pattern %s: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", pattern)
+		}
+	}
+
+	var files []string
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			return nil, fmt.Errorf("error opening file: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, match)
+			continue
+		}
+		found, err := findReportFiles(match, reportType)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// findReportFiles lists the files below dir with the extensions of the report type
+func findReportFiles(dir, reportType string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		if hasReportExtension(path, reportType) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching %s: %w", dir, err)
+	}
+	if len(files) == 0 && reportType == "auto" {
+		return nil, fmt.Errorf("no report files found in %s", dir)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s report files found in %s", reportType, dir)
+	}
+	return files, nil
+}
+
+// hasReportExtension reports whether a file may hold a report of the type, or of any
+// type when the type is to be detected by the API
+func hasReportExtension(name, reportType string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for candidateType, extensions := range reportExtensions {
+		if candidateType != reportType && reportType != "auto" {
+			continue
+		}
+		for _, candidate := range extensions {
+			if ext == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// globFiles expands a pattern like filepath.Glob, except that a ** segment also matches
+// any number of directories
+func globFiles(pattern string) ([]string, error) {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(filepath.FromSlash(pattern))
+	}
+
+	segments := strings.Split(pattern, "/")
+	root := globRoot(segments)
+	var matches []string
+	err := filepath.WalkDir(filepath.FromSlash(root), func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if matchSegments(segments, strings.Split(filepath.ToSlash(name), "/")) {
+			matches = append(matches, name)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// globRoot returns the directory a pattern's matches are searched in: its leading
+// segments without any wildcard
+func globRoot(segments []string) string {
+	i := 0
+	for i < len(segments) && !strings.ContainsAny(segments[i], "*?[") {
+		i++
+	}
+	root := strings.Join(segments[:i], "/")
+	switch {
+	case root == "" && len(segments) > 0 && segments[0] == "" && i > 0:
+		return "/"
+	case root == "":
+		return "."
+	default:
+		return root
+	}
+}
+
+// matchSegments matches the segments of a slash-separated path against those of a
+// pattern, where a ** segment matches zero or more path segments
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// findAttachments returns the existing files the JUnit reports reference with the
+// [[ATTACHMENT|path]] convention, and the referenced paths that do not exist. A relative
+// path is looked up in the working directory, then next to the report.
+func findAttachments(reports []string, reportType string) ([]string, []string, error) {
+	if reportType != "junit" && reportType != "auto" {
+		return nil, nil, nil
+	}
+
+	var found, missing []string
+	seen := make(map[string]bool)
+	for _, report := range reports {
+		if strings.ToLower(filepath.Ext(report)) != ".xml" {
+			continue
+		}
+		refs, err := attachmentRefs(report)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, ref := range refs {
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			if file := resolveAttachment(ref, report); file != "" {
+				found = append(found, file)
+			} else {
+				missing = append(missing, ref)
+			}
+		}
+	}
+	return found, missing, nil
+}
+
+// attachmentRefs lists the paths a report references as attachments
+func attachmentRefs(report string) ([]string, error) {
+	file, err := os.Open(report)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	var refs []string
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		for _, match := range attachmentMarker.FindAllStringSubmatch(line, -1) {
+			refs = append(refs, strings.TrimSpace(match[1]))
+		}
+		if err == io.EOF {
+			return refs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", report, err)
+		}
+	}
+}
+
+// resolveAttachment returns the regular file a referenced path stands for, or "" when there is none
+func resolveAttachment(ref, report string) string {
+	candidates := []string{ref}
+	if !filepath.IsAbs(ref) {
+		candidates = append(candidates, filepath.Join(filepath.Dir(report), ref))
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate
+		}
+	}
+	return ""
+}
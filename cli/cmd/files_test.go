@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTree creates the files of names, slash-separated and relative to a new temporary
+// directory, and returns the directory
+func writeTree(t *testing.T, names ...string) string {
+	dir := t.TempDir()
+	for _, name := range names {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(file, []byte("<testsuite/>"), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestResolveReportFiles(t *testing.T) {
+	dir := writeTree(t,
+		"top.xml",
+		"reports/go/test.json",
+		"reports/integration/b.xml",
+		"reports/integration/deep/c.xml",
+		"reports/unit/a.xml",
+		"reports/unit/notes.txt",
+		"reports/empty/.keep",
+	)
+	in := func(names ...string) []string {
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = filepath.Join(dir, filepath.FromSlash(name))
+		}
+		return paths
+	}
+
+	tests := []struct {
+		name       string
+		patterns   []string
+		reportType string
+		want       []string
+		wantErr    string
+	}{
+		{
+			name:       "file",
+			patterns:   in("reports/unit/a.xml"),
+			reportType: "junit",
+			want:       in("reports/unit/a.xml"),
+		},
+		{
+			name:       "directory searched for the extensions of the type",
+			patterns:   in("reports"),
+			reportType: "junit",
+			want:       in("reports/integration/b.xml", "reports/integration/deep/c.xml", "reports/unit/a.xml"),
+		},
+		{
+			name:       "directory searched for the extensions of any type",
+			patterns:   in("reports"),
+			reportType: "auto",
+			want: in("reports/go/test.json", "reports/integration/b.xml", "reports/integration/deep/c.xml",
+				"reports/unit/a.xml", "reports/unit/notes.txt"),
+		},
+		{
+			name:       "glob",
+			patterns:   in("reports/*/*.xml"),
+			reportType: "junit",
+			want:       in("reports/integration/b.xml", "reports/unit/a.xml"),
+		},
+		{
+			name:       "double star matches any number of directories",
+			patterns:   in("**/*.xml"),
+			reportType: "junit",
+			want:       in("reports/integration/b.xml", "reports/integration/deep/c.xml", "reports/unit/a.xml", "top.xml"),
+		},
+		{
+			name:       "double star within the pattern",
+			patterns:   in("reports/**/deep/*.xml"),
+			reportType: "junit",
+			want:       in("reports/integration/deep/c.xml"),
+		},
+		{
+			name:       "glob matching a directory",
+			patterns:   in("reports/u*"),
+			reportType: "junit",
+			want:       in("reports/unit/a.xml"),
+		},
+		{
+			name:       "files named twice are uploaded once",
+			patterns:   append(in("reports/unit/a.xml"), in("reports/unit", "reports/*/a.xml")...),
+			reportType: "junit",
+			want:       in("reports/unit/a.xml"),
+		},
+		{
+			name:       "allure results directory as a whole",
+			patterns:   in("reports"),
+			reportType: "allure",
+			want:       in("reports"),
+		},
+		{
+			name:       "several allure results directories",
+			patterns:   in("reports/unit", "reports/integration"),
+			reportType: "allure",
+			wantErr:    "single allure-results directory",
+		},
+		{
+			name:       "glob without matches",
+			patterns:   in("reports/*/*.trx"),
+			reportType: "trx",
+			wantErr:    "no files match",
+		},
+		{
+			name:       "directory without reports of the type",
+			patterns:   in("reports/go"),
+			reportType: "junit",
+			wantErr:    "no junit report files found",
+		},
+		{
+			name:       "directory without reports of any type",
+			patterns:   in("reports/empty"),
+			reportType: "auto",
+			wantErr:    "no report files found",
+		},
+		{
+			name:       "missing file",
+			patterns:   in("missing.xml"),
+			reportType: "junit",
+			wantErr:    "error opening file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveReportFiles(tt.patterns, tt.reportType)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveReportFiles() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveReportFiles() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveReportFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"reports/*.xml", "reports/a.xml", true},
+		{"reports/*.xml", "reports/unit/a.xml", false},
+		{"reports/**/*.xml", "reports/a.xml", true},
+		{"reports/**/*.xml", "reports/unit/deep/a.xml", true},
+		{"reports/**", "reports/unit/a.xml", true},
+		{"**/TEST-*.xml", "build/test-results/TEST-Pay.xml", true},
+		{"**/TEST-*.xml", "build/test-results/Pay.xml", false},
+		{"reports/**/unit/*.xml", "reports/unit/a.xml", true},
+		{"reports/**/unit/*.xml", "reports/integration/a.xml", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			got := matchSegments(strings.Split(tt.pattern, "/"), strings.Split(tt.name, "/"))
+			if got != tt.want {
+				t.Errorf("matchSegments(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlobRoot(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"reports/**/*.xml", "reports"},
+		{"build/test-results/*/TEST-*.xml", "build/test-results"},
+		{"**/*.xml", "."},
+		{"/ci/reports/**/*.xml", "/ci/reports"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if got := globRoot(strings.Split(tt.pattern, "/")); got != tt.want {
+				t.Errorf("globRoot(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,69 +3,151 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/BennyEisner/test-results/cli/internal/ci"
 	"github.com/BennyEisner/test-results/cli/internal/client"
 	"github.com/BennyEisner/test-results/cli/internal/config"
 	"github.com/spf13/cobra"
 )
 
 var (
-	project  string
-	tags     []string
-	file     string
-	testType string
+	project     string
+	files       []string
+	testType    string
+	buildNumber string
+	wait        bool
+	waitTimeout time.Duration
+	attach      bool
+	build       client.BuildMetadata
+	properties  []string
+	detectCI    bool
+	dryRun      bool
+	shardBuild  int64
+	shardIndex  int
 )
 
+// pollInterval is the time between two checks of an import while waiting for it
+const pollInterval = 2 * time.Second
+
+// supportedTypes are the report formats the API can parse
+var supportedTypes = []string{"auto", "junit", "readyapi", "nunit", "xunit", "trx", "tap", "gotest", "cucumber", "ctrf", "allure"}
+
+func isSupportedType(t string) bool {
+	for _, supported := range supportedTypes {
+		if t == supported {
+			return true
+		}
+	}
+	return false
+}
+
 var postCmd = &cobra.Command{
-	Use:   "post",
+	Use:   "post [files...]",
 	Short: "Post test results to the REST API",
-	Long: `Upload test results (JUnit or ReadyAPI format) to a centralized results API.
+	Long: `Upload test results (JUnit, ReadyAPI, NUnit 3, xUnit v2, TRX, TAP, go test -json, Cucumber JSON, CTRF or Allure format) to a centralized results API.
+The API detects the format of the results unless --type names it.
+
+--file takes files, directories and glob patterns, where ** matches any number of
+directories; files given as arguments are added to them. Directories are searched
+for report files of the --type. Several reports are zipped and imported as one build.
+Files that JUnit reports reference with [[ATTACHMENT|path]] in the output of a test
+are uploaded with them and stored as attachments of the test.
+
+In GitHub Actions, GitLab CI, Jenkins, CircleCI, Azure Pipelines, Buildkite and Travis CI
+the build number, CI provider, run URL, branch, commit and pull request are read from
+the environment unless flags give them. --dry-run shows what was detected.
+
+In a build split across parallel CI shards, open the build once with
+'test-results shards open' and have every shard post its results with --build-id and
+--shard-index; the build is complete once all of its shards posted.
+
+--tags labels the build, e.g. to chart smoke and regression runs apart; test cases
+are tagged from their tag and tags properties in JUnit reports and from Cucumber tags.
 
 Example:
-  test-results post --project myproj --file results.xml --type junit --tags smoke,api`,
+  test-results post --project myproj --file results.xml --type junit --tags smoke,api
+  go test -json ./... > results.json && test-results post --project 1:2 --file results.json
+  test-results post --project 1:2 --file build/allure-results --type allure
+  test-results post --project 1:2 --file 'modules/**/TEST-*.xml'
+  test-results post --project 1:2 build/test-results/*.xml
+  test-results post --project 1:2 --file results.xml --build-number 42 --wait
+  test-results post --project 1:2 --file results.xml --build-id 17 --shard-index $CI_NODE_INDEX
+  test-results post --project 1:2 --file results.xml --commit $(git rev-parse HEAD) --branch main --property environment=staging`,
 
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if project == "" {
 			return fmt.Errorf("required flag --project not set")
 		}
-		if file == "" {
+		if !isSupportedType(testType) {
+			return fmt.Errorf("unsupported --type: %s (must be one of: %s)", testType, strings.Join(supportedTypes, ", "))
+		}
+		if !cmd.Flags().Changed("file") && len(args) > 0 {
+			files = nil
+		}
+		paths, err := resolveReportFiles(append(files, args...), testType)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
 			return fmt.Errorf("required flag --file not set")
 		}
-		if testType != "junit" && testType != "readyapi" {
-			return fmt.Errorf("unsupported --type: %s (must be 'junit' or 'readyapi')", testType)
+		var attachments, missing []string
+		if attach {
+			if attachments, missing, err = findAttachments(paths, testType); err != nil {
+				return err
+			}
 		}
-
-		// For now, only implement JUnit posting
-		if testType != "junit" {
-			return fmt.Errorf("only 'junit' type is currently supported")
+		if build.Properties, err = parseProperties(properties); err != nil {
+			return err
 		}
-
-		// Parse project and suite IDs from the project flag
-		// Expected format: "projectID:suiteID"
-		parts := strings.Split(project, ":")
-		if len(parts) != 2 {
-			return fmt.Errorf("project flag must be in format 'projectID:suiteID' (e.g., '1:2')")
+		var detected *ci.Environment
+		if detectCI {
+			detected = ci.Detect(os.Getenv)
+			applyCIEnvironment(detected)
 		}
 
-		projectID, err := strconv.ParseInt(parts[0], 10, 64)
+		projectID, suiteID, err := parseProject(project)
 		if err != nil {
-			return fmt.Errorf("invalid project ID: %s", parts[0])
+			return err
 		}
-
-		suiteID, err := strconv.ParseInt(parts[1], 10, 64)
+		shard, err := uploadShard()
 		if err != nil {
-			return fmt.Errorf("invalid suite ID: %s", parts[1])
+			return err
 		}
 
-		fmt.Printf("Posting JUnit results:\n")
+		if detected != nil {
+			fmt.Printf("Detected %s\n", detected.Name)
+		}
+		fmt.Println("Posting test results:")
 		fmt.Printf("- Project ID: %d\n", projectID)
 		fmt.Printf("- Suite ID: %d\n", suiteID)
-		fmt.Printf("- File: %s\n", file)
+		if len(paths) == 1 {
+			fmt.Printf("- File: %s\n", paths[0])
+		} else {
+			fmt.Printf("- Files: %d, uploaded as one zip archive\n", len(paths))
+		}
+		if len(attachments) > 0 {
+			fmt.Printf("- Attachments: %d\n", len(attachments))
+		}
+		for _, ref := range missing {
+			fmt.Printf("Warning: attachment %s not found\n", ref)
+		}
 		fmt.Printf("- Type: %s\n", testType)
-		if len(tags) > 0 {
-			fmt.Printf("- Tags: %s\n", strings.Join(tags, ", "))
+		if buildNumber != "" {
+			fmt.Printf("- Build number: %s\n", buildNumber)
+		}
+		printBuildMetadata(build)
+		if shard != nil {
+			fmt.Printf("- Shard %d of build %d\n", shard.Index, shard.BuildID)
+		}
+		if dryRun {
+			fmt.Println("Dry run: nothing was uploaded.")
+			return nil
 		}
 
 		// Load configuration
@@ -75,23 +157,203 @@ Example:
 		apiClient := client.NewAPIClient(cfg)
 
 		// Call the client to upload the file
-		response, err := apiClient.PostJUnitFile(projectID, suiteID, file)
+		job, err := apiClient.PostTestResults(projectID, suiteID, paths, client.UploadOptions{
+			Format:      testType,
+			BuildNumber: buildNumber,
+			Attachments: attachments,
+			Build:       build,
+			Shard:       shard,
+		})
 		if err != nil {
-			log.Fatalf("Error uploading JUnit file: %v", err)
+			log.Fatalf("Error uploading test results: %v", err)
 		}
 
-		fmt.Println("Successfully uploaded JUnit file.")
-		fmt.Println("API Response:", response)
+		if job.Replayed {
+			fmt.Println("These test results were already uploaded; not importing them again.")
+		} else {
+			fmt.Printf("Successfully uploaded %s test results.\n", job.Format)
+		}
+		fmt.Printf("Import %d is %s; check it at %s/api/imports/%d\n", job.ID, job.State, cfg.APIBaseURL, job.ID)
+		if !wait {
+			return nil
+		}
 
-		return nil
+		job, err = apiClient.WaitForImport(job.ID, pollInterval, waitTimeout, printImportProgress())
+		if err != nil {
+			return err
+		}
+		return reportImport(job)
 	},
 }
 
+// parseProject reads the project and suite IDs of the --project flag, given as "projectID:suiteID"
+func parseProject(value string) (int64, int64, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("project flag must be in format 'projectID:suiteID' (e.g., '1:2')")
+	}
+
+	projectID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid project ID: %s", parts[0])
+	}
+
+	suiteID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid suite ID: %s", parts[1])
+	}
+	return projectID, suiteID, nil
+}
+
+// uploadShard reads the sharded build and shard index of --build-id and --shard-index,
+// which are given together
+func uploadShard() (*client.Shard, error) {
+	if shardBuild == 0 && shardIndex < 0 {
+		return nil, nil
+	}
+	if shardBuild <= 0 || shardIndex < 0 {
+		return nil, fmt.Errorf("--build-id and --shard-index must be given together")
+	}
+	return &client.Shard{BuildID: shardBuild, Index: shardIndex}, nil
+}
+
+// applyCIEnvironment fills the build number and build metadata the flags leave empty
+// from the environment of the CI service the CLI runs in
+func applyCIEnvironment(env *ci.Environment) {
+	if env == nil {
+		return
+	}
+	for _, field := range []struct {
+		value    *string
+		detected string
+	}{
+		{&buildNumber, env.BuildNumber},
+		{&build.CIProvider, env.Provider},
+		{&build.CIURL, env.URL},
+		{&build.CommitSHA, env.CommitSHA},
+		{&build.Branch, env.Branch},
+		{&build.BaseBranch, env.BaseBranch},
+		{&build.Author, env.Author},
+		{&build.Trigger, env.Trigger},
+	} {
+		if *field.value == "" {
+			*field.value = field.detected
+		}
+	}
+	if build.PRNumber == 0 {
+		build.PRNumber = env.PRNumber
+	}
+}
+
+// parseProperties reads the name=value pairs of --property
+func parseProperties(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	properties := make(map[string]string, len(values))
+	for _, value := range values {
+		name, v, ok := strings.Cut(value, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid --property %q: must be name=value", value)
+		}
+		properties[strings.TrimSpace(name)] = v
+	}
+	return properties, nil
+}
+
+// printBuildMetadata prints the build metadata and tags that are set
+func printBuildMetadata(build client.BuildMetadata) {
+	for _, field := range [][2]string{
+		{"CI provider", build.CIProvider},
+		{"CI URL", build.CIURL},
+		{"Commit", build.CommitSHA},
+		{"Branch", build.Branch},
+		{"Base branch", build.BaseBranch},
+		{"Author", build.Author},
+		{"Trigger", build.Trigger},
+	} {
+		if field[1] != "" {
+			fmt.Printf("- %s: %s\n", field[0], field[1])
+		}
+	}
+	if build.PRNumber > 0 {
+		fmt.Printf("- Pull request: #%d\n", build.PRNumber)
+	}
+	for _, name := range sortedKeys(build.Properties) {
+		fmt.Printf("- %s: %s\n", name, build.Properties[name])
+	}
+	if len(build.Tags) > 0 {
+		fmt.Printf("- Tags: %s\n", strings.Join(build.Tags, ", "))
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printImportProgress returns a callback printing an import job whenever its state or counts change
+func printImportProgress() func(*client.ImportJob) {
+	var last string
+	return func(job *client.ImportJob) {
+		line := fmt.Sprintf("- %s: %d test cases", job.State, job.Total)
+		if line != last {
+			fmt.Println(line)
+			last = line
+		}
+	}
+}
+
+// reportImport prints the outcome of a finished import, failing when the import failed
+func reportImport(job *client.ImportJob) error {
+	for _, warning := range job.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+	for _, file := range job.Files {
+		if file.Error != "" {
+			fmt.Printf("- %s: not imported: %s\n", file.Name, file.Error)
+		} else {
+			fmt.Printf("- %s: %d test cases\n", file.Name, file.Total)
+		}
+	}
+	if job.State != "done" {
+		return fmt.Errorf("import %d failed: %s", job.ID, job.Error)
+	}
+
+	fmt.Printf("Imported %d test cases: %d passed, %d failed, %d errored, %d skipped\n",
+		job.Total, job.Passed, job.Failed, job.Errored, job.Skipped)
+	if job.BuildID != nil {
+		fmt.Printf("Build ID: %d\n", *job.BuildID)
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(postCmd)
 	postCmd.Flags().StringVar(&project, "project", "", "Project ID (required)")
-	postCmd.Flags().StringVar(&file, "file", "junit.xml", "Path to JUnit XML file (optional)")
-	postCmd.Flags().StringVar(&testType, "type", "junit", "Test type: junit or readyapi (optional)")
-	postCmd.Flags().StringSliceVar(&tags, "tags", nil, "Comma-separated tags (optional)")
+	postCmd.Flags().StringSliceVar(&files, "file", []string{"junit.xml"}, "Test report files, directories or glob patterns, or an allure-results directory (optional)")
+	postCmd.Flags().StringVar(&testType, "type", "auto", "Test type: auto, junit, readyapi, nunit, xunit, trx, tap, gotest, cucumber, ctrf or allure (optional)")
+	postCmd.Flags().StringVar(&buildNumber, "build-number", "", "Build number of the results, defaults to the upload time; retried uploads of a build are imported once (optional)")
+	postCmd.Flags().BoolVar(&attach, "attachments", true, "Upload the files JUnit reports reference with [[ATTACHMENT|path]] (optional)")
+	postCmd.Flags().StringVar(&build.CIProvider, "ci-provider", "", "CI provider that ran the tests (optional)")
+	postCmd.Flags().StringVar(&build.CIURL, "ci-url", "", "URL of the CI run (optional)")
+	postCmd.Flags().StringVar(&build.CommitSHA, "commit", "", "Commit SHA the tests ran against (optional)")
+	postCmd.Flags().StringVar(&build.Branch, "branch", "", "Branch the tests ran on (optional)")
+	postCmd.Flags().StringVar(&build.BaseBranch, "base-branch", "", "Branch the pull request merges into (optional)")
+	postCmd.Flags().IntVar(&build.PRNumber, "pr", 0, "Pull request number (optional)")
+	postCmd.Flags().StringVar(&build.Author, "author", "", "Author of the commit or pull request (optional)")
+	postCmd.Flags().StringVar(&build.Trigger, "trigger", "", "What triggered the build, e.g. push, pull_request, schedule or manual (optional)")
+	postCmd.Flags().StringArrayVar(&properties, "property", nil, "Build property as name=value, e.g. environment=staging; may be repeated (optional)")
+	postCmd.Flags().BoolVar(&detectCI, "detect-ci", true, "Fill the build number and metadata not given by flags from the environment of the CI service (optional)")
+	postCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be uploaded, including the detected CI build, without uploading (optional)")
+	postCmd.Flags().StringSliceVar(&build.Tags, "tags", nil, "Comma-separated tags of the build, e.g. smoke,api; may be repeated (optional)")
+	postCmd.Flags().Int64Var(&shardBuild, "build-id", 0, "Sharded build to add the results to, opened with 'shards open' (optional)")
+	postCmd.Flags().IntVar(&shardIndex, "shard-index", -1, "Index of the CI shard posting the results, from 0; requires --build-id (optional)")
+	postCmd.Flags().BoolVar(&wait, "wait", false, "Wait until the API has finished importing the results (optional)")
+	postCmd.Flags().DurationVar(&waitTimeout, "wait-timeout", 30*time.Minute, "Longest time to wait with --wait (optional)")
 	postCmd.MarkFlagRequired("project")
 }
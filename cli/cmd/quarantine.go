@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/BennyEisner/test-results/cli/internal/client"
+	"github.com/BennyEisner/test-results/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	quarantineProject int64
+	skipFormat        string
+	skipOutput        string
+)
+
+// skipWriter writes the skip file of a test runner for quarantined test cases, returning
+// the quarantined test cases the test runner cannot skip
+type skipWriter func(w io.Writer, quarantines []client.Quarantine) ([]client.Quarantine, error)
+
+// skipFormats are the skip writers of each test runner, keyed by format name
+var skipFormats = map[string]skipWriter{
+	"plain":    writePlainSkips,
+	"surefire": writeSurefireSkips,
+	"go":       writeGoSkips,
+	"jest":     writeJestSkips,
+	"pytest":   writePytestSkips,
+}
+
+var quarantineCmd = &cobra.Command{
+	Use:   "quarantine",
+	Short: "Write the quarantined tests of a project as a skip file for a test runner",
+	Long: `Fetch the active quarantines of a project and write the quarantined tests in
+the --format of a test runner, to stdout or the --output file:
+
+  plain     the class name and name of a test, tab separated, one test per line
+  surefire  Class#method lines for -Dsurefire.excludesFile
+  go        a regular expression for go test -skip; subtests are left out, as -skip
+            matches the levels of a test name one by one
+  jest      a regular expression for --testNamePattern that matches all other tests
+  pytest    an expression for -k; the parameters of parameterized tests are dropped,
+            so all their cases are skipped
+
+Example:
+  test-results quarantine --project 1 --format surefire --output quarantined.txt
+  mvn test -Dsurefire.excludesFile=quarantined.txt
+  go test -skip "$(test-results quarantine --project 1 --format go)" ./...`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		write, ok := skipFormats[skipFormat]
+		if !ok {
+			return fmt.Errorf("unsupported format %q; use plain, surefire, go, jest or pytest", skipFormat)
+		}
+
+		apiClient := client.NewAPIClient(config.LoadConfig())
+		quarantines, err := apiClient.GetQuarantines(quarantineProject)
+		if err != nil {
+			return fmt.Errorf("error fetching quarantines: %w", err)
+		}
+
+		out := io.Writer(os.Stdout)
+		if skipOutput != "" {
+			file, err := os.Create(skipOutput)
+			if err != nil {
+				return fmt.Errorf("error creating %s: %w", skipOutput, err)
+			}
+			defer file.Close()
+			out = file
+		}
+		leftOut, err := write(out, quarantines)
+		if err != nil {
+			return fmt.Errorf("error writing skip file: %w", err)
+		}
+		for _, q := range leftOut {
+			fmt.Fprintf(os.Stderr, "Leaving out %s: the %s format cannot skip it\n", q.TestCaseName, skipFormat)
+		}
+		fmt.Fprintf(os.Stderr, "%d quarantined tests\n", len(quarantines)-len(leftOut))
+		return nil
+	},
+}
+
+func writePlainSkips(w io.Writer, quarantines []client.Quarantine) ([]client.Quarantine, error) {
+	for _, q := range quarantines {
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", q.ClassName, q.TestCaseName); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func writeSurefireSkips(w io.Writer, quarantines []client.Quarantine) ([]client.Quarantine, error) {
+	for _, q := range quarantines {
+		if _, err := fmt.Fprintf(w, "%s#%s\n", q.ClassName, q.TestCaseName); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// writeGoSkips leaves out subtests, as go test -skip cannot skip a subtest alone
+func writeGoSkips(w io.Writer, quarantines []client.Quarantine) ([]client.Quarantine, error) {
+	var names []string
+	var leftOut []client.Quarantine
+	for _, q := range quarantines {
+		if strings.Contains(q.TestCaseName, "/") {
+			leftOut = append(leftOut, q)
+			continue
+		}
+		names = append(names, regexp.QuoteMeta(q.TestCaseName))
+	}
+	if len(names) == 0 {
+		return leftOut, nil
+	}
+	_, err := fmt.Fprintf(w, "^(%s)$\n", strings.Join(uniqueNames(names), "|"))
+	return leftOut, err
+}
+
+func writeJestSkips(w io.Writer, quarantines []client.Quarantine) ([]client.Quarantine, error) {
+	names := make([]string, len(quarantines))
+	for i, q := range quarantines {
+		names[i] = regexp.QuoteMeta(q.TestCaseName)
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	_, err := fmt.Fprintf(w, "^(?!(%s)$).*\n", strings.Join(uniqueNames(names), "|"))
+	return nil, err
+}
+
+func writePytestSkips(w io.Writer, quarantines []client.Quarantine) ([]client.Quarantine, error) {
+	names := make([]string, len(quarantines))
+	for i, q := range quarantines {
+		name, _, _ := strings.Cut(q.TestCaseName, "[")
+		names[i] = name
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	_, err := fmt.Fprintf(w, "not (%s)\n", strings.Join(uniqueNames(names), " or "))
+	return nil, err
+}
+
+// uniqueNames drops the repeated names, keeping the first of each
+func uniqueNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	unique := names[:0]
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+func init() {
+	rootCmd.AddCommand(quarantineCmd)
+
+	quarantineCmd.Flags().Int64Var(&quarantineProject, "project", 0, "Project ID (required)")
+	quarantineCmd.Flags().StringVar(&skipFormat, "format", "plain", "Skip file format: plain, surefire, go, jest or pytest (optional)")
+	quarantineCmd.Flags().StringVar(&skipOutput, "output", "", "File to write the skip file to, defaults to stdout (optional)")
+	quarantineCmd.MarkFlagRequired("project")
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/BennyEisner/test-results/cli/internal/client"
+)
+
+// quarantined returns quarantines of the test cases with the names, all of one class
+func quarantined(names ...string) []client.Quarantine {
+	quarantines := make([]client.Quarantine, len(names))
+	for i, name := range names {
+		quarantines[i] = client.Quarantine{ID: int64(i + 1), TestCaseName: name, ClassName: "com.example.CheckoutTest"}
+	}
+	return quarantines
+}
+
+func TestSkipWriters(t *testing.T) {
+	tests := []struct {
+		name        string
+		write       skipWriter
+		quarantines []client.Quarantine
+		want        string
+		wantLeftOut []string
+	}{
+		{
+			name:        "plain",
+			write:       writePlainSkips,
+			quarantines: quarantined("testPay", "testRefund"),
+			want:        "com.example.CheckoutTest\ttestPay\ncom.example.CheckoutTest\ttestRefund\n",
+		},
+		{
+			name:        "surefire",
+			write:       writeSurefireSkips,
+			quarantines: quarantined("testPay", "testRefund"),
+			want:        "com.example.CheckoutTest#testPay\ncom.example.CheckoutTest#testRefund\n",
+		},
+		{
+			name:        "go",
+			write:       writeGoSkips,
+			quarantines: quarantined("TestPay", "TestRefund"),
+			want:        "^(TestPay|TestRefund)$\n",
+		},
+		{
+			name:        "go quotes regular expression characters",
+			write:       writeGoSkips,
+			quarantines: quarantined("TestPay.Card", "TestRefund(1)"),
+			want:        `^(TestPay\.Card|TestRefund\(1\))$` + "\n",
+		},
+		{
+			name:        "go leaves out subtests",
+			write:       writeGoSkips,
+			quarantines: quarantined("TestPay", "TestRefund/partial", "TestCancel/by_user"),
+			want:        "^(TestPay)$\n",
+			wantLeftOut: []string{"TestRefund/partial", "TestCancel/by_user"},
+		},
+		{
+			name:        "go with only subtests",
+			write:       writeGoSkips,
+			quarantines: quarantined("TestRefund/partial"),
+			want:        "",
+			wantLeftOut: []string{"TestRefund/partial"},
+		},
+		{
+			name:        "go drops repeated names",
+			write:       writeGoSkips,
+			quarantines: quarantined("TestPay", "TestRefund", "TestPay"),
+			want:        "^(TestPay|TestRefund)$\n",
+		},
+		{
+			name:        "jest",
+			write:       writeJestSkips,
+			quarantines: quarantined("pays by card", "refunds (partial)", "pays by card"),
+			want:        `^(?!(pays by card|refunds \(partial\))$).*` + "\n",
+		},
+		{
+			name:        "pytest drops parameters",
+			write:       writePytestSkips,
+			quarantines: quarantined("test_pay[card]", "test_pay[cash]", "test_refund"),
+			want:        "not (test_pay or test_refund)\n",
+		},
+		{
+			name:        "no quarantines",
+			write:       writePytestSkips,
+			quarantines: nil,
+			want:        "",
+		},
+		{
+			name:        "jest without quarantines",
+			write:       writeJestSkips,
+			quarantines: nil,
+			want:        "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			leftOut, err := tt.write(&out, tt.quarantines)
+
+			if err != nil {
+				t.Fatalf("write() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("write() wrote %q, want %q", out.String(), tt.want)
+			}
+			var names []string
+			for _, q := range leftOut {
+				names = append(names, q.TestCaseName)
+			}
+			if !reflect.DeepEqual(names, tt.wantLeftOut) {
+				t.Errorf("write() left out %v, want %v", names, tt.wantLeftOut)
+			}
+		})
+	}
+}
+
+func TestGoSkipsMatchTheQuarantinedTests(t *testing.T) {
+	var out bytes.Buffer
+	if _, err := writeGoSkips(&out, quarantined("TestPay.Card", "TestRefund")); err != nil {
+		t.Fatalf("writeGoSkips() error = %v", err)
+	}
+	skip := regexp.MustCompile(out.String()[:out.Len()-1])
+
+	for name, want := range map[string]bool{
+		"TestPay.Card":  true,
+		"TestRefund":    true,
+		"TestPayXCard":  false,
+		"TestRefundAll": false,
+		"XTestRefund":   false,
+	} {
+		if got := skip.MatchString(name); got != want {
+			t.Errorf("skip pattern matches %s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestUniqueNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{"no repeats", []string{"a", "b"}, []string{"a", "b"}},
+		{"repeats keep the first", []string{"b", "a", "b", "c", "a"}, []string{"b", "a", "c"}},
+		{"empty", []string{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := uniqueNames(tt.names); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("uniqueNames(%v) = %v, want %v", tt.names, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BennyEisner/test-results/cli/internal/ci"
+	"github.com/BennyEisner/test-results/cli/internal/client"
+	"github.com/BennyEisner/test-results/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	shardCount   int
+	shardTimeout time.Duration
+	finalizeID   int64
+)
+
+var shardsCmd = &cobra.Command{
+	Use:   "shards",
+	Short: "Open and finalize builds whose results are posted by parallel CI shards",
+	Long: `A sharded build collects the results of the parallel shards of one CI run.
+Open it once before the shards run, pass its ID to every shard, and post the results
+of each shard with --build-id and --shard-index. The build is finalized once all of
+its shards posted or its timeout elapsed; 'shards finalize' finalizes it earlier.
+
+Example:
+  BUILD_ID=$(test-results shards open --project 1:2 --shards 20)
+  test-results post --project 1:2 --file results.xml --build-id $BUILD_ID --shard-index 3
+  test-results shards finalize --build-id $BUILD_ID`,
+}
+
+var shardsOpenCmd = &cobra.Command{
+	Use:   "open",
+	Short: "Open a build that waits for the results of parallel CI shards and print its ID",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if shardCount < 1 {
+			return fmt.Errorf("--shards must be at least 1")
+		}
+		_, suiteID, err := parseProject(project)
+		if err != nil {
+			return err
+		}
+		if build.Properties, err = parseProperties(properties); err != nil {
+			return err
+		}
+		if detectCI {
+			applyCIEnvironment(ci.Detect(os.Getenv))
+		}
+		if buildNumber == "" {
+			buildNumber = time.Now().UTC().Format("20060102-150405")
+		}
+
+		apiClient := client.NewAPIClient(config.LoadConfig())
+		opened, err := apiClient.OpenShardedBuild(suiteID, buildNumber, build, shardCount, shardTimeout)
+		if err != nil {
+			return fmt.Errorf("error opening build: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Opened build %s waiting for %d shards\n", opened.BuildNumber, shardCount)
+		// The ID alone goes to stdout so that scripts can capture it
+		fmt.Println(opened.ID)
+		return nil
+	},
+}
+
+var shardsFinalizeCmd = &cobra.Command{
+	Use:   "finalize",
+	Short: "Finalize a sharded build with the shards posted so far",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		apiClient := client.NewAPIClient(config.LoadConfig())
+		finalized, err := apiClient.FinalizeBuild(finalizeID)
+		if err != nil {
+			return fmt.Errorf("error finalizing build: %w", err)
+		}
+		fmt.Printf("Build %s finalized with %d shards\n", finalized.BuildNumber, finalized.ReceivedShards)
+		if len(finalized.MissingShards) > 0 {
+			missing := make([]string, len(finalized.MissingShards))
+			for i, index := range finalized.MissingShards {
+				missing[i] = fmt.Sprint(index)
+			}
+			fmt.Printf("Missing shards: %s\n", strings.Join(missing, ", "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(shardsCmd)
+	shardsCmd.AddCommand(shardsOpenCmd, shardsFinalizeCmd)
+
+	shardsOpenCmd.Flags().StringVar(&project, "project", "", "Project and suite IDs as projectID:suiteID (required)")
+	shardsOpenCmd.Flags().IntVar(&shardCount, "shards", 0, "Number of CI shards posting results to the build (required)")
+	shardsOpenCmd.Flags().DurationVar(&shardTimeout, "timeout", 0, "Time after which the build is finalized with the shards posted so far, at most 24h; defaults to 1h (optional)")
+	shardsOpenCmd.Flags().StringVar(&buildNumber, "build-number", "", "Build number, defaults to the one of the CI run or the current time (optional)")
+	shardsOpenCmd.Flags().StringVar(&build.CommitSHA, "commit", "", "Commit SHA the tests run against (optional)")
+	shardsOpenCmd.Flags().StringVar(&build.Branch, "branch", "", "Branch the tests run on (optional)")
+	shardsOpenCmd.Flags().StringArrayVar(&properties, "property", nil, "Build property as name=value; may be repeated (optional)")
+	shardsOpenCmd.Flags().StringSliceVar(&build.Tags, "tags", nil, "Comma-separated tags of the build (optional)")
+	shardsOpenCmd.Flags().BoolVar(&detectCI, "detect-ci", true, "Fill the build number and metadata not given by flags from the environment of the CI service (optional)")
+	shardsOpenCmd.MarkFlagRequired("project")
+	shardsOpenCmd.MarkFlagRequired("shards")
+
+	shardsFinalizeCmd.Flags().Int64Var(&finalizeID, "build-id", 0, "Sharded build to finalize (required)")
+	shardsFinalizeCmd.MarkFlagRequired("build-id")
+}
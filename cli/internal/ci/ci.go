@@ -0,0 +1,243 @@
+// Package ci detects the CI service the CLI runs in from its environment variables
+package ci
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Environment is what a CI service tells about the build it runs; fields it does not
+// provide are empty
+type Environment struct {
+	Provider    string // identifier sent as the CI provider of the build, e.g. "github-actions"
+	Name        string // name of the CI service, e.g. "GitHub Actions"
+	BuildNumber string
+	URL         string // web page of the build
+	CommitSHA   string
+	Branch      string
+	BaseBranch  string // branch a pull request merges into
+	PRNumber    int
+	Author      string
+	Trigger     string // push, pull_request, schedule, manual or what else the service reports
+}
+
+// detector recognizes one CI service and reads its environment
+type detector struct {
+	detect func(getenv func(string) string) bool
+	read   func(getenv func(string) string) *Environment
+}
+
+var detectors = []detector{
+	{isSet("GITHUB_ACTIONS"), githubActions},
+	{isSet("GITLAB_CI"), gitlabCI},
+	{isSet("CIRCLECI"), circleCI},
+	{isSet("TF_BUILD"), azurePipelines},
+	{isSet("BUILDKITE"), buildkite},
+	{isSet("TRAVIS"), travis},
+	{isSet("JENKINS_URL"), jenkins},
+}
+
+// Detect returns the environment of the CI service the CLI runs in, or nil when it
+// does not run in a known CI service. getenv is usually os.Getenv.
+func Detect(getenv func(string) string) *Environment {
+	for _, d := range detectors {
+		if d.detect(getenv) {
+			return d.read(getenv)
+		}
+	}
+	return nil
+}
+
+func isSet(name string) func(getenv func(string) string) bool {
+	return func(getenv func(string) string) bool {
+		value := strings.ToLower(getenv(name))
+		return value != "" && value != "false"
+	}
+}
+
+func githubActions(getenv func(string) string) *Environment {
+	env := &Environment{
+		Provider:    "github-actions",
+		Name:        "GitHub Actions",
+		BuildNumber: getenv("GITHUB_RUN_NUMBER"),
+		CommitSHA:   getenv("GITHUB_SHA"),
+		Branch:      firstOf(getenv("GITHUB_HEAD_REF"), branchOf(getenv("GITHUB_REF"))),
+		BaseBranch:  getenv("GITHUB_BASE_REF"),
+		PRNumber:    pullRequestOf(getenv("GITHUB_REF")),
+		Author:      getenv("GITHUB_ACTOR"),
+		Trigger:     trigger(getenv("GITHUB_EVENT_NAME"), map[string]string{"pull_request_target": "pull_request", "workflow_dispatch": "manual"}),
+	}
+	if server, repo, run := getenv("GITHUB_SERVER_URL"), getenv("GITHUB_REPOSITORY"), getenv("GITHUB_RUN_ID"); server != "" && repo != "" && run != "" {
+		env.URL = server + "/" + repo + "/actions/runs/" + run
+	}
+	return env
+}
+
+func gitlabCI(getenv func(string) string) *Environment {
+	return &Environment{
+		Provider:    "gitlab-ci",
+		Name:        "GitLab CI",
+		BuildNumber: getenv("CI_PIPELINE_IID"),
+		URL:         getenv("CI_PIPELINE_URL"),
+		CommitSHA:   getenv("CI_COMMIT_SHA"),
+		Branch:      firstOf(getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME"), getenv("CI_COMMIT_BRANCH")),
+		BaseBranch:  getenv("CI_MERGE_REQUEST_TARGET_BRANCH_NAME"),
+		PRNumber:    number(getenv("CI_MERGE_REQUEST_IID")),
+		Author:      getenv("GITLAB_USER_LOGIN"),
+		Trigger:     trigger(getenv("CI_PIPELINE_SOURCE"), map[string]string{"merge_request_event": "pull_request", "web": "manual"}),
+	}
+}
+
+func circleCI(getenv func(string) string) *Environment {
+	pr := number(getenv("CIRCLE_PR_NUMBER"))
+	if pr == 0 {
+		pr = number(lastSegment(getenv("CIRCLE_PULL_REQUEST")))
+	}
+	return &Environment{
+		Provider:    "circleci",
+		Name:        "CircleCI",
+		BuildNumber: getenv("CIRCLE_BUILD_NUM"),
+		URL:         getenv("CIRCLE_BUILD_URL"),
+		CommitSHA:   getenv("CIRCLE_SHA1"),
+		Branch:      getenv("CIRCLE_BRANCH"),
+		PRNumber:    pr,
+		Author:      getenv("CIRCLE_USERNAME"),
+		Trigger:     pullRequestOrPush(pr),
+	}
+}
+
+func azurePipelines(getenv func(string) string) *Environment {
+	env := &Environment{
+		Provider:    "azure-pipelines",
+		Name:        "Azure Pipelines",
+		BuildNumber: getenv("BUILD_BUILDNUMBER"),
+		CommitSHA:   getenv("BUILD_SOURCEVERSION"),
+		Branch:      branchOf(firstOf(getenv("SYSTEM_PULLREQUEST_SOURCEBRANCH"), getenv("BUILD_SOURCEBRANCH"))),
+		BaseBranch:  branchOf(getenv("SYSTEM_PULLREQUEST_TARGETBRANCH")),
+		PRNumber:    number(firstOf(getenv("SYSTEM_PULLREQUEST_PULLREQUESTNUMBER"), getenv("SYSTEM_PULLREQUEST_PULLREQUESTID"))),
+		Author:      getenv("BUILD_REQUESTEDFOR"),
+		Trigger: trigger(getenv("BUILD_REASON"), map[string]string{
+			"individualci": "push", "batchedci": "push", "pullrequest": "pull_request", "schedule": "schedule", "manual": "manual",
+		}),
+	}
+	if collection, project, id := getenv("SYSTEM_COLLECTIONURI"), getenv("SYSTEM_TEAMPROJECT"), getenv("BUILD_BUILDID"); collection != "" && project != "" && id != "" {
+		env.URL = strings.TrimSuffix(collection, "/") + "/" + project + "/_build/results?buildId=" + id
+	}
+	return env
+}
+
+func buildkite(getenv func(string) string) *Environment {
+	pr := number(getenv("BUILDKITE_PULL_REQUEST"))
+	env := &Environment{
+		Provider:    "buildkite",
+		Name:        "Buildkite",
+		BuildNumber: getenv("BUILDKITE_BUILD_NUMBER"),
+		URL:         getenv("BUILDKITE_BUILD_URL"),
+		CommitSHA:   getenv("BUILDKITE_COMMIT"),
+		Branch:      getenv("BUILDKITE_BRANCH"),
+		BaseBranch:  getenv("BUILDKITE_PULL_REQUEST_BASE_BRANCH"),
+		PRNumber:    pr,
+		Author:      getenv("BUILDKITE_BUILD_AUTHOR"),
+		Trigger:     trigger(getenv("BUILDKITE_SOURCE"), map[string]string{"ui": "manual", "webhook": pullRequestOrPush(pr)}),
+	}
+	if env.CommitSHA == "HEAD" {
+		env.CommitSHA = ""
+	}
+	return env
+}
+
+func travis(getenv func(string) string) *Environment {
+	pr := number(getenv("TRAVIS_PULL_REQUEST"))
+	env := &Environment{
+		Provider:    "travis-ci",
+		Name:        "Travis CI",
+		BuildNumber: getenv("TRAVIS_BUILD_NUMBER"),
+		URL:         getenv("TRAVIS_BUILD_WEB_URL"),
+		CommitSHA:   firstOf(getenv("TRAVIS_PULL_REQUEST_SHA"), getenv("TRAVIS_COMMIT")),
+		Branch:      firstOf(getenv("TRAVIS_PULL_REQUEST_BRANCH"), getenv("TRAVIS_BRANCH")),
+		PRNumber:    pr,
+		Trigger:     trigger(getenv("TRAVIS_EVENT_TYPE"), map[string]string{"cron": "schedule"}),
+	}
+	if pr > 0 {
+		// TRAVIS_BRANCH is the branch a pull request merges into
+		env.BaseBranch = getenv("TRAVIS_BRANCH")
+	}
+	return env
+}
+
+func jenkins(getenv func(string) string) *Environment {
+	pr := number(getenv("CHANGE_ID"))
+	return &Environment{
+		Provider:    "jenkins",
+		Name:        "Jenkins",
+		BuildNumber: getenv("BUILD_NUMBER"),
+		URL:         getenv("BUILD_URL"),
+		CommitSHA:   getenv("GIT_COMMIT"),
+		Branch:      firstOf(getenv("CHANGE_BRANCH"), getenv("BRANCH_NAME"), strings.TrimPrefix(getenv("GIT_BRANCH"), "origin/")),
+		BaseBranch:  getenv("CHANGE_TARGET"),
+		PRNumber:    pr,
+		Author:      getenv("CHANGE_AUTHOR"),
+		Trigger:     pullRequestOrPush(pr),
+	}
+}
+
+// firstOf returns the first value that is not empty
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// branchOf returns the branch of a git ref such as refs/heads/main, or "" for other refs
+func branchOf(ref string) string {
+	if branch, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+		return branch
+	}
+	if strings.HasPrefix(ref, "refs/") {
+		return ""
+	}
+	return ref
+}
+
+// pullRequestOf returns the number of a pull request ref such as refs/pull/12/merge
+func pullRequestOf(ref string) int {
+	rest, ok := strings.CutPrefix(ref, "refs/pull/")
+	if !ok {
+		return 0
+	}
+	n, _, _ := strings.Cut(rest, "/")
+	return number(n)
+}
+
+func lastSegment(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
+}
+
+// number parses a positive number, returning 0 for anything else such as "false"
+func number(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+func pullRequestOrPush(pr int) string {
+	if pr > 0 {
+		return "pull_request"
+	}
+	return "push"
+}
+
+// trigger lower-cases what a service reports as the cause of a build and maps its own
+// names onto common ones
+func trigger(value string, names map[string]string) string {
+	value = strings.ToLower(value)
+	if name, ok := names[value]; ok {
+		return name
+	}
+	return value
+}
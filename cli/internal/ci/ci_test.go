@@ -0,0 +1,251 @@
+package ci
+
+import (
+	"reflect"
+	"testing"
+)
+
+// envOf returns a getenv reading the variables of vars, and "" for any other
+func envOf(vars map[string]string) func(string) string {
+	return func(name string) string {
+		return vars[name]
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		vars map[string]string
+		want *Environment
+	}{
+		{
+			name: "no CI service",
+			vars: map[string]string{"HOME": "/root"},
+			want: nil,
+		},
+		{
+			name: "GitHub Actions push",
+			vars: map[string]string{
+				"GITHUB_ACTIONS": "true", "GITHUB_RUN_NUMBER": "42", "GITHUB_SHA": "abc123",
+				"GITHUB_REF": "refs/heads/main", "GITHUB_ACTOR": "octocat", "GITHUB_EVENT_NAME": "push",
+				"GITHUB_SERVER_URL": "https://github.com", "GITHUB_REPOSITORY": "acme/shop", "GITHUB_RUN_ID": "9001",
+			},
+			want: &Environment{
+				Provider: "github-actions", Name: "GitHub Actions", BuildNumber: "42",
+				URL: "https://github.com/acme/shop/actions/runs/9001", CommitSHA: "abc123",
+				Branch: "main", Author: "octocat", Trigger: "push",
+			},
+		},
+		{
+			name: "GitHub Actions pull request",
+			vars: map[string]string{
+				"GITHUB_ACTIONS": "true", "GITHUB_REF": "refs/pull/12/merge", "GITHUB_HEAD_REF": "feature/pay",
+				"GITHUB_BASE_REF": "main", "GITHUB_EVENT_NAME": "pull_request_target",
+			},
+			want: &Environment{
+				Provider: "github-actions", Name: "GitHub Actions",
+				Branch: "feature/pay", BaseBranch: "main", PRNumber: 12, Trigger: "pull_request",
+			},
+		},
+		{
+			name: "GitHub Actions tag",
+			vars: map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_REF": "refs/tags/v1.0.0", "GITHUB_EVENT_NAME": "workflow_dispatch"},
+			want: &Environment{Provider: "github-actions", Name: "GitHub Actions", Trigger: "manual"},
+		},
+		{
+			name: "GitLab CI merge request",
+			vars: map[string]string{
+				"GITLAB_CI": "true", "CI_PIPELINE_IID": "7", "CI_PIPELINE_URL": "https://gitlab.com/acme/shop/-/pipelines/70",
+				"CI_COMMIT_SHA": "def456", "CI_MERGE_REQUEST_SOURCE_BRANCH_NAME": "feature/pay", "CI_COMMIT_BRANCH": "",
+				"CI_MERGE_REQUEST_TARGET_BRANCH_NAME": "main", "CI_MERGE_REQUEST_IID": "3",
+				"GITLAB_USER_LOGIN": "dev", "CI_PIPELINE_SOURCE": "merge_request_event",
+			},
+			want: &Environment{
+				Provider: "gitlab-ci", Name: "GitLab CI", BuildNumber: "7", URL: "https://gitlab.com/acme/shop/-/pipelines/70",
+				CommitSHA: "def456", Branch: "feature/pay", BaseBranch: "main", PRNumber: 3, Author: "dev", Trigger: "pull_request",
+			},
+		},
+		{
+			name: "GitLab CI web pipeline",
+			vars: map[string]string{"GITLAB_CI": "true", "CI_COMMIT_BRANCH": "main", "CI_PIPELINE_SOURCE": "web"},
+			want: &Environment{Provider: "gitlab-ci", Name: "GitLab CI", Branch: "main", Trigger: "manual"},
+		},
+		{
+			name: "CircleCI pull request URL",
+			vars: map[string]string{
+				"CIRCLECI": "true", "CIRCLE_BUILD_NUM": "88", "CIRCLE_SHA1": "abc", "CIRCLE_BRANCH": "feature/pay",
+				"CIRCLE_PULL_REQUEST": "https://github.com/acme/shop/pull/15", "CIRCLE_USERNAME": "dev",
+			},
+			want: &Environment{
+				Provider: "circleci", Name: "CircleCI", BuildNumber: "88", CommitSHA: "abc", Branch: "feature/pay",
+				PRNumber: 15, Author: "dev", Trigger: "pull_request",
+			},
+		},
+		{
+			name: "CircleCI push",
+			vars: map[string]string{"CIRCLECI": "true", "CIRCLE_BRANCH": "main"},
+			want: &Environment{Provider: "circleci", Name: "CircleCI", Branch: "main", Trigger: "push"},
+		},
+		{
+			name: "Azure Pipelines pull request",
+			vars: map[string]string{
+				"TF_BUILD": "True", "BUILD_BUILDNUMBER": "20240501.1", "BUILD_SOURCEVERSION": "abc",
+				"BUILD_SOURCEBRANCH": "refs/pull/21/merge", "SYSTEM_PULLREQUEST_SOURCEBRANCH": "refs/heads/feature/pay",
+				"SYSTEM_PULLREQUEST_TARGETBRANCH": "refs/heads/main", "SYSTEM_PULLREQUEST_PULLREQUESTID": "21",
+				"BUILD_REQUESTEDFOR": "Dev", "BUILD_REASON": "PullRequest",
+				"SYSTEM_COLLECTIONURI": "https://dev.azure.com/acme/", "SYSTEM_TEAMPROJECT": "shop", "BUILD_BUILDID": "314",
+			},
+			want: &Environment{
+				Provider: "azure-pipelines", Name: "Azure Pipelines", BuildNumber: "20240501.1",
+				URL: "https://dev.azure.com/acme/shop/_build/results?buildId=314", CommitSHA: "abc",
+				Branch: "feature/pay", BaseBranch: "main", PRNumber: 21, Author: "Dev", Trigger: "pull_request",
+			},
+		},
+		{
+			name: "Azure Pipelines batched push",
+			vars: map[string]string{"TF_BUILD": "True", "BUILD_SOURCEBRANCH": "refs/heads/main", "BUILD_REASON": "BatchedCI"},
+			want: &Environment{Provider: "azure-pipelines", Name: "Azure Pipelines", Branch: "main", Trigger: "push"},
+		},
+		{
+			name: "Buildkite pull request webhook",
+			vars: map[string]string{
+				"BUILDKITE": "true", "BUILDKITE_BUILD_NUMBER": "5", "BUILDKITE_BUILD_URL": "https://buildkite.com/acme/shop/builds/5",
+				"BUILDKITE_COMMIT": "abc", "BUILDKITE_BRANCH": "feature/pay", "BUILDKITE_PULL_REQUEST": "9",
+				"BUILDKITE_PULL_REQUEST_BASE_BRANCH": "main", "BUILDKITE_BUILD_AUTHOR": "Dev", "BUILDKITE_SOURCE": "webhook",
+			},
+			want: &Environment{
+				Provider: "buildkite", Name: "Buildkite", BuildNumber: "5", URL: "https://buildkite.com/acme/shop/builds/5",
+				CommitSHA: "abc", Branch: "feature/pay", BaseBranch: "main", PRNumber: 9, Author: "Dev", Trigger: "pull_request",
+			},
+		},
+		{
+			name: "Buildkite build from the UI at HEAD",
+			vars: map[string]string{
+				"BUILDKITE": "true", "BUILDKITE_COMMIT": "HEAD", "BUILDKITE_BRANCH": "main",
+				"BUILDKITE_PULL_REQUEST": "false", "BUILDKITE_SOURCE": "ui",
+			},
+			want: &Environment{Provider: "buildkite", Name: "Buildkite", Branch: "main", Trigger: "manual"},
+		},
+		{
+			name: "Buildkite push webhook",
+			vars: map[string]string{"BUILDKITE": "true", "BUILDKITE_PULL_REQUEST": "false", "BUILDKITE_SOURCE": "webhook"},
+			want: &Environment{Provider: "buildkite", Name: "Buildkite", Trigger: "push"},
+		},
+		{
+			name: "Travis CI pull request",
+			vars: map[string]string{
+				"TRAVIS": "true", "TRAVIS_BUILD_NUMBER": "11", "TRAVIS_BUILD_WEB_URL": "https://travis-ci.com/acme/shop/builds/11",
+				"TRAVIS_COMMIT": "merge", "TRAVIS_PULL_REQUEST_SHA": "abc", "TRAVIS_PULL_REQUEST": "4",
+				"TRAVIS_PULL_REQUEST_BRANCH": "feature/pay", "TRAVIS_BRANCH": "main", "TRAVIS_EVENT_TYPE": "pull_request",
+			},
+			want: &Environment{
+				Provider: "travis-ci", Name: "Travis CI", BuildNumber: "11", URL: "https://travis-ci.com/acme/shop/builds/11",
+				CommitSHA: "abc", Branch: "feature/pay", BaseBranch: "main", PRNumber: 4, Trigger: "pull_request",
+			},
+		},
+		{
+			name: "Travis CI cron",
+			vars: map[string]string{
+				"TRAVIS": "true", "TRAVIS_COMMIT": "abc", "TRAVIS_PULL_REQUEST": "false",
+				"TRAVIS_BRANCH": "main", "TRAVIS_EVENT_TYPE": "cron",
+			},
+			want: &Environment{Provider: "travis-ci", Name: "Travis CI", CommitSHA: "abc", Branch: "main", Trigger: "schedule"},
+		},
+		{
+			name: "Jenkins multibranch pull request",
+			vars: map[string]string{
+				"JENKINS_URL": "https://jenkins.acme.dev/", "BUILD_NUMBER": "3", "BUILD_URL": "https://jenkins.acme.dev/job/shop/3/",
+				"GIT_COMMIT": "abc", "CHANGE_ID": "8", "CHANGE_BRANCH": "feature/pay", "BRANCH_NAME": "PR-8",
+				"CHANGE_TARGET": "main", "CHANGE_AUTHOR": "dev",
+			},
+			want: &Environment{
+				Provider: "jenkins", Name: "Jenkins", BuildNumber: "3", URL: "https://jenkins.acme.dev/job/shop/3/",
+				CommitSHA: "abc", Branch: "feature/pay", BaseBranch: "main", PRNumber: 8, Author: "dev", Trigger: "pull_request",
+			},
+		},
+		{
+			name: "Jenkins freestyle job",
+			vars: map[string]string{"JENKINS_URL": "https://jenkins.acme.dev/", "GIT_BRANCH": "origin/main"},
+			want: &Environment{Provider: "jenkins", Name: "Jenkins", Branch: "main", Trigger: "push"},
+		},
+		{
+			name: "service turned off",
+			vars: map[string]string{"GITHUB_ACTIONS": "false", "CIRCLECI": "true", "CIRCLE_BRANCH": "main"},
+			want: &Environment{Provider: "circleci", Name: "CircleCI", Branch: "main", Trigger: "push"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Detect(envOf(tt.vars))
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Detect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBranchOf(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"refs/heads/main", "main"},
+		{"refs/heads/feature/pay", "feature/pay"},
+		{"refs/tags/v1.0.0", ""},
+		{"refs/pull/12/merge", ""},
+		{"main", "main"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if got := branchOf(tt.ref); got != tt.want {
+				t.Errorf("branchOf(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPullRequestOf(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{"refs/pull/12/merge", 12},
+		{"refs/pull/7/head", 7},
+		{"refs/pull/abc/merge", 0},
+		{"refs/heads/main", 0},
+		{"", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if got := pullRequestOf(tt.ref); got != tt.want {
+				t.Errorf("pullRequestOf(%q) = %d, want %d", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	names := map[string]string{"merge_request_event": "pull_request", "web": "manual"}
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"merge_request_event", "pull_request"},
+		{"Web", "manual"},
+		{"schedule", "schedule"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := trigger(tt.value, names); got != tt.want {
+				t.Errorf("trigger(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
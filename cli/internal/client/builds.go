@@ -0,0 +1,96 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Build is a build as returned by the API, with the state of its shards
+type Build struct {
+	ID             int64      `json:"id"`
+	BuildNumber    string     `json:"build_number"`
+	ExpectedShards *int       `json:"expected_shards"`
+	ReceivedShards int        `json:"received_shards"`
+	MissingShards  []int      `json:"missing_shards"`
+	ShardDeadline  *time.Time `json:"shard_deadline"`
+	FinalizedAt    *time.Time `json:"finalized_at"`
+}
+
+// shardedBuildRequest is the body of POST /api/builds opening a sharded build
+type shardedBuildRequest struct {
+	SuiteID        int64             `json:"test_suite_id"`
+	BuildNumber    string            `json:"build_number"`
+	Timestamp      time.Time         `json:"timestamp"`
+	CIProvider     string            `json:"ci_provider,omitempty"`
+	CIURL          string            `json:"ci_url,omitempty"`
+	CommitSHA      string            `json:"commit_sha,omitempty"`
+	Branch         string            `json:"branch,omitempty"`
+	BaseBranch     string            `json:"base_branch,omitempty"`
+	PRNumber       *int              `json:"pr_number,omitempty"`
+	Author         string            `json:"author,omitempty"`
+	Trigger        string            `json:"trigger,omitempty"`
+	Properties     map[string]string `json:"properties,omitempty"`
+	Tags           []string          `json:"tags,omitempty"`
+	ExpectedShards int               `json:"expected_shards"`
+	ShardTimeout   int               `json:"shard_timeout,omitempty"`
+}
+
+// OpenShardedBuild creates a build of the suite that waits for shards CI shards to upload
+// their results, and is finalized with the shards received once timeout elapsed; a zero
+// timeout selects the default of the API
+func (c *APIClient) OpenShardedBuild(suiteID int64, buildNumber string, build BuildMetadata, shards int, timeout time.Duration) (*Build, error) {
+	request := shardedBuildRequest{
+		SuiteID:        suiteID,
+		BuildNumber:    buildNumber,
+		Timestamp:      time.Now().UTC(),
+		CIProvider:     build.CIProvider,
+		CIURL:          build.CIURL,
+		CommitSHA:      build.CommitSHA,
+		Branch:         build.Branch,
+		BaseBranch:     build.BaseBranch,
+		Author:         build.Author,
+		Trigger:        build.Trigger,
+		Properties:     build.Properties,
+		Tags:           build.Tags,
+		ExpectedShards: shards,
+		ShardTimeout:   int(timeout.Seconds()),
+	}
+	if build.PRNumber > 0 {
+		request.PRNumber = &build.PRNumber
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding build: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Post(c.BaseURL+"/api/builds", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var created Build
+	if err := decodeResponse(resp, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// FinalizeBuild finalizes a sharded build with the shards received so far
+func (c *APIClient) FinalizeBuild(id int64) (*Build, error) {
+	url := fmt.Sprintf("%s/api/builds/%d/finalize", c.BaseURL, id)
+
+	resp, err := c.HTTPClient.Post(url, "application/json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var build Build
+	if err := decodeResponse(resp, &build); err != nil {
+		return nil, err
+	}
+	return &build, nil
+}
@@ -1,13 +1,23 @@
 package client
 
 import (
+	"archive/zip"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/BennyEisner/test-results/cli/internal/config"
 )
@@ -26,63 +36,342 @@ func NewAPIClient(cfg *config.Config) *APIClient {
 	}
 }
 
-// PostJUnitFile uploads a JUnit XML file to the API.
-func (c *APIClient) PostJUnitFile(projectID, suiteID int64, filePath string) (string, error) {
-	url := fmt.Sprintf("%s/api/projects/%d/suites/%d/junit_imports", c.BaseURL, projectID, suiteID)
+// ImportJob is the state of a report import run by the API in the background
+type ImportJob struct {
+	ID       int64    `json:"id"`
+	Format   string   `json:"format"`
+	State    string   `json:"state"`
+	Total    int      `json:"total"`
+	Passed   int      `json:"passed"`
+	Failed   int      `json:"failed"`
+	Errored  int      `json:"errored"`
+	Skipped  int      `json:"skipped"`
+	Warnings []string `json:"warnings"`
+	// Files are the parse results of the files of a multi-file upload
+	Files   []FileResult `json:"files"`
+	BuildID *int64       `json:"build_id"`
+	Error   string       `json:"error"`
+	// Replayed is set when the API had already received the same upload and did not import it again
+	Replayed bool `json:"replayed"`
+}
 
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", fmt.Errorf("error opening file: %w", err)
-	}
-	defer file.Close()
+// FileResult is the outcome of parsing one file of a multi-file upload
+type FileResult struct {
+	Name  string `json:"name"`
+	Total int    `json:"total"`
+	Error string `json:"error"`
+}
+
+// Finished reports whether the import is done or failed
+func (j *ImportJob) Finished() bool {
+	return j.State == "done" || j.State == "failed"
+}
+
+// UploadOptions describe an upload of test results besides its report files
+type UploadOptions struct {
+	Format      string // server-side parser to use, e.g. "junit" or "readyapi"
+	BuildNumber string
+	// Attachments are the files the reports reference, added to the zip archive
+	Attachments []string
+	Build       BuildMetadata
+	// Shard, when set, adds the reports to an open sharded build instead of creating one
+	Shard *Shard
+}
+
+// Shard names the sharded build an upload belongs to and the index of the CI shard, from 0
+type Shard struct {
+	BuildID int64
+	Index   int
+}
+
+// BuildMetadata describes the build test results are uploaded for; empty fields are left out
+type BuildMetadata struct {
+	CIProvider string
+	CIURL      string
+	CommitSHA  string
+	Branch     string
+	BaseBranch string
+	PRNumber   int
+	Author     string
+	Trigger    string
+	Properties map[string]string
+	Tags       []string
+}
+
+// PostTestResults uploads test report files to the API and returns the import job
+// queued for them. A single file is uploaded as is; several files, or a directory such
+// as allure-results, are uploaded as a zip archive and imported as one build.
+// The upload carries an Idempotency-Key derived from the build number and the
+// report, so that a retried upload returns the import of the first one.
+func (c *APIClient) PostTestResults(projectID, suiteID int64, filePaths []string, opts UploadOptions) (*ImportJob, error) {
+	url := fmt.Sprintf("%s/api/projects/%d/suites/%d/junit_imports", c.BaseURL, projectID, suiteID)
 
 	// Create a buffer and multipart writer
 	var requestBody bytes.Buffer
 	writer := multipart.NewWriter(&requestBody)
 
 	// Create a form file field
-	fileWriter, err := writer.CreateFormFile("junitFile", filepath.Base(filePath))
-	if err != nil {
-		return "", fmt.Errorf("error creating form file: %w", err)
+	reportHash := sha256.New()
+	if err := writeReports(writer, filePaths, opts.Attachments, reportHash); err != nil {
+		return nil, err
 	}
-
-	_, err = io.Copy(fileWriter, file)
-	if err != nil {
-		return "", fmt.Errorf("error copying file content: %w", err)
+	if err := writeFields(writer, opts); err != nil {
+		return nil, err
 	}
 
 	// Close the writer before creating the request
-	err = writer.Close()
-	if err != nil {
-		return "", fmt.Errorf("error closing multipart writer: %w", err)
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error closing multipart writer: %w", err)
 	}
 
 	//  Create the HTTP request
 	req, err := http.NewRequest("POST", url, &requestBody)
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
+		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
 	//  Set headers
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 	req.Header.Set("Content-Length", fmt.Sprintf("%d", requestBody.Len()))
+	req.Header.Set("Idempotency-Key", IdempotencyKey(opts.BuildNumber, reportHash.Sum(nil)))
 
 	//  Send the request
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error sending request: %w", err)
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var job ImportJob
+	if err := decodeResponse(resp, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// writeFields adds the format, build number, build metadata, tags and shard of an upload to the form
+func writeFields(writer *multipart.Writer, opts UploadOptions) error {
+	build := opts.Build
+	fields := [][2]string{
+		{"format", opts.Format},
+		{"build_number", opts.BuildNumber},
+		{"ci_provider", build.CIProvider},
+		{"ci_url", build.CIURL},
+		{"commit_sha", build.CommitSHA},
+		{"branch", build.Branch},
+		{"base_branch", build.BaseBranch},
+		{"author", build.Author},
+		{"trigger", build.Trigger},
+	}
+	if build.PRNumber > 0 {
+		fields = append(fields, [2]string{"pr_number", strconv.Itoa(build.PRNumber)})
+	}
+	if opts.Shard != nil {
+		fields = append(fields,
+			[2]string{"build_id", strconv.FormatInt(opts.Shard.BuildID, 10)},
+			[2]string{"shard_index", strconv.Itoa(opts.Shard.Index)})
+	}
+	names := make([]string, 0, len(build.Properties))
+	for name := range build.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fields = append(fields, [2]string{"property", name + "=" + build.Properties[name]})
+	}
+	for _, tag := range build.Tags {
+		fields = append(fields, [2]string{"tag", tag})
+	}
+
+	for _, field := range fields {
+		if field[1] == "" {
+			continue
+		}
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return fmt.Errorf("error writing %s field: %w", field[0], err)
+		}
+	}
+	return nil
+}
+
+// GetImportJob fetches the current state of an import job
+func (c *APIClient) GetImportJob(id int64) (*ImportJob, error) {
+	url := fmt.Sprintf("%s/api/imports/%d", c.BaseURL, id)
+
+	resp, err := c.HTTPClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	//  Read the response
+	var job ImportJob
+	if err := decodeResponse(resp, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// WaitForImport polls an import job every interval until it finishes or timeout
+// elapses, passing every state it sees to onUpdate
+func (c *APIClient) WaitForImport(id int64, interval, timeout time.Duration, onUpdate func(*ImportJob)) (*ImportJob, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		job, err := c.GetImportJob(id)
+		if err != nil {
+			return nil, err
+		}
+		onUpdate(job)
+		if job.Finished() {
+			return job, nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return job, fmt.Errorf("import %d still %s after %s", id, job.State, timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// IdempotencyKey derives the key of an upload from its build number and the SHA-256 of its report
+func IdempotencyKey(buildNumber string, reportHash []byte) string {
+	key := sha256.New()
+	key.Write([]byte(buildNumber))
+	key.Write([]byte{0})
+	key.Write(reportHash)
+	return hex.EncodeToString(key.Sum(nil))
+}
+
+// decodeResponse decodes a JSON response body into v, turning error statuses into errors
+func decodeResponse(resp *http.Response, v interface{}) error {
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error reading response body: %w", err)
+		return fmt.Errorf("error reading response body: %w", err)
 	}
 
 	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, v); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// writeReports adds the reports at paths to the form, passing what it writes to digest.
+// Several reports, or reports with attachments, are zipped together.
+func writeReports(writer *multipart.Writer, paths, attachments []string, digest hash.Hash) error {
+	switch {
+	case len(paths) == 0:
+		return fmt.Errorf("no report files to upload")
+	case len(paths) == 1 && len(attachments) == 0:
+		return writeReport(writer, paths[0], digest)
+	}
+
+	formFile, err := writer.CreateFormFile("junitFile", "reports.zip")
+	if err != nil {
+		return fmt.Errorf("error creating form file: %w", err)
+	}
+	return zipFiles(io.MultiWriter(formFile, digest), append(append([]string(nil), paths...), attachments...))
+}
+
+// writeReport adds the report at path to the form, passing what it writes to digest;
+// directories are zipped
+func writeReport(writer *multipart.Writer, path string, digest hash.Hash) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("error opening file: %w", err)
+	}
+
+	name := filepath.Base(path)
+	if info.IsDir() {
+		name += ".zip"
+	}
+	formFile, err := writer.CreateFormFile("junitFile", name)
+	if err != nil {
+		return fmt.Errorf("error creating form file: %w", err)
+	}
+	fileWriter := io.MultiWriter(formFile, digest)
+
+	if info.IsDir() {
+		return zipDirectory(fileWriter, path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(fileWriter, file); err != nil {
+		return fmt.Errorf("error copying file content: %w", err)
+	}
+	return nil
+}
+
+// zipDirectory writes the regular files below dir to w as a zip archive
+func zipDirectory(w io.Writer, dir string) error {
+	archive := zip.NewWriter(w)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		entry, err := archive.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(entry, file)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error zipping directory: %w", err)
+	}
+	return archive.Close()
+}
+
+// zipFiles writes the files at paths to w as a zip archive, naming each by its path
+// relative to the working directory
+func zipFiles(w io.Writer, paths []string) error {
+	archive := zip.NewWriter(w)
+	for _, path := range paths {
+		if err := addZipFile(archive, archiveName(path), path); err != nil {
+			return fmt.Errorf("error zipping %s: %w", path, err)
+		}
 	}
+	return archive.Close()
+}
 
-	return string(respBody), nil
+func addZipFile(archive *zip.Writer, name, path string) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(entry, file)
+	return err
+}
+
+// archiveName names a file in an archive by its slash-separated path relative to the
+// working directory, or by its absolute path without the leading slash when it lies outside
+func archiveName(path string) string {
+	if rel, err := filepath.Rel(".", path); err == nil && !strings.HasPrefix(rel, "..") && !filepath.IsAbs(rel) {
+		return filepath.ToSlash(rel)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(abs, filepath.VolumeName(abs))), "/")
 }
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+// Quarantine is a quarantined test case as returned by the API
+type Quarantine struct {
+	ID            int64      `json:"id"`
+	TestCaseID    int64      `json:"test_case_id"`
+	TestCaseName  string     `json:"test_case_name"`
+	ClassName     string     `json:"class_name"`
+	QuarantinedBy string     `json:"quarantined_by"`
+	Reason        string     `json:"reason"`
+	IssueURL      string     `json:"issue_url,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+}
+
+// GetQuarantines fetches the active quarantines of a project
+func (c *APIClient) GetQuarantines(projectID int64) ([]Quarantine, error) {
+	url := fmt.Sprintf("%s/api/projects/%d/quarantines", c.BaseURL, projectID)
+
+	resp, err := c.HTTPClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var quarantines []Quarantine
+	if err := decodeResponse(resp, &quarantines); err != nil {
+		return nil, err
+	}
+	return quarantines, nil
+}
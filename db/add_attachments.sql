@@ -0,0 +1,21 @@
+-- Migration to store attachments of executions and per-project attachment quotas
+-- Run this against your existing database
+
+CREATE TABLE attachments (
+    id SERIAL PRIMARY KEY,
+    execution_id INTEGER NOT NULL REFERENCES build_test_case_executions(id) ON DELETE CASCADE,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE, -- key of the content in the blob store
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE project_attachment_quotas (
+    project_id INTEGER PRIMARY KEY REFERENCES projects(id) ON DELETE CASCADE,
+    quota_bytes BIGINT NOT NULL
+);
+
+CREATE INDEX idx_attachments_execution_id ON attachments(execution_id);
+CREATE INDEX idx_attachments_project_id ON attachments(project_id);
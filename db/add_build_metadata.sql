@@ -0,0 +1,13 @@
+-- Migration to record the commit, branch, pull request, author and trigger of builds
+-- Run this against your existing database
+
+ALTER TABLE builds ADD COLUMN commit_sha TEXT; -- Commit the build ran against, lower-case hex
+ALTER TABLE builds ADD COLUMN branch TEXT;
+ALTER TABLE builds ADD COLUMN base_branch TEXT; -- Branch a pull request merges into
+ALTER TABLE builds ADD COLUMN pr_number INTEGER;
+ALTER TABLE builds ADD COLUMN author TEXT;
+ALTER TABLE builds ADD COLUMN trigger TEXT; -- e.g. 'push', 'pull_request', 'schedule' or 'manual'
+
+CREATE INDEX idx_builds_commit_sha ON builds(commit_sha text_pattern_ops);
+CREATE INDEX idx_builds_branch ON builds(branch);
+CREATE INDEX idx_builds_pr_number ON builds(pr_number);
@@ -0,0 +1,17 @@
+-- Migration to let parallel CI shards upload the results of one build
+-- Run this against your existing database
+
+ALTER TABLE builds ADD COLUMN expected_shards INTEGER; -- Number of CI shards uploading the results of a sharded build
+ALTER TABLE builds ADD COLUMN shard_deadline TIMESTAMPTZ; -- When a sharded build is finalized with the shards received so far
+ALTER TABLE builds ADD COLUMN finalized_at TIMESTAMPTZ; -- When a sharded build stopped accepting shards
+
+CREATE TABLE build_shards (
+    build_id INTEGER NOT NULL REFERENCES builds(id) ON DELETE CASCADE,
+    shard_index INTEGER NOT NULL,
+    test_case_count INTEGER NOT NULL,
+    duration DOUBLE PRECISION NOT NULL,
+    received_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (build_id, shard_index)
+);
+
+CREATE INDEX idx_builds_open_shard_deadline ON builds(shard_deadline) WHERE finalized_at IS NULL;
@@ -0,0 +1,23 @@
+-- Migration to give builds a lifecycle status
+-- Run this against your existing database
+
+ALTER TABLE builds ADD COLUMN status TEXT NOT NULL DEFAULT 'pending'; -- 'pending', 'running', 'passed', 'failed', 'errored' or 'aborted'
+ALTER TABLE builds ADD COLUMN started_at TIMESTAMPTZ;
+ALTER TABLE builds ADD COLUMN finished_at TIMESTAMPTZ;
+
+-- Builds imported so far finished when they were imported; sharded builds still
+-- waiting for shards are running once a shard arrived
+UPDATE builds b SET
+    status = CASE
+        WHEN EXISTS (SELECT 1 FROM build_test_case_executions e WHERE e.build_id = b.id AND e.status = 'failed') THEN 'failed'
+        WHEN EXISTS (SELECT 1 FROM build_test_case_executions e WHERE e.build_id = b.id AND e.status = 'error') THEN 'errored'
+        ELSE 'passed' END,
+    started_at = b.created_at,
+    finished_at = COALESCE(b.finalized_at, b.created_at)
+WHERE b.expected_shards IS NULL OR b.finalized_at IS NOT NULL;
+
+UPDATE builds b SET status = 'running', started_at = b.created_at
+WHERE b.expected_shards IS NOT NULL AND b.finalized_at IS NULL
+  AND EXISTS (SELECT 1 FROM build_shards s WHERE s.build_id = b.id);
+
+CREATE INDEX idx_builds_status ON builds(status);
@@ -0,0 +1,10 @@
+-- Migration to store build tags, e.g. the --tags of an upload
+-- Run this against your existing database
+
+CREATE TABLE build_tags (
+    build_id INTEGER NOT NULL REFERENCES builds(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    PRIMARY KEY (build_id, name)
+);
+
+CREATE INDEX idx_build_tags_name ON build_tags(name);
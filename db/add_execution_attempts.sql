@@ -0,0 +1,12 @@
+-- Migration to record every attempt of a test case retried within a build
+-- Run this against your existing database
+
+ALTER TABLE build_test_case_executions ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1; -- Run of the test case in the build, counted from 1
+ALTER TABLE build_test_case_executions ADD COLUMN retried BOOLEAN NOT NULL DEFAULT FALSE; -- TRUE for attempts the test case was retried after
+
+ALTER TABLE build_test_case_executions DROP CONSTRAINT build_test_case_executions_build_id_test_case_id_key;
+ALTER TABLE build_test_case_executions ADD CONSTRAINT build_test_case_executions_build_id_test_case_id_attempt_key
+    UNIQUE (build_id, test_case_id, attempt);
+
+-- One final attempt per test case per build
+CREATE UNIQUE INDEX idx_btexec_final_attempt ON build_test_case_executions(build_id, test_case_id) WHERE NOT retried;
@@ -0,0 +1,25 @@
+-- Migration to store the logs of executions compressed, in their own table
+-- Run this against your existing database
+
+CREATE TABLE execution_logs (
+    execution_id INTEGER NOT NULL REFERENCES build_test_case_executions(id) ON DELETE CASCADE,
+    stream TEXT NOT NULL, -- 'stdout' or 'stderr'
+    content BYTEA NOT NULL,
+    encoding TEXT NOT NULL DEFAULT 'gzip', -- 'gzip', or 'identity' for logs stored uncompressed
+    size BIGINT NOT NULL, -- Size of the stored log, uncompressed
+    original_size BIGINT NOT NULL, -- Size of the log as reported, before it was cut
+    truncated BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (execution_id, stream)
+);
+
+-- Logs imported before are kept uncompressed
+INSERT INTO execution_logs (execution_id, stream, content, encoding, size, original_size)
+SELECT id, 'stdout', convert_to(system_out, 'UTF8'), 'identity', octet_length(system_out), octet_length(system_out)
+FROM build_test_case_executions WHERE system_out IS NOT NULL AND system_out <> '';
+
+INSERT INTO execution_logs (execution_id, stream, content, encoding, size, original_size)
+SELECT id, 'stderr', convert_to(system_err, 'UTF8'), 'identity', octet_length(system_err), octet_length(system_err)
+FROM build_test_case_executions WHERE system_err IS NOT NULL AND system_err <> '';
+
+ALTER TABLE build_test_case_executions DROP COLUMN system_out;
+ALTER TABLE build_test_case_executions DROP COLUMN system_err;
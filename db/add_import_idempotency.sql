@@ -0,0 +1,9 @@
+-- Migration to detect repeated uploads of the same report
+-- Run this against your existing database
+
+ALTER TABLE import_jobs ADD COLUMN idempotency_key TEXT;
+ALTER TABLE import_jobs ADD COLUMN content_hash TEXT;
+
+-- A repeated upload to a suite returns the earlier job unless that one failed
+CREATE UNIQUE INDEX idx_import_jobs_idempotency_key ON import_jobs(test_suite_id, idempotency_key) WHERE state <> 'failed';
+CREATE UNIQUE INDEX idx_import_jobs_content_hash ON import_jobs(test_suite_id, content_hash) WHERE state <> 'failed';
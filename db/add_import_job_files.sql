@@ -0,0 +1,4 @@
+-- Migration to record the parse result of each report file of an archive upload
+-- Run this against your existing database
+
+ALTER TABLE import_jobs ADD COLUMN files JSONB NOT NULL DEFAULT '[]';
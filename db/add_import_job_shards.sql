@@ -0,0 +1,11 @@
+-- Migration to detect repeated uploads per shard of a sharded build
+-- Run this against your existing database
+
+ALTER TABLE import_jobs ADD COLUMN shard_build_id INTEGER; -- sharded build the upload adds a shard to
+ALTER TABLE import_jobs ADD COLUMN shard_index INTEGER;
+
+-- Shards may upload identical reports, so an upload only repeats an earlier one to the same shard
+DROP INDEX IF EXISTS idx_import_jobs_idempotency_key;
+DROP INDEX IF EXISTS idx_import_jobs_content_hash;
+CREATE UNIQUE INDEX idx_import_jobs_idempotency_key ON import_jobs(test_suite_id, idempotency_key, COALESCE(shard_build_id, 0), COALESCE(shard_index, -1)) WHERE state <> 'failed';
+CREATE UNIQUE INDEX idx_import_jobs_content_hash ON import_jobs(test_suite_id, content_hash, COALESCE(shard_build_id, 0), COALESCE(shard_index, -1)) WHERE state <> 'failed';
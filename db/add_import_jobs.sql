@@ -0,0 +1,27 @@
+-- Migration to track uploads imported in the background
+-- Run this against your existing database
+
+-- Table: import_jobs
+-- Uploads imported in the background, polled through GET /imports/{id}
+CREATE TABLE import_jobs (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    test_suite_id INTEGER NOT NULL REFERENCES test_suites(id) ON DELETE CASCADE,
+    format TEXT NOT NULL,
+    build_number TEXT,
+    state TEXT NOT NULL, -- 'queued', 'parsing', 'writing', 'done' or 'failed'
+    total INTEGER NOT NULL DEFAULT 0,
+    passed INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    errored INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    warnings TEXT[] NOT NULL DEFAULT '{}',
+    build_id INTEGER REFERENCES builds(id) ON DELETE SET NULL,
+    error TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_import_jobs_test_suite_id ON import_jobs(test_suite_id);
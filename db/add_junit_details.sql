@@ -0,0 +1,40 @@
+-- Migration to store the full JUnit schema: suite runs, properties and test output
+-- Run this against your existing database
+
+ALTER TABLE build_test_case_executions ADD COLUMN system_out TEXT;
+ALTER TABLE build_test_case_executions ADD COLUMN system_err TEXT;
+
+CREATE TABLE build_suite_runs (
+    id SERIAL PRIMARY KEY,
+    build_id INTEGER NOT NULL REFERENCES builds(id) ON DELETE CASCADE,
+    test_suite_id INTEGER NOT NULL REFERENCES test_suites(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    hostname TEXT,
+    started_at TIMESTAMPTZ,
+    tests INTEGER,
+    failures INTEGER,
+    errors INTEGER,
+    skipped INTEGER,
+    time DOUBLE PRECISION,
+    system_out TEXT,
+    system_err TEXT
+);
+
+CREATE TABLE build_properties (
+    id SERIAL PRIMARY KEY,
+    build_id INTEGER NOT NULL REFERENCES builds(id) ON DELETE CASCADE,
+    build_suite_run_id INTEGER REFERENCES build_suite_runs(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    value TEXT
+);
+
+CREATE TABLE execution_properties (
+    id SERIAL PRIMARY KEY,
+    build_test_case_execution_id INTEGER NOT NULL REFERENCES build_test_case_executions(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    value TEXT
+);
+
+CREATE INDEX idx_build_suite_runs_build_id ON build_suite_runs(build_id);
+CREATE INDEX idx_build_properties_build_id ON build_properties(build_id);
+CREATE INDEX idx_execution_properties_btexec_id ON execution_properties(build_test_case_execution_id);
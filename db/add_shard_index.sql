@@ -0,0 +1,5 @@
+-- Migration to record the shard that reported each execution and suite run of a sharded build
+-- Run this against your existing database
+
+ALTER TABLE build_test_case_executions ADD COLUMN shard_index INTEGER; -- Shard of a sharded build that reported the execution
+ALTER TABLE build_suite_runs ADD COLUMN shard_index INTEGER; -- Shard of a sharded build that reported the suite
@@ -0,0 +1,4 @@
+-- Migration to store the reason reported for skipped test cases
+-- Run this against your existing database
+
+ALTER TABLE build_test_case_executions ADD COLUMN skip_message TEXT;
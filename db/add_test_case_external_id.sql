@@ -0,0 +1,6 @@
+-- Migration to identify test cases by a framework-provided ID, e.g. an Allure historyId
+-- Run this against your existing database
+
+ALTER TABLE test_cases ADD COLUMN external_id TEXT;
+
+CREATE INDEX idx_test_cases_external_id ON test_cases(external_id);
@@ -0,0 +1,11 @@
+-- Migration to keep one test case per fingerprint in a suite, so that shards of a build
+-- imported at the same time do not both create a test case they report
+-- Run this against your existing database
+-- Test cases sharing the fingerprint of an older test case of their suite were never
+-- matched by it; they lose their fingerprint and can be merged into the older one
+
+UPDATE test_cases tc SET fingerprint = NULL
+FROM test_cases older
+WHERE older.suite_id = tc.suite_id AND older.fingerprint = tc.fingerprint AND older.id < tc.id;
+
+CREATE UNIQUE INDEX idx_test_cases_suite_fingerprint ON test_cases(suite_id, fingerprint);
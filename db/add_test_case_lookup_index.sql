@@ -0,0 +1,5 @@
+-- Migration to index test cases by suite, classname and name, used to resolve
+-- the test cases of streamed imports batch by batch
+-- Run this against your existing database
+
+CREATE INDEX idx_test_cases_suite_classname_name ON test_cases(suite_id, classname, name);
@@ -0,0 +1,12 @@
+-- Migration to store the parameters of parameterized test cases and configurable parameter patterns
+-- Run this against your existing database
+-- Existing test cases get the parameters recognized by the default patterns; setting the
+-- test identity of a project recomputes them
+
+ALTER TABLE test_cases ADD COLUMN params TEXT; -- Parameters at the end of the name of a parameterized test case, e.g. '[case-17]'
+
+ALTER TABLE project_test_identities ALTER COLUMN fields DROP NOT NULL;
+ALTER TABLE project_test_identities ADD COLUMN param_patterns TEXT[];
+
+UPDATE test_cases SET params = substring(name from '(\[[^\[\]]*\]|\([^()]*\))$')
+WHERE name ~ '.(\[[^\[\]]*\]|\([^()]*\))$';
@@ -0,0 +1,6 @@
+-- Migration to model subtests as children of their parent test case
+-- Run this against your existing database
+
+ALTER TABLE test_cases ADD COLUMN parent_id INTEGER REFERENCES test_cases(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_test_cases_parent_id ON test_cases(parent_id);
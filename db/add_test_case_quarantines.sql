@@ -0,0 +1,13 @@
+-- Migration to quarantine test cases, taking them out of pass rate metrics and build statuses
+-- Run this against your existing database
+
+CREATE TABLE test_case_quarantines (
+    id SERIAL PRIMARY KEY,
+    test_case_id INTEGER NOT NULL UNIQUE REFERENCES test_cases(id) ON DELETE CASCADE,
+    quarantined_by TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    issue_url TEXT, -- Issue tracking the fix of the test case
+    expires_at TIMESTAMPTZ, -- When the quarantine ends, NULL until it is lifted
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
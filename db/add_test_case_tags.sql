@@ -0,0 +1,10 @@
+-- Migration to store test case tags, e.g. Cucumber scenario tags
+-- Run this against your existing database
+
+CREATE TABLE test_case_tags (
+    test_case_id INTEGER NOT NULL REFERENCES test_cases(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    PRIMARY KEY (test_case_id, name)
+);
+
+CREATE INDEX idx_test_case_tags_name ON test_case_tags(name);
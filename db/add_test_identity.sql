@@ -0,0 +1,27 @@
+-- Migration to identify test cases by a configurable fingerprint and to suggest renamed test cases
+-- Run this against your existing database
+-- Existing test cases get their fingerprint when they are next imported, or when the
+-- test identity of their project is set
+
+ALTER TABLE test_cases ADD COLUMN file TEXT;
+ALTER TABLE test_cases ADD COLUMN fingerprint TEXT;
+
+CREATE TABLE project_test_identities (
+    project_id INTEGER PRIMARY KEY REFERENCES projects(id) ON DELETE CASCADE,
+    fields TEXT[] NOT NULL
+);
+
+CREATE TABLE test_case_rename_suggestions (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    build_id INTEGER NOT NULL REFERENCES builds(id) ON DELETE CASCADE,
+    old_test_case_id INTEGER NOT NULL REFERENCES test_cases(id) ON DELETE CASCADE,
+    new_test_case_id INTEGER NOT NULL REFERENCES test_cases(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (old_test_case_id, new_test_case_id)
+);
+
+CREATE INDEX idx_test_cases_fingerprint ON test_cases(fingerprint);
+CREATE INDEX idx_rename_suggestions_project_id ON test_case_rename_suggestions(project_id);
+CREATE INDEX idx_rename_suggestions_new_test_case_id ON test_case_rename_suggestions(new_test_case_id);
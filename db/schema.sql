@@ -18,11 +18,23 @@ CREATE TABLE builds (
     id SERIAL PRIMARY KEY,
     test_suite_id INTEGER NOT NULL REFERENCES test_suites(id) ON DELETE CASCADE,
     build_number TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending', -- 'pending', 'running', 'passed', 'failed', 'errored' or 'aborted'
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
     ci_provider TEXT NOT NULL,
     ci_url TEXT,
     created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
     test_case_count INTEGER,
-    duration DOUBLE PRECISION
+    duration DOUBLE PRECISION,
+    commit_sha TEXT, -- Commit the build ran against, lower-case hex
+    branch TEXT,
+    base_branch TEXT, -- Branch a pull request merges into
+    pr_number INTEGER,
+    author TEXT,
+    trigger TEXT, -- e.g. 'push', 'pull_request', 'schedule' or 'manual'
+    expected_shards INTEGER, -- Number of CI shards uploading the results of a sharded build
+    shard_deadline TIMESTAMPTZ, -- When a sharded build is finalized with the shards received so far
+    finalized_at TIMESTAMPTZ -- When a sharded build stopped accepting shards
 );
 
 -- Table: test_cases
@@ -30,7 +42,12 @@ CREATE TABLE test_cases (
     id SERIAL PRIMARY KEY,
     suite_id INTEGER NOT NULL REFERENCES test_suites(id) ON DELETE CASCADE, -- Defines which suite this test case belongs to
     name TEXT NOT NULL,
-    classname TEXT NOT NULL
+    classname TEXT NOT NULL,
+    parent_id INTEGER REFERENCES test_cases(id) ON DELETE CASCADE, -- Parent test of a subtest
+    external_id TEXT, -- Stable identity reported by the test framework, e.g. an Allure historyId
+    file TEXT, -- Source file reported for the test case
+    fingerprint TEXT, -- hex SHA-256 of the fields the project identifies test cases by, see project_test_identities
+    params TEXT -- Parameters at the end of the name of a parameterized test case, e.g. '[case-17]'
 );
 
 -- Table: build_test_case_executions
@@ -41,7 +58,24 @@ CREATE TABLE build_test_case_executions (
     status TEXT NOT NULL, -- e.g., 'passed', 'failed', 'skipped', 'error'
     execution_time DOUBLE PRECISION, -- Actual time taken for this specific execution
     created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
-    UNIQUE (build_id, test_case_id) -- Ensures one record per test case per build
+    skip_message TEXT, -- Reason reported for a skipped test case
+    attempt INTEGER NOT NULL DEFAULT 1, -- Run of the test case in the build, counted from 1
+    retried BOOLEAN NOT NULL DEFAULT FALSE, -- TRUE for attempts the test case was retried after
+    shard_index INTEGER, -- Shard of a sharded build that reported the execution
+    UNIQUE (build_id, test_case_id, attempt)
+);
+
+-- Table: execution_logs
+-- The standard output and error of an execution, cut to the configured size
+CREATE TABLE execution_logs (
+    execution_id INTEGER NOT NULL REFERENCES build_test_case_executions(id) ON DELETE CASCADE,
+    stream TEXT NOT NULL, -- 'stdout' or 'stderr'
+    content BYTEA NOT NULL,
+    encoding TEXT NOT NULL DEFAULT 'gzip', -- 'gzip', or 'identity' for logs stored uncompressed
+    size BIGINT NOT NULL, -- Size of the stored log, uncompressed
+    original_size BIGINT NOT NULL, -- Size of the log as reported, before it was cut
+    truncated BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (execution_id, stream)
 );
 
 -- Table: failures
@@ -54,14 +88,183 @@ CREATE TABLE failures (
     UNIQUE (build_test_case_execution_id) -- Assuming one failure detail entry per execution
 );
 
+-- Table: build_suite_runs
+-- What each (possibly nested) suite of an imported report reported for a build
+CREATE TABLE build_suite_runs (
+    id SERIAL PRIMARY KEY,
+    build_id INTEGER NOT NULL REFERENCES builds(id) ON DELETE CASCADE,
+    test_suite_id INTEGER NOT NULL REFERENCES test_suites(id) ON DELETE CASCADE,
+    name TEXT NOT NULL, -- Suite name as it appeared in the report
+    hostname TEXT,
+    started_at TIMESTAMPTZ,
+    tests INTEGER,
+    failures INTEGER,
+    errors INTEGER,
+    skipped INTEGER,
+    time DOUBLE PRECISION,
+    system_out TEXT,
+    system_err TEXT,
+    shard_index INTEGER -- Shard of a sharded build that reported the suite
+);
+
+-- Table: build_properties
+CREATE TABLE build_properties (
+    id SERIAL PRIMARY KEY,
+    build_id INTEGER NOT NULL REFERENCES builds(id) ON DELETE CASCADE,
+    build_suite_run_id INTEGER REFERENCES build_suite_runs(id) ON DELETE CASCADE, -- Suite that reported the property, NULL for build-wide properties
+    name TEXT NOT NULL,
+    value TEXT
+);
+
+-- Table: execution_properties
+CREATE TABLE execution_properties (
+    id SERIAL PRIMARY KEY,
+    build_test_case_execution_id INTEGER NOT NULL REFERENCES build_test_case_executions(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    value TEXT
+);
+
+-- Table: test_case_tags
+CREATE TABLE test_case_tags (
+    test_case_id INTEGER NOT NULL REFERENCES test_cases(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    PRIMARY KEY (test_case_id, name)
+);
+
+-- Table: build_tags
+-- Labels of a build, e.g. 'smoke' or 'nightly', given on upload or through the API
+CREATE TABLE build_tags (
+    build_id INTEGER NOT NULL REFERENCES builds(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    PRIMARY KEY (build_id, name)
+);
+
+-- Table: build_shards
+-- Shards that uploaded their results to a sharded build
+CREATE TABLE build_shards (
+    build_id INTEGER NOT NULL REFERENCES builds(id) ON DELETE CASCADE,
+    shard_index INTEGER NOT NULL,
+    test_case_count INTEGER NOT NULL,
+    duration DOUBLE PRECISION NOT NULL,
+    received_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (build_id, shard_index)
+);
+
+-- Uploads imported in the background, polled through GET /imports/{id}
+CREATE TABLE import_jobs (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    test_suite_id INTEGER NOT NULL REFERENCES test_suites(id) ON DELETE CASCADE,
+    format TEXT NOT NULL,
+    build_number TEXT,
+    idempotency_key TEXT, -- Idempotency-Key header of the upload
+    content_hash TEXT, -- hex SHA-256 of the uploaded report
+    shard_build_id INTEGER, -- sharded build the upload adds a shard to
+    shard_index INTEGER,
+    state TEXT NOT NULL, -- 'queued', 'parsing', 'writing', 'done' or 'failed'
+    total INTEGER NOT NULL DEFAULT 0,
+    passed INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    errored INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    warnings TEXT[] NOT NULL DEFAULT '{}',
+    files JSONB NOT NULL DEFAULT '[]', -- parse result of each report file of an archive upload
+    build_id INTEGER REFERENCES builds(id) ON DELETE SET NULL,
+    error TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Table: attachments
+-- Files such as screenshots or logs stored with an execution; the content is kept in the blob store
+CREATE TABLE attachments (
+    id SERIAL PRIMARY KEY,
+    execution_id INTEGER NOT NULL REFERENCES build_test_case_executions(id) ON DELETE CASCADE,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE, -- key of the content in the blob store
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Table: project_attachment_quotas
+-- Total attachment size allowed per project, overriding the configured default
+CREATE TABLE project_attachment_quotas (
+    project_id INTEGER PRIMARY KEY REFERENCES projects(id) ON DELETE CASCADE,
+    quota_bytes BIGINT NOT NULL
+);
+
+-- Table: project_test_identities
+-- Fields the test cases of a project are identified by, overriding the default of suite, classname, name and params,
+-- and the patterns the parameters of their names are recognized by
+CREATE TABLE project_test_identities (
+    project_id INTEGER PRIMARY KEY REFERENCES projects(id) ON DELETE CASCADE,
+    fields TEXT[], -- among 'suite', 'classname', 'name', 'file' and 'params', NULL for the default
+    param_patterns TEXT[] -- regular expressions where the parameters of a name start, NULL for brackets and parentheses at its end
+);
+
+-- Table: test_case_rename_suggestions
+-- Test cases that disappeared from a build, suggested as renamed to test cases that appeared in it
+CREATE TABLE test_case_rename_suggestions (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    build_id INTEGER NOT NULL REFERENCES builds(id) ON DELETE CASCADE,
+    old_test_case_id INTEGER NOT NULL REFERENCES test_cases(id) ON DELETE CASCADE,
+    new_test_case_id INTEGER NOT NULL REFERENCES test_cases(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL, -- from 0 to 1, higher for closer matches
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (old_test_case_id, new_test_case_id)
+);
+
+-- Table: test_case_quarantines
+-- Test cases taken out of pass rate metrics and build statuses while their executions are kept
+CREATE TABLE test_case_quarantines (
+    id SERIAL PRIMARY KEY,
+    test_case_id INTEGER NOT NULL UNIQUE REFERENCES test_cases(id) ON DELETE CASCADE,
+    quarantined_by TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    issue_url TEXT, -- Issue tracking the fix of the test case
+    expires_at TIMESTAMPTZ, -- When the quarantine ends, NULL until it is lifted
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Indexes for performance (optional but recommended)
 CREATE INDEX idx_test_suites_project_id ON test_suites(project_id);
 CREATE INDEX idx_builds_test_suite_id ON builds(test_suite_id);
+CREATE INDEX idx_builds_status ON builds(status);
+CREATE INDEX idx_builds_commit_sha ON builds(commit_sha text_pattern_ops);
+CREATE INDEX idx_builds_branch ON builds(branch);
+CREATE INDEX idx_builds_pr_number ON builds(pr_number);
+CREATE INDEX idx_builds_open_shard_deadline ON builds(shard_deadline) WHERE finalized_at IS NULL;
 CREATE INDEX idx_test_suites_parent_id ON test_suites(parent_id);
 CREATE INDEX idx_test_cases_suite_id ON test_cases(suite_id);
+CREATE INDEX idx_test_cases_parent_id ON test_cases(parent_id);
+CREATE INDEX idx_test_cases_suite_classname_name ON test_cases(suite_id, classname, name);
+CREATE INDEX idx_test_cases_external_id ON test_cases(external_id);
+CREATE INDEX idx_test_cases_fingerprint ON test_cases(fingerprint);
+CREATE UNIQUE INDEX idx_test_cases_suite_fingerprint ON test_cases(suite_id, fingerprint);
+CREATE INDEX idx_test_case_tags_name ON test_case_tags(name);
+CREATE INDEX idx_build_tags_name ON build_tags(name);
 CREATE INDEX idx_btexec_build_id ON build_test_case_executions(build_id);
 CREATE INDEX idx_btexec_test_case_id ON build_test_case_executions(test_case_id);
 CREATE INDEX idx_failures_btexec_id ON failures(build_test_case_execution_id);
+CREATE INDEX idx_build_suite_runs_build_id ON build_suite_runs(build_id);
+CREATE INDEX idx_build_properties_build_id ON build_properties(build_id);
+CREATE INDEX idx_execution_properties_btexec_id ON execution_properties(build_test_case_execution_id);
+CREATE INDEX idx_import_jobs_test_suite_id ON import_jobs(test_suite_id);
+CREATE INDEX idx_attachments_execution_id ON attachments(execution_id);
+CREATE INDEX idx_attachments_project_id ON attachments(project_id);
+CREATE INDEX idx_rename_suggestions_project_id ON test_case_rename_suggestions(project_id);
+CREATE INDEX idx_rename_suggestions_new_test_case_id ON test_case_rename_suggestions(new_test_case_id);
+-- One final attempt per test case per build
+CREATE UNIQUE INDEX idx_btexec_final_attempt ON build_test_case_executions(build_id, test_case_id) WHERE NOT retried;
+-- A repeated upload to a suite returns the earlier job unless that one failed
+CREATE UNIQUE INDEX idx_import_jobs_idempotency_key ON import_jobs(test_suite_id, idempotency_key, COALESCE(shard_build_id, 0), COALESCE(shard_index, -1)) WHERE state <> 'failed';
+CREATE UNIQUE INDEX idx_import_jobs_content_hash ON import_jobs(test_suite_id, content_hash, COALESCE(shard_build_id, 0), COALESCE(shard_index, -1)) WHERE state <> 'failed';
 -- Authentication tables for OAuth2 and API key authentication
 
 -- Users table for authenticated users